@@ -17,6 +17,7 @@ import (
 	"runtime"
 	"time"
 
+	addonv1alpha1 "github.com/open-cluster-management/api/addon/v1alpha1"
 	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
 	workv1 "github.com/open-cluster-management/api/work/v1"
 	certificatesv1 "k8s.io/api/certificates/v1"
@@ -24,6 +25,8 @@ import (
 	"k8s.io/klog"
 
 	"github.com/open-cluster-management/managedcluster-import-controller/pkg/controller"
+	"github.com/open-cluster-management/managedcluster-import-controller/pkg/controller/backpressure"
+	"github.com/open-cluster-management/managedcluster-import-controller/pkg/controller/hubkubeconfig"
 	ocinfrav1 "github.com/openshift/api/config/v1"
 	hivev1 "github.com/openshift/hive/apis/hive/v1"
 	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
@@ -34,10 +37,12 @@ import (
 	sdkVersion "github.com/operator-framework/operator-sdk/version"
 	"github.com/spf13/pflag"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth" // Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.
 	"k8s.io/client-go/rest"
 	rbacv1 "k8s.io/kubernetes/pkg/apis/rbac/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
@@ -47,6 +52,7 @@ var (
 	metricsHost               = "0.0.0.0"
 	metricsPort         int32 = 8383
 	operatorMetricsPort int32 = 8686
+	healthProbePort     int32 = 8081
 )
 
 var log = logf.Log.WithName("cmd")
@@ -63,6 +69,9 @@ func main() {
 	// be added before calling pflag.Parse().
 	pflag.CommandLine.AddFlagSet(zap.FlagSet())
 
+	// Add the flags of the csr controller
+	controller.CSROptions.AddFlags(flag.CommandLine)
+
 	// Add flags registered by imported packages (e.g. glog and
 	// controller-runtime)
 	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
@@ -93,6 +102,23 @@ func main() {
 		log.Error(err, "")
 		os.Exit(1)
 	}
+	// Watch the hub of the hub kubeconfig secret instead of the cluster the controller runs on if set
+	hubCfg := cfg
+	if hubkubeconfig.Enabled() {
+		kubeClient, err := kubernetes.NewForConfig(cfg)
+		if err != nil {
+			log.Error(err, "")
+			os.Exit(1)
+		}
+		hubCfg, err = hubkubeconfig.Load(kubeClient, os.Getenv("POD_NAMESPACE"))
+		if err != nil {
+			log.Error(err, "")
+			os.Exit(1)
+		}
+		log.Info("Watching the hub of the hub kubeconfig secret", "host", hubCfg.Host)
+	}
+	// Slow the reconcile retries while the apiserver throttles the controller
+	hubCfg.Wrap(backpressure.WrapTransport)
 
 	ctx := context.TODO()
 	// Become the leader before proceeding
@@ -103,15 +129,21 @@ func main() {
 	}
 
 	// Create a new Cmd to provide shared dependencies and start components
-	mgr, err := manager.New(cfg, manager.Options{
-		Namespace:          namespace,
-		MetricsBindAddress: fmt.Sprintf("%s:%d", metricsHost, metricsPort),
+	mgr, err := manager.New(hubCfg, manager.Options{
+		Namespace:              namespace,
+		MetricsBindAddress:     fmt.Sprintf("%s:%d", metricsHost, metricsPort),
+		HealthProbeBindAddress: fmt.Sprintf("%s:%d", metricsHost, healthProbePort),
 	})
 	if err != nil {
 		log.Error(err, "")
 		os.Exit(1)
 	}
 
+	if err := mgr.AddHealthzCheck("ping", healthz.Ping); err != nil {
+		log.Error(err, "")
+		os.Exit(1)
+	}
+
 	log.Info("Registering Components.")
 
 	// Setup Scheme for all resources
@@ -141,6 +173,11 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err := addonv1alpha1.Install(mgr.GetScheme()); err != nil {
+		log.Error(err, "")
+		os.Exit(1)
+	}
+
 	if err := rbacv1.AddToScheme(mgr.GetScheme()); err != nil {
 		log.Error(err, "")
 		os.Exit(1)
@@ -152,7 +189,7 @@ func main() {
 	}
 
 	log.Info("Setup manager with controllers")
-	missingGVS, err := controller.GetMissingGVS(cfg)
+	missingGVS, err := controller.GetMissingGVS(hubCfg)
 	if err != nil {
 		log.Error(err, "")
 		os.Exit(1)
@@ -174,7 +211,7 @@ func main() {
 			change := false
 			for !change {
 				time.Sleep(time.Second * 10)
-				currentMissingGVS, err := controller.GetMissingGVS(cfg)
+				currentMissingGVS, err := controller.GetMissingGVS(hubCfg)
 				if err != nil {
 					log.Error(err, "")
 					os.Exit(1)
@@ -6,12 +6,18 @@ package controller
 import (
 	"github.com/open-cluster-management/managedcluster-import-controller/pkg/controller/csr"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
 
+// CSROptions are the options of the csr controller, the main binds them to its flags
+var CSROptions = csr.NewCSRControllerOptions()
+
 func init() {
 	// AddToManagerFuncs is a list of functions and manadatory GVs to create controllers and add them to a manager.
 	AddToManagerFuncs = append(AddToManagerFuncs, addToManager{
-		function:               csr.Add,
+		function: func(m manager.Manager) error {
+			return csr.Add(m, CSROptions)
+		},
 		MandatoryGroupVersions: []schema.GroupVersion{},
 	})
 }
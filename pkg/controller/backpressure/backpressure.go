@@ -0,0 +1,139 @@
+// Copyright Contributors to the Open Cluster Management project
+
+// Package backpressure slows the reconcile retries of the controllers while the hub apiserver throttles them
+package backpressure
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/util/workqueue"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/ratelimiter"
+)
+
+var log = logf.Log.WithName("backpressure")
+
+const (
+	// minDelay is the delay added to the retries on the first throttled response, it doubles on each
+	// new throttled response up to maxDelay
+	minDelay = time.Second
+	maxDelay = 2 * time.Minute
+	// recoveryPeriod is the period without throttled response after which the delay is halved
+	recoveryPeriod = 30 * time.Second
+)
+
+var backpressureDelaySeconds = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "managedcluster_import_apiserver_backpressure_delay_seconds",
+	Help: "Delay added to the reconcile retries while the hub apiserver throttles the controller",
+})
+
+func init() {
+	metrics.Registry.MustRegister(backpressureDelaySeconds)
+}
+
+// monitor tracks the throttled responses of the hub apiserver and derives the delay added to the retries
+type monitor struct {
+	lock sync.Mutex
+	// delay is the delay added to the retries, 0 while the apiserver is healthy
+	delay time.Duration
+	// updatedAt is the time of the last throttled response or of the last halving of the delay
+	updatedAt time.Time
+}
+
+// defaultMonitor is fed by WrapTransport and read by the rate limiters of NewRateLimiter
+var defaultMonitor = &monitor{}
+
+// throttled doubles the delay on a throttled response
+func (m *monitor) throttled(now time.Time) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.decay(now)
+	switch {
+	case m.delay == 0:
+		m.delay = minDelay
+	case m.delay < maxDelay:
+		m.delay *= 2
+		if m.delay > maxDelay {
+			m.delay = maxDelay
+		}
+	}
+	m.updatedAt = now
+	backpressureDelaySeconds.Set(m.delay.Seconds())
+}
+
+// currentDelay returns the delay added to the retries at now
+func (m *monitor) currentDelay(now time.Time) time.Duration {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.decay(now)
+	return m.delay
+}
+
+// decay halves the delay for each recovery period elapsed since the last update, the delay is removed
+// once it falls below minDelay
+func (m *monitor) decay(now time.Time) {
+	if m.delay == 0 {
+		return
+	}
+	periods := now.Sub(m.updatedAt) / recoveryPeriod
+	if periods <= 0 {
+		return
+	}
+	for ; periods > 0 && m.delay > 0; periods-- {
+		m.delay /= 2
+		m.updatedAt = m.updatedAt.Add(recoveryPeriod)
+		if m.delay < minDelay {
+			m.delay = 0
+			log.Info("Hub apiserver recovered, backpressure removed")
+		}
+	}
+	backpressureDelaySeconds.Set(m.delay.Seconds())
+}
+
+// throttleDetector is a round tripper recording the throttled responses of the apiserver in its monitor
+type throttleDetector struct {
+	monitor *monitor
+	next    http.RoundTripper
+}
+
+func (d *throttleDetector) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := d.next.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+		log.Info("Hub apiserver throttled the request", "method", req.Method, "path", req.URL.Path)
+		d.monitor.throttled(time.Now())
+	}
+	return resp, err
+}
+
+// WrapTransport wraps the transport of the hub rest config to detect the throttled responses of the apiserver,
+// it is installed with rest.Config.Wrap
+func WrapTransport(rt http.RoundTripper) http.RoundTripper {
+	return &throttleDetector{monitor: defaultMonitor, next: rt}
+}
+
+// rateLimiter adds the backpressure delay to the retries of the base rate limiter
+type rateLimiter struct {
+	ratelimiter.RateLimiter
+	monitor *monitor
+}
+
+func (l *rateLimiter) When(item interface{}) time.Duration {
+	return l.RateLimiter.When(item) + l.monitor.currentDelay(time.Now())
+}
+
+// NewRateLimiter returns the default controller rate limiter slowed by the throttled responses detected
+// by WrapTransport
+func NewRateLimiter() ratelimiter.RateLimiter {
+	return newRateLimiter(defaultMonitor)
+}
+
+func newRateLimiter(m *monitor) ratelimiter.RateLimiter {
+	return &rateLimiter{
+		RateLimiter: workqueue.DefaultControllerRateLimiter(),
+		monitor:     m,
+	}
+}
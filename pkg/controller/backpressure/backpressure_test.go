@@ -0,0 +1,124 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package backpressure
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_monitor_currentDelay(t *testing.T) {
+	throttledAt := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		throttles int
+		elapsed   time.Duration
+		want      time.Duration
+	}{
+		{
+			name: "healthy",
+			want: 0,
+		},
+		{
+			name:      "throttled",
+			throttles: 1,
+			want:      minDelay,
+		},
+		{
+			name:      "throttled repeatedly",
+			throttles: 4,
+			want:      8 * minDelay,
+		},
+		{
+			name:      "throttled up to the maximum",
+			throttles: 20,
+			want:      maxDelay,
+		},
+		{
+			name:      "recovering",
+			throttles: 4,
+			elapsed:   2 * recoveryPeriod,
+			want:      2 * minDelay,
+		},
+		{
+			name:      "recovered",
+			throttles: 4,
+			elapsed:   4 * recoveryPeriod,
+			want:      0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &monitor{}
+			for i := 0; i < tt.throttles; i++ {
+				m.throttled(throttledAt)
+			}
+			if got := m.currentDelay(throttledAt.Add(tt.elapsed)); got != tt.want {
+				t.Errorf("currentDelay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_throttleDetector(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		wantThrottled bool
+	}{
+		{
+			name:   "ok",
+			status: http.StatusOK,
+		},
+		{
+			name:   "server error",
+			status: http.StatusInternalServerError,
+		},
+		{
+			name:          "too many requests",
+			status:        http.StatusTooManyRequests,
+			wantThrottled: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+			m := &monitor{}
+			httpClient := &http.Client{Transport: &throttleDetector{monitor: m, next: http.DefaultTransport}}
+
+			resp, err := httpClient.Get(server.URL + "/api/v1/namespaces")
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if throttled := m.currentDelay(time.Now()) > 0; throttled != tt.wantThrottled {
+				t.Errorf("throttled = %v, want %v", throttled, tt.wantThrottled)
+			}
+		})
+	}
+}
+
+func Test_rateLimiter_When(t *testing.T) {
+	m := &monitor{}
+	limiter := newRateLimiter(m)
+	if got := limiter.When("healthy"); got >= minDelay {
+		t.Errorf("When() = %v while healthy, want less than %v", got, minDelay)
+	}
+	m.throttled(time.Now())
+	m.throttled(time.Now())
+	if got := limiter.When("throttled"); got < 2*minDelay {
+		t.Errorf("When() = %v while throttled, want at least %v", got, 2*minDelay)
+	}
+	limiter.Forget("throttled")
+	if got := limiter.NumRequeues("throttled"); got != 0 {
+		t.Errorf("NumRequeues() = %d after Forget(), want 0", got)
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+
+	addonv1alpha1 "github.com/open-cluster-management/api/addon/v1alpha1"
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// addOnLabel carries the name of the add-on of the registration csrs of the add-on agents
+	addOnLabel = "open-cluster-management.io/addon-name"
+	// addOnUsernameSignature is the username of the agent of an add-on of a cluster requesting its registration csrs
+	addOnUsernameSignature = "system:open-cluster-management:%s:%s:agent"
+	// addOnAllowlistEnvVarName is a comma separated list of the add-ons whose registration csrs are approved without
+	// a ManagedClusterAddOn in the cluster namespace
+	addOnAllowlistEnvVarName = "CSR_ADDON_ALLOWLIST"
+)
+
+// getAddOnAllowlist returns the CSR_ADDON_ALLOWLIST value, nil if not set
+func getAddOnAllowlist() ([]string, error) {
+	v := os.Getenv(addOnAllowlistEnvVarName)
+	if v == "" {
+		return nil, nil
+	}
+	addOns := []string{}
+	for _, name := range splitList(v) {
+		if errs := validation.IsDNS1123Subdomain(name); len(errs) != 0 {
+			return nil, fmt.Errorf("invalid %s add-on %q: %s", addOnAllowlistEnvVarName, name, strings.Join(errs, ", "))
+		}
+		addOns = append(addOns, name)
+	}
+	return addOns, nil
+}
+
+// getAddOnName returns the add-on of the registration csr of an add-on agent, empty if the csr is not an add-on csr
+func getAddOnName(csr *certificatesv1.CertificateSigningRequest) string {
+	return csr.GetLabels()[addOnLabel]
+}
+
+// validAddOnUsername checks the csr is requested by the agent of the add-on of the cluster
+func validAddOnUsername(csr *certificatesv1.CertificateSigningRequest, clusterName, addOnName string) bool {
+	return csr.Spec.Username == fmt.Sprintf(addOnUsernameSignature, clusterName, addOnName)
+}
+
+// addOnPredicate checks the csr is a pending registration csr of an add-on requested by the agent of the add-on
+func addOnPredicate(csr *certificatesv1.CertificateSigningRequest) bool {
+	clusterName, addOnName := getClusterName(csr), getAddOnName(csr)
+	return clusterName != "" && addOnName != "" &&
+		getApprovalType(csr) == "" &&
+		validAddOnUsername(csr, clusterName, addOnName)
+}
+
+// verifyAddOnIdentity checks the subject of the certificate request is the identity of the agent of the add-on, its
+// common name must be system:open-cluster-management:<cluster>:<addon> or prefixed by it and its organizations must
+// be the add-on group of the cluster
+func verifyAddOnIdentity(x509cr *x509.CertificateRequest, clusterName, addOnName string) error {
+	addOnIdentity := clusterCommonNamePrefix + clusterName + ":" + addOnName
+	commonName := x509cr.Subject.CommonName
+	if commonName != addOnIdentity && !strings.HasPrefix(commonName, addOnIdentity+":") {
+		return fmt.Errorf("common name %q is not an identity of the add-on %s of cluster %s",
+			commonName, addOnName, clusterName)
+	}
+	for _, organization := range x509cr.Subject.Organization {
+		if organization != addOnIdentity {
+			return fmt.Errorf("organization %q is not a group of the add-on %s of cluster %s",
+				organization, addOnName, clusterName)
+		}
+	}
+	return nil
+}
+
+// addOnRegistered checks the add-on is allowlisted or has a ManagedClusterAddOn in the cluster namespace
+func (r *ReconcileCSR) addOnRegistered(clusterName, addOnName string) (bool, error) {
+	if containsString(r.addOnAllowlist, addOnName) {
+		return true, nil
+	}
+	addOn := &addonv1alpha1.ManagedClusterAddOn{}
+	ctx, cancel := r.apiCallContext()
+	defer cancel()
+	err := r.client.Get(ctx, types.NamespacedName{Namespace: clusterName, Name: addOnName}, addOn)
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// reconcileAddOnCSR approves the registration csr of the agent of an add-on of an accepted cluster. The add-on csrs
+// are evaluated apart from the bootstrap csrs and are never denied, so they can not start the denial cooldown of the
+// cluster nor otherwise affect its registration.
+func (r *ReconcileCSR) reconcileAddOnCSR(
+	csr *certificatesv1.CertificateSigningRequest,
+	addOnName string) (reconcile.Result, error) {
+	clusterName := getClusterName(csr)
+	reqLogger := csrLogger(csr).WithValues("addon", addOnName)
+	csrSeenTotal.WithLabelValues(clusterName).Inc()
+
+	if err := r.clusterNameFilter.allows(clusterName); err != nil {
+		reqLogger.Info("Add-on CSR not approved, the cluster is not allowed", "decision", decisionSkip, "reason", err.Error())
+		return reconcile.Result{}, r.markPending(csr, ReasonClusterNameNotAllowed, err.Error())
+	}
+
+	if !validAddOnUsername(csr, clusterName, addOnName) {
+		reqLogger.Info("Skipping add-on CSR requested by another username", "decision", decisionSkip)
+		return reconcile.Result{}, nil
+	}
+
+	x509cr, err := validateRequest(csr)
+	if err != nil {
+		reqLogger.Info("Add-on CSR not approved", "decision", decisionSkip, "reason", err.Error())
+		return reconcile.Result{}, r.markPending(csr, ReasonInvalidCertificateRequest, err.Error())
+	}
+
+	cluster := clusterv1.ManagedCluster{}
+	ctx, cancel := r.apiCallContext()
+	err = r.client.Get(ctx, types.NamespacedName{Name: clusterName}, &cluster)
+	cancel()
+	if err != nil {
+		if errors.IsNotFound(err) {
+			reqLogger.V(debugLevel).Info("Add-on CSR not approved, the ManagedCluster is not found",
+				"decision", decisionSkip)
+			return reconcile.Result{}, r.markPending(csr, ReasonClusterNotFound,
+				fmt.Sprintf("The ManagedCluster %s does not exist", clusterName))
+		}
+		return reconcile.Result{}, err
+	}
+	if !cluster.Spec.HubAcceptsClient {
+		reqLogger.Info("Add-on CSR not approved, the cluster is not accepted by the hub", "decision", decisionSkip)
+		return reconcile.Result{Requeue: true, RequeueAfter: clusterNotAcceptedRequeuePeriod},
+			r.markPending(csr, ReasonClusterNotAccepted,
+				fmt.Sprintf("The ManagedCluster %s is not accepted by the hub", clusterName))
+	}
+	if err := allowsClusterSet(r.clusterSetAllowlist, &cluster); err != nil {
+		reqLogger.Info("Add-on CSR not approved, the cluster set is not allowed", "decision", decisionSkip,
+			"reason", err.Error())
+		return reconcile.Result{}, r.markPending(csr, ReasonClusterSetNotAllowed, err.Error())
+	}
+
+	registered, err := r.addOnRegistered(clusterName, addOnName)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if !registered {
+		reqLogger.Info("Add-on CSR not approved, the add-on is not enabled on the cluster", "decision", decisionSkip)
+		return reconcile.Result{}, r.markPending(csr, ReasonAddOnNotFound,
+			fmt.Sprintf("The add-on %s has no ManagedClusterAddOn in the namespace %s", addOnName, clusterName))
+	}
+
+	if err := verifyAddOnIdentity(x509cr, clusterName, addOnName); err != nil {
+		reqLogger.Info("Add-on CSR not approved", "decision", decisionSkip, "reason", err.Error())
+		return reconcile.Result{}, r.markPending(csr, ReasonAddOnIdentityMismatch, err.Error())
+	}
+	if err := r.requestAllowlist.verify(csr); err != nil {
+		reqLogger.Info("Add-on CSR not approved", "decision", decisionSkip, "reason", err.Error())
+		return reconcile.Result{}, r.markPending(csr, ReasonRequestNotAllowed, err.Error())
+	}
+
+	policy, err := r.approvalPolicyFor(csr)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	reqLogger.Info("Approving add-on CSR", "decision", decisionApprove, "policy", policy.version())
+	if err := r.approveCSR(csr, &cluster, policy); err != nil {
+		return reconcile.Result{}, err
+	}
+	if !r.dryRun {
+		r.recordApprovalEvents(csr, &cluster)
+	}
+
+	if r.issuanceTimeout > 0 {
+		return reconcile.Result{Requeue: true, RequeueAfter: r.issuanceTimeout}, nil
+	}
+	return reconcile.Result{}, nil
+}
@@ -0,0 +1,202 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	addonv1alpha1 "github.com/open-cluster-management/api/addon/v1alpha1"
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const testAddOnName = "policy-controller"
+
+func Test_getAddOnAllowlist(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:  "add-ons",
+			value: "policy-controller, observability-controller",
+			want:  []string{"policy-controller", "observability-controller"},
+		},
+		{
+			name:    "invalid add-on",
+			value:   "Policy Controller",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(addOnAllowlistEnvVarName, tt.value)
+			defer os.Unsetenv(addOnAllowlistEnvVarName)
+			got, err := getAddOnAllowlist()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getAddOnAllowlist() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getAddOnAllowlist() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_ReconcileAddOn(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+	testscheme.AddKnownTypes(addonv1alpha1.GroupVersion, &addonv1alpha1.ManagedClusterAddOn{})
+
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterName},
+		Spec:       clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+	}
+	testAddOn := &addonv1alpha1.ManagedClusterAddOn{
+		ObjectMeta: metav1.ObjectMeta{Namespace: clusterName, Name: testAddOnName},
+	}
+	addOnIdentity := clusterCommonNamePrefix + clusterName + ":" + testAddOnName
+	addOnUsername := fmt.Sprintf(addOnUsernameSignature, clusterName, testAddOnName)
+
+	tests := []struct {
+		name           string
+		username       string
+		commonName     string
+		organizations  []string
+		addOns         []runtime.Object
+		addOnAllowlist []string
+		wantCode       ReasonCode
+		wantApproval   string
+	}{
+		{
+			name:          "valid add-on csr",
+			username:      addOnUsername,
+			commonName:    addOnIdentity + ":agent",
+			organizations: []string{addOnIdentity},
+			addOns:        []runtime.Object{testAddOn},
+			wantCode:      ReasonAutoApproved,
+			wantApproval:  string(certificatesv1.CertificateApproved),
+		},
+		{
+			name:       "nonexistent add-on",
+			username:   addOnUsername,
+			commonName: addOnIdentity,
+			wantCode:   ReasonAddOnNotFound,
+		},
+		{
+			name:           "allowlisted add-on",
+			username:       addOnUsername,
+			commonName:     addOnIdentity,
+			addOnAllowlist: []string{testAddOnName},
+			wantCode:       ReasonAutoApproved,
+			wantApproval:   string(certificatesv1.CertificateApproved),
+		},
+		{
+			name:       "identity of the cluster",
+			username:   addOnUsername,
+			commonName: clusterCommonNamePrefix + clusterName,
+			addOns:     []runtime.Object{testAddOn},
+			wantCode:   ReasonAddOnIdentityMismatch,
+		},
+		{
+			// the add-on csrs requested by another username are left untouched, never denied
+			name:       "requested by the bootstrap service account",
+			username:   fmt.Sprintf(userNameSignature, clusterName, clusterName),
+			commonName: addOnIdentity,
+			addOns:     []runtime.Object{testAddOn},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr := &certificatesv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   csrNameReconcile,
+					Labels: map[string]string{clusterLabel: clusterName, addOnLabel: testAddOnName},
+				},
+				Spec: certificatesv1.CertificateSigningRequestSpec{
+					Username:   tt.username,
+					Request:    newCSRRequest(t, tt.commonName, tt.organizations),
+					SignerName: certificatesv1.KubeAPIServerClientSignerName,
+					Usages:     []certificatesv1.KeyUsage{certificatesv1.UsageClientAuth},
+				},
+			}
+			objs := append([]runtime.Object{csr, testManagedCluster}, tt.addOns...)
+			r := &ReconcileCSR{
+				client:               fake.NewFakeClientWithScheme(testscheme, objs...),
+				kubeClient:           fakeclientset.NewSimpleClientset(csr),
+				scheme:               testscheme,
+				podNamespace:         testPodNamespace,
+				addOnAllowlist:       tt.addOnAllowlist,
+				requestAllowlist:     &requestAllowlist{usages: defaultAllowedUsages, signers: defaultAllowedSigners},
+				denyUnauthorizedCSRs: true,
+			}
+			if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}}); err != nil {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+			}
+			got, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), csrNameReconcile, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code := got.Annotations[ReasonCodeAnnotation]; code != string(tt.wantCode) {
+				t.Errorf("reason code annotation = %q, want %q", code, tt.wantCode)
+			}
+			if approval := getApprovalType(got); approval != tt.wantApproval {
+				t.Errorf("CSR approval = %q, want %q", approval, tt.wantApproval)
+			}
+		})
+	}
+}
+
+func Test_addOnPredicate(t *testing.T) {
+	tests := []struct {
+		name     string
+		labels   map[string]string
+		username string
+		want     bool
+	}{
+		{
+			name:     "add-on csr",
+			labels:   map[string]string{clusterLabel: clusterName, addOnLabel: testAddOnName},
+			username: fmt.Sprintf(addOnUsernameSignature, clusterName, testAddOnName),
+			want:     true,
+		},
+		{
+			name:     "username of another add-on",
+			labels:   map[string]string{clusterLabel: clusterName, addOnLabel: testAddOnName},
+			username: fmt.Sprintf(addOnUsernameSignature, clusterName, "other-addon"),
+		},
+		{
+			name:     "bootstrap csr",
+			labels:   map[string]string{clusterLabel: clusterName},
+			username: fmt.Sprintf(addOnUsernameSignature, clusterName, testAddOnName),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr := &certificatesv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{Labels: tt.labels},
+				Spec:       certificatesv1.CertificateSigningRequestSpec{Username: tt.username},
+			}
+			if got := addOnPredicate(csr); got != tt.want {
+				t.Errorf("addOnPredicate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// apiCallContext is the context of the API calls of the reconciles, it is cancelled when the manager stops so the
+// calls in progress do not delay the shutdown. A nil apiCallContext neither times out nor is cancelled.
+type apiCallContext struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	timeout time.Duration
+}
+
+// newAPICallContext returns the context of the API calls timing out after timeout, they do not time out if timeout
+// is not positive
+func newAPICallContext(timeout time.Duration) (*apiCallContext, error) {
+	if timeout < 0 {
+		return nil, fmt.Errorf("invalid --csr-api-call-timeout value %v, must not be negative", timeout)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &apiCallContext{ctx: ctx, cancel: cancel, timeout: timeout}, nil
+}
+
+// Start cancels the API calls once stop is closed
+func (c *apiCallContext) Start(stop <-chan struct{}) error {
+	<-stop
+	c.cancel()
+	return nil
+}
+
+// NeedLeaderElection is false, the calls are cancelled on the shutdown whether the manager is the leader or not
+func (c *apiCallContext) NeedLeaderElection() bool {
+	return false
+}
+
+// apiCallContext returns the context of an API call of r and its cancel func, which must be called once the call
+// returns
+func (r *ReconcileCSR) apiCallContext() (context.Context, context.CancelFunc) {
+	if r.apiCalls == nil {
+		return context.WithCancel(context.Background())
+	}
+	if r.apiCalls.timeout <= 0 {
+		return context.WithCancel(r.apiCalls.ctx)
+	}
+	return context.WithTimeout(r.apiCalls.ctx, r.apiCalls.timeout)
+}
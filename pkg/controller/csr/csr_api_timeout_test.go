@@ -0,0 +1,106 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// blockingClient blocks the gets of the ManagedClusters until their context is done
+type blockingClient struct {
+	client.Client
+}
+
+func (c *blockingClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	if _, ok := obj.(*clusterv1.ManagedCluster); ok {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func Test_newAPICallContext(t *testing.T) {
+	if _, err := newAPICallContext(-time.Second); err == nil {
+		t.Errorf("newAPICallContext() of a negative timeout error = nil, want an error")
+	}
+	c, err := newAPICallContext(0)
+	if err != nil {
+		t.Fatalf("newAPICallContext() error = %v", err)
+	}
+	r := &ReconcileCSR{apiCalls: c}
+	ctx, cancel := r.apiCallContext()
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Errorf("apiCallContext() without timeout has a deadline")
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = c.Start(stop)
+	}()
+	close(stop)
+	<-done
+	if ctx.Err() != context.Canceled {
+		t.Errorf("apiCallContext() error on the shutdown = %v, want %v", ctx.Err(), context.Canceled)
+	}
+}
+
+func TestReconcileCSR_ReconcileAPICallTimeout(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	csr := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   csrNameReconcile,
+			Labels: map[string]string{clusterLabel: clusterName},
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Username:   fmt.Sprintf(userNameSignature, clusterName, clusterName),
+			Request:    newCSRRequest(t, clusterCommonNamePrefix+clusterName, nil),
+			SignerName: certificatesv1.KubeAPIServerClientSignerName,
+			Usages:     []certificatesv1.KeyUsage{certificatesv1.UsageClientAuth},
+		},
+	}
+	apiCalls, err := newAPICallContext(50 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &ReconcileCSR{
+		client:       &blockingClient{Client: fake.NewFakeClientWithScheme(testscheme, csr.DeepCopy())},
+		kubeClient:   fakeclientset.NewSimpleClientset(csr.DeepCopy()),
+		scheme:       testscheme,
+		podNamespace: testPodNamespace,
+		apiCalls:     apiCalls,
+	}
+
+	result := make(chan error)
+	go func() {
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}})
+		result <- err
+	}()
+	select {
+	case err := <-result:
+		if err != context.DeadlineExceeded {
+			t.Errorf("ReconcileCSR.Reconcile() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("ReconcileCSR.Reconcile() did not time out")
+	}
+}
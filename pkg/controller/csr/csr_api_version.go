@@ -0,0 +1,537 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	certificatesv1beta1 "k8s.io/api/certificates/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+)
+
+// apiVersionRefreshEnvVarName is the period of the re-discovery of the CSR API version served by the hub
+const apiVersionRefreshEnvVarName = "CSR_API_VERSION_REFRESH"
+
+// apiVersionModeEnvVarName selects how the version of the certificates API of the csrs is chosen, apiVersionModeHub
+// if not set
+const apiVersionModeEnvVarName = "CSR_API_VERSION_MODE"
+
+const (
+	// apiVersionModeHub updates all the csrs with the version preferred by the hub
+	apiVersionModeHub = "hub"
+	// apiVersionModePerCSR updates each csr with the version it is served with, for example in a fleet mixing the
+	// v1 csrs of the local-cluster self-import and the v1beta1 csrs of the remote spokes
+	apiVersionModePerCSR = "per-csr"
+)
+
+const (
+	defaultAPIVersionRefresh = 10 * time.Minute
+	// maxAPIVersionErrors is the number of consecutive CSR API errors invalidating the discovered version
+	maxAPIVersionErrors = 3
+)
+
+// apiVersionResolver discovers the version of the certificates API served by the hub, v1 is preferred over
+// v1beta1. The discovered version is cached, it is discovered again after the refresh period or once the
+// CSR API calls failed maxAPIVersionErrors times in a row, for example when the hub is downgraded and the v1 API
+// disappears, so the controller switches of version without a restart.
+// A nil apiVersionResolver always resolves v1.
+type apiVersionResolver struct {
+	discovery    discovery.DiscoveryInterface
+	refresh      time.Duration
+	lock         sync.Mutex
+	version      string
+	served       map[string]bool
+	discoveredAt time.Time
+	errors       int
+}
+
+// newAPIVersionResolver returns a resolver discovering the version every refresh, nil if discoveryClient is nil
+func newAPIVersionResolver(discoveryClient discovery.DiscoveryInterface, refresh time.Duration) *apiVersionResolver {
+	if discoveryClient == nil {
+		return nil
+	}
+	return &apiVersionResolver{
+		discovery: discoveryClient,
+		refresh:   refresh,
+	}
+}
+
+// getAPIVersionMode returns the CSR_API_VERSION_MODE value, apiVersionModeHub if not set
+func getAPIVersionMode() (string, error) {
+	switch v := os.Getenv(apiVersionModeEnvVarName); v {
+	case "":
+		return apiVersionModeHub, nil
+	case apiVersionModeHub, apiVersionModePerCSR:
+		return v, nil
+	default:
+		return "", fmt.Errorf("invalid %s value %q, must be %s or %s", apiVersionModeEnvVarName, v,
+			apiVersionModeHub, apiVersionModePerCSR)
+	}
+}
+
+// csrVersions records the version of the certificates API each csr is served with.
+// A nil csrVersions records nothing.
+type csrVersions struct {
+	lock     sync.Mutex
+	versions map[string]string
+}
+
+// newCSRVersions returns the csr versions of the per-csr mode, nil in the other modes
+func newCSRVersions(mode string) *csrVersions {
+	if mode != apiVersionModePerCSR {
+		return nil
+	}
+	return &csrVersions{versions: map[string]string{}}
+}
+
+func (v *csrVersions) set(csrName, version string) {
+	if v == nil {
+		return
+	}
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.versions[csrName] = version
+}
+
+func (v *csrVersions) get(csrName string) (string, bool) {
+	if v == nil {
+		return "", false
+	}
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	version, ok := v.versions[csrName]
+	return version, ok
+}
+
+// forget clears the version of a deleted csr
+func (v *csrVersions) forget(csrName string) {
+	if v == nil {
+		return
+	}
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	delete(v.versions, csrName)
+}
+
+// getAPIVersionRefresh returns the CSR_API_VERSION_REFRESH value, defaultAPIVersionRefresh if not set
+func getAPIVersionRefresh() (time.Duration, error) {
+	v := os.Getenv(apiVersionRefreshEnvVarName)
+	if v == "" {
+		return defaultAPIVersionRefresh, nil
+	}
+	refresh, err := time.ParseDuration(v)
+	if err != nil || refresh <= 0 {
+		return 0, fmt.Errorf("invalid %s value %q, must be a positive duration", apiVersionRefreshEnvVarName, v)
+	}
+	return refresh, nil
+}
+
+// servesCSRs returns true if the group version of the certificates API serves the csrs
+func servesCSRs(discoveryClient discovery.DiscoveryInterface, groupVersion string) bool {
+	resources, err := discoveryClient.ServerResourcesForGroupVersion(groupVersion)
+	if err != nil {
+		return false
+	}
+	for _, resource := range resources.APIResources {
+		if resource.Name == "certificatesigningrequests" {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns the version of the certificates API to use, the version discovered last is kept if the
+// discovery fails
+func (r *apiVersionResolver) resolve(now time.Time) (string, error) {
+	if r == nil {
+		return certificatesV1, nil
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.version != "" && now.Sub(r.discoveredAt) < r.refresh {
+		return r.version, nil
+	}
+
+	version := ""
+	served := map[string]bool{}
+	for _, v := range []string{certificatesV1, certificatesV1beta1} {
+		if !servesCSRs(r.discovery, certificatesv1.GroupName+"/"+v) {
+			continue
+		}
+		if version == "" {
+			version = v
+		}
+		served[v] = true
+	}
+	if version == "" {
+		if r.version != "" {
+			return r.version, nil
+		}
+		return "", fmt.Errorf("the hub serves no version of the certificatesigningrequests API")
+	}
+	if version != r.version {
+		log.Info("Certificates API version discovered", "version", version, "previous", r.version)
+	}
+	r.version = version
+	r.served = served
+	r.discoveredAt = now
+	r.errors = 0
+	return version, nil
+}
+
+// serves returns true if the hub serves the version of the certificates API, every version is assumed served
+// without a resolver
+func (r *apiVersionResolver) serves(version string, now time.Time) bool {
+	if r == nil {
+		return true
+	}
+	if _, err := r.resolve(now); err != nil {
+		return false
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.served[version]
+}
+
+// observe records the outcome of a CSR API call, the discovered version is invalidated after
+// maxAPIVersionErrors consecutive errors hinting that the version is no longer served
+func (r *apiVersionResolver) observe(err error) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if err == nil || !(errors.IsNotFound(err) || errors.IsMethodNotSupported(err)) {
+		r.errors = 0
+		return
+	}
+	r.errors++
+	if r.errors >= maxAPIVersionErrors {
+		log.Info("Repeated certificates API errors, invalidating the discovered version", "version", r.version)
+		r.version = ""
+		r.errors = 0
+	}
+}
+
+// toV1beta1CSR converts the v1 csr to its v1beta1 equivalent
+func toV1beta1CSR(csr *certificatesv1.CertificateSigningRequest) *certificatesv1beta1.CertificateSigningRequest {
+	out := &certificatesv1beta1.CertificateSigningRequest{
+		ObjectMeta: *csr.ObjectMeta.DeepCopy(),
+		Spec: certificatesv1beta1.CertificateSigningRequestSpec{
+			Request:  csr.Spec.Request,
+			Username: csr.Spec.Username,
+			UID:      csr.Spec.UID,
+			Groups:   csr.Spec.Groups,
+		},
+		Status: certificatesv1beta1.CertificateSigningRequestStatus{
+			Certificate: csr.Status.Certificate,
+		},
+	}
+	if csr.Spec.SignerName != "" {
+		signerName := csr.Spec.SignerName
+		out.Spec.SignerName = &signerName
+	}
+	for _, usage := range csr.Spec.Usages {
+		out.Spec.Usages = append(out.Spec.Usages, certificatesv1beta1.KeyUsage(usage))
+	}
+	if csr.Spec.Extra != nil {
+		out.Spec.Extra = map[string]certificatesv1beta1.ExtraValue{}
+		for k, v := range csr.Spec.Extra {
+			out.Spec.Extra[k] = certificatesv1beta1.ExtraValue(v)
+		}
+	}
+	for _, c := range csr.Status.Conditions {
+		out.Status.Conditions = append(out.Status.Conditions, certificatesv1beta1.CertificateSigningRequestCondition{
+			Type:               certificatesv1beta1.RequestConditionType(c.Type),
+			Status:             c.Status,
+			Reason:             c.Reason,
+			Message:            c.Message,
+			LastUpdateTime:     c.LastUpdateTime,
+			LastTransitionTime: c.LastTransitionTime,
+		})
+	}
+	return out
+}
+
+// fromV1beta1CSR converts the v1beta1 csr to its v1 equivalent
+func fromV1beta1CSR(csr *certificatesv1beta1.CertificateSigningRequest) *certificatesv1.CertificateSigningRequest {
+	out := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: *csr.ObjectMeta.DeepCopy(),
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request:  csr.Spec.Request,
+			Username: csr.Spec.Username,
+			UID:      csr.Spec.UID,
+			Groups:   csr.Spec.Groups,
+		},
+		Status: certificatesv1.CertificateSigningRequestStatus{
+			Certificate: csr.Status.Certificate,
+		},
+	}
+	if csr.Spec.SignerName != nil {
+		out.Spec.SignerName = *csr.Spec.SignerName
+	} else {
+		out.Spec.SignerName = defaultV1beta1SignerName(csr)
+	}
+	for _, usage := range csr.Spec.Usages {
+		out.Spec.Usages = append(out.Spec.Usages, certificatesv1.KeyUsage(usage))
+	}
+	if csr.Spec.Extra != nil {
+		out.Spec.Extra = map[string]certificatesv1.ExtraValue{}
+		for k, v := range csr.Spec.Extra {
+			out.Spec.Extra[k] = certificatesv1.ExtraValue(v)
+		}
+	}
+	for _, c := range csr.Status.Conditions {
+		out.Status.Conditions = append(out.Status.Conditions, certificatesv1.CertificateSigningRequestCondition{
+			Type:               certificatesv1.RequestConditionType(c.Type),
+			Status:             c.Status,
+			Reason:             c.Reason,
+			Message:            c.Message,
+			LastUpdateTime:     c.LastUpdateTime,
+			LastTransitionTime: c.LastTransitionTime,
+		})
+	}
+	return out
+}
+
+// defaultV1beta1SignerName returns the signer of a v1beta1 csr created without a signer name by a hub older
+// than the signer names, v1 requires a signer name. As the apiserver defaulting, the csrs limited to the client
+// usages are signed by the kube-apiserver-client signer, the other csrs by the legacy-unknown signer.
+func defaultV1beta1SignerName(csr *certificatesv1beta1.CertificateSigningRequest) string {
+	clientAuth := false
+	for _, usage := range csr.Spec.Usages {
+		switch usage {
+		case certificatesv1beta1.UsageClientAuth:
+			clientAuth = true
+		case certificatesv1beta1.UsageDigitalSignature, certificatesv1beta1.UsageKeyEncipherment:
+		default:
+			return certificatesv1beta1.LegacyUnknownSignerName
+		}
+	}
+	if !clientAuth {
+		return certificatesv1beta1.LegacyUnknownSignerName
+	}
+	return certificatesv1beta1.KubeAPIServerClientSignerName
+}
+
+// discoverWatchVersion discovers the version of the certificates API the controller starts watching the csrs with,
+// v1 if the hub serves it or the discovery fails. The watch then follows the version served by the hub, see
+// csrWatchSwitch.
+func (r *ReconcileCSR) discoverWatchVersion(now time.Time) string {
+	version, err := r.apiVersions.resolve(now)
+	if err != nil {
+		log.Error(err, "failed to discover the certificates API version, watching v1")
+		version = certificatesV1
+	}
+	r.watch = newCSRWatch(version)
+	return version
+}
+
+// csrWatch tracks the versions of the certificates API the csrs are watched with. The csrs are read with the
+// version the watch switched to last, the watch of a version is started the first time the watch switches to it
+// and kept afterwards, so the csrs created once the hub stopped serving the startup version are still queued.
+// A nil csrWatch reads the csrs with v1.
+type csrWatch struct {
+	lock    sync.Mutex
+	version string
+	watched map[string]bool
+	// watchCSRs starts the watch of the csrs of a version, nil until the controller is added
+	watchCSRs func(version string) error
+}
+
+// newCSRWatch returns a watch reading the csrs with the version, the version is watched once the watch is started
+func newCSRWatch(version string) *csrWatch {
+	return &csrWatch{version: version, watched: map[string]bool{}}
+}
+
+// start starts the watch of the current version with watchCSRs
+func (w *csrWatch) start(watchCSRs func(version string) error) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if err := watchCSRs(w.version); err != nil {
+		return err
+	}
+	w.watchCSRs = watchCSRs
+	w.watched[w.version] = true
+	return nil
+}
+
+// current returns the version the csrs are read with
+func (w *csrWatch) current() string {
+	if w == nil {
+		return certificatesV1
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.version
+}
+
+// switchTo reads the csrs with the version, the watch of the version is started first if it is not watched yet.
+// The watch keeps its version if the version can not be watched.
+func (w *csrWatch) switchTo(version string) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if version == w.version {
+		return nil
+	}
+	if !w.watched[version] && w.watchCSRs != nil {
+		if err := w.watchCSRs(version); err != nil {
+			return err
+		}
+		w.watched[version] = true
+	}
+	log.Info("Switching the watch of the CSRs", "version", version, "previous", w.version)
+	w.version = version
+	return nil
+}
+
+// csrWatchSwitch switches the watch of the csrs of r to the version of the certificates API served by the hub
+// every refresh period
+type csrWatchSwitch struct {
+	r       *ReconcileCSR
+	refresh time.Duration
+}
+
+// Start switches the watch every refresh period until stop is closed, a failed switch is logged and retried at the
+// next period
+func (s *csrWatchSwitch) Start(stop <-chan struct{}) error {
+	ticker := time.NewTicker(s.refresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.r.switchWatchVersion(time.Now()); err != nil {
+				log.Error(err, "failed to switch the watch of the CSRs")
+			}
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+// switchWatchVersion switches the watch of the csrs to the version of the certificates API resolved at now
+func (r *ReconcileCSR) switchWatchVersion(now time.Time) error {
+	version, err := r.apiVersions.resolve(now)
+	if err != nil {
+		return err
+	}
+	return r.watch.switchTo(version)
+}
+
+// newWatchedCSR returns an empty csr of the watched version of the certificates API
+func newWatchedCSR(watchVersion string) runtime.Object {
+	if watchVersion == certificatesV1beta1 {
+		return &certificatesv1beta1.CertificateSigningRequest{}
+	}
+	return &certificatesv1.CertificateSigningRequest{}
+}
+
+// toV1CSR returns the v1 equivalent of a watched csr
+func toV1CSR(obj runtime.Object) *certificatesv1.CertificateSigningRequest {
+	if csr, ok := obj.(*certificatesv1beta1.CertificateSigningRequest); ok {
+		return fromV1beta1CSR(csr)
+	}
+	return obj.(*certificatesv1.CertificateSigningRequest)
+}
+
+// getCSR gets the csr from the cache of the current watched version of the certificates API. In the per-csr mode,
+// the csr is looked up with the other version served by the hub if it is not found, and the version it is found
+// with is recorded.
+func (r *ReconcileCSR) getCSR(key types.NamespacedName) (*certificatesv1.CertificateSigningRequest, error) {
+	ctx, cancel := r.apiCallContext()
+	defer cancel()
+	watchVersion := r.watch.current()
+	versions := []string{watchVersion}
+	if r.csrVersions != nil {
+		if watchVersion == certificatesV1beta1 {
+			versions = append(versions, certificatesV1)
+		} else {
+			versions = append(versions, certificatesV1beta1)
+		}
+	}
+	var err error
+	for i, version := range versions {
+		if i > 0 && !r.apiVersions.serves(version, time.Now()) {
+			continue
+		}
+		obj := newWatchedCSR(version)
+		if err = r.client.Get(ctx, key, obj); err == nil {
+			r.csrVersions.set(key.Name, version)
+			return toV1CSR(obj), nil
+		}
+		if !errors.IsNotFound(err) && !meta.IsNoMatchError(err) {
+			return nil, err
+		}
+	}
+	r.csrVersions.forget(key.Name)
+	return nil, err
+}
+
+// csrAPIVersion returns the version of the certificates API the csr is updated with, the version the csr is
+// served with in the per-csr mode if the hub still serves it, else the version preferred by the hub
+func (r *ReconcileCSR) csrAPIVersion(csr *certificatesv1.CertificateSigningRequest) (string, error) {
+	now := time.Now()
+	if version, ok := r.csrVersions.get(csr.Name); ok && r.apiVersions.serves(version, now) {
+		return version, nil
+	}
+	return r.apiVersions.resolve(now)
+}
+
+// updateCSR updates the csr with its certificates API version
+func (r *ReconcileCSR) updateCSR(csr *certificatesv1.CertificateSigningRequest) (*certificatesv1.CertificateSigningRequest, error) {
+	ctx, cancel := r.apiCallContext()
+	defer cancel()
+	version, err := r.csrAPIVersion(csr)
+	if err != nil {
+		return nil, err
+	}
+	if version == certificatesV1beta1 {
+		updated, err := r.kubeClient.CertificatesV1beta1().CertificateSigningRequests().Update(ctx,
+			toV1beta1CSR(csr), metav1.UpdateOptions{})
+		r.apiVersions.observe(err)
+		if err != nil {
+			return nil, err
+		}
+		return fromV1beta1CSR(updated), nil
+	}
+	updated, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Update(ctx, csr, metav1.UpdateOptions{})
+	r.apiVersions.observe(err)
+	return updated, err
+}
+
+// listCSRs lists the csrs with the certificates API version served by the hub
+func (r *ReconcileCSR) listCSRs(opts metav1.ListOptions) ([]certificatesv1.CertificateSigningRequest, error) {
+	ctx, cancel := r.apiCallContext()
+	defer cancel()
+	version, err := r.apiVersions.resolve(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if version == certificatesV1beta1 {
+		csrs, err := r.kubeClient.CertificatesV1beta1().CertificateSigningRequests().List(ctx, opts)
+		r.apiVersions.observe(err)
+		if err != nil {
+			return nil, err
+		}
+		items := make([]certificatesv1.CertificateSigningRequest, 0, len(csrs.Items))
+		for i := range csrs.Items {
+			items = append(items, *fromV1beta1CSR(&csrs.Items[i]))
+		}
+		return items, nil
+	}
+	csrs, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().List(ctx, opts)
+	r.apiVersions.observe(err)
+	if err != nil {
+		return nil, err
+	}
+	return csrs.Items, nil
+}
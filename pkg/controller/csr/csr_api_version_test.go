@@ -0,0 +1,586 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	certificatesv1beta1 "k8s.io/api/certificates/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// certificatesResources returns the discovery resources of the served versions of the certificates API
+func certificatesResources(versions ...string) []*metav1.APIResourceList {
+	resources := []*metav1.APIResourceList{}
+	for _, version := range versions {
+		resources = append(resources, &metav1.APIResourceList{
+			GroupVersion: certificatesv1.GroupName + "/" + version,
+			APIResources: []metav1.APIResource{{Name: "certificatesigningrequests", Kind: "CertificateSigningRequest"}},
+		})
+	}
+	return resources
+}
+
+func Test_getAPIVersionRefresh(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{
+			name: "not set",
+			want: defaultAPIVersionRefresh,
+		},
+		{
+			name:  "valid",
+			value: "1m",
+			want:  time.Minute,
+		},
+		{
+			name:    "invalid",
+			value:   "0s",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(apiVersionRefreshEnvVarName, tt.value)
+			defer os.Unsetenv(apiVersionRefreshEnvVarName)
+			got, err := getAPIVersionRefresh()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getAPIVersionRefresh() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("getAPIVersionRefresh() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_apiVersionResolver(t *testing.T) {
+	discovery := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}
+	resolver := newAPIVersionResolver(discovery, time.Minute)
+	start := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	notFound := errors.NewNotFound(schema.GroupResource{Group: certificatesv1.GroupName, Resource: "certificatesigningrequests"}, "")
+
+	steps := []struct {
+		name        string
+		served      []string
+		errors      []error
+		now         time.Time
+		wantVersion string
+		wantErr     bool
+	}{
+		{
+			name:    "no version served",
+			now:     start,
+			wantErr: true,
+		},
+		{
+			name:        "v1 preferred",
+			served:      []string{certificatesV1, certificatesV1beta1},
+			now:         start,
+			wantVersion: certificatesV1,
+		},
+		{
+			name:        "v1 removed, cached version kept",
+			served:      []string{certificatesV1beta1},
+			now:         start.Add(30 * time.Second),
+			wantVersion: certificatesV1,
+		},
+		{
+			name:        "errors below the threshold",
+			served:      []string{certificatesV1beta1},
+			errors:      []error{notFound, notFound},
+			now:         start.Add(30 * time.Second),
+			wantVersion: certificatesV1,
+		},
+		{
+			name:        "errors reset by a success",
+			served:      []string{certificatesV1beta1},
+			errors:      []error{nil, notFound, notFound},
+			now:         start.Add(30 * time.Second),
+			wantVersion: certificatesV1,
+		},
+		{
+			name:        "repeated errors invalidate the version",
+			served:      []string{certificatesV1beta1},
+			errors:      []error{notFound},
+			now:         start.Add(30 * time.Second),
+			wantVersion: certificatesV1beta1,
+		},
+		{
+			name:        "v1 restored, cached version kept",
+			served:      []string{certificatesV1, certificatesV1beta1},
+			now:         start.Add(time.Minute),
+			wantVersion: certificatesV1beta1,
+		},
+		{
+			name:        "re-discovered after the refresh",
+			served:      []string{certificatesV1, certificatesV1beta1},
+			now:         start.Add(2 * time.Minute),
+			wantVersion: certificatesV1,
+		},
+		{
+			name:        "discovery failure keeps the version",
+			now:         start.Add(4 * time.Minute),
+			wantVersion: certificatesV1,
+		},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			discovery.Resources = certificatesResources(step.served...)
+			for _, err := range step.errors {
+				resolver.observe(err)
+			}
+			version, err := resolver.resolve(step.now)
+			if (err != nil) != step.wantErr {
+				t.Fatalf("resolve() error = %v, wantErr %v", err, step.wantErr)
+			}
+			if version != step.wantVersion {
+				t.Errorf("resolve() = %q, want %q", version, step.wantVersion)
+			}
+		})
+	}
+
+	var disabled *apiVersionResolver
+	if version, err := disabled.resolve(start); err != nil || version != certificatesV1 {
+		t.Errorf("nil resolve() = %q, %v, want %q", version, err, certificatesV1)
+	}
+}
+
+func Test_toV1beta1CSR(t *testing.T) {
+	csr := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        csrNameReconcile,
+			Labels:      map[string]string{clusterLabel: clusterName},
+			Annotations: map[string]string{ReasonCodeAnnotation: string(ReasonAutoApproved)},
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request:    []byte("request"),
+			SignerName: certificatesv1.KubeAPIServerClientSignerName,
+			Usages:     []certificatesv1.KeyUsage{certificatesv1.UsageDigitalSignature, certificatesv1.UsageClientAuth},
+			Username:   "system:serviceaccount:cluster1:cluster1-bootstrap-sa",
+			UID:        "uid",
+			Groups:     []string{"system:serviceaccounts"},
+			Extra:      map[string]certificatesv1.ExtraValue{"authentication.kubernetes.io/pod-name": {"pod"}},
+		},
+		Status: certificatesv1.CertificateSigningRequestStatus{
+			Conditions: []certificatesv1.CertificateSigningRequestCondition{
+				{
+					Type:           certificatesv1.CertificateApproved,
+					Status:         corev1.ConditionTrue,
+					Reason:         string(ReasonAutoApproved),
+					LastUpdateTime: metav1.NewTime(time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)),
+				},
+			},
+			Certificate: []byte("certificate"),
+		},
+	}
+	converted := toV1beta1CSR(csr)
+	if converted.Spec.SignerName == nil || *converted.Spec.SignerName != csr.Spec.SignerName {
+		t.Errorf("v1beta1 signer name = %v, want %q", converted.Spec.SignerName, csr.Spec.SignerName)
+	}
+	if got := fromV1beta1CSR(converted); !reflect.DeepEqual(got, csr) {
+		t.Errorf("fromV1beta1CSR(toV1beta1CSR()) = %v, want %v", got, csr)
+	}
+}
+
+func TestReconcileCSR_ReconcileAPIVersionDowngrade(t *testing.T) {
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: clusterName,
+		},
+		Spec: clusterv1.ManagedClusterSpec{
+			HubAcceptsClient: true,
+		},
+	}
+
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	request := newCSRRequest(t, "system:open-cluster-management:"+clusterName, nil)
+	first := newDedupCSR("csr-1", request)
+	second := newDedupCSR("csr-2", newCSRRequest(t, "system:open-cluster-management:"+clusterName, nil))
+
+	kubeClient := fakeclientset.NewSimpleClientset(first, second)
+	discovery := kubeClient.Discovery().(*fakediscovery.FakeDiscovery)
+	discovery.Resources = certificatesResources(certificatesV1, certificatesV1beta1)
+	v1Removed := false
+	approvals := map[string]string{}
+	kubeClient.PrependReactor("update", "certificatesigningrequests",
+		func(action clienttesting.Action) (bool, runtime.Object, error) {
+			update := action.(clienttesting.UpdateAction)
+			version := action.GetResource().Version
+			if version == certificatesV1 && v1Removed {
+				return true, nil, errors.NewNotFound(action.GetResource().GroupResource(), "")
+			}
+			if update.GetSubresource() == "approval" {
+				name := update.GetObject().(metav1.Object).GetName()
+				approvals[name] = version
+			}
+			// the tracker stores the csrs with their v1 resource
+			if version == certificatesV1beta1 {
+				return true, update.GetObject(), nil
+			}
+			return false, nil, nil
+		})
+
+	r := &ReconcileCSR{
+		client:      fake.NewFakeClientWithScheme(testscheme, first, second, testManagedCluster),
+		kubeClient:  kubeClient,
+		scheme:      testscheme,
+		apiVersions: newAPIVersionResolver(discovery, time.Hour),
+	}
+	reconcileCSR := func(name string) error {
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: name}})
+		return err
+	}
+
+	if err := reconcileCSR(first.Name); err != nil {
+		t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+	}
+	if version := approvals[first.Name]; version != certificatesV1 {
+		t.Errorf("CSR %s approved with version %q, want %q", first.Name, version, certificatesV1)
+	}
+
+	//The hub is downgraded, the v1 API disappears
+	v1Removed = true
+	discovery.Resources = certificatesResources(certificatesV1beta1)
+	for i := 0; i < maxAPIVersionErrors; i++ {
+		if err := reconcileCSR(second.Name); err == nil {
+			t.Fatalf("ReconcileCSR.Reconcile() attempt %d expected an error", i)
+		}
+	}
+	if err := reconcileCSR(second.Name); err != nil {
+		t.Fatalf("ReconcileCSR.Reconcile() error = %v, want the v1beta1 API used", err)
+	}
+	if version := approvals[second.Name]; version != certificatesV1beta1 {
+		t.Errorf("CSR %s approved with version %q, want %q", second.Name, version, certificatesV1beta1)
+	}
+}
+
+func Test_defaultV1beta1SignerName(t *testing.T) {
+	tests := []struct {
+		name   string
+		usages []certificatesv1beta1.KeyUsage
+		want   string
+	}{
+		{
+			name:   "client certificate",
+			usages: []certificatesv1beta1.KeyUsage{certificatesv1beta1.UsageDigitalSignature, certificatesv1beta1.UsageKeyEncipherment, certificatesv1beta1.UsageClientAuth},
+			want:   certificatesv1beta1.KubeAPIServerClientSignerName,
+		},
+		{
+			name:   "serving certificate",
+			usages: []certificatesv1beta1.KeyUsage{certificatesv1beta1.UsageDigitalSignature, certificatesv1beta1.UsageServerAuth},
+			want:   certificatesv1beta1.LegacyUnknownSignerName,
+		},
+		{
+			name: "no usages",
+			want: certificatesv1beta1.LegacyUnknownSignerName,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr := &certificatesv1beta1.CertificateSigningRequest{
+				Spec: certificatesv1beta1.CertificateSigningRequestSpec{Usages: tt.usages},
+			}
+			if got := fromV1beta1CSR(csr).Spec.SignerName; got != tt.want {
+				t.Errorf("signer name = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_discoverWatchVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		versions []string
+		want     string
+	}{
+		{
+			name:     "v1 served",
+			versions: []string{certificatesV1, certificatesV1beta1},
+			want:     certificatesV1,
+		},
+		{
+			name:     "v1beta1 served",
+			versions: []string{certificatesV1beta1},
+			want:     certificatesV1beta1,
+		},
+		{
+			name: "discovery failed",
+			want: certificatesV1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			discovery := fakeclientset.NewSimpleClientset().Discovery().(*fakediscovery.FakeDiscovery)
+			discovery.Resources = certificatesResources(tt.versions...)
+			r := &ReconcileCSR{apiVersions: newAPIVersionResolver(discovery, time.Hour)}
+			if got := r.discoverWatchVersion(time.Now()); got != tt.want {
+				t.Errorf("discoverWatchVersion() = %q, want %q", got, tt.want)
+			}
+			if r.watch.current() != tt.want {
+				t.Errorf("watched version = %q, want %q", r.watch.current(), tt.want)
+			}
+			if _, ok := newWatchedCSR(r.watch.current()).(*certificatesv1beta1.CertificateSigningRequest); ok != (tt.want == certificatesV1beta1) {
+				t.Errorf("watched csr type %T, want version %s", newWatchedCSR(r.watch.current()), tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_ReconcileV1beta1(t *testing.T) {
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: clusterName,
+		},
+		Spec: clusterv1.ManagedClusterSpec{
+			HubAcceptsClient: true,
+		},
+	}
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	// a csr of a hub serving v1beta1 only, created without a signer name
+	v1CSR := newDedupCSR(csrNameReconcile, newCSRRequest(t, "system:open-cluster-management:"+clusterName, nil))
+	v1CSR.Spec.Usages = []certificatesv1.KeyUsage{certificatesv1.UsageDigitalSignature, certificatesv1.UsageClientAuth}
+	csr := toV1beta1CSR(v1CSR)
+
+	kubeClient := fakeclientset.NewSimpleClientset()
+	discovery := kubeClient.Discovery().(*fakediscovery.FakeDiscovery)
+	discovery.Resources = certificatesResources(certificatesV1beta1)
+	var approved *certificatesv1beta1.CertificateSigningRequest
+	kubeClient.PrependReactor("update", "certificatesigningrequests",
+		func(action clienttesting.Action) (bool, runtime.Object, error) {
+			if version := action.GetResource().Version; version != certificatesV1beta1 {
+				t.Errorf("csr updated with version %q, want %q", version, certificatesV1beta1)
+			}
+			update := action.(clienttesting.UpdateAction)
+			if update.GetSubresource() == "approval" {
+				approved = update.GetObject().(*certificatesv1beta1.CertificateSigningRequest)
+			}
+			return true, update.GetObject(), nil
+		})
+
+	r := &ReconcileCSR{
+		client:      fake.NewFakeClientWithScheme(testscheme, csr, testManagedCluster),
+		kubeClient:  kubeClient,
+		scheme:      testscheme,
+		apiVersions: newAPIVersionResolver(discovery, time.Hour),
+	}
+	if version := r.discoverWatchVersion(time.Now()); version != certificatesV1beta1 {
+		t.Fatalf("discoverWatchVersion() = %q, want %q", version, certificatesV1beta1)
+	}
+	if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csr.Name}}); err != nil {
+		t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+	}
+	if approved == nil {
+		t.Fatal("the v1beta1 csr is not approved")
+	}
+	if approved.Spec.SignerName == nil || *approved.Spec.SignerName != certificatesv1beta1.KubeAPIServerClientSignerName {
+		t.Errorf("approved csr signer name = %v, want %s", approved.Spec.SignerName, certificatesv1beta1.KubeAPIServerClientSignerName)
+	}
+}
+
+func TestReconcileCSR_ReconcileAfterWatchVersionRemoved(t *testing.T) {
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: clusterName,
+		},
+		Spec: clusterv1.ManagedClusterSpec{
+			HubAcceptsClient: true,
+		},
+	}
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	kubeClient := fakeclientset.NewSimpleClientset()
+	discovery := kubeClient.Discovery().(*fakediscovery.FakeDiscovery)
+	discovery.Resources = certificatesResources(certificatesV1, certificatesV1beta1)
+	approvals := map[string]string{}
+	kubeClient.PrependReactor("update", "certificatesigningrequests",
+		func(action clienttesting.Action) (bool, runtime.Object, error) {
+			update := action.(clienttesting.UpdateAction)
+			if update.GetSubresource() == "approval" {
+				approvals[update.GetObject().(metav1.Object).GetName()] = action.GetResource().Version
+			}
+			return true, update.GetObject(), nil
+		})
+
+	r := &ReconcileCSR{
+		client:      fake.NewFakeClientWithScheme(testscheme, testManagedCluster),
+		kubeClient:  kubeClient,
+		scheme:      testscheme,
+		apiVersions: newAPIVersionResolver(discovery, time.Hour),
+	}
+	now := time.Now()
+	if version := r.discoverWatchVersion(now); version != certificatesV1 {
+		t.Fatalf("discoverWatchVersion() = %q, want %q", version, certificatesV1)
+	}
+	watched := []string{}
+	if err := r.watch.start(func(version string) error {
+		watched = append(watched, version)
+		return nil
+	}); err != nil {
+		t.Fatalf("csrWatch.start() error = %v", err)
+	}
+
+	//The hub is downgraded, the v1 API disappears
+	discovery.Resources = certificatesResources(certificatesV1beta1)
+	for i := 0; i < 2; i++ {
+		if err := r.switchWatchVersion(now.Add(2 * time.Hour)); err != nil {
+			t.Fatalf("switchWatchVersion() error = %v", err)
+		}
+	}
+	if want := []string{certificatesV1, certificatesV1beta1}; !reflect.DeepEqual(watched, want) {
+		t.Errorf("watched versions = %v, want %v", watched, want)
+	}
+	if version := r.watch.current(); version != certificatesV1beta1 {
+		t.Errorf("current watched version = %q, want %q", version, certificatesV1beta1)
+	}
+
+	// a csr created once the v1 API disappeared is only served with v1beta1
+	csr := toV1beta1CSR(newDedupCSR(csrNameReconcile, newCSRRequest(t, "system:open-cluster-management:"+clusterName, nil)))
+	if err := r.client.Create(context.TODO(), csr); err != nil {
+		t.Fatalf("failed to create the csr: %v", err)
+	}
+	if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csr.Name}}); err != nil {
+		t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+	}
+	if version := approvals[csr.Name]; version != certificatesV1beta1 {
+		t.Errorf("CSR %s approved with version %q, want %q", csr.Name, version, certificatesV1beta1)
+	}
+}
+
+func Test_getAPIVersionMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "not set",
+			want: apiVersionModeHub,
+		},
+		{
+			name:  "per-csr",
+			value: apiVersionModePerCSR,
+			want:  apiVersionModePerCSR,
+		},
+		{
+			name:    "invalid",
+			value:   "v1beta1",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(apiVersionModeEnvVarName, tt.value)
+			defer os.Unsetenv(apiVersionModeEnvVarName)
+			got, err := getAPIVersionMode()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getAPIVersionMode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("getAPIVersionMode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_ReconcileMixedAPIVersions(t *testing.T) {
+	const localClusterName = "local-cluster"
+	newClusterCSR := func(name, cluster string) *certificatesv1.CertificateSigningRequest {
+		csr := newDedupCSR(name, newCSRRequest(t, "system:open-cluster-management:"+cluster, nil))
+		csr.Labels[clusterLabel] = cluster
+		csr.Spec.Username = fmt.Sprintf(userNameSignature, cluster, cluster)
+		csr.Spec.Usages = []certificatesv1.KeyUsage{certificatesv1.UsageDigitalSignature, certificatesv1.UsageClientAuth}
+		return csr
+	}
+	// the local-cluster self-import csr is served with v1, the remote spoke csr with v1beta1
+	localCSR := newClusterCSR("csr-local", localClusterName)
+	remoteCSR := toV1beta1CSR(newClusterCSR("csr-remote", clusterName))
+
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	tests := []struct {
+		name string
+		mode string
+		want map[string]string
+	}{
+		{
+			name: "per-csr",
+			mode: apiVersionModePerCSR,
+			want: map[string]string{localCSR.Name: certificatesV1, remoteCSR.Name: certificatesV1beta1},
+		},
+		{
+			name: "hub",
+			mode: apiVersionModeHub,
+			want: map[string]string{localCSR.Name: certificatesV1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kubeClient := fakeclientset.NewSimpleClientset(localCSR.DeepCopy(), remoteCSR.DeepCopy())
+			discovery := kubeClient.Discovery().(*fakediscovery.FakeDiscovery)
+			discovery.Resources = certificatesResources(certificatesV1, certificatesV1beta1)
+			approvals := map[string]string{}
+			kubeClient.PrependReactor("update", "certificatesigningrequests",
+				func(action clienttesting.Action) (bool, runtime.Object, error) {
+					update := action.(clienttesting.UpdateAction)
+					if update.GetSubresource() == "approval" {
+						approvals[update.GetObject().(metav1.Object).GetName()] = action.GetResource().Version
+					}
+					return false, nil, nil
+				})
+
+			r := &ReconcileCSR{
+				client: fake.NewFakeClientWithScheme(testscheme, localCSR.DeepCopy(), remoteCSR.DeepCopy(),
+					&clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: localClusterName},
+						Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true}},
+					&clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: clusterName},
+						Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true}}),
+				kubeClient:  kubeClient,
+				scheme:      testscheme,
+				apiVersions: newAPIVersionResolver(discovery, time.Hour),
+				csrVersions: newCSRVersions(tt.mode),
+			}
+			if version := r.discoverWatchVersion(time.Now()); version != certificatesV1 {
+				t.Fatalf("discoverWatchVersion() = %q, want %q", version, certificatesV1)
+			}
+			for _, name := range []string{localCSR.Name, remoteCSR.Name} {
+				if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: name}}); err != nil {
+					t.Fatalf("ReconcileCSR.Reconcile(%s) error = %v", name, err)
+				}
+			}
+			if !reflect.DeepEqual(approvals, tt.want) {
+				t.Errorf("approval versions = %v, want %v", approvals, tt.want)
+			}
+		})
+	}
+}
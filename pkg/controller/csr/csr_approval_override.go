@@ -0,0 +1,74 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"fmt"
+	"regexp"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+)
+
+const (
+	// approvalReasonAnnotation overrides the reason of the approval condition of the csrs, set on the csr or on its
+	// ManagedCluster, for example with a ticket ID
+	approvalReasonAnnotation = "import.open-cluster-management.io/approval-reason"
+	// approvalMessageAnnotation overrides the message of the approval condition of the csrs, set on the csr or on its
+	// ManagedCluster, for example with a policy reference
+	approvalMessageAnnotation = "import.open-cluster-management.io/approval-message"
+)
+
+// the limits of the reason and the message of the conditions of the API
+const (
+	maxApprovalReasonLength  = 1024
+	maxApprovalMessageLength = 32768
+)
+
+// approvalReasonPattern is the format of the reasons of the conditions of the API
+var approvalReasonPattern = regexp.MustCompile(`^[A-Za-z]([A-Za-z0-9_,:]*[A-Za-z0-9_])?$`)
+
+// getApprovalOverride returns the value of the annotation on the csr, or on its cluster if not set on the csr,
+// empty if set on neither
+func getApprovalOverride(
+	csr *certificatesv1.CertificateSigningRequest,
+	cluster *clusterv1.ManagedCluster,
+	annotation string) string {
+	if v := csr.Annotations[annotation]; v != "" {
+		return v
+	}
+	if cluster != nil {
+		return cluster.Annotations[annotation]
+	}
+	return ""
+}
+
+// overrideApprovalCondition sets the reason and the message of the approval condition of the csr to the values of
+// the approvalReasonAnnotation and the approvalMessageAnnotation, the condition is not changed and an error is
+// returned if an override is not valid
+func overrideApprovalCondition(
+	condition *certificatesv1.CertificateSigningRequestCondition,
+	csr *certificatesv1.CertificateSigningRequest,
+	cluster *clusterv1.ManagedCluster) error {
+	reason := getApprovalOverride(csr, cluster, approvalReasonAnnotation)
+	if len(reason) > maxApprovalReasonLength {
+		return fmt.Errorf("invalid %s annotation, longer than %d characters", approvalReasonAnnotation,
+			maxApprovalReasonLength)
+	}
+	if reason != "" && !approvalReasonPattern.MatchString(reason) {
+		return fmt.Errorf("invalid %s annotation %q, must match %s", approvalReasonAnnotation, reason,
+			approvalReasonPattern.String())
+	}
+	message := getApprovalOverride(csr, cluster, approvalMessageAnnotation)
+	if len(message) > maxApprovalMessageLength {
+		return fmt.Errorf("invalid %s annotation, longer than %d characters", approvalMessageAnnotation,
+			maxApprovalMessageLength)
+	}
+	if reason != "" {
+		condition.Reason = reason
+	}
+	if message != "" {
+		condition.Message = message
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcileCSR_ReconcileApprovalOverride(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	const defaultMessagePrefix = "The managedcluster-import-controller auto approval automatically approved this CSR"
+	tests := []struct {
+		name               string
+		csrAnnotations     map[string]string
+		clusterAnnotations map[string]string
+		wantReason         string
+		wantMessage        string
+	}{
+		{
+			name:        "default",
+			wantReason:  string(ReasonAutoApproved),
+			wantMessage: defaultMessagePrefix,
+		},
+		{
+			name: "override on the csr",
+			csrAnnotations: map[string]string{
+				approvalReasonAnnotation:  "Ticket_OPS1234",
+				approvalMessageAnnotation: "Approved under the onboarding policy OPS-1234",
+			},
+			wantReason:  "Ticket_OPS1234",
+			wantMessage: "Approved under the onboarding policy OPS-1234",
+		},
+		{
+			name: "override inherited from the cluster",
+			clusterAnnotations: map[string]string{
+				approvalReasonAnnotation:  "Ticket_OPS5678",
+				approvalMessageAnnotation: "Approved under the fleet policy OPS-5678",
+			},
+			wantReason:  "Ticket_OPS5678",
+			wantMessage: "Approved under the fleet policy OPS-5678",
+		},
+		{
+			name:               "csr override over the cluster override",
+			csrAnnotations:     map[string]string{approvalReasonAnnotation: "Ticket_OPS1234"},
+			clusterAnnotations: map[string]string{approvalReasonAnnotation: "Ticket_OPS5678"},
+			wantReason:         "Ticket_OPS1234",
+			wantMessage:        defaultMessagePrefix,
+		},
+		{
+			name:           "invalid reason",
+			csrAnnotations: map[string]string{approvalReasonAnnotation: "OPS-1234 approved"},
+			wantReason:     string(ReasonAutoApproved),
+			wantMessage:    defaultMessagePrefix,
+		},
+		{
+			name: "message too long",
+			csrAnnotations: map[string]string{
+				approvalReasonAnnotation:  "Ticket_OPS1234",
+				approvalMessageAnnotation: strings.Repeat("a", maxApprovalMessageLength+1),
+			},
+			wantReason:  string(ReasonAutoApproved),
+			wantMessage: defaultMessagePrefix,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: clusterName, Annotations: tt.clusterAnnotations},
+				Spec:       clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+			}
+			csr := &certificatesv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        csrNameReconcile,
+					Labels:      map[string]string{clusterLabel: clusterName},
+					Annotations: tt.csrAnnotations,
+				},
+				Spec: certificatesv1.CertificateSigningRequestSpec{
+					Username:   fmt.Sprintf(userNameSignature, clusterName, clusterName),
+					Request:    newCSRRequest(t, clusterCommonNamePrefix+clusterName, nil),
+					SignerName: certificatesv1.KubeAPIServerClientSignerName,
+					Usages:     []certificatesv1.KeyUsage{certificatesv1.UsageClientAuth},
+				},
+			}
+			r := &ReconcileCSR{
+				client:       fake.NewFakeClientWithScheme(testscheme, csr.DeepCopy(), cluster),
+				kubeClient:   fakeclientset.NewSimpleClientset(csr.DeepCopy()),
+				scheme:       testscheme,
+				podNamespace: testPodNamespace,
+			}
+			if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}}); err != nil {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+			}
+			got, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), csrNameReconcile, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if getApprovalType(got) != string(certificatesv1.CertificateApproved) {
+				t.Fatalf("CSR approval = %q, want approved", getApprovalType(got))
+			}
+			condition := got.Status.Conditions[0]
+			if condition.Reason != tt.wantReason {
+				t.Errorf("approval condition reason = %q, want %q", condition.Reason, tt.wantReason)
+			}
+			if !strings.HasPrefix(condition.Message, tt.wantMessage) {
+				t.Errorf("approval condition message = %q, want %q", condition.Message, tt.wantMessage)
+			}
+			// the reason code stays the code of the auto approval
+			if code := got.Annotations[ReasonCodeAnnotation]; code != string(ReasonAutoApproved) {
+				t.Errorf("reason code annotation = %q, want %q", code, ReasonAutoApproved)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"bytes"
+	"fmt"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// approvalConflictRetry is the backoff of the approval updates conflicting with another update of the csr, for
+// example the annotations of a peer controller, the error is returned once its steps are exhausted
+var approvalConflictRetry = retry.DefaultRetry
+
+// getLatestCSR gets the csr from the API server with its certificates API version, bypassing the cache
+func (r *ReconcileCSR) getLatestCSR(
+	csr *certificatesv1.CertificateSigningRequest) (*certificatesv1.CertificateSigningRequest, error) {
+	ctx, cancel := r.apiCallContext()
+	defer cancel()
+	version, err := r.csrAPIVersion(csr)
+	if err != nil {
+		return nil, err
+	}
+	if version == certificatesV1beta1 {
+		latest, err := r.kubeClient.CertificatesV1beta1().CertificateSigningRequests().Get(ctx, csr.Name,
+			metav1.GetOptions{})
+		r.apiVersions.observe(err)
+		if err != nil {
+			return nil, err
+		}
+		return fromV1beta1CSR(latest), nil
+	}
+	latest, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(ctx, csr.Name, metav1.GetOptions{})
+	r.apiVersions.observe(err)
+	return latest, err
+}
+
+// verifyStillPending checks the latest version of the csr is still pending and still carries the request the
+// decision was taken on
+func verifyStillPending(csr, latest *certificatesv1.CertificateSigningRequest) error {
+	if approval := getApprovalType(latest); approval != "" {
+		return fmt.Errorf("the CSR %s is already %s", csr.Name, approval)
+	}
+	if latest.UID != csr.UID || latest.Spec.Username != csr.Spec.Username ||
+		!bytes.Equal(latest.Spec.Request, csr.Spec.Request) {
+		return fmt.Errorf("the CSR %s changed since it was evaluated", csr.Name)
+	}
+	return nil
+}
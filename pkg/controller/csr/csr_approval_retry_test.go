@@ -0,0 +1,135 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcileCSR_ReconcileApprovalConflict(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterName},
+		Spec:       clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+	}
+	csr := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   csrNameReconcile,
+			Labels: map[string]string{clusterLabel: clusterName},
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Username:   fmt.Sprintf(userNameSignature, clusterName, clusterName),
+			Request:    newCSRRequest(t, clusterCommonNamePrefix+clusterName, nil),
+			SignerName: certificatesv1.KubeAPIServerClientSignerName,
+			Usages:     []certificatesv1.KeyUsage{certificatesv1.UsageClientAuth},
+		},
+	}
+	denied := []certificatesv1.CertificateSigningRequestCondition{
+		{Type: certificatesv1.CertificateDenied, Status: corev1.ConditionTrue, Reason: "DeniedByAdmin"},
+	}
+
+	tests := []struct {
+		name string
+		// conflicts is the number of the approval updates failing with a conflict
+		conflicts int
+		// deniedOnConflict denies the csr in the tracker on the first conflict
+		deniedOnConflict bool
+		wantErr          bool
+		wantUpdates      int
+		wantApproval     string
+	}{
+		{
+			name:         "no conflict",
+			wantUpdates:  1,
+			wantApproval: string(certificatesv1.CertificateApproved),
+		},
+		{
+			name:         "conflict on the first update",
+			conflicts:    1,
+			wantUpdates:  2,
+			wantApproval: string(certificatesv1.CertificateApproved),
+		},
+		{
+			name:         "conflicts until the retries are exhausted",
+			conflicts:    approvalConflictRetry.Steps,
+			wantErr:      true,
+			wantUpdates:  approvalConflictRetry.Steps,
+			wantApproval: "",
+		},
+		{
+			name:             "csr denied by another update",
+			conflicts:        1,
+			deniedOnConflict: true,
+			wantErr:          true,
+			wantUpdates:      1,
+			wantApproval:     string(certificatesv1.CertificateDenied),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kubeClient := fakeclientset.NewSimpleClientset(csr.DeepCopy())
+			updates := 0
+			kubeClient.PrependReactor("update", "certificatesigningrequests",
+				func(action clienttesting.Action) (bool, runtime.Object, error) {
+					if action.GetSubresource() != "approval" {
+						return false, nil, nil
+					}
+					updates++
+					if updates > tt.conflicts {
+						return false, nil, nil
+					}
+					if tt.deniedOnConflict {
+						latest, err := kubeClient.Tracker().Get(action.GetResource(), "", csrNameReconcile)
+						if err != nil {
+							t.Fatal(err)
+						}
+						latest = latest.DeepCopyObject()
+						latest.(*certificatesv1.CertificateSigningRequest).Status.Conditions = denied
+						if err := kubeClient.Tracker().Update(action.GetResource(), latest, ""); err != nil {
+							t.Fatal(err)
+						}
+					}
+					return true, nil, errors.NewConflict(action.GetResource().GroupResource(), csrNameReconcile,
+						fmt.Errorf("the object has been modified"))
+				})
+			r := &ReconcileCSR{
+				client:       fake.NewFakeClientWithScheme(testscheme, csr.DeepCopy(), testManagedCluster),
+				kubeClient:   kubeClient,
+				scheme:       testscheme,
+				podNamespace: testPodNamespace,
+			}
+			_, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if updates != tt.wantUpdates {
+				t.Errorf("approval updates = %d, want %d", updates, tt.wantUpdates)
+			}
+			got, err := kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), csrNameReconcile, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if approval := getApprovalType(got); approval != tt.wantApproval {
+				t.Errorf("CSR approval = %q, want %q", approval, tt.wantApproval)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// controllerConfigEnvVarName is the name of the singleton ImportControllerConfig holding the approval
+// kill switch, the approval can not be halted if not set
+const controllerConfigEnvVarName = "IMPORT_CONTROLLER_CONFIG"
+
+var controllerConfigGVR = schema.GroupVersionResource{
+	Group:    "import.open-cluster-management.io",
+	Version:  "v1alpha1",
+	Resource: "importcontrollerconfigs",
+}
+
+var approvalEnabledGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "managedcluster_import_csr_approval_enabled",
+	Help: "Whether the CSR auto approval is enabled (1) or halted by the ImportControllerConfig (0)",
+})
+
+func init() {
+	approvalEnabledGauge.Set(1)
+	metrics.Registry.MustRegister(approvalEnabledGauge)
+}
+
+// states of the approval switch
+const (
+	approvalSwitchUnknown int32 = iota
+	approvalSwitchEnabled
+	approvalSwitchDisabled
+)
+
+// approvalSwitch halts the approval of all the csrs while disabled. Its state is unknown until the
+// ImportControllerConfig is loaded. A nil approvalSwitch is always enabled.
+type approvalSwitch struct {
+	state int32
+}
+
+// newApprovalSwitch returns the approval switch of the ImportControllerConfig, nil if configName is empty
+func newApprovalSwitch(configName string) *approvalSwitch {
+	if configName == "" {
+		return nil
+	}
+	return &approvalSwitch{}
+}
+
+// enabled returns whether the approval is enabled and false if the state is unknown
+func (s *approvalSwitch) enabled() (enabled, known bool) {
+	if s == nil {
+		return true, true
+	}
+	state := atomic.LoadInt32(&s.state)
+	return state == approvalSwitchEnabled, state != approvalSwitchUnknown
+}
+
+func (s *approvalSwitch) set(enabled bool) {
+	if enabled {
+		atomic.StoreInt32(&s.state, approvalSwitchEnabled)
+		approvalEnabledGauge.Set(1)
+		return
+	}
+	atomic.StoreInt32(&s.state, approvalSwitchDisabled)
+	approvalEnabledGauge.Set(0)
+}
+
+// reloadApprovalSwitch sets the approval switch from the spec of the ImportControllerConfig and reports
+// the effective state in its status. The approval is enabled if the config does not exist.
+// An emergency stop halts the approval and records the recently approved csrs for a revocation follow-up.
+func (r *ReconcileCSR) reloadApprovalSwitch(config *unstructured.Unstructured) error {
+	if config == nil {
+		log.Info("ImportControllerConfig not found, CSR approval enabled", "name", r.controllerConfigName)
+		r.approvalSwitch.set(true)
+		return nil
+	}
+	enabled, found, err := unstructured.NestedBool(config.Object, "spec", "approvalEnabled")
+	if err != nil {
+		log.Error(err, "Invalid ImportControllerConfig, keep the approval switch", "name", config.GetName())
+		return err
+	}
+	if !found {
+		enabled = true
+	}
+	stop, lookback, err := getEmergencyStop(config)
+	if err != nil {
+		log.Error(err, "Invalid ImportControllerConfig, keep the approval switch", "name", config.GetName())
+		return err
+	}
+	enabled = enabled && !stop
+	if current, known := r.approvalSwitch.enabled(); !known || current != enabled {
+		log.Info("CSR approval switched", "name", config.GetName(), "approvalEnabled", enabled, "emergencyStop", stop)
+	}
+	r.approvalSwitch.set(enabled)
+
+	config = config.DeepCopy()
+	changed, err := r.setEmergencyStopStatus(config, stop, lookback, time.Now())
+	if err != nil {
+		return err
+	}
+	if reported, found, _ := unstructured.NestedBool(config.Object, "status", "approvalEnabled"); !found || reported != enabled {
+		if err := unstructured.SetNestedField(config.Object, enabled, "status", "approvalEnabled"); err != nil {
+			return err
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	_, err = r.dynamicClient.Resource(controllerConfigGVR).UpdateStatus(context.TODO(), config, metav1.UpdateOptions{})
+	return err
+}
+
+// addApprovalSwitchWatch watches the ImportControllerConfig to switch the approval without restart
+func addApprovalSwitchWatch(mgr manager.Manager, r *ReconcileCSR) error {
+	if r.controllerConfigName == "" {
+		return nil
+	}
+	if r.dynamicClient == nil {
+		return fmt.Errorf("a dynamic client is required to watch the ImportControllerConfig %s", r.controllerConfigName)
+	}
+	fieldSelector := "metadata.name=" + r.controllerConfigName
+	lw := &toolscache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = fieldSelector
+			return r.dynamicClient.Resource(controllerConfigGVR).List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = fieldSelector
+			return r.dynamicClient.Resource(controllerConfigGVR).Watch(context.TODO(), options)
+		},
+	}
+	reload := func(obj interface{}) {
+		if config, ok := obj.(*unstructured.Unstructured); ok {
+			if err := r.reloadApprovalSwitch(config); err != nil {
+				log.Error(err, "Unable to reload the approval switch", "name", r.controllerConfigName)
+			}
+		}
+	}
+	store, informer := toolscache.NewInformer(lw, &unstructured.Unstructured{}, 0, toolscache.ResourceEventHandlerFuncs{
+		AddFunc: reload,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			reload(newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			_ = r.reloadApprovalSwitch(nil)
+		},
+	})
+	return mgr.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+		go informer.Run(stop)
+		if toolscache.WaitForCacheSync(stop, informer.HasSynced) && len(store.List()) == 0 {
+			_ = r.reloadApprovalSwitch(nil)
+		}
+		<-stop
+		return nil
+	}))
+}
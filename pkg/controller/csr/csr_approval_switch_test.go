@@ -0,0 +1,186 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const controllerConfigName = "import-controller-config"
+
+var switchEnabled, switchDisabled = true, false
+
+func newControllerConfig(approvalEnabled *bool) *unstructured.Unstructured {
+	config := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": controllerConfigGVR.GroupVersion().String(),
+		"kind":       "ImportControllerConfig",
+		"metadata": map[string]interface{}{
+			"name": controllerConfigName,
+		},
+	}}
+	if approvalEnabled != nil {
+		_ = unstructured.SetNestedField(config.Object, *approvalEnabled, "spec", "approvalEnabled")
+	}
+	return config
+}
+
+func Test_approvalSwitch_enabled(t *testing.T) {
+	tests := []struct {
+		name        string
+		sw          *approvalSwitch
+		set         *bool
+		wantEnabled bool
+		wantKnown   bool
+	}{
+		{
+			name:        "no switch",
+			wantEnabled: true,
+			wantKnown:   true,
+		},
+		{
+			name: "not loaded",
+			sw:   newApprovalSwitch(controllerConfigName),
+		},
+		{
+			name:        "enabled",
+			sw:          newApprovalSwitch(controllerConfigName),
+			set:         &switchEnabled,
+			wantEnabled: true,
+			wantKnown:   true,
+		},
+		{
+			name:      "disabled",
+			sw:        newApprovalSwitch(controllerConfigName),
+			set:       &switchDisabled,
+			wantKnown: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.set != nil {
+				tt.sw.set(*tt.set)
+			}
+			enabled, known := tt.sw.enabled()
+			if enabled != tt.wantEnabled || known != tt.wantKnown {
+				t.Errorf("enabled() = %v, %v, want %v, %v", enabled, known, tt.wantEnabled, tt.wantKnown)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_ReconcileApprovalSwitch(t *testing.T) {
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: clusterName,
+		},
+		Spec: clusterv1.ManagedClusterSpec{
+			HubAcceptsClient: true,
+		},
+	}
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	r := &ReconcileCSR{
+		scheme:               testscheme,
+		controllerConfigName: controllerConfigName,
+		dynamicClient:        dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), newControllerConfig(nil)),
+		approvalSwitch:       newApprovalSwitch(controllerConfigName),
+	}
+	reconcileCSR := func(t *testing.T, name string) (reconcile.Result, bool) {
+		csr := &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+				Labels: map[string]string{
+					clusterLabel: clusterName,
+				},
+			},
+			Spec: certificatesv1.CertificateSigningRequestSpec{
+				Username: fmt.Sprintf(userNameSignature, clusterName, clusterName),
+				Request:  newCSRRequest(t, "system:open-cluster-management:"+clusterName, nil),
+			},
+		}
+		r.client = fake.NewFakeClientWithScheme(testscheme, testManagedCluster, csr)
+		r.kubeClient = fakeclientset.NewSimpleClientset(csr)
+		res, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: name}})
+		if err != nil {
+			t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+		}
+		got, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res, getApprovalType(got) == string(certificatesv1.CertificateApproved)
+	}
+
+	steps := []struct {
+		name         string
+		config       *unstructured.Unstructured
+		wantApproved bool
+		wantGauge    float64
+	}{
+		{
+			name:         "switch not loaded",
+			wantApproved: false,
+		},
+		{
+			name:         "approval disabled",
+			config:       newControllerConfig(&switchDisabled),
+			wantApproved: false,
+			wantGauge:    0,
+		},
+		{
+			name:         "approval re-enabled",
+			config:       newControllerConfig(&switchEnabled),
+			wantApproved: true,
+			wantGauge:    1,
+		},
+		{
+			name:         "approval enabled by default",
+			config:       newControllerConfig(nil),
+			wantApproved: true,
+			wantGauge:    1,
+		},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if step.config != nil {
+				if err := r.reloadApprovalSwitch(step.config); err != nil {
+					t.Fatalf("reloadApprovalSwitch() error = %v", err)
+				}
+				got, err := r.dynamicClient.Resource(controllerConfigGVR).Get(context.TODO(), controllerConfigName, metav1.GetOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if reported, _, _ := unstructured.NestedBool(got.Object, "status", "approvalEnabled"); reported != step.wantApproved {
+					t.Errorf("status.approvalEnabled = %v, want %v", reported, step.wantApproved)
+				}
+				if gauge := testutil.ToFloat64(approvalEnabledGauge); gauge != step.wantGauge {
+					t.Errorf("approval enabled gauge = %v, want %v", gauge, step.wantGauge)
+				}
+			}
+			res, approved := reconcileCSR(t, csrNameReconcile)
+			if approved != step.wantApproved {
+				t.Errorf("CSR approved = %v, want %v", approved, step.wantApproved)
+			}
+			if !approved && !res.Requeue {
+				t.Errorf("CSR pending on the approval switch should be requeued, got %v", res)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+)
+
+const (
+	// approvalWebhookURLEnvVarName is the URL of the webhook deciding the approval of the csrs passing all the checks
+	// of the controller, no webhook is called if not set
+	approvalWebhookURLEnvVarName = "CSR_APPROVAL_WEBHOOK_URL"
+	// approvalWebhookTimeoutEnvVarName is the timeout of a call of the webhook
+	approvalWebhookTimeoutEnvVarName = "CSR_APPROVAL_WEBHOOK_TIMEOUT"
+	// approvalWebhookFailurePolicyEnvVarName is the outcome of the csrs whose webhook call failed
+	approvalWebhookFailurePolicyEnvVarName = "CSR_APPROVAL_WEBHOOK_FAILURE_POLICY"
+
+	// webhookFailurePolicyFail leaves the csr pending when the webhook call fails
+	webhookFailurePolicyFail = "fail"
+	// webhookFailurePolicyIgnore approves the csr when the webhook call fails
+	webhookFailurePolicyIgnore = "ignore"
+
+	defaultApprovalWebhookTimeout = 10 * time.Second
+	// approvalWebhookRequeuePeriod is the period the csrs left pending by a failed webhook call are evaluated again
+	approvalWebhookRequeuePeriod = 30 * time.Second
+	// maxApprovalWebhookResponseSize is the size limit of the verdict of the webhook
+	maxApprovalWebhookResponseSize = 64 * 1024
+)
+
+// approvalWebhookRequest is the JSON body posted to the webhook for a csr
+type approvalWebhookRequest struct {
+	Name        string                 `json:"name"`
+	ClusterName string                 `json:"clusterName"`
+	Username    string                 `json:"username"`
+	Groups      []string               `json:"groups,omitempty"`
+	SignerName  string                 `json:"signerName"`
+	Usages      []string               `json:"usages,omitempty"`
+	Subject     approvalWebhookSubject `json:"subject"`
+	DNSNames    []string               `json:"dnsNames,omitempty"`
+	URIs        []string               `json:"uris,omitempty"`
+}
+
+// approvalWebhookSubject is the parsed subject of the certificate request of the csr
+type approvalWebhookSubject struct {
+	CommonName          string   `json:"commonName"`
+	Organizations       []string `json:"organizations,omitempty"`
+	OrganizationalUnits []string `json:"organizationalUnits,omitempty"`
+}
+
+// approvalWebhookVerdict is the JSON verdict of the webhook
+type approvalWebhookVerdict struct {
+	// Allowed approves the csr if true, and denies it else
+	Allowed bool `json:"allowed"`
+	// Reason is the message of the denial of the csr
+	Reason string `json:"reason,omitempty"`
+}
+
+// approvalWebhook posts the csrs passing all the checks of the controller to an external policy engine which
+// approves or denies them. A nil approvalWebhook allows all the csrs.
+type approvalWebhook struct {
+	url           string
+	failurePolicy string
+	httpClient    *http.Client
+}
+
+// getApprovalWebhook returns the webhook configured by the CSR_APPROVAL_WEBHOOK env vars, nil if no URL is set
+func getApprovalWebhook() (*approvalWebhook, error) {
+	endpoint := os.Getenv(approvalWebhookURLEnvVarName)
+	if endpoint == "" {
+		return nil, nil
+	}
+	if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid %s value %q, must be an http or https URL", approvalWebhookURLEnvVarName, endpoint)
+	}
+
+	timeout := defaultApprovalWebhookTimeout
+	if v := os.Getenv(approvalWebhookTimeoutEnvVarName); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid %s value %q, must be a positive duration", approvalWebhookTimeoutEnvVarName, v)
+		}
+		timeout = d
+	}
+
+	failurePolicy := webhookFailurePolicyFail
+	switch v := os.Getenv(approvalWebhookFailurePolicyEnvVarName); v {
+	case "":
+	case webhookFailurePolicyFail, webhookFailurePolicyIgnore:
+		failurePolicy = v
+	default:
+		return nil, fmt.Errorf("invalid %s value %q, must be %s or %s",
+			approvalWebhookFailurePolicyEnvVarName, v, webhookFailurePolicyFail, webhookFailurePolicyIgnore)
+	}
+
+	return &approvalWebhook{
+		url:           endpoint,
+		failurePolicy: failurePolicy,
+		httpClient:    &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// failsOpen checks the csrs whose webhook call failed are approved
+func (w *approvalWebhook) failsOpen() bool {
+	return w.failurePolicy == webhookFailurePolicyIgnore
+}
+
+// review posts the csr and its parsed request to the webhook with ctx and returns its verdict,
+// a nil webhook allows the csr
+func (w *approvalWebhook) review(
+	ctx context.Context,
+	csr *certificatesv1.CertificateSigningRequest,
+	x509cr *x509.CertificateRequest,
+	clusterName string) (*approvalWebhookVerdict, error) {
+	if w == nil {
+		return &approvalWebhookVerdict{Allowed: true}, nil
+	}
+
+	request := approvalWebhookRequest{
+		Name:        csr.Name,
+		ClusterName: clusterName,
+		Username:    csr.Spec.Username,
+		Groups:      csr.Spec.Groups,
+		SignerName:  csr.Spec.SignerName,
+		Subject: approvalWebhookSubject{
+			CommonName:          x509cr.Subject.CommonName,
+			Organizations:       x509cr.Subject.Organization,
+			OrganizationalUnits: x509cr.Subject.OrganizationalUnit,
+		},
+		DNSNames: x509cr.DNSNames,
+	}
+	for _, usage := range csr.Spec.Usages {
+		request.Usages = append(request.Usages, string(usage))
+	}
+	for _, uri := range x509cr.URIs {
+		request.URIs = append(request.URIs, uri.String())
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		return nil, fmt.Errorf("the approval webhook returned the status %d", resp.StatusCode)
+	}
+
+	verdict := &approvalWebhookVerdict{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxApprovalWebhookResponseSize)).Decode(verdict); err != nil {
+		return nil, fmt.Errorf("invalid verdict of the approval webhook: %v", err)
+	}
+	return verdict, nil
+}
@@ -0,0 +1,234 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func Test_getApprovalWebhook(t *testing.T) {
+	tests := []struct {
+		name              string
+		url               string
+		timeout           string
+		failurePolicy     string
+		wantNil           bool
+		wantTimeout       time.Duration
+		wantFailurePolicy string
+		wantErr           bool
+	}{
+		{
+			name:    "not set",
+			wantNil: true,
+		},
+		{
+			name:              "defaults",
+			url:               "https://policy.example.com/csr",
+			wantTimeout:       defaultApprovalWebhookTimeout,
+			wantFailurePolicy: webhookFailurePolicyFail,
+		},
+		{
+			name:              "timeout and failure policy",
+			url:               "http://policy:8080/csr",
+			timeout:           "2s",
+			failurePolicy:     webhookFailurePolicyIgnore,
+			wantTimeout:       2 * time.Second,
+			wantFailurePolicy: webhookFailurePolicyIgnore,
+		},
+		{
+			name:    "invalid url",
+			url:     "policy:8080",
+			wantErr: true,
+		},
+		{
+			name:    "invalid timeout",
+			url:     "http://policy:8080/csr",
+			timeout: "-1s",
+			wantErr: true,
+		},
+		{
+			name:          "invalid failure policy",
+			url:           "http://policy:8080/csr",
+			failurePolicy: "approve",
+			wantErr:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(approvalWebhookURLEnvVarName, tt.url)
+			defer os.Unsetenv(approvalWebhookURLEnvVarName)
+			os.Setenv(approvalWebhookTimeoutEnvVarName, tt.timeout)
+			defer os.Unsetenv(approvalWebhookTimeoutEnvVarName)
+			os.Setenv(approvalWebhookFailurePolicyEnvVarName, tt.failurePolicy)
+			defer os.Unsetenv(approvalWebhookFailurePolicyEnvVarName)
+			got, err := getApprovalWebhook()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getApprovalWebhook() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (got == nil) != tt.wantNil {
+				t.Fatalf("getApprovalWebhook() = %v, wantNil %v", got, tt.wantNil)
+			}
+			if got == nil {
+				return
+			}
+			if got.httpClient.Timeout != tt.wantTimeout {
+				t.Errorf("getApprovalWebhook() timeout = %v, want %v", got.httpClient.Timeout, tt.wantTimeout)
+			}
+			if got.failurePolicy != tt.wantFailurePolicy {
+				t.Errorf("getApprovalWebhook() failurePolicy = %q, want %q", got.failurePolicy, tt.wantFailurePolicy)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_ReconcileApprovalWebhook(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterName},
+		Spec:       clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+	}
+	csr := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   csrNameReconcile,
+			Labels: map[string]string{clusterLabel: clusterName},
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Username:   fmt.Sprintf(userNameSignature, clusterName, clusterName),
+			Request:    newCSRRequest(t, clusterCommonNamePrefix+clusterName, nil),
+			SignerName: certificatesv1.KubeAPIServerClientSignerName,
+			Usages:     []certificatesv1.KeyUsage{certificatesv1.UsageClientAuth},
+		},
+	}
+
+	verdictHandler := func(verdict approvalWebhookVerdict) http.HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			request := approvalWebhookRequest{}
+			if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+				t.Errorf("invalid webhook request: %v", err)
+			}
+			if request.ClusterName != clusterName || request.Subject.CommonName != clusterCommonNamePrefix+clusterName {
+				t.Errorf("webhook request = %+v, want the cluster %s and its common name", request, clusterName)
+			}
+			_ = json.NewEncoder(w).Encode(verdict)
+		}
+	}
+	slowHandler := func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		_ = json.NewEncoder(w).Encode(approvalWebhookVerdict{Allowed: true})
+	}
+
+	tests := []struct {
+		name          string
+		handler       http.HandlerFunc
+		failurePolicy string
+		noWebhook     bool
+		wantRequeue   bool
+		wantCode      ReasonCode
+		wantMessage   string
+		wantApproval  string
+	}{
+		{
+			name:         "no webhook",
+			noWebhook:    true,
+			wantCode:     ReasonAutoApproved,
+			wantApproval: string(certificatesv1.CertificateApproved),
+		},
+		{
+			name:         "approved by the webhook",
+			handler:      verdictHandler(approvalWebhookVerdict{Allowed: true}),
+			wantCode:     ReasonAutoApproved,
+			wantApproval: string(certificatesv1.CertificateApproved),
+		},
+		{
+			name:         "denied by the webhook",
+			handler:      verdictHandler(approvalWebhookVerdict{Reason: "cluster1 is not in the inventory"}),
+			wantCode:     ReasonWebhookDenied,
+			wantMessage:  "cluster1 is not in the inventory",
+			wantApproval: string(certificatesv1.CertificateDenied),
+		},
+		{
+			name:          "webhook timeout fails closed",
+			handler:       slowHandler,
+			failurePolicy: webhookFailurePolicyFail,
+			wantRequeue:   true,
+			wantCode:      ReasonWebhookUnavailable,
+		},
+		{
+			name:          "webhook timeout fails open",
+			handler:       slowHandler,
+			failurePolicy: webhookFailurePolicyIgnore,
+			wantCode:      ReasonAutoApproved,
+			wantApproval:  string(certificatesv1.CertificateApproved),
+		},
+		{
+			name: "webhook error fails closed",
+			handler: func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			failurePolicy: webhookFailurePolicyFail,
+			wantRequeue:   true,
+			wantCode:      ReasonWebhookUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ReconcileCSR{
+				client:       fake.NewFakeClientWithScheme(testscheme, csr.DeepCopy(), testManagedCluster),
+				kubeClient:   fakeclientset.NewSimpleClientset(csr.DeepCopy()),
+				scheme:       testscheme,
+				podNamespace: testPodNamespace,
+			}
+			if !tt.noWebhook {
+				server := httptest.NewServer(tt.handler)
+				defer server.Close()
+				r.approvalWebhook = &approvalWebhook{
+					url:           server.URL,
+					failurePolicy: tt.failurePolicy,
+					httpClient:    &http.Client{Timeout: 50 * time.Millisecond},
+				}
+			}
+			result, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}})
+			if err != nil {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+			}
+			if result.Requeue != tt.wantRequeue {
+				t.Errorf("ReconcileCSR.Reconcile() requeue = %v, want %v", result.Requeue, tt.wantRequeue)
+			}
+			got, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), csrNameReconcile, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code := got.Annotations[ReasonCodeAnnotation]; code != string(tt.wantCode) {
+				t.Errorf("reason code annotation = %q, want %q", code, tt.wantCode)
+			}
+			if tt.wantMessage != "" && got.Annotations[ReasonMessageAnnotation] != tt.wantMessage {
+				t.Errorf("reason message annotation = %q, want %q", got.Annotations[ReasonMessageAnnotation], tt.wantMessage)
+			}
+			if approval := getApprovalType(got); approval != tt.wantApproval {
+				t.Errorf("CSR approval = %q, want %q", approval, tt.wantApproval)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+)
+
+const (
+	// approvalWindowEnvVarName is the duration after the creation of a ManagedCluster during which its csrs are
+	// approved, the later csrs are left for a manual approval. The csrs are approved whenever they are created if
+	// not set.
+	approvalWindowEnvVarName = "CSR_APPROVAL_WINDOW"
+	// approvalWindowExemptUsernamesEnvVarName is a comma separated list of the templates of the usernames whose csrs
+	// are approved after the approval window, for example the service account rotating the certificates of a cluster
+	approvalWindowExemptUsernamesEnvVarName = "CSR_APPROVAL_WINDOW_EXEMPT_USERNAMES"
+)
+
+// approvalWindow limits the approval of the csrs of a cluster to the csrs created within window after the cluster,
+// so a leaked bootstrap token can not join the cluster again later. A nil approvalWindow approves all the csrs.
+type approvalWindow struct {
+	window time.Duration
+	// exemptUsernames are the templates of the usernames whose csrs are approved after the window, nil if none
+	exemptUsernames usernameTemplates
+}
+
+// getApprovalWindow returns the window of the CSR_APPROVAL_WINDOW and its exempted usernames, nil if not set
+func getApprovalWindow() (*approvalWindow, error) {
+	v := os.Getenv(approvalWindowEnvVarName)
+	if v == "" {
+		return nil, nil
+	}
+	window, err := time.ParseDuration(v)
+	if err != nil || window <= 0 {
+		return nil, fmt.Errorf("invalid %s value %q, must be a positive duration", approvalWindowEnvVarName, v)
+	}
+	exemptUsernames, err := getUsernameTemplatesEnv(approvalWindowExemptUsernamesEnvVarName)
+	if err != nil {
+		return nil, err
+	}
+	return &approvalWindow{window: window, exemptUsernames: exemptUsernames}, nil
+}
+
+// verify checks the csr is created within the window after its cluster or is requested by an exempted username
+func (w *approvalWindow) verify(csr *certificatesv1.CertificateSigningRequest, cluster *clusterv1.ManagedCluster) error {
+	if w == nil {
+		return nil
+	}
+	if w.exemptUsernames != nil && w.exemptUsernames.matches(csr.Spec.Username, cluster.Name) {
+		return nil
+	}
+	closedAt := cluster.CreationTimestamp.Add(w.window)
+	if csr.CreationTimestamp.Time.After(closedAt) {
+		return fmt.Errorf("the CSR is created after the approval window of the ManagedCluster %s closed at %s, "+
+			"it must be approved manually", cluster.Name, closedAt.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
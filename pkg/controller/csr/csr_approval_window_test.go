@@ -0,0 +1,186 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func Test_getApprovalWindow(t *testing.T) {
+	tests := []struct {
+		name            string
+		window          string
+		exemptUsernames string
+		wantNil         bool
+		wantWindow      time.Duration
+		wantExempt      int
+		wantErr         bool
+	}{
+		{
+			name:    "not set",
+			wantNil: true,
+		},
+		{
+			name:       "window",
+			window:     "24h",
+			wantWindow: 24 * time.Hour,
+		},
+		{
+			name:            "window with exempted usernames",
+			window:          "1h",
+			exemptUsernames: "system:serviceaccount:{{.ClusterName}}:rotation-sa",
+			wantWindow:      time.Hour,
+			wantExempt:      1,
+		},
+		{
+			name:    "invalid window",
+			window:  "0s",
+			wantErr: true,
+		},
+		{
+			name:            "invalid exempted username",
+			window:          "1h",
+			exemptUsernames: "{{.Cluster}}",
+			wantErr:         true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(approvalWindowEnvVarName, tt.window)
+			defer os.Unsetenv(approvalWindowEnvVarName)
+			os.Setenv(approvalWindowExemptUsernamesEnvVarName, tt.exemptUsernames)
+			defer os.Unsetenv(approvalWindowExemptUsernamesEnvVarName)
+			got, err := getApprovalWindow()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getApprovalWindow() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (got == nil) != tt.wantNil {
+				t.Fatalf("getApprovalWindow() = %v, wantNil %v", got, tt.wantNil)
+			}
+			if got == nil {
+				return
+			}
+			if got.window != tt.wantWindow {
+				t.Errorf("getApprovalWindow() window = %v, want %v", got.window, tt.wantWindow)
+			}
+			if len(got.exemptUsernames) != tt.wantExempt {
+				t.Errorf("getApprovalWindow() exempted usernames = %d, want %d", len(got.exemptUsernames), tt.wantExempt)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_ReconcileApprovalWindow(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	clusterCreated := time.Now().Add(-48 * time.Hour)
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterName, CreationTimestamp: metav1.NewTime(clusterCreated)},
+		Spec:       clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+	}
+	rotationUsername := fmt.Sprintf("system:serviceaccount:%s:rotation-sa", clusterName)
+	rotationTemplate, err := parseUsernameTemplate("system:serviceaccount:{{.ClusterName}}:rotation-sa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bootstrapTemplate, err := parseUsernameTemplate(defaultUsernameTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	window := &approvalWindow{window: 24 * time.Hour, exemptUsernames: usernameTemplates{rotationTemplate}}
+
+	newCSR := func(username string, created time.Time) *certificatesv1.CertificateSigningRequest {
+		return &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              csrNameReconcile,
+				Labels:            map[string]string{clusterLabel: clusterName},
+				CreationTimestamp: metav1.NewTime(created),
+			},
+			Spec: certificatesv1.CertificateSigningRequestSpec{
+				Username:   username,
+				Request:    newCSRRequest(t, clusterCommonNamePrefix+clusterName, nil),
+				SignerName: certificatesv1.KubeAPIServerClientSignerName,
+				Usages:     []certificatesv1.KeyUsage{certificatesv1.UsageClientAuth},
+			},
+		}
+	}
+	bootstrapUsername := fmt.Sprintf(userNameSignature, clusterName, clusterName)
+
+	tests := []struct {
+		name         string
+		csr          *certificatesv1.CertificateSigningRequest
+		window       *approvalWindow
+		wantCode     ReasonCode
+		wantApproval string
+	}{
+		{
+			name:         "csr in the window",
+			csr:          newCSR(bootstrapUsername, clusterCreated.Add(time.Hour)),
+			window:       window,
+			wantCode:     ReasonAutoApproved,
+			wantApproval: string(certificatesv1.CertificateApproved),
+		},
+		{
+			name:     "csr out of the window",
+			csr:      newCSR(bootstrapUsername, clusterCreated.Add(25*time.Hour)),
+			window:   window,
+			wantCode: ReasonApprovalWindowExpired,
+		},
+		{
+			name:         "rotation csr out of the window",
+			csr:          newCSR(rotationUsername, clusterCreated.Add(25*time.Hour)),
+			window:       window,
+			wantCode:     ReasonAutoApproved,
+			wantApproval: string(certificatesv1.CertificateApproved),
+		},
+		{
+			name:         "csr out of the window without window",
+			csr:          newCSR(bootstrapUsername, clusterCreated.Add(25*time.Hour)),
+			wantCode:     ReasonAutoApproved,
+			wantApproval: string(certificatesv1.CertificateApproved),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ReconcileCSR{
+				client:            fake.NewFakeClientWithScheme(testscheme, tt.csr.DeepCopy(), testManagedCluster),
+				kubeClient:        fakeclientset.NewSimpleClientset(tt.csr.DeepCopy()),
+				scheme:            testscheme,
+				podNamespace:      testPodNamespace,
+				usernameTemplates: usernameTemplates{bootstrapTemplate, rotationTemplate},
+				approvalWindow:    tt.window,
+			}
+			if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}}); err != nil {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+			}
+			got, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), csrNameReconcile, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code := got.Annotations[ReasonCodeAnnotation]; code != string(tt.wantCode) {
+				t.Errorf("reason code annotation = %q, want %q", code, tt.wantCode)
+			}
+			if approval := getApprovalType(got); approval != tt.wantApproval {
+				t.Errorf("CSR approval = %q, want %q", approval, tt.wantApproval)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// attestationKeySecretEnvVarName is the secret of the POD_NAMESPACE holding the ECDSA P-256 private key
+	// signing the attestations of the approval decisions, no attestation is produced if not set
+	attestationKeySecretEnvVarName = "CSR_ATTESTATION_KEY_SECRET"
+	// attestationKeySecretKey is the data key of the PEM encoded private key in the attestation key secret
+	attestationKeySecretKey = "key.pem"
+
+	// attestationLabel labels the configmaps holding the attestations
+	attestationLabel = "import.open-cluster-management.io/csr-attestation"
+	// attestationDataKey is the data key of the compact JWS in the attestation configmaps
+	attestationDataKey = "attestation"
+)
+
+// approvalAttestation is the payload of the attestation of an approval decision
+type approvalAttestation struct {
+	CSRName            string `json:"csrName"`
+	ClusterName        string `json:"clusterName"`
+	RequestFingerprint string `json:"requestFingerprint"`
+	Decision           string `json:"decision"`
+	Reason             string `json:"reason"`
+	Message            string `json:"message"`
+	DecidedAt          string `json:"decidedAt"`
+}
+
+// jwsHeader is the protected header of the attestations, kid is the hex encoded SHA-256 of the DER encoded
+// public key
+type jwsHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// parseAttestationKey parses the PEM encoded ECDSA P-256 private key, in the SEC 1 or PKCS #8 form
+func parseAttestationKey(data []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+	var key *ecdsa.PrivateKey
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		k, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key = k
+	case "PRIVATE KEY":
+		k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		ecKey, ok := k.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("the key is not an ECDSA key")
+		}
+		key = ecKey
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if key.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("the key must be a P-256 key")
+	}
+	return key, nil
+}
+
+// attestationKeyID returns the key id of the public key of the attestations
+func attestationKeyID(key *ecdsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// signAttestation returns the ES256 compact JWS of the payload
+func signAttestation(payload interface{}, key *ecdsa.PrivateKey) (string, error) {
+	keyID, err := attestationKeyID(&key.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	header, err := json.Marshal(jwsHeader{Algorithm: "ES256", KeyID: keyID})
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(body)
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	// the ES256 signature is the concatenation of r and s, each padded to 32 bytes
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// attestationName returns the name of the configmap holding the attestation of the csr
+func attestationName(csr *certificatesv1.CertificateSigningRequest) string {
+	return "csr-attestation-" + csr.Name
+}
+
+// attestDecision signs the attestation of the approval decision on the csr with the key of the attestation key
+// secret and writes it in a configmap of the podNamespace. It is called before the decision is applied so that
+// no decision is applied without its attestation.
+func (r *ReconcileCSR) attestDecision(
+	csr *certificatesv1.CertificateSigningRequest,
+	clusterName string,
+	condition certificatesv1.CertificateSigningRequestCondition,
+	now time.Time) error {
+	if r.attestationKeySecretName == "" {
+		return nil
+	}
+	ctx, cancel := r.apiCallContext()
+	defer cancel()
+	secret, err := r.kubeClient.CoreV1().Secrets(r.podNamespace).Get(
+		ctx, r.attestationKeySecretName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	key, err := parseAttestationKey(secret.Data[attestationKeySecretKey])
+	if err != nil {
+		return fmt.Errorf("invalid %s in secret %s/%s: %v",
+			attestationKeySecretKey, r.podNamespace, r.attestationKeySecretName, err)
+	}
+	jws, err := signAttestation(approvalAttestation{
+		CSRName:            csr.Name,
+		ClusterName:        clusterName,
+		RequestFingerprint: requestFingerprint(csr),
+		Decision:           string(condition.Type),
+		Reason:             condition.Reason,
+		Message:            condition.Message,
+		DecidedAt:          now.UTC().Format(time.RFC3339),
+	}, key)
+	if err != nil {
+		return err
+	}
+
+	configMaps := r.kubeClient.CoreV1().ConfigMaps(r.podNamespace)
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      attestationName(csr),
+			Namespace: r.podNamespace,
+			Labels: map[string]string{
+				attestationLabel: "true",
+				clusterLabel:     clusterName,
+			},
+		},
+		Data: map[string]string{
+			attestationDataKey: jws,
+		},
+	}
+	_, err = configMaps.Create(ctx, configMap, metav1.CreateOptions{})
+	if errors.IsAlreadyExists(err) {
+		_, err = configMaps.Update(ctx, configMap, metav1.UpdateOptions{})
+	}
+	return err
+}
@@ -0,0 +1,252 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const testAttestationKeySecretName = "csr-attestation-key"
+
+// verifyAttestation verifies the ES256 compact JWS with the public key and returns its payload
+func verifyAttestation(jws string, key *ecdsa.PublicKey) (*approvalAttestation, error) {
+	parts := strings.Split(jws, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("not a compact JWS")
+	}
+	header := jwsHeader{}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Algorithm != "ES256" {
+		return nil, fmt.Errorf("unexpected algorithm %q", header.Algorithm)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(signature) != 64 {
+		return nil, fmt.Errorf("invalid signature")
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(key, digest[:], r, s) {
+		return nil, fmt.Errorf("signature verification failed")
+	}
+	payload := &approvalAttestation{}
+	return payload, decodeSegment(parts[1], payload)
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func newAttestationKey(t *testing.T) (*ecdsa.PrivateKey, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func Test_parseAttestationKey(t *testing.T) {
+	_, pkcs8 := newAttestationKey(t)
+	key, _ := newAttestationKey(t)
+	sec1, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384DER, err := x509.MarshalECPrivateKey(p384)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{
+			name: "pkcs8",
+			data: pkcs8,
+		},
+		{
+			name: "sec1",
+			data: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1}),
+		},
+		{
+			name:    "not pem",
+			data:    []byte("key"),
+			wantErr: true,
+		},
+		{
+			name:    "unsupported block",
+			data:    pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: []byte("key")}),
+			wantErr: true,
+		},
+		{
+			name:    "not a P-256 key",
+			data:    pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: p384DER}),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseAttestationKey(tt.data); (err != nil) != tt.wantErr {
+				t.Errorf("parseAttestationKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_ReconcileAttestation(t *testing.T) {
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: clusterName,
+		},
+		Spec: clusterv1.ManagedClusterSpec{
+			HubAcceptsClient: true,
+		},
+	}
+
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	key, keyPEM := newAttestationKey(t)
+	otherKey, _ := newAttestationKey(t)
+	newKeySecret := func(data []byte) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      testAttestationKeySecretName,
+				Namespace: testPodNamespace,
+			},
+			Data: map[string][]byte{
+				attestationKeySecretKey: data,
+			},
+		}
+	}
+	request := newCSRRequest(t, "system:open-cluster-management:"+clusterName, nil)
+	crossNamespaceCSR := newDedupCSR(csrNameReconcile, request)
+	crossNamespaceCSR.Spec.Username = fmt.Sprintf(userNameSignature, "other-cluster", "other-cluster")
+
+	tests := []struct {
+		name         string
+		csr          *certificatesv1.CertificateSigningRequest
+		keySecret    *corev1.Secret
+		wantDecision string
+		wantErr      bool
+	}{
+		{
+			name:         "approval attested",
+			csr:          newDedupCSR(csrNameReconcile, request),
+			keySecret:    newKeySecret(keyPEM),
+			wantDecision: string(certificatesv1.CertificateApproved),
+		},
+		{
+			name:         "denial attested",
+			csr:          crossNamespaceCSR,
+			keySecret:    newKeySecret(keyPEM),
+			wantDecision: string(certificatesv1.CertificateDenied),
+		},
+		{
+			name:    "missing key secret",
+			csr:     newDedupCSR(csrNameReconcile, request),
+			wantErr: true,
+		},
+		{
+			name:      "invalid key",
+			csr:       newDedupCSR(csrNameReconcile, request),
+			keySecret: newKeySecret([]byte("key")),
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := []runtime.Object{tt.csr}
+			if tt.keySecret != nil {
+				objs = append(objs, tt.keySecret)
+			}
+			r := &ReconcileCSR{
+				client:                   fake.NewFakeClientWithScheme(testscheme, tt.csr, testManagedCluster),
+				kubeClient:               fakeclientset.NewSimpleClientset(objs...),
+				scheme:                   testscheme,
+				podNamespace:             testPodNamespace,
+				attestationKeySecretName: testAttestationKeySecretName,
+			}
+			_, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			csr, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(),
+				csrNameReconcile, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			configMap, err := r.kubeClient.CoreV1().ConfigMaps(testPodNamespace).Get(context.TODO(),
+				attestationName(csr), metav1.GetOptions{})
+			if tt.wantErr {
+				if approval := getApprovalType(csr); approval != "" {
+					t.Errorf("CSR approval = %q, want none without attestation", approval)
+				}
+				if err == nil {
+					t.Error("no attestation expected")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("attestation configmap error = %v", err)
+			}
+			if approval := getApprovalType(csr); approval != tt.wantDecision {
+				t.Errorf("CSR approval = %q, want %q", approval, tt.wantDecision)
+			}
+
+			jws := configMap.Data[attestationDataKey]
+			attestation, err := verifyAttestation(jws, &key.PublicKey)
+			if err != nil {
+				t.Fatalf("attestation verification error = %v", err)
+			}
+			if attestation.CSRName != csrNameReconcile || attestation.ClusterName != clusterName ||
+				attestation.Decision != tt.wantDecision || attestation.RequestFingerprint != requestFingerprint(csr) {
+				t.Errorf("attestation = %+v", attestation)
+			}
+			if _, err := verifyAttestation(jws, &otherKey.PublicKey); err == nil {
+				t.Error("the attestation must not verify against another key")
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// auditConfigMapEnvVarName is the name of the configmap of the POD_NAMESPACE the approval decisions are
+	// recorded in, the decisions are not recorded if not set
+	auditConfigMapEnvVarName = "CSR_AUDIT_CONFIGMAP"
+	// auditFlushIntervalEnvVarName is the period of the writes of the buffered decisions in the audit configmap
+	auditFlushIntervalEnvVarName = "CSR_AUDIT_FLUSH_INTERVAL"
+	// auditFlushEntriesEnvVarName is the number of buffered decisions written without waiting for the period
+	auditFlushEntriesEnvVarName = "CSR_AUDIT_FLUSH_ENTRIES"
+
+	defaultAuditFlushInterval = 10 * time.Second
+	defaultAuditFlushEntries  = 100
+
+	// maxAuditConfigMapSize is the size of the audit configmap data above which the recorded decisions are
+	// archived in a new configmap, below the 1MiB limit of the objects
+	maxAuditConfigMapSize = 512 * 1024
+	// auditLabel labels the audit configmap and its archives
+	auditLabel = "import.open-cluster-management.io/csr-audit"
+)
+
+// getAuditFlush returns the CSR_AUDIT_FLUSH_INTERVAL and CSR_AUDIT_FLUSH_ENTRIES values, their defaults if not set
+func getAuditFlush() (time.Duration, int, error) {
+	interval := defaultAuditFlushInterval
+	if v := os.Getenv(auditFlushIntervalEnvVarName); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return 0, 0, fmt.Errorf("invalid %s value %q, must be a positive duration", auditFlushIntervalEnvVarName, v)
+		}
+		interval = d
+	}
+	entries := defaultAuditFlushEntries
+	if v := os.Getenv(auditFlushEntriesEnvVarName); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return 0, 0, fmt.Errorf("invalid %s value %q, must be a positive integer", auditFlushEntriesEnvVarName, v)
+		}
+		entries = n
+	}
+	return interval, entries, nil
+}
+
+// auditLog buffers the approval decisions and appends them in batches to the audit configmap, every flush
+// interval or once flushEntries decisions are buffered, so high approval rates do not contend on the configmap.
+// A batch failing to be written is kept and written with the next batch, no decision is dropped.
+// A nil auditLog records nothing.
+type auditLog struct {
+	kubeClient    kubernetes.Interface
+	namespace     string
+	configMapName string
+	flushInterval time.Duration
+	flushEntries  int
+
+	lock    sync.Mutex
+	pending []approvalAttestation
+	// full is signaled when flushEntries decisions are buffered
+	full chan struct{}
+	// flushLock serializes the flushes
+	flushLock sync.Mutex
+}
+
+// newAuditLog returns the audit log of the configmap, nil if configMapName is empty or kubeClient is nil
+func newAuditLog(
+	kubeClient kubernetes.Interface,
+	namespace, configMapName string,
+	flushInterval time.Duration,
+	flushEntries int) *auditLog {
+	if configMapName == "" || kubeClient == nil {
+		return nil
+	}
+	return &auditLog{
+		kubeClient:    kubeClient,
+		namespace:     namespace,
+		configMapName: configMapName,
+		flushInterval: flushInterval,
+		flushEntries:  flushEntries,
+		full:          make(chan struct{}, 1),
+	}
+}
+
+// record buffers the decision of the condition on the csr
+func (a *auditLog) record(
+	csr *certificatesv1.CertificateSigningRequest,
+	clusterName string,
+	condition certificatesv1.CertificateSigningRequestCondition,
+	now time.Time) {
+	if a == nil {
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.pending = append(a.pending, approvalAttestation{
+		CSRName:            csr.Name,
+		ClusterName:        clusterName,
+		RequestFingerprint: requestFingerprint(csr),
+		Decision:           string(condition.Type),
+		Reason:             condition.Reason,
+		Message:            condition.Message,
+		DecidedAt:          now.UTC().Format(time.RFC3339),
+	})
+	if len(a.pending) >= a.flushEntries {
+		select {
+		case a.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Start flushes the buffered decisions every flush interval or once the buffer is full until stop is closed,
+// the decisions are flushed a last time on stop
+func (a *auditLog) Start(stop <-chan struct{}) error {
+	ticker := time.NewTicker(a.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-a.full:
+		case <-stop:
+			if err := a.flush(time.Now()); err != nil {
+				log.Error(err, "failed to flush the CSR audit log", "configmap", a.configMapName)
+			}
+			return nil
+		}
+		if err := a.flush(time.Now()); err != nil {
+			log.Error(err, "failed to flush the CSR audit log", "configmap", a.configMapName)
+		}
+	}
+}
+
+// flush appends the buffered decisions as a batch to the audit configmap, the batch is put back in the buffer if
+// it can not be written
+func (a *auditLog) flush(now time.Time) error {
+	a.flushLock.Lock()
+	defer a.flushLock.Unlock()
+
+	a.lock.Lock()
+	batch := a.pending
+	a.pending = nil
+	a.lock.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	if err := a.write(batch, now); err != nil {
+		a.lock.Lock()
+		a.pending = append(batch, a.pending...)
+		a.lock.Unlock()
+		return err
+	}
+	return nil
+}
+
+// write appends the batch under a data key of its flush time to the audit configmap, one JSON decision per line.
+// The recorded decisions are moved to an archive configmap first if the batch would exceed the
+// maxAuditConfigMapSize.
+func (a *auditLog) write(batch []approvalAttestation, now time.Time) error {
+	lines := make([]string, 0, len(batch))
+	for _, entry := range batch {
+		b, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, string(b))
+	}
+	batchKey := fmt.Sprintf("%020d", now.UnixNano())
+	batchData := strings.Join(lines, "\n")
+
+	configMaps := a.kubeClient.CoreV1().ConfigMaps(a.namespace)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap, err := configMaps.Get(context.TODO(), a.configMapName, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			_, err = configMaps.Create(context.TODO(), a.newConfigMap(a.configMapName,
+				map[string]string{batchKey: batchData}), metav1.CreateOptions{})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		size := len(batchKey) + len(batchData)
+		for k, v := range configMap.Data {
+			size += len(k) + len(v)
+		}
+		if size > maxAuditConfigMapSize && len(configMap.Data) > 0 {
+			archive := a.newConfigMap(fmt.Sprintf("%s-%d", a.configMapName, now.UnixNano()), configMap.Data)
+			if _, err := configMaps.Create(context.TODO(), archive, metav1.CreateOptions{}); err != nil &&
+				!errors.IsAlreadyExists(err) {
+				return err
+			}
+			configMap.Data = nil
+		}
+		if configMap.Data == nil {
+			configMap.Data = map[string]string{}
+		}
+		configMap.Data[batchKey] = batchData
+		_, err = configMaps.Update(context.TODO(), configMap, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+func (a *auditLog) newConfigMap(name string, data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: a.namespace,
+			Labels:    map[string]string{auditLabel: "true"},
+		},
+		Data: data,
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+const auditConfigMapName = "csr-audit"
+
+// auditedCSRs returns the names of the csrs recorded in the audit configmap and its archives, in the order
+// of their batches
+func auditedCSRs(t *testing.T, a *auditLog) []string {
+	configMaps, err := a.kubeClient.CoreV1().ConfigMaps(a.namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: auditLabel + "=true",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	batches := map[string]string{}
+	for _, configMap := range configMaps.Items {
+		for k, v := range configMap.Data {
+			batches[k] = v
+		}
+	}
+	keys := []string{}
+	for k := range batches {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	names := []string{}
+	for _, k := range keys {
+		for _, line := range strings.Split(batches[k], "\n") {
+			entry := approvalAttestation{}
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				t.Fatalf("invalid audit entry %q: %v", line, err)
+			}
+			names = append(names, entry.CSRName)
+		}
+	}
+	return names
+}
+
+func recordApprovals(a *auditLog, first, count int) []string {
+	names := []string{}
+	for i := first; i < first+count; i++ {
+		csr := newDedupCSR(fmt.Sprintf("csr-%d", i), []byte(fmt.Sprintf("request-%d", i)))
+		a.record(csr, clusterName, certificatesv1.CertificateSigningRequestCondition{
+			Type:   certificatesv1.CertificateApproved,
+			Reason: string(ReasonAutoApproved),
+		}, time.Now())
+		names = append(names, csr.Name)
+	}
+	return names
+}
+
+// waitForAudited waits for the csrs to be recorded in the audit configmap
+func waitForAudited(t *testing.T, a *auditLog, want []string) {
+	var got []string
+	for i := 0; i < 100; i++ {
+		if got = auditedCSRs(t, a); len(got) >= len(want) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("audited csrs = %v, want %v", got, want)
+	}
+}
+
+func Test_getAuditFlush(t *testing.T) {
+	tests := []struct {
+		name         string
+		interval     string
+		entries      string
+		wantInterval time.Duration
+		wantEntries  int
+		wantErr      bool
+	}{
+		{
+			name:         "defaults",
+			wantInterval: defaultAuditFlushInterval,
+			wantEntries:  defaultAuditFlushEntries,
+		},
+		{
+			name:         "valid",
+			interval:     "1m",
+			entries:      "10",
+			wantInterval: time.Minute,
+			wantEntries:  10,
+		},
+		{
+			name:     "invalid interval",
+			interval: "0s",
+			wantErr:  true,
+		},
+		{
+			name:    "invalid entries",
+			entries: "-1",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(auditFlushIntervalEnvVarName, tt.interval)
+			os.Setenv(auditFlushEntriesEnvVarName, tt.entries)
+			defer os.Unsetenv(auditFlushIntervalEnvVarName)
+			defer os.Unsetenv(auditFlushEntriesEnvVarName)
+			interval, entries, err := getAuditFlush()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getAuditFlush() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if interval != tt.wantInterval || entries != tt.wantEntries {
+				t.Errorf("getAuditFlush() = %v, %d, want %v, %d", interval, entries, tt.wantInterval, tt.wantEntries)
+			}
+		})
+	}
+}
+
+func Test_auditLog_flushOnCount(t *testing.T) {
+	kubeClient := fakeclientset.NewSimpleClientset()
+	writes := 0
+	kubeClient.PrependReactor("*", "configmaps", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if action.GetVerb() == "create" || action.GetVerb() == "update" {
+			writes++
+		}
+		return false, nil, nil
+	})
+	a := newAuditLog(kubeClient, "open-cluster-management", auditConfigMapName, time.Hour, 5)
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() { done <- a.Start(stop) }()
+
+	want := recordApprovals(a, 0, 4)
+	time.Sleep(50 * time.Millisecond)
+	if got := auditedCSRs(t, a); len(got) != 0 {
+		t.Errorf("audited csrs = %v before the flush threshold, want none", got)
+	}
+	want = append(want, recordApprovals(a, 4, 1)...)
+	waitForAudited(t, a, want)
+	if writes != 1 {
+		t.Errorf("audit configmap writes = %d, want 1 for the batch", writes)
+	}
+
+	// the decisions buffered below the threshold are flushed on stop
+	want = append(want, recordApprovals(a, 5, 2)...)
+	close(stop)
+	if err := <-done; err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	waitForAudited(t, a, want)
+}
+
+func Test_auditLog_flushOnInterval(t *testing.T) {
+	a := newAuditLog(fakeclientset.NewSimpleClientset(), "open-cluster-management", auditConfigMapName,
+		20*time.Millisecond, 100)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() { _ = a.Start(stop) }()
+
+	want := recordApprovals(a, 0, 3)
+	waitForAudited(t, a, want)
+	want = append(want, recordApprovals(a, 3, 2)...)
+	waitForAudited(t, a, want)
+}
+
+func Test_auditLog_flushFailure(t *testing.T) {
+	kubeClient := fakeclientset.NewSimpleClientset()
+	failing := true
+	kubeClient.PrependReactor("create", "configmaps", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if failing {
+			return true, nil, errors.NewServiceUnavailable("unavailable")
+		}
+		return false, nil, nil
+	})
+	a := newAuditLog(kubeClient, "open-cluster-management", auditConfigMapName, time.Hour, 100)
+
+	want := recordApprovals(a, 0, 3)
+	if err := a.flush(time.Now()); err == nil {
+		t.Fatal("flush() expected an error")
+	}
+	want = append(want, recordApprovals(a, 3, 2)...)
+	failing = false
+	if err := a.flush(time.Now()); err != nil {
+		t.Fatalf("flush() error = %v", err)
+	}
+	waitForAudited(t, a, want)
+}
+
+func Test_auditLog_rotation(t *testing.T) {
+	existing := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      auditConfigMapName,
+			Namespace: "open-cluster-management",
+			Labels:    map[string]string{auditLabel: "true"},
+		},
+		Data: map[string]string{
+			fmt.Sprintf("%020d", 1): `{"csrName":"csr-old","message":"` + strings.Repeat("x", maxAuditConfigMapSize) + `"}`,
+		},
+	}
+	kubeClient := fakeclientset.NewSimpleClientset(existing)
+	a := newAuditLog(kubeClient, "open-cluster-management", auditConfigMapName, time.Hour, 100)
+
+	want := append([]string{"csr-old"}, recordApprovals(a, 0, 2)...)
+	if err := a.flush(time.Now()); err != nil {
+		t.Fatalf("flush() error = %v", err)
+	}
+	waitForAudited(t, a, want)
+
+	configMap, err := kubeClient.CoreV1().ConfigMaps(a.namespace).Get(context.TODO(), auditConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configMap.Data) != 1 {
+		t.Errorf("audit configmap batches = %d, want the new batch only", len(configMap.Data))
+	}
+	actions := kubeClient.Actions()
+	archived := false
+	for _, action := range actions {
+		if create, ok := action.(clienttesting.CreateAction); ok &&
+			action.GetResource() == (schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}) &&
+			create.GetObject().(*corev1.ConfigMap).Name != auditConfigMapName {
+			archived = true
+		}
+	}
+	if !archived {
+		t.Error("the recorded decisions are not archived")
+	}
+}
+
+func Test_auditLog_disabled(t *testing.T) {
+	if a := newAuditLog(fakeclientset.NewSimpleClientset(), "open-cluster-management", "", time.Hour, 100); a != nil {
+		t.Fatalf("newAuditLog() = %v, want nil without configmap", a)
+	}
+	var a *auditLog
+	recordApprovals(a, 0, 1)
+}
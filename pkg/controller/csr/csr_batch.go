@@ -0,0 +1,26 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+// approvePendingCSRs evaluates the pending csrs of the cluster but the approved one. Each csr is evaluated on its
+// own with all the checks, and only the csrs the controller watches, requested by a bootstrap username of the cluster,
+// are evaluated. The results of the evaluations are dropped, a csr left pending is reconciled again from the queue.
+func (r *ReconcileCSR) approvePendingCSRs(clusterName, approvedName string) {
+	for _, request := range pendingCSRRequests(r.client, r.watch.current(), clusterName) {
+		if request.Name == approvedName {
+			continue
+		}
+		csr, err := r.getCSR(request.NamespacedName)
+		if err != nil {
+			log.Error(err, "failed to get the pending CSR of the cluster", "name", request.Name, "cluster", clusterName)
+			continue
+		}
+		if !r.watchesSigner(csr) || !r.watches(csr) {
+			continue
+		}
+		if _, err := r.reconcileCSR(request, false); err != nil {
+			log.Error(err, "failed to evaluate the pending CSR of the cluster", "name", request.Name,
+				"cluster", clusterName)
+		}
+	}
+}
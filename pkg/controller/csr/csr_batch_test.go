@@ -0,0 +1,101 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcileCSR_ReconcileBatchApproval(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterName},
+		Spec:       clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+	}
+	newCSR := func(name, username string) *certificatesv1.CertificateSigningRequest {
+		return &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{clusterLabel: clusterName},
+			},
+			Spec: certificatesv1.CertificateSigningRequestSpec{
+				Username:   username,
+				Request:    newCSRRequest(t, clusterCommonNamePrefix+clusterName, nil),
+				SignerName: certificatesv1.KubeAPIServerClientSignerName,
+				Usages:     []certificatesv1.KeyUsage{certificatesv1.UsageClientAuth},
+			},
+		}
+	}
+	bootstrapUsername := fmt.Sprintf(userNameSignature, clusterName, clusterName)
+	// the csrs left by the restarts of the agent, and one csr of a username which is not a bootstrap username
+	csrs := []runtime.Object{
+		newCSR("csr-1", bootstrapUsername),
+		newCSR("csr-2", bootstrapUsername),
+		newCSR("csr-invalid", unauthorizedUsername),
+	}
+
+	tests := []struct {
+		name          string
+		batchApproval bool
+		wantApproved  map[string]bool
+	}{
+		{
+			name:          "batch approval",
+			batchApproval: true,
+			wantApproved:  map[string]bool{"csr-1": true, "csr-2": true},
+		},
+		{
+			name:         "single approval",
+			wantApproved: map[string]bool{"csr-1": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := []runtime.Object{testManagedCluster}
+			for _, csr := range csrs {
+				objs = append(objs, csr.DeepCopyObject())
+			}
+			r := &ReconcileCSR{
+				client:        fake.NewFakeClientWithScheme(testscheme, objs...),
+				kubeClient:    fakeclientset.NewSimpleClientset(csrs...),
+				scheme:        testscheme,
+				podNamespace:  testPodNamespace,
+				batchApproval: tt.batchApproval,
+			}
+			if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "csr-1"}}); err != nil {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+			}
+			for _, name := range []string{"csr-1", "csr-2", "csr-invalid"} {
+				got, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), name, metav1.GetOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				approved := getApprovalType(got) == string(certificatesv1.CertificateApproved)
+				if approved != tt.wantApproved[name] {
+					t.Errorf("csr %s approved = %v, want %v", name, approved, tt.wantApproved[name])
+				}
+				if !approved && getApprovalType(got) != "" {
+					t.Errorf("csr %s approval = %q, want pending", name, getApprovalType(got))
+				}
+				if name == "csr-invalid" && got.Annotations[ReasonCodeAnnotation] != "" {
+					t.Errorf("csr %s reason code = %q, want the csr not evaluated", name, got.Annotations[ReasonCodeAnnotation])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// challengeSecretEnvVarName is the name of the secret, in the POD_NAMESPACE, holding the shared
+	// key used to sign the bootstrap challenge. The challenge verification is disabled when it is not set.
+	challengeSecretEnvVarName = "CSR_CHALLENGE_SECRET"
+	challengeSecretKey        = "key"
+	// previousChallengeSecretKey holds the key replaced by a rotation, the challenges signed with it are accepted
+	// until the time of the previousChallengeExpirationKey so the joins in progress are not broken by the rotation
+	previousChallengeSecretKey     = "previousKey"
+	previousChallengeExpirationKey = "previousKeyExpiresAt"
+	// challengeURIPrefix prefixes the hex encoded HMAC-SHA256 of the cluster name in an URI SAN of the CSR
+	challengeURIPrefix = "open-cluster-management:bootstrap-challenge:"
+)
+
+// parseCertificateRequest decodes the PEM block of the csr request and parses the x509 certificate request
+func parseCertificateRequest(csr *certificatesv1.CertificateSigningRequest) (*x509.CertificateRequest, error) {
+	block, _ := pem.Decode(csr.Spec.Request)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, fmt.Errorf("PEM block type must be CERTIFICATE REQUEST")
+	}
+	return x509.ParseCertificateRequest(block.Bytes)
+}
+
+// computeChallenge returns the hex encoded HMAC-SHA256 of the cluster name signed with the key
+func computeChallenge(key []byte, clusterName string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(clusterName))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyChallenge checks that the csr request carries a challenge URI SAN signed with one of the keys for the cluster,
+// the csr is verified once one of its challenge URI SANs is valid
+func verifyChallenge(csr *certificatesv1.CertificateSigningRequest, clusterName string, keys ...[]byte) error {
+	x509cr, err := parseCertificateRequest(csr)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, uri := range x509cr.URIs {
+		challenge := strings.TrimPrefix(uri.String(), challengeURIPrefix)
+		if challenge == uri.String() {
+			continue
+		}
+		found = true
+		for _, key := range keys {
+			if hmac.Equal([]byte(challenge), []byte(computeChallenge(key, clusterName))) {
+				return nil
+			}
+		}
+	}
+	if found {
+		return fmt.Errorf("invalid bootstrap challenge for cluster %s", clusterName)
+	}
+	return fmt.Errorf("bootstrap challenge not found")
+}
+
+// getChallengeKeys reads the shared challenge keys from the challenge secret, the current key and,
+// during a key rotation, the previous key until it expires
+func (r *ReconcileCSR) getChallengeKeys(secretName string, now time.Time) ([][]byte, error) {
+	ctx, cancel := r.apiCallContext()
+	defer cancel()
+	secret, err := r.kubeClient.CoreV1().Secrets(r.podNamespace).Get(
+		ctx, secretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	key := secret.Data[challengeSecretKey]
+	if len(key) == 0 {
+		return nil, fmt.Errorf("key %s not found in secret %s/%s",
+			challengeSecretKey, r.podNamespace, secretName)
+	}
+	keys := [][]byte{key}
+
+	previousKey := secret.Data[previousChallengeSecretKey]
+	if len(previousKey) == 0 {
+		return keys, nil
+	}
+	expiresAt, err := time.Parse(time.RFC3339, string(secret.Data[previousChallengeExpirationKey]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s in secret %s/%s, the %s must expire: %v",
+			previousChallengeExpirationKey, r.podNamespace, secretName, previousChallengeSecretKey, err)
+	}
+	if now.Before(expiresAt) {
+		keys = append(keys, previousKey)
+	}
+	return keys, nil
+}
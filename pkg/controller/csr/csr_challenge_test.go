@@ -0,0 +1,316 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	/* #nosec */
+	challengeSecretName = "csr-challenge"
+	testPodNamespace    = "open-cluster-management"
+)
+
+// newCSRRequest generates a PEM encoded certificate request with the given subject and URI SANs
+func newCSRRequest(t *testing.T, commonName string, organizations []string, uris ...string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.CertificateRequest{
+		Subject: pkix.Name{
+			CommonName:   commonName,
+			Organization: organizations,
+		},
+	}
+	for _, u := range uris {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template.URIs = append(template.URIs, parsed)
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, template, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+}
+
+func newChallengeCSR(t *testing.T, uris ...string) *certificatesv1.CertificateSigningRequest {
+	return &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: csrNameReconcile,
+			Labels: map[string]string{
+				clusterLabel: clusterName,
+			},
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Username: fmt.Sprintf(userNameSignature, clusterName, clusterName),
+			Request:  newCSRRequest(t, "system:open-cluster-management:"+clusterName, nil, uris...),
+		},
+	}
+}
+
+func Test_verifyChallenge(t *testing.T) {
+	key := []byte("shared-key")
+	validChallenge := challengeURIPrefix + computeChallenge(key, clusterName)
+	otherClusterChallenge := challengeURIPrefix + computeChallenge(key, "othercluster")
+	otherKeyChallenge := challengeURIPrefix + computeChallenge([]byte("other-key"), clusterName)
+
+	tests := []struct {
+		name    string
+		csr     *certificatesv1.CertificateSigningRequest
+		wantErr bool
+	}{
+		{
+			name:    "valid challenge",
+			csr:     newChallengeCSR(t, "spiffe://cluster.local/ns/agent", validChallenge),
+			wantErr: false,
+		},
+		{
+			name:    "challenge for another cluster",
+			csr:     newChallengeCSR(t, otherClusterChallenge),
+			wantErr: true,
+		},
+		{
+			name:    "challenge signed with another key",
+			csr:     newChallengeCSR(t, otherKeyChallenge),
+			wantErr: true,
+		},
+		{
+			name:    "valid challenge after an invalid one",
+			csr:     newChallengeCSR(t, otherKeyChallenge, validChallenge),
+			wantErr: false,
+		},
+		{
+			name:    "invalid challenges only",
+			csr:     newChallengeCSR(t, otherClusterChallenge, otherKeyChallenge),
+			wantErr: true,
+		},
+		{
+			name:    "missing challenge",
+			csr:     newChallengeCSR(t),
+			wantErr: true,
+		},
+		{
+			name: "no request",
+			csr: &certificatesv1.CertificateSigningRequest{
+				Spec: certificatesv1.CertificateSigningRequestSpec{},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := verifyChallenge(tt.csr, clusterName, key); (err != nil) != tt.wantErr {
+				t.Errorf("verifyChallenge() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_ReconcileChallenge(t *testing.T) {
+	key := []byte("shared-key")
+	challengeSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      challengeSecretName,
+			Namespace: testPodNamespace,
+		},
+		Data: map[string][]byte{
+			challengeSecretKey: key,
+		},
+	}
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: clusterName,
+		},
+		Spec: clusterv1.ManagedClusterSpec{
+			HubAcceptsClient: true,
+		},
+	}
+
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	tests := []struct {
+		name         string
+		csr          *certificatesv1.CertificateSigningRequest
+		secret       *corev1.Secret
+		wantApproved bool
+		wantErr      bool
+	}{
+		{
+			name:         "valid challenge",
+			csr:          newChallengeCSR(t, challengeURIPrefix+computeChallenge(key, clusterName)),
+			secret:       challengeSecret,
+			wantApproved: true,
+		},
+		{
+			name:         "invalid challenge",
+			csr:          newChallengeCSR(t, challengeURIPrefix+computeChallenge([]byte("wrong"), clusterName)),
+			secret:       challengeSecret,
+			wantApproved: false,
+		},
+		{
+			name:         "missing challenge",
+			csr:          newChallengeCSR(t),
+			secret:       challengeSecret,
+			wantApproved: false,
+		},
+		{
+			name:    "missing challenge secret",
+			csr:     newChallengeCSR(t, challengeURIPrefix+computeChallenge(key, clusterName)),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kubeClient := fakeclientset.NewSimpleClientset(tt.csr)
+			if tt.secret != nil {
+				kubeClient = fakeclientset.NewSimpleClientset(tt.csr, tt.secret)
+			}
+			r := &ReconcileCSR{
+				client:              fake.NewFakeClientWithScheme(testscheme, testManagedCluster, tt.csr),
+				kubeClient:          kubeClient,
+				scheme:              testscheme,
+				challengeSecretName: challengeSecretName,
+				podNamespace:        testPodNamespace,
+			}
+			_, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReconcileCSR.Reconcile() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			csr, err := kubeClient.CertificatesV1().CertificateSigningRequests().Get(
+				context.TODO(), csrNameReconcile, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if approved := getApprovalType(csr) == string(certificatesv1.CertificateApproved); approved != tt.wantApproved {
+				t.Errorf("CSR approved = %v, want %v", approved, tt.wantApproved)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_ReconcileChallengeKeyRotation(t *testing.T) {
+	currentKey := []byte("current-key")
+	previousKey := []byte("previous-key")
+	newRotatedSecret := func(expiresAt string) *corev1.Secret {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      challengeSecretName,
+				Namespace: testPodNamespace,
+			},
+			Data: map[string][]byte{
+				challengeSecretKey:         currentKey,
+				previousChallengeSecretKey: previousKey,
+			},
+		}
+		if expiresAt != "" {
+			secret.Data[previousChallengeExpirationKey] = []byte(expiresAt)
+		}
+		return secret
+	}
+	inWindow := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	afterWindow := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: clusterName,
+		},
+		Spec: clusterv1.ManagedClusterSpec{
+			HubAcceptsClient: true,
+		},
+	}
+
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	tests := []struct {
+		name         string
+		key          []byte
+		secret       *corev1.Secret
+		wantApproved bool
+		wantErr      bool
+	}{
+		{
+			name:         "current key during the rotation",
+			key:          currentKey,
+			secret:       newRotatedSecret(inWindow),
+			wantApproved: true,
+		},
+		{
+			name:         "previous key during the rotation",
+			key:          previousKey,
+			secret:       newRotatedSecret(inWindow),
+			wantApproved: true,
+		},
+		{
+			name:         "current key after the rotation",
+			key:          currentKey,
+			secret:       newRotatedSecret(afterWindow),
+			wantApproved: true,
+		},
+		{
+			name:         "previous key after the rotation",
+			key:          previousKey,
+			secret:       newRotatedSecret(afterWindow),
+			wantApproved: false,
+		},
+		{
+			name:    "previous key without expiration",
+			key:     previousKey,
+			secret:  newRotatedSecret(""),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr := newChallengeCSR(t, challengeURIPrefix+computeChallenge(tt.key, clusterName))
+			kubeClient := fakeclientset.NewSimpleClientset(csr, tt.secret)
+			r := &ReconcileCSR{
+				client:              fake.NewFakeClientWithScheme(testscheme, testManagedCluster, csr),
+				kubeClient:          kubeClient,
+				scheme:              testscheme,
+				challengeSecretName: challengeSecretName,
+				podNamespace:        testPodNamespace,
+			}
+			_, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			got, err := kubeClient.CertificatesV1().CertificateSigningRequests().Get(
+				context.TODO(), csrNameReconcile, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if approved := getApprovalType(got) == string(certificatesv1.CertificateApproved); approved != tt.wantApproved {
+				t.Errorf("CSR approved = %v, want %v", approved, tt.wantApproved)
+			}
+		})
+	}
+}
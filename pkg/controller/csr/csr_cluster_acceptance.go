@@ -0,0 +1,73 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	certificatesv1beta1 "k8s.io/api/certificates/v1beta1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// clusterAcceptedPredicate selects the ManagedClusters becoming accepted by the hub
+func clusterAcceptedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		GenericFunc: func(e event.GenericEvent) bool { return false },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return false },
+		CreateFunc: func(e event.CreateEvent) bool {
+			cluster, ok := e.Object.(*clusterv1.ManagedCluster)
+			return ok && cluster.Spec.HubAcceptsClient
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			newCluster, okNew := e.ObjectNew.(*clusterv1.ManagedCluster)
+			oldCluster, okOld := e.ObjectOld.(*clusterv1.ManagedCluster)
+			return okNew && okOld && newCluster.Spec.HubAcceptsClient && !oldCluster.Spec.HubAcceptsClient
+		},
+	}
+}
+
+// pendingCSRRequests returns the requests of the pending csrs of the cluster in the cache of the watched version
+// of the certificates API
+func pendingCSRRequests(c client.Client, watchVersion, clusterName string) []reconcile.Request {
+	csrs := []*certificatesv1.CertificateSigningRequest{}
+	for _, label := range clusterLabels() {
+		labels := client.MatchingLabels{label: clusterName}
+		if watchVersion == certificatesV1beta1 {
+			list := &certificatesv1beta1.CertificateSigningRequestList{}
+			if err := c.List(context.TODO(), list, labels); err != nil {
+				log.Error(err, "failed to list the CSRs of the cluster", "cluster", clusterName)
+				return nil
+			}
+			for i := range list.Items {
+				csrs = append(csrs, fromV1beta1CSR(&list.Items[i]))
+			}
+		} else {
+			list := &certificatesv1.CertificateSigningRequestList{}
+			if err := c.List(context.TODO(), list, labels); err != nil {
+				log.Error(err, "failed to list the CSRs of the cluster", "cluster", clusterName)
+				return nil
+			}
+			for i := range list.Items {
+				csrs = append(csrs, &list.Items[i])
+			}
+		}
+	}
+
+	requests := []reconcile.Request{}
+	enqueued := map[string]bool{}
+	for _, csr := range csrs {
+		// a csr carrying several cluster name labels is listed for each of them
+		if getApprovalType(csr) != "" || getClusterName(csr) != clusterName || enqueued[csr.Name] {
+			continue
+		}
+		enqueued[csr.Name] = true
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: csr.Name}})
+	}
+	return requests
+}
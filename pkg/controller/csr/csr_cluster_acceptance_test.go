@@ -0,0 +1,167 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func newAcceptedCluster(accepted bool) *clusterv1.ManagedCluster {
+	return &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: clusterName,
+		},
+		Spec: clusterv1.ManagedClusterSpec{
+			HubAcceptsClient: accepted,
+		},
+	}
+}
+
+func Test_clusterAcceptedPredicate(t *testing.T) {
+	p := clusterAcceptedPredicate()
+	tests := []struct {
+		name string
+		old  *clusterv1.ManagedCluster
+		new  *clusterv1.ManagedCluster
+		want bool
+	}{
+		{
+			name: "accepted",
+			old:  newAcceptedCluster(false),
+			new:  newAcceptedCluster(true),
+			want: true,
+		},
+		{
+			name: "still accepted",
+			old:  newAcceptedCluster(true),
+			new:  newAcceptedCluster(true),
+		},
+		{
+			name: "acceptance revoked",
+			old:  newAcceptedCluster(true),
+			new:  newAcceptedCluster(false),
+		},
+		{
+			name: "created accepted",
+			new:  newAcceptedCluster(true),
+			want: true,
+		},
+		{
+			name: "created not accepted",
+			new:  newAcceptedCluster(false),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got bool
+			if tt.old == nil {
+				got = p.Create(event.CreateEvent{Meta: tt.new, Object: tt.new})
+			} else {
+				got = p.Update(event.UpdateEvent{MetaOld: tt.old, ObjectOld: tt.old, MetaNew: tt.new, ObjectNew: tt.new})
+			}
+			if got != tt.want {
+				t.Errorf("predicate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_pendingCSRRequests(t *testing.T) {
+	pending := newDedupCSR("csr-pending", []byte("pending"))
+	approved := newDedupCSR("csr-approved", []byte("approved"))
+	approved.Status.Conditions = []certificatesv1.CertificateSigningRequestCondition{
+		{Type: certificatesv1.CertificateApproved, Status: corev1.ConditionTrue},
+	}
+	other := newDedupCSR("csr-other", []byte("other"))
+	other.Labels[clusterLabel] = "othercluster"
+
+	tests := []struct {
+		name         string
+		watchVersion string
+		objs         []runtime.Object
+	}{
+		{
+			name:         "v1",
+			watchVersion: certificatesV1,
+			objs:         []runtime.Object{pending, approved, other},
+		},
+		{
+			name:         "v1beta1",
+			watchVersion: certificatesV1beta1,
+			objs:         []runtime.Object{toV1beta1CSR(pending), toV1beta1CSR(approved), toV1beta1CSR(other)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewFakeClientWithScheme(scheme.Scheme, tt.objs...)
+			want := []reconcile.Request{{NamespacedName: types.NamespacedName{Name: pending.Name}}}
+			if got := pendingCSRRequests(c, tt.watchVersion, clusterName); !reflect.DeepEqual(got, want) {
+				t.Errorf("pendingCSRRequests() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_ReconcileClusterNotAccepted(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	csr := newDedupCSR(csrNameReconcile, newCSRRequest(t, clusterCommonNamePrefix+clusterName, nil))
+	cluster := newAcceptedCluster(false)
+	r := &ReconcileCSR{
+		client:     fake.NewFakeClientWithScheme(testscheme, csr, cluster),
+		kubeClient: fakeclientset.NewSimpleClientset(csr),
+		scheme:     testscheme,
+	}
+	reconcileCSR := func() (reconcile.Result, *certificatesv1.CertificateSigningRequest) {
+		res, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}})
+		if err != nil {
+			t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+		}
+		got, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), csrNameReconcile,
+			metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res, got
+	}
+
+	res, got := reconcileCSR()
+	if approval := getApprovalType(got); approval != "" {
+		t.Fatalf("CSR approval = %q before the cluster is accepted, want none", approval)
+	}
+	if code := got.Annotations[ReasonCodeAnnotation]; code != string(ReasonClusterNotAccepted) {
+		t.Errorf("reason code annotation = %q, want %q", code, ReasonClusterNotAccepted)
+	}
+	if !res.Requeue || res.RequeueAfter != clusterNotAcceptedRequeuePeriod {
+		t.Errorf("result = %v, want a requeue after %v", res, clusterNotAcceptedRequeuePeriod)
+	}
+
+	// the hub admin accepts the cluster, its pending csr is enqueued again
+	cluster.Spec.HubAcceptsClient = true
+	if err := r.client.Update(context.TODO(), cluster); err != nil {
+		t.Fatal(err)
+	}
+	requests := pendingCSRRequests(r.client, certificatesV1, clusterName)
+	if len(requests) != 1 || requests[0].Name != csrNameReconcile {
+		t.Fatalf("pendingCSRRequests() = %v, want the pending csr", requests)
+	}
+	if _, got := reconcileCSR(); getApprovalType(got) != string(certificatesv1.CertificateApproved) {
+		t.Errorf("CSR approval once the cluster is accepted = %q, want approved", getApprovalType(got))
+	}
+}
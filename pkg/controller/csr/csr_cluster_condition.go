@@ -0,0 +1,40 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"fmt"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+)
+
+// ManagedClusterCSRAutoApproved is the condition of the ManagedClusters whose csrs are approved by the controller,
+// its message names the last approved csr
+const ManagedClusterCSRAutoApproved = "CSRAutoApproved"
+
+// setClusterApprovedCondition sets the approval of the csr in the CSRAutoApproved condition of its ManagedCluster.
+// The condition is set on the latest ManagedCluster and updated with its resource version, so the conditions of the
+// other controllers are kept and a conflicting update is retried.
+func (r *ReconcileCSR) setClusterApprovedCondition(clusterName, csrName string, now time.Time) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		ctx, cancel := r.apiCallContext()
+		defer cancel()
+		cluster := &clusterv1.ManagedCluster{}
+		if err := r.client.Get(ctx, types.NamespacedName{Name: clusterName}, cluster); err != nil {
+			return err
+		}
+		meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+			Type:   ManagedClusterCSRAutoApproved,
+			Status: metav1.ConditionTrue,
+			Reason: string(ReasonAutoApproved),
+			Message: fmt.Sprintf("The CSR %s is approved at %s", csrName,
+				now.UTC().Format(time.RFC3339)),
+		})
+		return r.client.Status().Update(ctx, cluster)
+	})
+}
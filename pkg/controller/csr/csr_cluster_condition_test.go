@@ -0,0 +1,116 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// conflictingStatusClient fails the first conflicts status updates with a conflict
+type conflictingStatusClient struct {
+	client.Client
+	conflicts int
+}
+
+func (c *conflictingStatusClient) Status() client.StatusWriter {
+	return &conflictingStatusWriter{StatusWriter: c.Client.Status(), client: c}
+}
+
+type conflictingStatusWriter struct {
+	client.StatusWriter
+	client *conflictingStatusClient
+}
+
+func (w *conflictingStatusWriter) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	if w.client.conflicts > 0 {
+		w.client.conflicts--
+		return errors.NewConflict(schema.GroupResource{Resource: "managedclusters"}, clusterName, fmt.Errorf("conflict"))
+	}
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
+
+func TestReconcileCSR_ReconcileClusterApprovedCondition(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	// the condition of another controller must be kept
+	joinedCondition := metav1.Condition{
+		Type:               clusterv1.ManagedClusterConditionJoined,
+		Status:             metav1.ConditionTrue,
+		Reason:             "ManagedClusterJoined",
+		LastTransitionTime: metav1.NewTime(time.Now().Add(-time.Hour)),
+	}
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterName},
+		Spec:       clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+		Status:     clusterv1.ManagedClusterStatus{Conditions: []metav1.Condition{joinedCondition}},
+	}
+	newCSR := func(name string) *certificatesv1.CertificateSigningRequest {
+		return &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{clusterLabel: clusterName},
+			},
+			Spec: certificatesv1.CertificateSigningRequestSpec{
+				Username:   fmt.Sprintf(userNameSignature, clusterName, clusterName),
+				Request:    newCSRRequest(t, clusterCommonNamePrefix+clusterName, nil),
+				SignerName: certificatesv1.KubeAPIServerClientSignerName,
+				Usages:     []certificatesv1.KeyUsage{certificatesv1.UsageClientAuth},
+			},
+		}
+	}
+	first, second := newCSR("csr-1"), newCSR("csr-2")
+
+	c := &conflictingStatusClient{
+		Client:    fake.NewFakeClientWithScheme(testscheme, testManagedCluster, first, second),
+		conflicts: 2,
+	}
+	r := &ReconcileCSR{
+		client:       c,
+		kubeClient:   fakeclientset.NewSimpleClientset(first, second),
+		scheme:       testscheme,
+		podNamespace: testPodNamespace,
+	}
+	for _, csr := range []*certificatesv1.CertificateSigningRequest{first, second} {
+		if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csr.Name}}); err != nil {
+			t.Fatalf("ReconcileCSR.Reconcile(%s) error = %v", csr.Name, err)
+		}
+	}
+
+	cluster := &clusterv1.ManagedCluster{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: clusterName}, cluster); err != nil {
+		t.Fatal(err)
+	}
+	if len(cluster.Status.Conditions) != 2 {
+		t.Fatalf("conditions = %v, want the joined and the approved conditions", cluster.Status.Conditions)
+	}
+	if !meta.IsStatusConditionTrue(cluster.Status.Conditions, clusterv1.ManagedClusterConditionJoined) {
+		t.Errorf("conditions = %v, the joined condition is not kept", cluster.Status.Conditions)
+	}
+	condition := meta.FindStatusCondition(cluster.Status.Conditions, ManagedClusterCSRAutoApproved)
+	if condition == nil || condition.Status != metav1.ConditionTrue {
+		t.Fatalf("approved condition = %v, want true", condition)
+	}
+	if !strings.Contains(condition.Message, second.Name) {
+		t.Errorf("approved condition message = %q, want the last approved csr %s", condition.Message, second.Name)
+	}
+}
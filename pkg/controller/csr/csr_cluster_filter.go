@@ -0,0 +1,101 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"fmt"
+	"os"
+	"path"
+)
+
+const (
+	// clusterAllowlistEnvVarName is a comma separated list of the names or glob patterns of the clusters whose csrs
+	// can be approved, all the clusters are allowed if not set
+	clusterAllowlistEnvVarName = "CSR_CLUSTER_ALLOWLIST"
+	// clusterDenylistEnvVarName is a comma separated list of the names or glob patterns of the clusters whose csrs
+	// are never approved, it takes precedence over the allowlist
+	clusterDenylistEnvVarName = "CSR_CLUSTER_DENYLIST"
+)
+
+// clusterNameFilter restricts the clusters whose csrs are approved by their name. A nil clusterNameFilter allows all
+// the clusters.
+type clusterNameFilter struct {
+	// allow are the patterns of the allowed clusters, all the clusters are allowed if empty
+	allow []string
+	// deny are the patterns of the denied clusters, a denied cluster is not allowed even if it matches allow
+	deny []string
+}
+
+// getClusterNameFilter returns the filter of the CSR_CLUSTER_ALLOWLIST and CSR_CLUSTER_DENYLIST values, nil if
+// none is set
+func getClusterNameFilter() (*clusterNameFilter, error) {
+	allow, err := getClusterPatterns(clusterAllowlistEnvVarName)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := getClusterPatterns(clusterDenylistEnvVarName)
+	if err != nil {
+		return nil, err
+	}
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil, nil
+	}
+	return &clusterNameFilter{allow: allow, deny: deny}, nil
+}
+
+// newClusterNameFilter returns the filter of the allow and deny patterns, nil if both are empty
+func newClusterNameFilter(allow, deny []string) (*clusterNameFilter, error) {
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil, nil
+	}
+	for _, pattern := range append(append([]string{}, allow...), deny...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid cluster name pattern %q: %v", pattern, err)
+		}
+	}
+	return &clusterNameFilter{allow: allow, deny: deny}, nil
+}
+
+// getClusterPatterns returns the glob patterns of the env var, none if not set
+func getClusterPatterns(envVarName string) ([]string, error) {
+	v := os.Getenv(envVarName)
+	if v == "" {
+		return nil, nil
+	}
+	patterns := splitList(v)
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid %s pattern %q: %v", envVarName, pattern, err)
+		}
+	}
+	return patterns, nil
+}
+
+// allows returns an error if the csrs of the cluster are not approved, the cluster matches a deny pattern or the
+// allowlist is set and the cluster matches none of its patterns
+func (f *clusterNameFilter) allows(clusterName string) error {
+	if f == nil {
+		return nil
+	}
+	if pattern, ok := matchClusterName(f.deny, clusterName); ok {
+		return fmt.Errorf("the cluster %s matches the %s pattern %q", clusterName, clusterDenylistEnvVarName, pattern)
+	}
+	if len(f.allow) == 0 {
+		return nil
+	}
+	if _, ok := matchClusterName(f.allow, clusterName); !ok {
+		return fmt.Errorf("the cluster %s matches no %s pattern", clusterName, clusterAllowlistEnvVarName)
+	}
+	return nil
+}
+
+// matchClusterName returns the first pattern the cluster name matches
+func matchClusterName(patterns []string, clusterName string) (string, bool) {
+	for _, pattern := range patterns {
+		// the patterns are validated when they are loaded
+		if matched, _ := path.Match(pattern, clusterName); matched {
+			return pattern, true
+		}
+	}
+	return "", false
+}
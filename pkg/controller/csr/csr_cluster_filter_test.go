@@ -0,0 +1,185 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func Test_getClusterNameFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		allow   string
+		deny    string
+		want    *clusterNameFilter
+		wantErr bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:  "allow and deny",
+			allow: "prod-*, cluster1",
+			deny:  "prod-quarantined-*",
+			want:  &clusterNameFilter{allow: []string{"prod-*", "cluster1"}, deny: []string{"prod-quarantined-*"}},
+		},
+		{
+			name:    "invalid pattern",
+			deny:    "prod-[",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(clusterAllowlistEnvVarName, tt.allow)
+			os.Setenv(clusterDenylistEnvVarName, tt.deny)
+			defer os.Unsetenv(clusterAllowlistEnvVarName)
+			defer os.Unsetenv(clusterDenylistEnvVarName)
+			got, err := getClusterNameFilter()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getClusterNameFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getClusterNameFilter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_clusterNameFilter_allows(t *testing.T) {
+	tests := []struct {
+		name        string
+		filter      *clusterNameFilter
+		clusterName string
+		wantErr     bool
+	}{
+		{
+			name:        "no filter",
+			clusterName: "cluster1",
+		},
+		{
+			name:        "empty allowlist",
+			filter:      &clusterNameFilter{deny: []string{"quarantined"}},
+			clusterName: "cluster1",
+		},
+		{
+			name:        "exact name allowed",
+			filter:      &clusterNameFilter{allow: []string{"cluster1"}},
+			clusterName: "cluster1",
+		},
+		{
+			name:        "glob allowed",
+			filter:      &clusterNameFilter{allow: []string{"prod-*", "stage-??"}},
+			clusterName: "stage-01",
+		},
+		{
+			name:        "not allowed",
+			filter:      &clusterNameFilter{allow: []string{"prod-*"}},
+			clusterName: "dev-cluster",
+			wantErr:     true,
+		},
+		{
+			name:        "exact name denied",
+			filter:      &clusterNameFilter{deny: []string{"quarantined"}},
+			clusterName: "quarantined",
+			wantErr:     true,
+		},
+		{
+			name:        "deny takes precedence over allow",
+			filter:      &clusterNameFilter{allow: []string{"prod-*"}, deny: []string{"prod-quarantined-*"}},
+			clusterName: "prod-quarantined-1",
+			wantErr:     true,
+		},
+		{
+			name:        "allowed and not denied",
+			filter:      &clusterNameFilter{allow: []string{"prod-*"}, deny: []string{"prod-quarantined-*"}},
+			clusterName: "prod-1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.filter.allows(tt.clusterName); (err != nil) != tt.wantErr {
+				t.Errorf("clusterNameFilter.allows() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_ReconcileClusterNameFilter(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterName},
+		Spec:       clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+	}
+	csr := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   csrNameReconcile,
+			Labels: map[string]string{clusterLabel: clusterName},
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Username:   fmt.Sprintf(userNameSignature, clusterName, clusterName),
+			Request:    newCSRRequest(t, clusterCommonNamePrefix+clusterName, nil),
+			SignerName: certificatesv1.KubeAPIServerClientSignerName,
+			Usages:     []certificatesv1.KeyUsage{certificatesv1.UsageClientAuth},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		filter       *clusterNameFilter
+		wantCode     ReasonCode
+		wantApproval string
+	}{
+		{
+			name:         "allowed",
+			filter:       &clusterNameFilter{allow: []string{"my*"}},
+			wantCode:     ReasonAutoApproved,
+			wantApproval: string(certificatesv1.CertificateApproved),
+		},
+		{
+			name:     "denied",
+			filter:   &clusterNameFilter{allow: []string{"*"}, deny: []string{clusterName}},
+			wantCode: ReasonClusterNameNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ReconcileCSR{
+				client:            fake.NewFakeClientWithScheme(testscheme, csr.DeepCopy(), testManagedCluster),
+				kubeClient:        fakeclientset.NewSimpleClientset(csr.DeepCopy()),
+				scheme:            testscheme,
+				podNamespace:      testPodNamespace,
+				clusterNameFilter: tt.filter,
+			}
+			if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}}); err != nil {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+			}
+			got, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), csrNameReconcile, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code := got.Annotations[ReasonCodeAnnotation]; code != string(tt.wantCode) {
+				t.Errorf("reason code annotation = %q, want %q", code, tt.wantCode)
+			}
+			if approval := getApprovalType(got); approval != tt.wantApproval {
+				t.Errorf("CSR approval = %q, want %q", approval, tt.wantApproval)
+			}
+		})
+	}
+}
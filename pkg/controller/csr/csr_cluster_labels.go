@@ -0,0 +1,59 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// legacyClusterLabelsEnvVarName is a comma separated list of the label keys the csrs of the previous releases carry
+// the cluster name in, they are checked in order after the clusterLabel
+const legacyClusterLabelsEnvVarName = "CSR_LEGACY_CLUSTER_LABELS"
+
+// clusterLabelKey is the label key of the cluster name of the csrs, the clusterLabel unless the ClusterLabel of the
+// CSRControllerOptions is set
+var clusterLabelKey = clusterLabel
+
+// legacyClusterLabels are the legacy label keys of the cluster name of the csrs, checked in order after the
+// clusterLabelKey
+var legacyClusterLabels []string
+
+// getLegacyClusterLabels returns the CSR_LEGACY_CLUSTER_LABELS value, nil if not set
+func getLegacyClusterLabels() ([]string, error) {
+	v := os.Getenv(legacyClusterLabelsEnvVarName)
+	if v == "" {
+		return nil, nil
+	}
+	labels := []string{}
+	for _, label := range strings.Split(v, ",") {
+		label = strings.TrimSpace(label)
+		if label == "" {
+			continue
+		}
+		if errs := validation.IsQualifiedName(label); len(errs) != 0 {
+			return nil, fmt.Errorf("invalid %s label %q: %s", legacyClusterLabelsEnvVarName, label,
+				strings.Join(errs, ", "))
+		}
+		labels = append(labels, label)
+	}
+	return labels, nil
+}
+
+// validateClusterLabels checks the labels are valid label keys
+func validateClusterLabels(labels ...string) error {
+	for _, label := range labels {
+		if errs := validation.IsQualifiedName(label); len(errs) != 0 {
+			return fmt.Errorf("invalid cluster name label %q: %s", label, strings.Join(errs, ", "))
+		}
+	}
+	return nil
+}
+
+// clusterLabels returns the label keys of the cluster name of the csrs, the clusterLabelKey first
+func clusterLabels() []string {
+	return append([]string{clusterLabelKey}, legacyClusterLabels...)
+}
@@ -0,0 +1,79 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const legacyClusterLabel = "legacy/cluster-name"
+
+func Test_getLegacyClusterLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:  "labels",
+			value: "cluster.open-cluster-management.io/name, legacy/cluster-name",
+			want:  []string{"cluster.open-cluster-management.io/name", "legacy/cluster-name"},
+		},
+		{
+			name:    "invalid label",
+			value:   "legacy/cluster name",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(legacyClusterLabelsEnvVarName, tt.value)
+			defer os.Unsetenv(legacyClusterLabelsEnvVarName)
+			got, err := getLegacyClusterLabels()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getLegacyClusterLabels() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getLegacyClusterLabels() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_pendingCSRRequestsLegacyLabels(t *testing.T) {
+	defer func(labels []string) { legacyClusterLabels = labels }(legacyClusterLabels)
+	legacyClusterLabels = []string{legacyClusterLabel}
+
+	current := newDedupCSR("csr-current", []byte("current"))
+	legacy := newDedupCSR("csr-legacy", []byte("legacy"))
+	delete(legacy.Labels, clusterLabel)
+	legacy.Labels[legacyClusterLabel] = clusterName
+	both := newDedupCSR("csr-both", []byte("both"))
+	both.Labels[legacyClusterLabel] = clusterName
+	// the current label wins over the legacy label
+	other := newDedupCSR("csr-other", []byte("other"))
+	other.Labels[clusterLabel] = "othercluster"
+	other.Labels[legacyClusterLabel] = clusterName
+
+	c := fake.NewFakeClientWithScheme(scheme.Scheme, []runtime.Object{current, legacy, both, other}...)
+	want := []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: both.Name}},
+		{NamespacedName: types.NamespacedName{Name: current.Name}},
+		{NamespacedName: types.NamespacedName{Name: legacy.Name}},
+	}
+	if got := pendingCSRRequests(c, certificatesV1, clusterName); !reflect.DeepEqual(got, want) {
+		t.Errorf("pendingCSRRequests() = %v, want %v", got, want)
+	}
+}
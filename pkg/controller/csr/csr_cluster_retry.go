@@ -0,0 +1,95 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// clusterNotFoundBackoffEnvVarName is the first delay the csrs of a ManagedCluster not found yet are requeued
+	// after, the delay doubles on each attempt
+	clusterNotFoundBackoffEnvVarName = "CSR_CLUSTER_NOT_FOUND_BACKOFF"
+	// clusterNotFoundMaxAttemptsEnvVarName is the number of times the csrs of a ManagedCluster not found yet are
+	// requeued, they are not requeued if 0
+	clusterNotFoundMaxAttemptsEnvVarName = "CSR_CLUSTER_NOT_FOUND_MAX_ATTEMPTS"
+
+	defaultClusterNotFoundBackoff     = 2 * time.Second
+	defaultClusterNotFoundMaxAttempts = 5
+)
+
+// getClusterNotFoundRetry returns the CSR_CLUSTER_NOT_FOUND_BACKOFF and CSR_CLUSTER_NOT_FOUND_MAX_ATTEMPTS values,
+// their defaults if not set
+func getClusterNotFoundRetry() (time.Duration, int, error) {
+	backoff := defaultClusterNotFoundBackoff
+	if v := os.Getenv(clusterNotFoundBackoffEnvVarName); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return 0, 0, fmt.Errorf("invalid %s value %q, must be a positive duration", clusterNotFoundBackoffEnvVarName, v)
+		}
+		backoff = d
+	}
+	maxAttempts := defaultClusterNotFoundMaxAttempts
+	if v := os.Getenv(clusterNotFoundMaxAttemptsEnvVarName); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, fmt.Errorf("invalid %s value %q, must be a non-negative integer",
+				clusterNotFoundMaxAttemptsEnvVarName, v)
+		}
+		maxAttempts = n
+	}
+	return backoff, maxAttempts, nil
+}
+
+// clusterNotFoundRetry counts the attempts of the csrs whose ManagedCluster is not found yet, the bootstrap csr of
+// a registering cluster often arrives before the ManagedCluster is in the cache.
+// A nil clusterNotFoundRetry never requeues a csr.
+type clusterNotFoundRetry struct {
+	backoff     time.Duration
+	maxAttempts int
+	lock        sync.Mutex
+	attempts    map[string]int
+}
+
+// newClusterNotFoundRetry returns the retry of maxAttempts from backoff, nil if maxAttempts is not positive
+func newClusterNotFoundRetry(backoff time.Duration, maxAttempts int) *clusterNotFoundRetry {
+	if maxAttempts <= 0 || backoff <= 0 {
+		return nil
+	}
+	return &clusterNotFoundRetry{
+		backoff:     backoff,
+		maxAttempts: maxAttempts,
+		attempts:    map[string]int{},
+	}
+}
+
+// next records an attempt of the csr and returns the delay it is requeued after, false once the attempts are
+// exhausted
+func (r *clusterNotFoundRetry) next(csrName string) (time.Duration, bool) {
+	if r == nil {
+		return 0, false
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	attempts := r.attempts[csrName] + 1
+	if attempts > r.maxAttempts {
+		delete(r.attempts, csrName)
+		return 0, false
+	}
+	r.attempts[csrName] = attempts
+	return r.backoff << uint(attempts-1), true
+}
+
+// forget clears the attempts of the csr once its ManagedCluster is found
+func (r *clusterNotFoundRetry) forget(csrName string) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.attempts, csrName)
+}
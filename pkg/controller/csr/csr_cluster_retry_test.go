@@ -0,0 +1,162 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func Test_getClusterNotFoundRetry(t *testing.T) {
+	tests := []struct {
+		name            string
+		backoff         string
+		maxAttempts     string
+		wantBackoff     time.Duration
+		wantMaxAttempts int
+		wantErr         bool
+	}{
+		{
+			name:            "defaults",
+			wantBackoff:     defaultClusterNotFoundBackoff,
+			wantMaxAttempts: defaultClusterNotFoundMaxAttempts,
+		},
+		{
+			name:            "valid",
+			backoff:         "500ms",
+			maxAttempts:     "0",
+			wantBackoff:     500 * time.Millisecond,
+			wantMaxAttempts: 0,
+		},
+		{
+			name:    "invalid backoff",
+			backoff: "-1s",
+			wantErr: true,
+		},
+		{
+			name:        "invalid max attempts",
+			maxAttempts: "many",
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(clusterNotFoundBackoffEnvVarName, tt.backoff)
+			os.Setenv(clusterNotFoundMaxAttemptsEnvVarName, tt.maxAttempts)
+			defer os.Unsetenv(clusterNotFoundBackoffEnvVarName)
+			defer os.Unsetenv(clusterNotFoundMaxAttemptsEnvVarName)
+			backoff, maxAttempts, err := getClusterNotFoundRetry()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getClusterNotFoundRetry() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if backoff != tt.wantBackoff || maxAttempts != tt.wantMaxAttempts {
+				t.Errorf("getClusterNotFoundRetry() = %v, %d, want %v, %d", backoff, maxAttempts,
+					tt.wantBackoff, tt.wantMaxAttempts)
+			}
+		})
+	}
+}
+
+func Test_clusterNotFoundRetry_next(t *testing.T) {
+	retry := newClusterNotFoundRetry(time.Second, 3)
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		if got, ok := retry.next("csr-1"); !ok || got != want {
+			t.Errorf("next() attempt %d = %v, %v, want %v, true", i+1, got, ok, want)
+		}
+	}
+	if got, ok := retry.next("csr-1"); ok {
+		t.Errorf("next() after the max attempts = %v, want no requeue", got)
+	}
+	// the attempts start again on a new event once exhausted or forgotten
+	if got, ok := retry.next("csr-1"); !ok || got != time.Second {
+		t.Errorf("next() after exhausted = %v, %v, want %v, true", got, ok, time.Second)
+	}
+	retry.forget("csr-1")
+	if got, ok := retry.next("csr-1"); !ok || got != time.Second {
+		t.Errorf("next() after forget = %v, %v, want %v, true", got, ok, time.Second)
+	}
+
+	disabled := newClusterNotFoundRetry(time.Second, 0)
+	if got, ok := disabled.next("csr-1"); ok {
+		t.Errorf("next() without attempts = %v, want no requeue", got)
+	}
+	disabled.forget("csr-1")
+}
+
+func TestReconcileCSR_ReconcileClusterNotFound(t *testing.T) {
+	testCSR := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: csrNameReconcile,
+			Labels: map[string]string{
+				clusterLabel: clusterName,
+			},
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Username: fmt.Sprintf(userNameSignature, clusterName, clusterName),
+			Request:  newCSRRequest(t, "system:open-cluster-management:"+clusterName, nil),
+		},
+	}
+
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	r := &ReconcileCSR{
+		client:          fake.NewFakeClientWithScheme(testscheme, testCSR),
+		kubeClient:      fakeclientset.NewSimpleClientset(testCSR),
+		scheme:          testscheme,
+		clusterNotFound: newClusterNotFoundRetry(time.Second, 3),
+	}
+	reconcileCSR := func() (reconcile.Result, string) {
+		res, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}})
+		if err != nil {
+			t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+		}
+		csr, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), csrNameReconcile,
+			metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res, getApprovalType(csr)
+	}
+
+	// the ManagedCluster is not in the cache during the first 2 reconciles
+	for i, want := range []time.Duration{time.Second, 2 * time.Second} {
+		res, approval := reconcileCSR()
+		if approval != "" {
+			t.Fatalf("CSR approval = %q without the cluster, want none", approval)
+		}
+		if !res.Requeue || res.RequeueAfter != want {
+			t.Errorf("reconcile %d result = %v, want a requeue after %v", i+1, res, want)
+		}
+	}
+
+	if err := r.client.Create(context.TODO(), &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: clusterName,
+		},
+		Spec: clusterv1.ManagedClusterSpec{
+			HubAcceptsClient: true,
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, approval := reconcileCSR(); approval != string(certificatesv1.CertificateApproved) {
+		t.Errorf("CSR approval once the cluster appears = %q, want approved", approval)
+	}
+	if _, ok := r.clusterNotFound.attempts[csrNameReconcile]; ok {
+		t.Error("the attempts of the CSR are not forgotten once the cluster is found")
+	}
+}
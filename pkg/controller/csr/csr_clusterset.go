@@ -0,0 +1,73 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// clusterSetLabel carries the ManagedClusterSet of a ManagedCluster
+	clusterSetLabel = "cluster.open-cluster-management.io/clusterset"
+	// clusterSetAllowlistEnvVarName is a comma separated list of the ManagedClusterSets whose clusters can get their
+	// csrs approved, the clusters of all the sets and without set are allowed if not set
+	clusterSetAllowlistEnvVarName = "CSR_CLUSTERSET_ALLOWLIST"
+)
+
+// getClusterSetAllowlist returns the CSR_CLUSTERSET_ALLOWLIST value, nil if not set
+func getClusterSetAllowlist() ([]string, error) {
+	v := os.Getenv(clusterSetAllowlistEnvVarName)
+	if v == "" {
+		return nil, nil
+	}
+	return validateClusterSets(clusterSetAllowlistEnvVarName, splitList(v))
+}
+
+// validateClusterSets returns the cluster sets of the source setting, an error if one of them is not a valid name
+func validateClusterSets(source string, clusterSets []string) ([]string, error) {
+	for _, name := range clusterSets {
+		if errs := validation.IsDNS1123Subdomain(name); len(errs) != 0 {
+			return nil, fmt.Errorf("invalid %s ManagedClusterSet %q: %s", source, name, strings.Join(errs, ", "))
+		}
+	}
+	return clusterSets, nil
+}
+
+// allowsClusterSet returns an error if the csrs of the cluster are not approved, the allowlist is set and the
+// cluster belongs to none of its ManagedClusterSets
+func allowsClusterSet(allowlist []string, cluster *clusterv1.ManagedCluster) error {
+	if len(allowlist) == 0 {
+		return nil
+	}
+	clusterSet := cluster.Labels[clusterSetLabel]
+	if clusterSet == "" {
+		return fmt.Errorf("the cluster %s belongs to no ManagedClusterSet of the %s", cluster.Name,
+			clusterSetAllowlistEnvVarName)
+	}
+	for _, allowed := range allowlist {
+		if clusterSet == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("the cluster %s belongs to the ManagedClusterSet %s out of the %s", cluster.Name, clusterSet,
+		clusterSetAllowlistEnvVarName)
+}
+
+// clusterSetChangedPredicate selects the ManagedClusters moving to another ManagedClusterSet
+func clusterSetChangedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		GenericFunc: func(e event.GenericEvent) bool { return false },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return false },
+		CreateFunc:  func(e event.CreateEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return e.MetaNew.GetLabels()[clusterSetLabel] != e.MetaOld.GetLabels()[clusterSetLabel]
+		},
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func Test_getClusterSetAllowlist(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "not set"},
+		{name: "cluster sets", value: "team-a, team-b", want: []string{"team-a", "team-b"}},
+		{name: "invalid cluster set", value: "team-a,Team_B", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(clusterSetAllowlistEnvVarName, tt.value)
+			defer os.Unsetenv(clusterSetAllowlistEnvVarName)
+			got, err := getClusterSetAllowlist()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getClusterSetAllowlist() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getClusterSetAllowlist() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_clusterSetChangedPredicate(t *testing.T) {
+	newCluster := func(clusterSet string) *clusterv1.ManagedCluster {
+		cluster := &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: clusterName}}
+		if clusterSet != "" {
+			cluster.Labels = map[string]string{clusterSetLabel: clusterSet}
+		}
+		return cluster
+	}
+	tests := []struct {
+		name       string
+		old, new   *clusterv1.ManagedCluster
+		wantUpdate bool
+	}{
+		{name: "same cluster set", old: newCluster("team-a"), new: newCluster("team-a")},
+		{name: "another cluster set", old: newCluster("team-a"), new: newCluster("team-b"), wantUpdate: true},
+		{name: "added to a cluster set", old: newCluster(""), new: newCluster("team-a"), wantUpdate: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := clusterSetChangedPredicate()
+			e := event.UpdateEvent{MetaOld: tt.old, ObjectOld: tt.old, MetaNew: tt.new, ObjectNew: tt.new}
+			if got := p.Update(e); got != tt.wantUpdate {
+				t.Errorf("UpdateFunc() = %v, want %v", got, tt.wantUpdate)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_ReconcileClusterSetAllowlist(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	csr := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   csrNameReconcile,
+			Labels: map[string]string{clusterLabel: clusterName},
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Username:   fmt.Sprintf(userNameSignature, clusterName, clusterName),
+			Request:    newCSRRequest(t, clusterCommonNamePrefix+clusterName, nil),
+			SignerName: certificatesv1.KubeAPIServerClientSignerName,
+			Usages:     []certificatesv1.KeyUsage{certificatesv1.UsageClientAuth},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		allowlist    []string
+		clusterSet   string
+		wantCode     ReasonCode
+		wantApproval string
+	}{
+		{
+			name:         "cluster of an allowed cluster set",
+			allowlist:    []string{"team-a", "team-b"},
+			clusterSet:   "team-b",
+			wantCode:     ReasonAutoApproved,
+			wantApproval: string(certificatesv1.CertificateApproved),
+		},
+		{
+			name:       "cluster of another cluster set",
+			allowlist:  []string{"team-a"},
+			clusterSet: "team-b",
+			wantCode:   ReasonClusterSetNotAllowed,
+		},
+		{
+			name:      "cluster without cluster set",
+			allowlist: []string{"team-a"},
+			wantCode:  ReasonClusterSetNotAllowed,
+		},
+		{
+			name:         "all the cluster sets allowed",
+			clusterSet:   "team-b",
+			wantCode:     ReasonAutoApproved,
+			wantApproval: string(certificatesv1.CertificateApproved),
+		},
+		{
+			name:         "cluster without cluster set and all the cluster sets allowed",
+			wantCode:     ReasonAutoApproved,
+			wantApproval: string(certificatesv1.CertificateApproved),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: clusterName},
+				Spec:       clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+			}
+			if tt.clusterSet != "" {
+				cluster.Labels = map[string]string{clusterSetLabel: tt.clusterSet}
+			}
+			r := &ReconcileCSR{
+				client:              fake.NewFakeClientWithScheme(testscheme, csr.DeepCopy(), cluster),
+				kubeClient:          fakeclientset.NewSimpleClientset(csr.DeepCopy()),
+				scheme:              testscheme,
+				podNamespace:        testPodNamespace,
+				clusterSetAllowlist: tt.allowlist,
+			}
+			if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}}); err != nil {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+			}
+			got, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), csrNameReconcile,
+				metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code := got.Annotations[ReasonCodeAnnotation]; code != string(tt.wantCode) {
+				t.Errorf("reason code annotation = %q, want %q", code, tt.wantCode)
+			}
+			if approval := getApprovalType(got); approval != tt.wantApproval {
+				t.Errorf("CSR approval = %q, want %q", approval, tt.wantApproval)
+			}
+		})
+	}
+}
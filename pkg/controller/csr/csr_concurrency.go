@@ -0,0 +1,24 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"fmt"
+
+	"github.com/open-cluster-management/managedcluster-import-controller/pkg/controller/backpressure"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+)
+
+// csrControllerOptions returns the options of the csr controller of r,
+// an error if its maxConcurrentReconciles is not positive
+func csrControllerOptions(r *ReconcileCSR) (controller.Options, error) {
+	if r.maxConcurrentReconciles < 1 {
+		return controller.Options{}, fmt.Errorf("invalid --csr-max-concurrent-reconciles value %d, must be positive",
+			r.maxConcurrentReconciles)
+	}
+	return controller.Options{
+		Reconciler:              r,
+		RateLimiter:             backpressure.NewRateLimiter(),
+		MaxConcurrentReconciles: r.maxConcurrentReconciles,
+	}, nil
+}
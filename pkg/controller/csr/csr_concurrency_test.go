@@ -0,0 +1,50 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"testing"
+)
+
+func Test_csrControllerOptions(t *testing.T) {
+	tests := []struct {
+		name                    string
+		maxConcurrentReconciles int
+		wantErr                 bool
+	}{
+		{
+			name:                    "single worker",
+			maxConcurrentReconciles: 1,
+		},
+		{
+			name:                    "several workers",
+			maxConcurrentReconciles: 8,
+		},
+		{
+			name:    "no worker",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ReconcileCSR{maxConcurrentReconciles: tt.maxConcurrentReconciles}
+			got, err := csrControllerOptions(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("csrControllerOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.MaxConcurrentReconciles != tt.maxConcurrentReconciles {
+				t.Errorf("csrControllerOptions() MaxConcurrentReconciles = %d, want %d",
+					got.MaxConcurrentReconciles, tt.maxConcurrentReconciles)
+			}
+			if got.Reconciler != r {
+				t.Errorf("csrControllerOptions() Reconciler = %v, want the reconciler", got.Reconciler)
+			}
+			if got.RateLimiter == nil {
+				t.Errorf("csrControllerOptions() RateLimiter = nil, want the backpressure rate limiter")
+			}
+		})
+	}
+}
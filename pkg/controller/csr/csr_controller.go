@@ -4,8 +4,11 @@
 package csr
 
 import (
-	"context"
 	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -13,8 +16,12 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
@@ -22,8 +29,22 @@ import (
 )
 
 const (
-	userNameSignature = "system:serviceaccount:%s:%s-bootstrap-sa"
-	clusterLabel      = "open-cluster-management.io/cluster-name"
+	userNameSignature              = "system:serviceaccount:%s:%s-bootstrap-sa"
+	serviceAccountUsernamePrefix   = "system:serviceaccount:"
+	bootstrapServiceAccountPostfix = "-bootstrap-sa"
+	clusterLabel                   = "open-cluster-management.io/cluster-name"
+	// manualApprovalAnnotation pins a csr for manual handling, the controller does not approve nor deny it
+	manualApprovalAnnotation = "import.open-cluster-management.io/manual-approval"
+)
+
+// clusterNotAcceptedRequeuePeriod is the period the csrs of a ManagedCluster not accepted by the hub are
+// evaluated again at, in case the acceptance of the cluster is missed
+const clusterNotAcceptedRequeuePeriod = 5 * time.Minute
+
+// versions of the certificates API
+const (
+	certificatesV1      = "v1"
+	certificatesV1beta1 = "v1beta1"
 )
 
 var log = logf.Log.WithName("controller_csr")
@@ -33,13 +54,16 @@ var log = logf.Log.WithName("controller_csr")
 * business logic.  Delete these comments after modifying this file.*
  */
 
-func getClusterName(csr *certificatesv1.CertificateSigningRequest) (clusterName string) {
-	for label, v := range csr.GetObjectMeta().GetLabels() {
-		if label == clusterLabel {
-			clusterName = v
+// getClusterName returns the value of the first cluster name label of the csr, the clusterLabelKey then the
+// legacyClusterLabels, empty if none is set
+func getClusterName(csr *certificatesv1.CertificateSigningRequest) string {
+	labels := csr.GetObjectMeta().GetLabels()
+	for _, label := range clusterLabels() {
+		if clusterName := labels[label]; clusterName != "" {
+			return clusterName
 		}
 	}
-	return clusterName
+	return ""
 }
 
 func getApprovalType(csr *certificatesv1.CertificateSigningRequest) string {
@@ -54,15 +78,44 @@ func getApprovalType(csr *certificatesv1.CertificateSigningRequest) string {
 	return ""
 }
 
-func validUsername(csr *certificatesv1.CertificateSigningRequest, clusterName string) bool {
-	return csr.Spec.Username == fmt.Sprintf(userNameSignature, clusterName, clusterName)
+// parseServiceAccountUsername splits a service account username into its namespace and name
+func parseServiceAccountUsername(username string) (namespace, name string, ok bool) {
+	if !strings.HasPrefix(username, serviceAccountUsernamePrefix) {
+		return "", "", false
+	}
+	parts := strings.Split(strings.TrimPrefix(username, serviceAccountUsernamePrefix), ":")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// validUsername checks the csr is requested by a bootstrap service account of the cluster, its username matches
+// one of the templates
+func validUsername(csr *certificatesv1.CertificateSigningRequest, clusterName string, templates usernameTemplates) bool {
+	return templates.matches(csr.Spec.Username, clusterName)
 }
 
-func csrPredicate(csr *certificatesv1.CertificateSigningRequest) bool {
+// crossNamespaceRequest checks if the csr is requested by a bootstrap service account
+// of another cluster namespace than the one of the cluster it claims
+func crossNamespaceRequest(csr *certificatesv1.CertificateSigningRequest, clusterName string) bool {
+	namespace, name, ok := parseServiceAccountUsername(csr.Spec.Username)
+	return ok &&
+		strings.HasSuffix(name, bootstrapServiceAccountPostfix) &&
+		namespace != clusterName
+}
+
+// requestedByCluster checks the csr is requested by a bootstrap service account of the cluster it claims, so the
+// requester is the cluster and not another requester setting the cluster name label of the cluster
+func (r *ReconcileCSR) requestedByCluster(csr *certificatesv1.CertificateSigningRequest, clusterName string) bool {
+	return validUsername(csr, clusterName, r.usernameTemplates) || selfManagedUsername(csr, clusterName, r.podNamespace)
+}
+
+func csrPredicate(csr *certificatesv1.CertificateSigningRequest, templates usernameTemplates) bool {
 	clusterName := getClusterName(csr)
 	return clusterName != "" &&
 		getApprovalType(csr) == "" &&
-		validUsername(csr, clusterName)
+		(validUsername(csr, clusterName, templates) || crossNamespaceRequest(csr, clusterName))
 }
 
 // blank assignment to verify that ReconcileCSR implements reconcile.Reconciler
@@ -75,6 +128,98 @@ type ReconcileCSR struct {
 	client     client.Client
 	kubeClient kubernetes.Interface
 	scheme     *runtime.Scheme
+	// challengeSecretName is the secret of the podNamespace holding the shared key of the bootstrap challenge,
+	// the challenge is not verified if challengeSecretName is empty
+	challengeSecretName string
+	podNamespace        string
+	// policyCompatibilityWindow is the duration during which the csrs created before an approval policy
+	// change are evaluated with the previous policy
+	policyCompatibilityWindow time.Duration
+	policyLock                sync.Mutex
+	policyHistory             *policyHistory
+	// denialCooldown skips the csrs of a cluster for a while after one of its csrs was denied
+	denialCooldown *denialCooldown
+	// approvalRulesConfigMapName is the configmap of the podNamespace holding the approval rules,
+	// the rules are not evaluated if approvalRulesConfigMapName is empty
+	approvalRulesConfigMapName string
+	// approvalRules holds the *approvalRules reloaded from the approval rules configmap
+	approvalRules atomic.Value
+	// rulesReloaded enqueues the csrs not allowed by the approval rules once the rules are reloaded, nil if the rules
+	// are not watched
+	rulesReloaded chan event.GenericEvent
+	// invalidRequestAction is the action on the csrs with an invalid request payload, skip if empty
+	invalidRequestAction string
+	// signerPolicies are the policies of the csrs of each signer, all the signers have the default policy if nil
+	signerPolicies map[string]signerPolicy
+	// controllerConfigName is the ImportControllerConfig holding the approval switch,
+	// the approval can not be halted if controllerConfigName is empty
+	controllerConfigName string
+	dynamicClient        dynamic.Interface
+	approvalSwitch       *approvalSwitch
+	// issuanceTimeout is the duration within which the certificate of an approved csr must be issued,
+	// the issuance is not verified if not positive
+	issuanceTimeout time.Duration
+	// dedup skips the csrs carrying the same request as a recently approved csr
+	dedup *csrDeduplicator
+	// attestationKeySecretName is the secret of the podNamespace holding the key signing the attestations of the
+	// approval decisions, no attestation is produced if attestationKeySecretName is empty
+	attestationKeySecretName string
+	// apiVersions resolves the version of the certificates API served by the hub
+	apiVersions *apiVersionResolver
+	// csrVersions records the version of the certificates API of each csr in the per-csr mode, nil in the hub mode
+	csrVersions *csrVersions
+	// audit records the applied approval decisions in the audit configmap, nil if not set
+	audit *auditLog
+	// watch is the version of the certificates API the csrs are watched and read with, v1 if nil
+	watch *csrWatch
+	// clusterNotFound requeues the csrs of the ManagedClusters not found yet
+	clusterNotFound *clusterNotFoundRetry
+	// crossCheckAnnotation is the annotation a peer controller sets to true on the csrs it verified,
+	// the csrs are not cross checked if crossCheckAnnotation is empty
+	crossCheckAnnotation string
+	// denialNotificationConfigMapName is the configmap of the cluster namespaces the denials of the csrs are
+	// explained in, the denials are not notified if denialNotificationConfigMapName is empty
+	denialNotificationConfigMapName string
+	// usernameTemplates are the templates of the usernames of the bootstrap service accounts,
+	// the defaultUsernameTemplates if nil
+	usernameTemplates usernameTemplates
+	// recorder records the events of the approvals, no event is recorded if nil
+	recorder record.EventRecorder
+	// maxManagedClusters is the number of accepted clusters above which the bootstrap csrs of the new clusters are
+	// not approved, the number of clusters is not limited if not positive
+	maxManagedClusters int
+	// denyUnauthorizedCSRs denies the csrs of the accepted clusters requested by an unauthorized username,
+	// they are ignored if false
+	denyUnauthorizedCSRs bool
+	// requestAllowlist restricts the signers and the key usages of the csrs approved with the default policy,
+	// they are not restricted if nil
+	requestAllowlist *requestAllowlist
+	// approvalRateLimiter limits the rate the csrs of each cluster are approved at
+	approvalRateLimiter *approvalRateLimiter
+	// addOnAllowlist are the add-ons whose csrs are approved without a ManagedClusterAddOn in the cluster namespace
+	addOnAllowlist []string
+	// dryRun logs the decisions on the csrs without updating them
+	dryRun bool
+	// clusterNameFilter restricts the clusters whose csrs are approved by their name, all the clusters are
+	// allowed if nil
+	clusterNameFilter *clusterNameFilter
+	// clusterSetAllowlist are the ManagedClusterSets whose clusters can get their csrs approved, the clusters of all
+	// the sets are allowed if empty
+	clusterSetAllowlist []string
+	// batchApproval evaluates the other pending csrs of a cluster once one of its csrs is approved
+	batchApproval bool
+	// verifyServiceAccounts skips the approval of the csrs whose requesting service account does not exist on the hub
+	verifyServiceAccounts bool
+	// orphanedAction is the action taken on the pending csrs of the deleted ManagedClusters, skip, deny or delete
+	orphanedAction string
+	// maxConcurrentReconciles is the number of workers of the csr controller
+	maxConcurrentReconciles int
+	// approvalWebhook decides the approval of the csrs passing all the checks, nil if no webhook is configured
+	approvalWebhook *approvalWebhook
+	// apiCalls is the context of the API calls of the reconciles, cancelled on the shutdown of the manager
+	apiCalls *apiCallContext
+	// approvalWindow limits the approval to the csrs created within a window after their cluster, nil if not limited
+	approvalWindow *approvalWindow
 }
 
 // Reconcile reads that state of the csr for a ReconcileCSR object and makes changes based on the state read
@@ -83,15 +228,21 @@ type ReconcileCSR struct {
 // The Controller will requeue the Request to be processed again if the returned error is non-nil or
 // Result.Requeue is true, otherwise upon completion it will remove the work from the queue.
 func (r *ReconcileCSR) Reconcile(request reconcile.Request) (reconcile.Result, error) {
-	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
-	reqLogger.Info("Reconciling CSR")
+	return r.reconcileCSR(request, r.batchApproval)
+}
 
-	// Fetch the CertificateSigningRequest instance
-	instance := &certificatesv1.CertificateSigningRequest{}
+// reconcileCSR evaluates the csr of the request, the other pending csrs of its cluster are evaluated with it once
+// it is approved if batch is true
+func (r *ReconcileCSR) reconcileCSR(request reconcile.Request, batch bool) (reconcile.Result, error) {
+	reqLogger := log.WithValues("csr", request.Name)
+	reqLogger.V(debugLevel).Info("Reconciling CSR")
+	defer observeReconcileDuration(time.Now())
 
-	if err := r.client.Get(context.TODO(), request.NamespacedName, instance); err != nil {
+	// Fetch the CertificateSigningRequest instance
+	instance, err := r.getCSR(request.NamespacedName)
+	if err != nil {
 		if errors.IsNotFound(err) {
-			reqLogger.Info("CSR ", instance.Name, " not found")
+			reqLogger.V(debugLevel).Info("CSR not found")
 			// Request object not found, could have been deleted after reconcile request.
 			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
 			// Return and don't requeue
@@ -101,37 +252,470 @@ func (r *ReconcileCSR) Reconcile(request reconcile.Request) (reconcile.Result, e
 		return reconcile.Result{}, err
 	}
 
+	reqLogger = csrLogger(instance)
+
 	if instance.DeletionTimestamp != nil {
-		reqLogger.Info("CSR ", instance.Name, " has deletiontimestamp set")
+		reqLogger.V(debugLevel).Info("CSR has deletiontimestamp set")
 		return reconcile.Result{}, nil
 	}
 
+	// an approved or denied csr is not evaluated again, for example on a resync
+	switch getApprovalType(instance) {
+	case string(certificatesv1.CertificateApproved):
+		return r.verifyIssuance(instance, time.Now())
+	case string(certificatesv1.CertificateDenied):
+		reqLogger.V(debugLevel).Info("Skipping CSR already denied")
+		return reconcile.Result{}, nil
+	}
+
+	if instance.Annotations[manualApprovalAnnotation] == "true" {
+		reqLogger.Info("Skipping CSR pinned for manual approval", "decision", decisionSkip)
+		return reconcile.Result{}, r.markPending(instance, ReasonManualApproval,
+			fmt.Sprintf("The CSR is left for manual approval by the %s annotation", manualApprovalAnnotation))
+	}
+
+	if enabled, known := r.approvalSwitch.enabled(); !known {
+		reqLogger.Info("Approval switch not loaded, requeue", "decision", decisionSkip, "config", r.controllerConfigName)
+		return reconcile.Result{Requeue: true, RequeueAfter: 10 * time.Second},
+			r.markPending(instance, ReasonConfigurationNotLoaded, "The approval switch is not loaded")
+	} else if !enabled {
+		reqLogger.Info("CSR approval halted by the ImportControllerConfig", "decision", decisionSkip,
+			"config", r.controllerConfigName)
+		return reconcile.Result{Requeue: true, RequeueAfter: 30 * time.Second},
+			r.markPending(instance, ReasonApprovalHalted,
+				fmt.Sprintf("The approval is halted by the ImportControllerConfig %s", r.controllerConfigName))
+	}
+
+	// the add-on csrs are evaluated apart so the add-ons can not affect the registration of the clusters
+	if addOnName := getAddOnName(instance); addOnName != "" {
+		return r.reconcileAddOnCSR(instance, addOnName)
+	}
+
 	clusterName := getClusterName(instance)
+	csrSeenTotal.WithLabelValues(clusterName).Inc()
+
+	if err := r.clusterNameFilter.allows(clusterName); err != nil {
+		reqLogger.Info("CSR not approved, the cluster is not allowed", "decision", decisionSkip, "reason", err.Error())
+		return reconcile.Result{}, r.markPending(instance, ReasonClusterNameNotAllowed, err.Error())
+	}
+
+	now := time.Now()
+	if remaining := r.denialCooldown.remaining(clusterName, now); remaining > 0 {
+		reqLogger.Info("Skipping CSR of a cluster recently denied", "decision", decisionSkip,
+			"cooldown", remaining.String())
+		// the end of the cooldown keeps the message stable across the reconciles of the csr, a changed message would
+		// update the csr and reconcile it again
+		return reconcile.Result{Requeue: true, RequeueAfter: remaining}, r.markPending(instance, ReasonDenialCooldown,
+			fmt.Sprintf("A CSR of the cluster %s was recently denied, the CSR is evaluated at %s", clusterName,
+				now.Add(remaining).UTC().Format(time.RFC3339)))
+	}
 
-	cluster := clusterv1.ManagedCluster{}
-	err := r.client.Get(context.TODO(), types.NamespacedName{Name: clusterName}, &cluster)
+	cluster, err := r.getManagedCluster(clusterName)
 	if err != nil {
-		reqLogger.Info("Warning", "error", err.Error())
+		// an error, for example a timeout of the call, requeues the csr
+		reqLogger.Error(err, "failed to get the ManagedCluster")
+		return reconcile.Result{}, err
+	}
+
+	decision, reason, x509cr := validateBootstrapCSR(instance, cluster, r.validateOptions())
+	if decision == DecisionSkip && reason.Code == "" {
+		reqLogger.Info("Skipping CSR", "decision", decisionSkip, "reason", reason.Message)
 		return reconcile.Result{}, nil
 	}
 
-	reqLogger.Info("Approving CSR", "name", instance.Name)
-	if instance.Status.Conditions == nil {
-		instance.Status.Conditions = make([]certificatesv1.CertificateSigningRequestCondition, 0)
+	if cluster != nil {
+		r.clusterNotFound.forget(instance.Name)
+		if cluster.DeletionTimestamp != nil && r.cleansOrphanedCSRs() {
+			return reconcile.Result{}, r.cleanOrphanedCSR(instance, clusterName)
+		}
 	}
 
-	instance.Status.Conditions = append(instance.Status.Conditions, certificatesv1.CertificateSigningRequestCondition{
-		Type:           certificatesv1.CertificateApproved,
-		Status:         corev1.ConditionTrue,
-		Reason:         "AutoApprovedByCSRController",
-		Message:        "The managedcluster-import-controller auto approval automatically approved this CSR",
-		LastUpdateTime: metav1.Now(),
-	})
+	switch {
+	case decision == DecisionDeny:
+		reqLogger.Info("Denying CSR", "decision", decisionDeny, "code", reason.Code, "reason", reason.Message)
+		return reconcile.Result{}, r.denyCSR(instance, clusterName, reason.Code, reason.Message)
+	case reason.Code == ReasonClusterNotFound:
+		reqLogger.V(debugLevel).Info("CSR not approved, the ManagedCluster is not found", "decision", decisionSkip)
+		// the ManagedCluster of a registering cluster may not be in the cache yet
+		if backoff, ok := r.clusterNotFound.next(instance.Name); ok {
+			return reconcile.Result{Requeue: true, RequeueAfter: backoff},
+				r.markPending(instance, reason.Code, reason.Message)
+		}
+		// the ManagedCluster is still not found once the attempts are exhausted, it is deleted
+		if r.cleansOrphanedCSRs() {
+			return reconcile.Result{}, r.cleanOrphanedCSR(instance, clusterName)
+		}
+		return reconcile.Result{}, r.markPending(instance, reason.Code, reason.Message)
+	case reason.Code == ReasonClusterNotAccepted:
+		// the csrs of a cluster are enqueued again when it is accepted
+		reqLogger.Info("CSR not approved, the cluster is not accepted by the hub", "decision", decisionSkip)
+		return reconcile.Result{Requeue: true, RequeueAfter: clusterNotAcceptedRequeuePeriod},
+			r.markPending(instance, reason.Code, reason.Message)
+	case decision == DecisionSkip:
+		reqLogger.Info("CSR not approved", "decision", decisionSkip, "code", reason.Code, "reason", reason.Message)
+		return reconcile.Result{}, r.markPending(instance, reason.Code, reason.Message)
+	}
+
+	if err := allowsClusterSet(r.clusterSetAllowlist, cluster); err != nil {
+		reqLogger.Info("CSR not approved, the cluster set is not allowed", "decision", decisionSkip,
+			"reason", err.Error())
+		return reconcile.Result{}, r.markPending(instance, ReasonClusterSetNotAllowed, err.Error())
+	}
+
+	if err := r.approvalWindow.verify(instance, cluster); err != nil {
+		reqLogger.Info("CSR not approved", "decision", decisionSkip, "reason", err.Error())
+		return reconcile.Result{}, r.markPending(instance, ReasonApprovalWindowExpired, err.Error())
+	}
+
+	if r.verifyServiceAccounts {
+		exists, err := r.serviceAccountExists(instance)
+		if err != nil {
+			reqLogger.Error(err, "failed to get the requesting service account")
+			return reconcile.Result{}, err
+		}
+		if !exists {
+			reqLogger.Info("CSR not approved, the requesting service account does not exist", "decision", decisionSkip)
+			return reconcile.Result{}, r.markPending(instance, ReasonServiceAccountNotFound,
+				fmt.Sprintf("The requesting service account %s does not exist", instance.Spec.Username))
+		}
+	}
+
+	ctx, cancel := r.apiCallContext()
+	withinCapacity, err := withinHubCapacity(ctx, r.client, cluster, r.maxManagedClusters)
+	cancel()
+	if err != nil {
+		reqLogger.Error(err, "failed to count the accepted ManagedClusters")
+		return reconcile.Result{}, err
+	}
+	if !withinCapacity {
+		reqLogger.Info("CSR not approved, the hub has the maximum number of clusters", "decision", decisionSkip,
+			"maxManagedClusters", r.maxManagedClusters)
+		return reconcile.Result{Requeue: true, RequeueAfter: hubCapacityRequeuePeriod},
+			r.markPending(instance, ReasonHubCapacityReached,
+				fmt.Sprintf("The hub has the maximum number of %d accepted ManagedClusters", r.maxManagedClusters))
+	}
+
+	if r.approvalRulesConfigMapName != "" {
+		rules := r.getApprovalRules()
+		if rules == nil {
+			reqLogger.Info("Approval rules not loaded, requeue", "decision", decisionSkip,
+				"configmap", r.approvalRulesConfigMapName)
+			return reconcile.Result{Requeue: true, RequeueAfter: 10 * time.Second},
+				r.markPending(instance, ReasonConfigurationNotLoaded, "The approval rules are not loaded")
+		}
+		if reason, err := rules.verifyOwnership(cluster); err != nil {
+			reqLogger.Info("Denying CSR of a cluster without allowed owner", "decision", decisionDeny,
+				"reason", err.Error())
+			return reconcile.Result{}, r.denyCSR(instance, clusterName, reason, err.Error())
+		}
+		if err := rules.allows(instance, cluster); err != nil {
+			reqLogger.Info("CSR not approved", "decision", decisionSkip, "reason", err.Error())
+			return reconcile.Result{}, r.markPending(instance, ReasonNotAllowedByRules, err.Error())
+		}
+	}
+
+	policy, err := r.approvalPolicyFor(instance)
+	if err != nil {
+		reqLogger.Error(err, "failed to get the approval policy")
+		return reconcile.Result{}, err
+	}
+
+	if _, ok := policy.SignerPolicies[instance.Spec.SignerName]; !ok {
+		if err := r.requestAllowlist.verify(instance); err != nil {
+			reqLogger.Info("CSR not approved", "decision", decisionSkip, "reason", err.Error())
+			return reconcile.Result{}, r.markPending(instance, ReasonRequestNotAllowed, err.Error())
+		}
+	}
+
+	signerPolicy, ok := policy.signerPolicy(instance.Spec.SignerName)
+	if !ok {
+		reqLogger.Info("CSR not approved", "decision", decisionSkip,
+			"reason", "no policy for signer "+instance.Spec.SignerName)
+		return reconcile.Result{}, r.markPending(instance, ReasonNoSignerPolicy,
+			fmt.Sprintf("The signer %s has no signer policy", instance.Spec.SignerName))
+	}
+	if err := signerPolicy.verify(instance, clusterName); err != nil {
+		reqLogger.Info("CSR not approved", "decision", decisionSkip, "reason", err.Error())
+		return reconcile.Result{}, r.markPending(instance, ReasonSignerPolicyViolation, err.Error())
+	}
+	// the csrs approved with the default policy are the client certificates of the klusterlet, the groups of the
+	// client certificates are verified whatever the signer policy
+	if _, ok := policy.SignerPolicies[instance.Spec.SignerName]; !ok {
+		if err := verifyIdentity(x509cr, clusterName); err != nil {
+			reqLogger.Info("Denying CSR with a foreign identity", "decision", decisionDeny, "reason", err.Error())
+			return reconcile.Result{}, r.denyCSR(instance, clusterName, ReasonIdentityMismatch, err.Error())
+		}
+	} else if instance.Spec.SignerName == certificatesv1.KubeAPIServerClientSignerName {
+		if err := verifyOrganizations(x509cr, clusterName); err != nil {
+			reqLogger.Info("Denying CSR with a foreign group", "decision", decisionDeny, "reason", err.Error())
+			return reconcile.Result{}, r.denyCSR(instance, clusterName, ReasonIdentityMismatch, err.Error())
+		}
+	}
+
+	if policy.ChallengeSecretName != "" && !signerPolicy.SkipChallenge {
+		keys, err := r.getChallengeKeys(policy.ChallengeSecretName, time.Now())
+		if err != nil {
+			reqLogger.Error(err, "failed to get the challenge keys", "secret", policy.ChallengeSecretName)
+			return reconcile.Result{}, err
+		}
+		if err := verifyChallenge(instance, clusterName, keys...); err != nil {
+			reqLogger.Info("CSR not approved", "decision", decisionSkip, "reason", err.Error())
+			return reconcile.Result{}, r.markPending(instance, ReasonChallengeFailed, err.Error())
+		}
+	}
+
+	if err := verifyCrossCheck(instance, r.crossCheckAnnotation); err != nil {
+		reqLogger.Info("CSR not approved", "decision", decisionSkip, "reason", err.Error())
+		return reconcile.Result{}, r.markPending(instance, ReasonCrossCheckPending, err.Error())
+	}
 
-	signingRequest := r.kubeClient.CertificatesV1().CertificateSigningRequests()
-	if _, err := signingRequest.UpdateApproval(context.TODO(), instance.Name, instance, metav1.UpdateOptions{}); err != nil {
+	ctx, cancel = r.apiCallContext()
+	verdict, err := r.approvalWebhook.review(ctx, instance, x509cr, clusterName)
+	cancel()
+	switch {
+	case err != nil && !r.approvalWebhook.failsOpen():
+		reqLogger.Error(err, "failed to call the approval webhook", "decision", decisionSkip)
+		return reconcile.Result{Requeue: true, RequeueAfter: approvalWebhookRequeuePeriod},
+			r.markPending(instance, ReasonWebhookUnavailable, fmt.Sprintf("The approval webhook call failed: %v", err))
+	case err != nil:
+		reqLogger.Error(err, "failed to call the approval webhook, the failure is ignored")
+	case !verdict.Allowed:
+		message := verdict.Reason
+		if message == "" {
+			message = "The CSR is denied by the approval webhook"
+		}
+		reqLogger.Info("Denying CSR denied by the approval webhook", "decision", decisionDeny, "reason", message)
+		return reconcile.Result{}, r.denyCSR(instance, clusterName, ReasonWebhookDenied, message)
+	}
+
+	// a duplicated csr is not approved, it does not take an approval of the rate of its cluster
+	if approved, ok := r.dedup.claim(instance, time.Now()); !ok {
+		reqLogger.Info("Skipping CSR duplicating an approved CSR", "decision", decisionSkip, "approved", approved)
+		return reconcile.Result{}, r.markPending(instance, ReasonDuplicateRequest,
+			fmt.Sprintf("The CSR has the same request as the approved CSR %s", approved))
+	}
+
+	if delay := r.approvalRateLimiter.reserve(clusterName, time.Now()); delay > 0 {
+		reqLogger.Info("CSR not approved, the cluster is over its approval rate", "decision", decisionSkip,
+			"requeueAfter", delay)
+		r.dedup.release(instance)
+		return reconcile.Result{Requeue: true, RequeueAfter: delay}, r.markPending(instance, ReasonApprovalRateLimited,
+			fmt.Sprintf("The ManagedCluster %s is over its approval rate", clusterName))
+	}
+
+	reqLogger.Info("Approving CSR", "decision", decisionApprove, "policy", policy.version())
+	if err := r.approveCSR(instance, cluster, policy); err != nil {
+		reqLogger.Error(err, "failed to approve the CSR")
+		r.dedup.release(instance)
 		return reconcile.Result{}, err
 	}
+	if r.dryRun {
+		// the csr is not approved in dry run, its request can be evaluated again
+		r.dedup.release(instance)
+	} else {
+		r.recordApprovalEvents(instance, cluster)
+		if err := r.setClusterApprovedCondition(clusterName, instance.Name, time.Now()); err != nil {
+			// the csr is approved already, the condition is informational only
+			reqLogger.Error(err, "failed to set the approval condition of the ManagedCluster")
+		}
+	}
+	if batch {
+		r.approvePendingCSRs(clusterName, instance.Name)
+	}
 
+	if r.issuanceTimeout > 0 {
+		return reconcile.Result{Requeue: true, RequeueAfter: r.issuanceTimeout}, nil
+	}
 	return reconcile.Result{}, nil
 }
+
+// getManagedCluster gets the ManagedCluster of the cluster from the cache, nil if it does not exist
+func (r *ReconcileCSR) getManagedCluster(clusterName string) (*clusterv1.ManagedCluster, error) {
+	ctx, cancel := r.apiCallContext()
+	defer cancel()
+	cluster := &clusterv1.ManagedCluster{}
+	err := r.client.Get(ctx, types.NamespacedName{Name: clusterName}, cluster)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cluster, nil
+}
+
+// approveCSR sets the approved condition on the csr and updates its approval,
+// the condition message is stamped with the version of the policy which approved the csr, the reason and the message
+// of the condition are overridden by the annotations of the csr or of its cluster
+// and the csr is annotated with its reason code and the identity of its requester, the approval is attested first
+// and recorded in the audit log once applied
+func (r *ReconcileCSR) approveCSR(
+	csr *certificatesv1.CertificateSigningRequest,
+	cluster *clusterv1.ManagedCluster,
+	policy approvalPolicy) error {
+	message := fmt.Sprintf("The managedcluster-import-controller auto approval automatically approved this CSR "+
+		"with approval policy %s", policy.version())
+	if r.dryRun {
+		recordDryRunDecision(csr, decisionApprove, ReasonAutoApproved, message)
+		return nil
+	}
+	annotations := reasonAnnotations(ReasonAutoApproved, message)
+	if err := recordRequesterIdentity(csr, annotations); err != nil {
+		return err
+	}
+	condition := certificatesv1.CertificateSigningRequestCondition{
+		Type:    certificatesv1.CertificateApproved,
+		Status:  corev1.ConditionTrue,
+		Reason:  string(ReasonAutoApproved),
+		Message: message,
+	}
+	if err := overrideApprovalCondition(&condition, csr, cluster); err != nil {
+		// the override is informational only, the csr is approved with the default condition
+		csrLogger(csr).Error(err, "failed to override the approval condition")
+	}
+	if err := r.attestDecision(csr, getClusterName(csr), condition, time.Now()); err != nil {
+		return err
+	}
+	csr, err := r.annotateCSR(csr, annotations)
+	if err != nil {
+		return err
+	}
+	if err := r.updateApproval(csr, condition); err != nil {
+		return err
+	}
+	r.audit.record(csr, getClusterName(csr), condition, time.Now())
+	csrApprovedTotal.WithLabelValues(getClusterName(csr)).Inc()
+	return nil
+}
+
+// denyCSR sets a denied condition with the reason and message on the csr, updates its approval
+// and starts the denial cooldown of the cluster
+func (r *ReconcileCSR) denyCSR(
+	csr *certificatesv1.CertificateSigningRequest,
+	clusterName string,
+	reason ReasonCode,
+	message string) error {
+	csr, err := r.updateDenial(csr, clusterName, reason, message)
+	if err != nil || csr == nil {
+		return err
+	}
+	// a requester claiming the cluster name label of another cluster can neither start the cooldown of that cluster
+	// nor write the notifications of its namespace
+	if !r.requestedByCluster(csr, clusterName) {
+		return nil
+	}
+	r.denialCooldown.recordDenial(clusterName, time.Now())
+	if err := r.notifyDenial(csr, clusterName, reason, message, time.Now()); err != nil {
+		// the csr is denied already, the notification is informational only
+		log.Error(err, "failed to notify the denial of the CSR", "name", csr.Name, "namespace", clusterName)
+	}
+	return nil
+}
+
+// updateDenial sets a denied condition with the reason and message on the csr and updates its approval, without
+// the cooldown nor the notification of the denial. It returns the denied csr, nil in the dry run mode.
+func (r *ReconcileCSR) updateDenial(
+	csr *certificatesv1.CertificateSigningRequest,
+	clusterName string,
+	reason ReasonCode,
+	message string) (*certificatesv1.CertificateSigningRequest, error) {
+	if r.dryRun {
+		recordDryRunDecision(csr, decisionDeny, reason, message)
+		return nil, nil
+	}
+	condition := certificatesv1.CertificateSigningRequestCondition{
+		Type:    certificatesv1.CertificateDenied,
+		Status:  corev1.ConditionTrue,
+		Reason:  string(reason),
+		Message: message,
+	}
+	if err := r.attestDecision(csr, clusterName, condition, time.Now()); err != nil {
+		return nil, err
+	}
+	csr, err := r.annotateCSR(csr, reasonAnnotations(reason, message))
+	if err != nil {
+		return nil, err
+	}
+	if err := r.updateApproval(csr, condition); err != nil {
+		return nil, err
+	}
+	r.audit.record(csr, clusterName, condition, time.Now())
+	csrDeniedTotal.WithLabelValues(clusterName, string(reason)).Inc()
+	return csr, nil
+}
+
+// updateApproval updates the approval of the csr with its certificates API version,
+// the condition is set with the timestamps of the version. A conflicting update is retried with approvalConflictRetry
+// on the latest version of the csr, as long as it is still pending with the same request.
+func (r *ReconcileCSR) updateApproval(
+	csr *certificatesv1.CertificateSigningRequest,
+	condition certificatesv1.CertificateSigningRequestCondition) error {
+	attempts := 0
+	err := retry.RetryOnConflict(approvalConflictRetry, func() error {
+		attempts++
+		if attempts == 1 {
+			return r.tryUpdateApproval(csr, condition)
+		}
+		latest, err := r.getLatestCSR(csr)
+		if err != nil {
+			return err
+		}
+		if err := verifyStillPending(csr, latest); err != nil {
+			return err
+		}
+		return r.tryUpdateApproval(latest, condition)
+	})
+	if err != nil {
+		updateApprovalErrorsTotal.WithLabelValues(getClusterName(csr)).Inc()
+	}
+	return err
+}
+
+// tryUpdateApproval updates the approval of the csr once
+func (r *ReconcileCSR) tryUpdateApproval(
+	csr *certificatesv1.CertificateSigningRequest,
+	condition certificatesv1.CertificateSigningRequestCondition) error {
+	version, err := r.csrAPIVersion(csr)
+	if err != nil {
+		return err
+	}
+	csr.Status.Conditions = setApprovalCondition(csr.Status.Conditions, condition, version, metav1.Now())
+	ctx, cancel := r.apiCallContext()
+	defer cancel()
+	if version == certificatesV1beta1 {
+		_, err = r.kubeClient.CertificatesV1beta1().CertificateSigningRequests().UpdateApproval(ctx,
+			toV1beta1CSR(csr), metav1.UpdateOptions{})
+	} else {
+		_, err = r.kubeClient.CertificatesV1().CertificateSigningRequests().UpdateApproval(ctx,
+			csr.Name, csr, metav1.UpdateOptions{})
+	}
+	r.apiVersions.observe(err)
+	return err
+}
+
+// setApprovalCondition sets the condition in the conditions with its timestamps populated for the apiVersion.
+// The lastUpdateTime is always refreshed. The lastTransitionTime only exists on the v1 API,
+// it is kept when the condition is set again with the same status, for example when re-approving
+// an already approved csr.
+func setApprovalCondition(
+	conditions []certificatesv1.CertificateSigningRequestCondition,
+	condition certificatesv1.CertificateSigningRequestCondition,
+	apiVersion string,
+	now metav1.Time) []certificatesv1.CertificateSigningRequestCondition {
+	condition.LastUpdateTime = now
+	if apiVersion == certificatesV1 {
+		condition.LastTransitionTime = now
+	}
+	for i := range conditions {
+		if conditions[i].Type != condition.Type {
+			continue
+		}
+		if apiVersion == certificatesV1 && conditions[i].Status == condition.Status &&
+			!conditions[i].LastTransitionTime.IsZero() {
+			condition.LastTransitionTime = conditions[i].LastTransitionTime
+		}
+		conditions[i] = condition
+		return conditions
+	}
+	return append(conditions, condition)
+}
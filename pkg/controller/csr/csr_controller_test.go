@@ -8,9 +8,11 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
 	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -37,6 +39,7 @@ func TestReconcileCSR_Reconcile(t *testing.T) {
 		},
 		Spec: certificatesv1.CertificateSigningRequestSpec{
 			Username: fmt.Sprintf(userNameSignature, clusterName, clusterName),
+			Request:  newCSRRequest(t, "system:open-cluster-management:"+clusterName, nil),
 		},
 	}
 
@@ -44,7 +47,9 @@ func TestReconcileCSR_Reconcile(t *testing.T) {
 		ObjectMeta: metav1.ObjectMeta{
 			Name: clusterName,
 		},
-		Spec: clusterv1.ManagedClusterSpec{},
+		Spec: clusterv1.ManagedClusterSpec{
+			HubAcceptsClient: true,
+		},
 	}
 
 	testscheme := scheme.Scheme
@@ -182,6 +187,40 @@ func Test_getClusterName(t *testing.T) {
 		},
 	}
 
+	defer func(labels []string) { legacyClusterLabels = labels }(legacyClusterLabels)
+	legacyClusterLabels = []string{"cluster.open-cluster-management.io/name", "legacy/cluster-name"}
+
+	testCSRLegacyLabel := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: csrNameReconcile,
+			Labels: map[string]string{
+				"legacy/cluster-name": clusterName,
+			},
+		},
+	}
+
+	testCSRBothLabels := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: csrNameReconcile,
+			Labels: map[string]string{
+				"cluster.open-cluster-management.io/name": "legacycluster",
+				"legacy/cluster-name":                     "oldercluster",
+				clusterLabel:                              clusterName,
+			},
+		},
+	}
+
+	testCSRLegacyLabels := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: csrNameReconcile,
+			Labels: map[string]string{
+				"cluster.open-cluster-management.io/name": "legacycluster",
+				"legacy/cluster-name":                     "oldercluster",
+				clusterLabel:                              "",
+			},
+		},
+	}
+
 	type args struct {
 		csr *certificatesv1.CertificateSigningRequest
 	}
@@ -190,6 +229,27 @@ func Test_getClusterName(t *testing.T) {
 		args            args
 		wantClusterName string
 	}{
+		{
+			name: "testCSRLegacyLabel",
+			args: args{
+				csr: testCSRLegacyLabel,
+			},
+			wantClusterName: clusterName,
+		},
+		{
+			name: "testCSRBothLabels",
+			args: args{
+				csr: testCSRBothLabels,
+			},
+			wantClusterName: clusterName,
+		},
+		{
+			name: "testCSRLegacyLabels",
+			args: args{
+				csr: testCSRLegacyLabels,
+			},
+			wantClusterName: "legacycluster",
+		},
 		{
 			name: "testCSR",
 			args: args{
@@ -360,7 +420,7 @@ func Test_validUsername(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := validUsername(tt.args.csr, tt.args.clusterName); got != tt.want {
+			if got := validUsername(tt.args.csr, tt.args.clusterName, nil); got != tt.want {
 				t.Errorf("validUsername() = %v, want %v", got, tt.want)
 			}
 		})
@@ -417,9 +477,361 @@ func Test_csrPredicate(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := csrPredicate(tt.args.csr); got != tt.want {
+			if got := csrPredicate(tt.args.csr, nil); got != tt.want {
 				t.Errorf("csrPredicate() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func Test_crossNamespaceRequest(t *testing.T) {
+	newCSR := func(username string) *certificatesv1.CertificateSigningRequest {
+		return &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: csrNameReconcile,
+				Labels: map[string]string{
+					clusterLabel: clusterName,
+				},
+			},
+			Spec: certificatesv1.CertificateSigningRequestSpec{
+				Username: username,
+			},
+		}
+	}
+
+	tests := []struct {
+		name               string
+		csr                *certificatesv1.CertificateSigningRequest
+		wantValid          bool
+		wantCrossNamespace bool
+	}{
+		{
+			name:               "consistent namespace",
+			csr:                newCSR(fmt.Sprintf(userNameSignature, clusterName, clusterName)),
+			wantValid:          true,
+			wantCrossNamespace: false,
+		},
+		{
+			name:               "bootstrap sa of another cluster",
+			csr:                newCSR(fmt.Sprintf(userNameSignature, "othercluster", "othercluster")),
+			wantValid:          false,
+			wantCrossNamespace: true,
+		},
+		{
+			name:               "cluster bootstrap sa in another namespace",
+			csr:                newCSR(fmt.Sprintf(userNameSignature, "othercluster", clusterName)),
+			wantValid:          false,
+			wantCrossNamespace: true,
+		},
+		{
+			name:               "not a bootstrap sa",
+			csr:                newCSR("system:serviceaccount:othercluster:default"),
+			wantValid:          false,
+			wantCrossNamespace: false,
+		},
+		{
+			name:               "not a service account",
+			csr:                newCSR("kube-admin"),
+			wantValid:          false,
+			wantCrossNamespace: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validUsername(tt.csr, clusterName, nil); got != tt.wantValid {
+				t.Errorf("validUsername() = %v, want %v", got, tt.wantValid)
+			}
+			if got := crossNamespaceRequest(tt.csr, clusterName); got != tt.wantCrossNamespace {
+				t.Errorf("crossNamespaceRequest() = %v, want %v", got, tt.wantCrossNamespace)
+			}
+			if got := csrPredicate(tt.csr, nil); got != (tt.wantValid || tt.wantCrossNamespace) {
+				t.Errorf("csrPredicate() = %v", got)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_ReconcileCrossNamespace(t *testing.T) {
+	testCSR := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: csrNameReconcile,
+			Labels: map[string]string{
+				clusterLabel: clusterName,
+			},
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Username: fmt.Sprintf(userNameSignature, "othercluster", "othercluster"),
+		},
+	}
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: clusterName,
+		},
+		Spec: clusterv1.ManagedClusterSpec{
+			HubAcceptsClient: true,
+		},
+	}
+
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	r := &ReconcileCSR{
+		client:     fake.NewFakeClientWithScheme(testscheme, testManagedCluster, testCSR),
+		kubeClient: fakeclientset.NewSimpleClientset(testCSR),
+		scheme:     testscheme,
+	}
+	if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}}); err != nil {
+		t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+	}
+	csr, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), csrNameReconcile, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if getApprovalType(csr) != string(certificatesv1.CertificateDenied) {
+		t.Errorf("CSR requested from another cluster namespace should be denied, got %v", csr.Status.Conditions)
+	}
+}
+
+func TestReconcileCSR_ReconcileManualApproval(t *testing.T) {
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: clusterName,
+		},
+		Spec: clusterv1.ManagedClusterSpec{
+			HubAcceptsClient: true,
+		},
+	}
+
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	tests := []struct {
+		name         string
+		annotations  map[string]string
+		wantApproval string
+	}{
+		{
+			name:         "not annotated",
+			wantApproval: string(certificatesv1.CertificateApproved),
+		},
+		{
+			name:         "manual approval",
+			annotations:  map[string]string{manualApprovalAnnotation: "true"},
+			wantApproval: "",
+		},
+		{
+			name:         "manual approval disabled",
+			annotations:  map[string]string{manualApprovalAnnotation: "false"},
+			wantApproval: string(certificatesv1.CertificateApproved),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testCSR := &certificatesv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: csrNameReconcile,
+					Labels: map[string]string{
+						clusterLabel: clusterName,
+					},
+					Annotations: tt.annotations,
+				},
+				Spec: certificatesv1.CertificateSigningRequestSpec{
+					Username: fmt.Sprintf(userNameSignature, clusterName, clusterName),
+					Request:  newCSRRequest(t, "system:open-cluster-management:"+clusterName, nil),
+				},
+			}
+			r := &ReconcileCSR{
+				client:     fake.NewFakeClientWithScheme(testscheme, testManagedCluster, testCSR),
+				kubeClient: fakeclientset.NewSimpleClientset(testCSR),
+				scheme:     testscheme,
+			}
+			if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}}); err != nil {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+			}
+			csr, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), csrNameReconcile, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if approval := getApprovalType(csr); approval != tt.wantApproval {
+				t.Errorf("CSR approval = %q, want %q", approval, tt.wantApproval)
+			}
+		})
+	}
+}
+
+func Test_setApprovalCondition(t *testing.T) {
+	approvedAt := metav1.NewTime(time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC))
+	now := metav1.NewTime(approvedAt.Add(time.Hour))
+	approved := certificatesv1.CertificateSigningRequestCondition{
+		Type:   certificatesv1.CertificateApproved,
+		Status: corev1.ConditionTrue,
+		Reason: "AutoApprovedByCSRController",
+	}
+	alreadyApproved := approved
+	alreadyApproved.LastUpdateTime = approvedAt
+	alreadyApproved.LastTransitionTime = approvedAt
+	notApproved := alreadyApproved
+	notApproved.Status = corev1.ConditionFalse
+
+	tests := []struct {
+		name                   string
+		apiVersion             string
+		conditions             []certificatesv1.CertificateSigningRequestCondition
+		wantLastUpdateTime     metav1.Time
+		wantLastTransitionTime metav1.Time
+	}{
+		{
+			name:                   "v1 approval",
+			apiVersion:             certificatesV1,
+			wantLastUpdateTime:     now,
+			wantLastTransitionTime: now,
+		},
+		{
+			name:                   "v1 re-approval keeps the transition time",
+			apiVersion:             certificatesV1,
+			conditions:             []certificatesv1.CertificateSigningRequestCondition{alreadyApproved},
+			wantLastUpdateTime:     now,
+			wantLastTransitionTime: approvedAt,
+		},
+		{
+			name:                   "v1 status change updates the transition time",
+			apiVersion:             certificatesV1,
+			conditions:             []certificatesv1.CertificateSigningRequestCondition{notApproved},
+			wantLastUpdateTime:     now,
+			wantLastTransitionTime: now,
+		},
+		{
+			name:               "v1beta1 approval",
+			apiVersion:         certificatesV1beta1,
+			wantLastUpdateTime: now,
+		},
+		{
+			name:               "v1beta1 re-approval",
+			apiVersion:         certificatesV1beta1,
+			conditions:         []certificatesv1.CertificateSigningRequestCondition{{Type: approved.Type, Status: approved.Status, LastUpdateTime: approvedAt}},
+			wantLastUpdateTime: now,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conditions := setApprovalCondition(tt.conditions, approved, tt.apiVersion, now)
+			if len(conditions) != 1 {
+				t.Fatalf("setApprovalCondition() returned %d conditions, want 1", len(conditions))
+			}
+			got := conditions[0]
+			if !got.LastUpdateTime.Equal(&tt.wantLastUpdateTime) {
+				t.Errorf("lastUpdateTime = %v, want %v", got.LastUpdateTime, tt.wantLastUpdateTime)
+			}
+			if !got.LastTransitionTime.Equal(&tt.wantLastTransitionTime) {
+				t.Errorf("lastTransitionTime = %v, want %v", got.LastTransitionTime, tt.wantLastTransitionTime)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_ReconcileDecided(t *testing.T) {
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: clusterName,
+		},
+		Spec: clusterv1.ManagedClusterSpec{
+			HubAcceptsClient: true,
+		},
+	}
+
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	tests := []struct {
+		name          string
+		conditions    []certificatesv1.CertificateSigningRequestCondition
+		wantApproval  string
+		wantApprovals int
+	}{
+		{
+			name:          "pending",
+			wantApproval:  string(certificatesv1.CertificateApproved),
+			wantApprovals: 1,
+		},
+		{
+			name: "approved",
+			conditions: []certificatesv1.CertificateSigningRequestCondition{
+				{Type: certificatesv1.CertificateApproved, Status: corev1.ConditionTrue, Reason: string(ReasonAutoApproved)},
+			},
+			wantApproval: string(certificatesv1.CertificateApproved),
+		},
+		{
+			name: "denied",
+			conditions: []certificatesv1.CertificateSigningRequestCondition{
+				{Type: certificatesv1.CertificateDenied, Status: corev1.ConditionTrue, Reason: string(ReasonClusterNotOwned)},
+			},
+			wantApproval: string(certificatesv1.CertificateDenied),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testCSR := &certificatesv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: csrNameReconcile,
+					Labels: map[string]string{
+						clusterLabel: clusterName,
+					},
+				},
+				Spec: certificatesv1.CertificateSigningRequestSpec{
+					Username: fmt.Sprintf(userNameSignature, clusterName, clusterName),
+					Request:  newCSRRequest(t, "system:open-cluster-management:"+clusterName, nil),
+				},
+				Status: certificatesv1.CertificateSigningRequestStatus{
+					Conditions: tt.conditions,
+				},
+			}
+			kubeClient := fakeclientset.NewSimpleClientset(testCSR)
+			r := &ReconcileCSR{
+				client:     fake.NewFakeClientWithScheme(testscheme, testManagedCluster, testCSR),
+				kubeClient: kubeClient,
+				scheme:     testscheme,
+			}
+			for i := 0; i < 3; i++ {
+				if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}}); err != nil {
+					t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+				}
+				// the cache catches up with the decision of the controller
+				csr, err := kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), csrNameReconcile, metav1.GetOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				cached := &certificatesv1.CertificateSigningRequest{}
+				if err := r.client.Get(context.TODO(), types.NamespacedName{Name: csrNameReconcile}, cached); err != nil {
+					t.Fatal(err)
+				}
+				csr.ResourceVersion = cached.ResourceVersion
+				if err := r.client.Update(context.TODO(), csr); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			csr, err := kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), csrNameReconcile, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if approval := getApprovalType(csr); approval != tt.wantApproval {
+				t.Errorf("CSR approval = %q, want %q", approval, tt.wantApproval)
+			}
+			if len(csr.Status.Conditions) != 1 {
+				t.Errorf("CSR conditions = %v, want a single condition", csr.Status.Conditions)
+			}
+			approvals := 0
+			for _, action := range kubeClient.Actions() {
+				if action.GetVerb() == "update" && action.GetSubresource() == "approval" {
+					approvals++
+				}
+			}
+			if approvals != tt.wantApprovals {
+				t.Errorf("approval updates = %d, want %d", approvals, tt.wantApprovals)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// denialCooldownEnvVarName is the duration during which the new csrs of a cluster are not evaluated
+// after a csr of the cluster was denied, there is no cooldown if not set
+const denialCooldownEnvVarName = "CSR_DENIAL_COOLDOWN"
+
+// denialCooldown records the last denial of each cluster to skip the csrs resubmitted right after a denial.
+// A nil denialCooldown never skips a csr.
+type denialCooldown struct {
+	window   time.Duration
+	lock     sync.Mutex
+	deniedAt map[string]time.Time
+}
+
+// newDenialCooldown returns a cooldown of window, nil if window is not positive
+func newDenialCooldown(window time.Duration) *denialCooldown {
+	if window <= 0 {
+		return nil
+	}
+	return &denialCooldown{
+		window:   window,
+		deniedAt: map[string]time.Time{},
+	}
+}
+
+// getDenialCooldown returns the CSR_DENIAL_COOLDOWN value, 0 if not set
+func getDenialCooldown() (time.Duration, error) {
+	v := os.Getenv(denialCooldownEnvVarName)
+	if v == "" {
+		return 0, nil
+	}
+	window, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value %q: %v", denialCooldownEnvVarName, v, err)
+	}
+	return window, nil
+}
+
+// recordDenial starts the cooldown of the cluster
+func (c *denialCooldown) recordDenial(clusterName string, now time.Time) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.deniedAt[clusterName] = now
+}
+
+// remaining returns the remaining cooldown of the cluster, 0 if the cluster is not cooling down
+func (c *denialCooldown) remaining(clusterName string, now time.Time) time.Duration {
+	if c == nil {
+		return 0
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	deniedAt, ok := c.deniedAt[clusterName]
+	if !ok {
+		return 0
+	}
+	remaining := deniedAt.Add(c.window).Sub(now)
+	if remaining <= 0 {
+		delete(c.deniedAt, clusterName)
+		return 0
+	}
+	return remaining
+}
@@ -0,0 +1,205 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func Test_denialCooldown_remaining(t *testing.T) {
+	deniedAt := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		cooldown *denialCooldown
+		cluster  string
+		now      time.Time
+		want     time.Duration
+	}{
+		{
+			name:     "no cooldown",
+			cooldown: newDenialCooldown(0),
+			cluster:  clusterName,
+			now:      deniedAt,
+			want:     0,
+		},
+		{
+			name:     "within the cooldown",
+			cooldown: newDenialCooldown(time.Minute),
+			cluster:  clusterName,
+			now:      deniedAt.Add(20 * time.Second),
+			want:     40 * time.Second,
+		},
+		{
+			name:     "after the cooldown",
+			cooldown: newDenialCooldown(time.Minute),
+			cluster:  clusterName,
+			now:      deniedAt.Add(2 * time.Minute),
+			want:     0,
+		},
+		{
+			name:     "other cluster",
+			cooldown: newDenialCooldown(time.Minute),
+			cluster:  "othercluster",
+			now:      deniedAt.Add(20 * time.Second),
+			want:     0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cooldown.recordDenial(clusterName, deniedAt)
+			if got := tt.cooldown.remaining(tt.cluster, tt.now); got != tt.want {
+				t.Errorf("remaining() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_ReconcileDenialCooldown(t *testing.T) {
+	newCSR := func(name, namespace string) *certificatesv1.CertificateSigningRequest {
+		return &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+				Labels: map[string]string{
+					clusterLabel: clusterName,
+				},
+			},
+			Spec: certificatesv1.CertificateSigningRequestSpec{
+				Username: fmt.Sprintf(userNameSignature, namespace, namespace),
+				Request:  newCSRRequest(t, "system:open-cluster-management:"+clusterName, nil),
+			},
+		}
+	}
+	// the cluster itself requests an invalid csr
+	deniedCSR := newCSR("csr-denied", clusterName)
+	deniedCSR.Spec.Request = []byte("invalid")
+	resubmittedCSR := newCSR("csr-resubmitted", clusterName)
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: clusterName,
+		},
+		Spec: clusterv1.ManagedClusterSpec{
+			HubAcceptsClient: true,
+		},
+	}
+
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	r := &ReconcileCSR{
+		client:         fake.NewFakeClientWithScheme(testscheme, testManagedCluster, deniedCSR, resubmittedCSR),
+		kubeClient:     fakeclientset.NewSimpleClientset(deniedCSR, resubmittedCSR),
+		scheme:         testscheme,
+		denialCooldown: newDenialCooldown(time.Minute),
+
+		invalidRequestAction: invalidRequestActionDeny,
+	}
+	message := ""
+	reconcileCSR := func(name string) (reconcile.Result, string) {
+		res, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: name}})
+		if err != nil {
+			t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+		}
+		csr, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		message = csr.Annotations[ReasonMessageAnnotation]
+		return res, getApprovalType(csr)
+	}
+
+	if _, approval := reconcileCSR(deniedCSR.Name); approval != string(certificatesv1.CertificateDenied) {
+		t.Fatalf("CSR approval = %q, want denied", approval)
+	}
+
+	res, approval := reconcileCSR(resubmittedCSR.Name)
+	if approval != "" {
+		t.Errorf("CSR resubmitted during the cooldown should be skipped, got approval %q", approval)
+	}
+	if !res.Requeue || res.RequeueAfter <= 0 || res.RequeueAfter > time.Minute {
+		t.Errorf("CSR resubmitted during the cooldown should be requeued after the cooldown, got %v", res)
+	}
+	// the message does not change across the reconciles, an update of the csr would reconcile it again
+	cooldownMessage := message
+	time.Sleep(10 * time.Millisecond)
+	reconcileCSR(resubmittedCSR.Name)
+	if message != cooldownMessage {
+		t.Errorf("CSR message during the cooldown = %q, want the stable %q", message, cooldownMessage)
+	}
+
+	// end the cooldown
+	r.denialCooldown.recordDenial(clusterName, time.Now().Add(-2*time.Minute))
+	if _, approval := reconcileCSR(resubmittedCSR.Name); approval != string(certificatesv1.CertificateApproved) {
+		t.Errorf("CSR approval after the cooldown = %q, want approved", approval)
+	}
+}
+
+func TestReconcileCSR_ReconcileDenialCooldownForeignRequester(t *testing.T) {
+	newCSR := func(name, namespace string) *certificatesv1.CertificateSigningRequest {
+		return &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{clusterLabel: clusterName},
+			},
+			Spec: certificatesv1.CertificateSigningRequestSpec{
+				Username: fmt.Sprintf(userNameSignature, namespace, namespace),
+				Request:  newCSRRequest(t, "system:open-cluster-management:"+clusterName, nil),
+			},
+		}
+	}
+	// another cluster claims the cluster name label of the cluster, it is denied with ClusterNamespaceMismatch
+	foreignCSR := newCSR("csr-foreign", "othercluster")
+	bootstrapCSR := newCSR("csr-bootstrap", clusterName)
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterName},
+		Spec:       clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+	}
+
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	r := &ReconcileCSR{
+		client:         fake.NewFakeClientWithScheme(testscheme, testManagedCluster, foreignCSR, bootstrapCSR),
+		kubeClient:     fakeclientset.NewSimpleClientset(foreignCSR, bootstrapCSR),
+		scheme:         testscheme,
+		denialCooldown: newDenialCooldown(time.Minute),
+	}
+	for _, csr := range []*certificatesv1.CertificateSigningRequest{foreignCSR, bootstrapCSR} {
+		if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csr.Name}}); err != nil {
+			t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+		}
+	}
+
+	foreign, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), foreignCSR.Name,
+		metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if approval := getApprovalType(foreign); approval != string(certificatesv1.CertificateDenied) {
+		t.Fatalf("foreign CSR approval = %q, want denied", approval)
+	}
+	if remaining := r.denialCooldown.remaining(clusterName, time.Now()); remaining != 0 {
+		t.Errorf("cooldown of the cluster after the denial of a foreign CSR = %v, want none", remaining)
+	}
+	bootstrap, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), bootstrapCSR.Name,
+		metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if approval := getApprovalType(bootstrap); approval != string(certificatesv1.CertificateApproved) {
+		t.Errorf("bootstrap CSR approval after the denial of a foreign CSR = %q, want approved", approval)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// crossCheckAnnotationEnvVarName is the annotation an independent peer controller sets to true on a csr once it
+// verified the csr, the csrs are approved only after the peer verification. The csrs are not cross checked if not set.
+const crossCheckAnnotationEnvVarName = "CSR_CROSS_CHECK_ANNOTATION"
+
+// getCrossCheckAnnotation returns the CSR_CROSS_CHECK_ANNOTATION value, empty if not set
+func getCrossCheckAnnotation() (string, error) {
+	v := os.Getenv(crossCheckAnnotationEnvVarName)
+	if v == "" {
+		return "", nil
+	}
+	if errs := validation.IsQualifiedName(v); len(errs) != 0 {
+		return "", fmt.Errorf("invalid %s value %q: %s", crossCheckAnnotationEnvVarName, v, strings.Join(errs, ", "))
+	}
+	return v, nil
+}
+
+// verifyCrossCheck returns an error if the csr does not carry the cross check annotation set to true by a peer,
+// any csr passes if annotation is empty
+func verifyCrossCheck(csr *certificatesv1.CertificateSigningRequest, annotation string) error {
+	if annotation == "" {
+		return nil
+	}
+	v, ok := csr.Annotations[annotation]
+	if !ok {
+		return fmt.Errorf("the CSR is not cross checked yet, the %s annotation is missing", annotation)
+	}
+	if v != "true" {
+		return fmt.Errorf("the CSR is not cross checked, the %s annotation is %q", annotation, v)
+	}
+	return verifyCrossCheckPeer(csr, annotation)
+}
+
+// verifyCrossCheckPeer returns an error if the cross check annotation of the csr is not set by a peer. The field
+// managers of the csr tell who set the annotation: the requester creating the csr owns its spec, a peer annotating
+// the csr afterwards owns the annotation only. A csr created with the annotation is never cross checked.
+func verifyCrossCheckPeer(csr *certificatesv1.CertificateSigningRequest, annotation string) error {
+	peer := false
+	for _, entry := range csr.ManagedFields {
+		if entry.FieldsV1 == nil {
+			continue
+		}
+		fields := map[string]interface{}{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			return fmt.Errorf("the managed fields of %s can not be parsed: %v", entry.Manager, err)
+		}
+		if !ownsAnnotation(fields, annotation) {
+			continue
+		}
+		if _, ok := fields["f:spec"]; ok {
+			return fmt.Errorf("the CSR is not cross checked, the %s annotation is set by its requester %s",
+				annotation, entry.Manager)
+		}
+		peer = true
+	}
+	if !peer {
+		return fmt.Errorf("the CSR is not cross checked, the peer setting the %s annotation is unknown", annotation)
+	}
+	return nil
+}
+
+// ownsAnnotation checks the fields of a field manager include the annotation
+func ownsAnnotation(fields map[string]interface{}, annotation string) bool {
+	metadata, _ := fields["f:metadata"].(map[string]interface{})
+	annotations, _ := metadata["f:annotations"].(map[string]interface{})
+	_, ok := annotations["f:"+annotation]
+	return ok
+}
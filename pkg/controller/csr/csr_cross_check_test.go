@@ -0,0 +1,172 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const testCrossCheckAnnotation = "security.example.com/csr-verified"
+
+func Test_getCrossCheckAnnotation(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:  "valid",
+			value: testCrossCheckAnnotation,
+			want:  testCrossCheckAnnotation,
+		},
+		{
+			name:    "invalid",
+			value:   "csr verified",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(crossCheckAnnotationEnvVarName, tt.value)
+			defer os.Unsetenv(crossCheckAnnotationEnvVarName)
+			got, err := getCrossCheckAnnotation()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getCrossCheckAnnotation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("getCrossCheckAnnotation() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_ReconcileCrossCheck(t *testing.T) {
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: clusterName,
+		},
+		Spec: clusterv1.ManagedClusterSpec{
+			HubAcceptsClient: true,
+		},
+	}
+
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	request := newCSRRequest(t, "system:open-cluster-management:"+clusterName, nil)
+	newCSR := func(annotations map[string]string) *certificatesv1.CertificateSigningRequest {
+		csr := newDedupCSR(csrNameReconcile, request)
+		csr.Annotations = annotations
+		return csr
+	}
+	annotationFields := `"f:metadata":{"f:annotations":{"f:` + testCrossCheckAnnotation + `":{}}}`
+	// the requester creates the csr, its field manager owns the spec
+	requesterFields := metav1.ManagedFieldsEntry{
+		Manager:    "kubectl-create",
+		Operation:  metav1.ManagedFieldsOperationUpdate,
+		FieldsType: "FieldsV1",
+		FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:request":{},"f:signerName":{}}}`)},
+	}
+	peerFields := metav1.ManagedFieldsEntry{
+		Manager:    "csr-verifier",
+		Operation:  metav1.ManagedFieldsOperationUpdate,
+		FieldsType: "FieldsV1",
+		FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{` + annotationFields + `}`)},
+	}
+	crossChecked := newCSR(map[string]string{testCrossCheckAnnotation: "true"})
+	crossChecked.ManagedFields = []metav1.ManagedFieldsEntry{requesterFields, peerFields}
+	// the requester sets the annotation itself when it creates the csr
+	selfChecked := newCSR(map[string]string{testCrossCheckAnnotation: "true"})
+	selfChecked.ManagedFields = []metav1.ManagedFieldsEntry{requesterFields}
+	selfChecked.ManagedFields[0].FieldsV1 = &metav1.FieldsV1{
+		Raw: []byte(`{` + annotationFields + `,"f:spec":{"f:request":{},"f:signerName":{}}}`),
+	}
+	unknownPeer := newCSR(map[string]string{testCrossCheckAnnotation: "true"})
+
+	tests := []struct {
+		name                 string
+		crossCheckAnnotation string
+		csr                  *certificatesv1.CertificateSigningRequest
+		wantApproved         bool
+		wantCode             ReasonCode
+	}{
+		{
+			name:         "cross check disabled",
+			csr:          newCSR(nil),
+			wantApproved: true,
+			wantCode:     ReasonAutoApproved,
+		},
+		{
+			name:                 "cross checked",
+			crossCheckAnnotation: testCrossCheckAnnotation,
+			csr:                  crossChecked,
+			wantApproved:         true,
+			wantCode:             ReasonAutoApproved,
+		},
+		{
+			name:                 "annotation set by the requester",
+			crossCheckAnnotation: testCrossCheckAnnotation,
+			csr:                  selfChecked,
+			wantCode:             ReasonCrossCheckPending,
+		},
+		{
+			name:                 "annotation without field manager",
+			crossCheckAnnotation: testCrossCheckAnnotation,
+			csr:                  unknownPeer,
+			wantCode:             ReasonCrossCheckPending,
+		},
+		{
+			name:                 "peer annotation missing",
+			crossCheckAnnotation: testCrossCheckAnnotation,
+			csr:                  newCSR(nil),
+			wantCode:             ReasonCrossCheckPending,
+		},
+		{
+			name:                 "peer annotation not true",
+			crossCheckAnnotation: testCrossCheckAnnotation,
+			csr:                  newCSR(map[string]string{testCrossCheckAnnotation: "false"}),
+			wantCode:             ReasonCrossCheckPending,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ReconcileCSR{
+				client:               fake.NewFakeClientWithScheme(testscheme, tt.csr, testManagedCluster),
+				kubeClient:           fakeclientset.NewSimpleClientset(tt.csr),
+				scheme:               testscheme,
+				crossCheckAnnotation: tt.crossCheckAnnotation,
+			}
+			req := reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}}
+			if _, err := r.Reconcile(req); err != nil {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+			}
+			csr, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(),
+				csrNameReconcile, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if approved := getApprovalType(csr) == string(certificatesv1.CertificateApproved); approved != tt.wantApproved {
+				t.Errorf("CSR approved = %v, want %v", approved, tt.wantApproved)
+			}
+			if code := csr.Annotations[ReasonCodeAnnotation]; code != string(tt.wantCode) {
+				t.Errorf("CSR reason code = %q, want %q", code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+)
+
+// dedupWindowEnvVarName is the duration during which the csrs carrying the same request as an approved csr
+// are not approved, the csrs are not deduplicated if not set
+const dedupWindowEnvVarName = "CSR_DEDUP_WINDOW"
+
+// csrDeduplicator records the fingerprint of the requests of the approved csrs so that the retries of an agent,
+// new csrs with an identical request, are approved only once.
+// A nil csrDeduplicator never skips a csr.
+type csrDeduplicator struct {
+	window time.Duration
+	lock   sync.Mutex
+	claims map[string]requestClaim
+}
+
+// requestClaim is the csr which claimed a request fingerprint and the claim time
+type requestClaim struct {
+	csrName   string
+	claimedAt time.Time
+}
+
+// newCSRDeduplicator returns a deduplicator of window, nil if window is not positive
+func newCSRDeduplicator(window time.Duration) *csrDeduplicator {
+	if window <= 0 {
+		return nil
+	}
+	return &csrDeduplicator{
+		window: window,
+		claims: map[string]requestClaim{},
+	}
+}
+
+// getDedupWindow returns the CSR_DEDUP_WINDOW value, 0 if not set
+func getDedupWindow() (time.Duration, error) {
+	v := os.Getenv(dedupWindowEnvVarName)
+	if v == "" {
+		return 0, nil
+	}
+	window, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value %q: %v", dedupWindowEnvVarName, v, err)
+	}
+	return window, nil
+}
+
+// requestFingerprint returns the hex encoded SHA-256 of the request of the csr
+func requestFingerprint(csr *certificatesv1.CertificateSigningRequest) string {
+	sum := sha256.Sum256(csr.Spec.Request)
+	return hex.EncodeToString(sum[:])
+}
+
+// claim claims the request of the csr before its approval. It returns false and the name of the claiming csr
+// if another csr claimed the same request within the window.
+func (d *csrDeduplicator) claim(csr *certificatesv1.CertificateSigningRequest, now time.Time) (string, bool) {
+	if d == nil {
+		return "", true
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for fingerprint, c := range d.claims {
+		if now.Sub(c.claimedAt) > d.window {
+			delete(d.claims, fingerprint)
+		}
+	}
+	fingerprint := requestFingerprint(csr)
+	if c, ok := d.claims[fingerprint]; ok && c.csrName != csr.Name {
+		return c.csrName, false
+	}
+	d.claims[fingerprint] = requestClaim{
+		csrName:   csr.Name,
+		claimedAt: now,
+	}
+	return "", true
+}
+
+// release releases the claim of the csr on its request, the csr was not approved
+func (d *csrDeduplicator) release(csr *certificatesv1.CertificateSigningRequest) {
+	if d == nil {
+		return
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	fingerprint := requestFingerprint(csr)
+	if c, ok := d.claims[fingerprint]; ok && c.csrName == csr.Name {
+		delete(d.claims, fingerprint)
+	}
+}
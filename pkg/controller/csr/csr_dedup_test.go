@@ -0,0 +1,286 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func newDedupCSR(name string, request []byte) *certificatesv1.CertificateSigningRequest {
+	return &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				clusterLabel: clusterName,
+			},
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Username: fmt.Sprintf(userNameSignature, clusterName, clusterName),
+			Request:  request,
+		},
+	}
+}
+
+func Test_getDedupWindow(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:  "valid",
+			value: "2m",
+			want:  2 * time.Minute,
+		},
+		{
+			name:    "invalid",
+			value:   "two minutes",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(dedupWindowEnvVarName, tt.value)
+			defer os.Unsetenv(dedupWindowEnvVarName)
+			got, err := getDedupWindow()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getDedupWindow() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("getDedupWindow() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_csrDeduplicator_claim(t *testing.T) {
+	request := newCSRRequest(t, "system:open-cluster-management:"+clusterName, nil)
+	otherRequest := newCSRRequest(t, "system:open-cluster-management:"+clusterName, nil)
+	claimedAt := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		dedup       *csrDeduplicator
+		csr         *certificatesv1.CertificateSigningRequest
+		released    bool
+		now         time.Time
+		wantClaimed string
+		wantOK      bool
+	}{
+		{
+			name:   "no deduplicator",
+			csr:    newDedupCSR("csr-2", request),
+			now:    claimedAt,
+			wantOK: true,
+		},
+		{
+			name:        "duplicate",
+			dedup:       newCSRDeduplicator(time.Minute),
+			csr:         newDedupCSR("csr-2", request),
+			now:         claimedAt.Add(30 * time.Second),
+			wantClaimed: "csr-1",
+		},
+		{
+			name:   "same csr",
+			dedup:  newCSRDeduplicator(time.Minute),
+			csr:    newDedupCSR("csr-1", request),
+			now:    claimedAt.Add(30 * time.Second),
+			wantOK: true,
+		},
+		{
+			name:   "other request",
+			dedup:  newCSRDeduplicator(time.Minute),
+			csr:    newDedupCSR("csr-2", otherRequest),
+			now:    claimedAt.Add(30 * time.Second),
+			wantOK: true,
+		},
+		{
+			name:   "duplicate after the window",
+			dedup:  newCSRDeduplicator(time.Minute),
+			csr:    newDedupCSR("csr-2", request),
+			now:    claimedAt.Add(2 * time.Minute),
+			wantOK: true,
+		},
+		{
+			name:     "duplicate of a released claim",
+			dedup:    newCSRDeduplicator(time.Minute),
+			csr:      newDedupCSR("csr-2", request),
+			released: true,
+			now:      claimedAt.Add(30 * time.Second),
+			wantOK:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := newDedupCSR("csr-1", request)
+			tt.dedup.claim(first, claimedAt)
+			if tt.released {
+				tt.dedup.release(first)
+			}
+			claimed, ok := tt.dedup.claim(tt.csr, tt.now)
+			if claimed != tt.wantClaimed || ok != tt.wantOK {
+				t.Errorf("claim() = %q, %v, want %q, %v", claimed, ok, tt.wantClaimed, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_ReconcileDuplicates(t *testing.T) {
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: clusterName,
+		},
+		Spec: clusterv1.ManagedClusterSpec{
+			HubAcceptsClient: true,
+		},
+	}
+
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	request := newCSRRequest(t, "system:open-cluster-management:"+clusterName, nil)
+	duplicates := []string{"csr-1", "csr-2", "csr-3", "csr-4"}
+	objs := []runtime.Object{}
+	for _, name := range duplicates {
+		objs = append(objs, newDedupCSR(name, request))
+	}
+	other := newDedupCSR("csr-other", newCSRRequest(t, "system:open-cluster-management:"+clusterName, nil))
+	objs = append(objs, other)
+
+	r := &ReconcileCSR{
+		client:     fake.NewFakeClientWithScheme(testscheme, append(objs, testManagedCluster)...),
+		kubeClient: fakeclientset.NewSimpleClientset(objs...),
+		scheme:     testscheme,
+		dedup:      newCSRDeduplicator(time.Minute),
+	}
+	var wg sync.WaitGroup
+	for _, name := range append(duplicates, other.Name) {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: name}}); err != nil {
+				t.Errorf("ReconcileCSR.Reconcile(%s) error = %v", name, err)
+			}
+		}(name)
+	}
+	wg.Wait()
+
+	approvals := 0
+	for _, name := range duplicates {
+		csr, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch getApprovalType(csr) {
+		case string(certificatesv1.CertificateApproved):
+			approvals++
+		case "":
+			if code := csr.Annotations[ReasonCodeAnnotation]; code != string(ReasonDuplicateRequest) {
+				t.Errorf("CSR %s reason code = %q, want %q", name, code, ReasonDuplicateRequest)
+			}
+		}
+	}
+	if approvals != 1 {
+		t.Errorf("duplicate CSRs approved = %d, want 1", approvals)
+	}
+	csr, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), other.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if approval := getApprovalType(csr); approval != string(certificatesv1.CertificateApproved) {
+		t.Errorf("CSR %s approval = %q, want %q", other.Name, approval, certificatesv1.CertificateApproved)
+	}
+}
+
+func TestReconcileCSR_ReconcileDuplicatesRateLimit(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	request := newCSRRequest(t, "system:open-cluster-management:"+clusterName, nil)
+	approved := newDedupCSR("csr-1", request)
+	duplicate := newDedupCSR("csr-2", request)
+	other := newDedupCSR("csr-other", newCSRRequest(t, "system:open-cluster-management:"+clusterName, nil))
+	objs := []runtime.Object{approved, duplicate, other}
+
+	r := &ReconcileCSR{
+		client:              fake.NewFakeClientWithScheme(testscheme, append(objs, newAcceptedCluster(true))...),
+		kubeClient:          fakeclientset.NewSimpleClientset(objs...),
+		scheme:              testscheme,
+		dedup:               newCSRDeduplicator(time.Minute),
+		approvalRateLimiter: newApprovalRateLimiter(0.01, 2),
+	}
+	// the duplicate is skipped without taking an approval of the burst, the other csr is approved
+	for _, name := range []string{approved.Name, duplicate.Name, other.Name} {
+		if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: name}}); err != nil {
+			t.Fatalf("ReconcileCSR.Reconcile(%s) error = %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		wantApproval string
+		wantReason   ReasonCode
+	}{
+		{name: approved.Name, wantApproval: string(certificatesv1.CertificateApproved)},
+		{name: duplicate.Name, wantReason: ReasonDuplicateRequest},
+		{name: other.Name, wantApproval: string(certificatesv1.CertificateApproved)},
+	}
+	for _, tt := range tests {
+		csr, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), tt.name,
+			metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if approval := getApprovalType(csr); approval != tt.wantApproval {
+			t.Errorf("CSR %s approval = %q, want %q", tt.name, approval, tt.wantApproval)
+		}
+		if tt.wantReason != "" && csr.Annotations[ReasonCodeAnnotation] != string(tt.wantReason) {
+			t.Errorf("CSR %s reason code = %q, want %q", tt.name, csr.Annotations[ReasonCodeAnnotation], tt.wantReason)
+		}
+	}
+}
+
+func TestReconcileCSR_ReconcileDuplicatesDryRun(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	request := newCSRRequest(t, "system:open-cluster-management:"+clusterName, nil)
+	csr := newDedupCSR("csr-1", request)
+	r := &ReconcileCSR{
+		client:     fake.NewFakeClientWithScheme(testscheme, csr, newAcceptedCluster(true)),
+		kubeClient: fakeclientset.NewSimpleClientset(csr),
+		scheme:     testscheme,
+		dryRun:     true,
+		dedup:      newCSRDeduplicator(time.Minute),
+	}
+	if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csr.Name}}); err != nil {
+		t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+	}
+
+	// the csr is not approved in dry run, it does not keep its request claimed
+	if claimant, ok := r.dedup.claim(newDedupCSR("csr-2", request), time.Now()); !ok {
+		t.Errorf("claim() of the request evaluated in dry run = %s, want no claimant", claimant)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"encoding/json"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// denialNotificationConfigMapEnvVarName is the name of the configmap of the cluster namespace the denials of
+	// the csrs of the cluster are explained in, the denials are not notified if not set
+	denialNotificationConfigMapEnvVarName = "CSR_DENIAL_NOTIFICATION_CONFIGMAP"
+	// maxDenialNotifications is the number of the most recent denials kept in the notification configmap
+	maxDenialNotifications = 10
+	// denialNotificationLabel labels the denial notification configmaps
+	denialNotificationLabel = "import.open-cluster-management.io/csr-denial-notification"
+)
+
+// denialNotification is the explanation of the denial of a csr, keyed by the csr name in the notification configmap
+type denialNotification struct {
+	Reason   string `json:"reason"`
+	Message  string `json:"message"`
+	DeniedAt string `json:"deniedAt"`
+}
+
+// notifyDenial records the denial of the csr in the notification configmap of the cluster namespace, so the
+// users of the namespace without read access to the csrs can see why the cluster does not join.
+// Only the maxDenialNotifications most recent denials are kept.
+func (r *ReconcileCSR) notifyDenial(
+	csr *certificatesv1.CertificateSigningRequest,
+	clusterName string,
+	reason ReasonCode,
+	message string,
+	now time.Time) error {
+	if r.denialNotificationConfigMapName == "" || clusterName == "" {
+		return nil
+	}
+	b, err := json.Marshal(denialNotification{
+		Reason:   string(reason),
+		Message:  message,
+		DeniedAt: now.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+
+	configMaps := r.kubeClient.CoreV1().ConfigMaps(clusterName)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		ctx, cancel := r.apiCallContext()
+		defer cancel()
+		configMap, err := configMaps.Get(ctx, r.denialNotificationConfigMapName, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			_, err = configMaps.Create(ctx, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      r.denialNotificationConfigMapName,
+					Namespace: clusterName,
+					Labels:    map[string]string{denialNotificationLabel: "true"},
+				},
+				Data: map[string]string{csr.Name: string(b)},
+			}, metav1.CreateOptions{})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if configMap.Data == nil {
+			configMap.Data = map[string]string{}
+		}
+		configMap.Data[csr.Name] = string(b)
+		pruneDenialNotifications(configMap.Data)
+		_, err = configMaps.Update(ctx, configMap, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// pruneDenialNotifications removes the oldest denials of data above maxDenialNotifications
+func pruneDenialNotifications(data map[string]string) {
+	for len(data) > maxDenialNotifications {
+		oldest, oldestAt := "", ""
+		for name, v := range data {
+			notification := denialNotification{}
+			// an unparsable entry is the oldest
+			_ = json.Unmarshal([]byte(v), &notification)
+			if oldest == "" || notification.DeniedAt < oldestAt ||
+				(notification.DeniedAt == oldestAt && name < oldest) {
+				oldest, oldestAt = name, notification.DeniedAt
+			}
+		}
+		delete(data, oldest)
+	}
+}
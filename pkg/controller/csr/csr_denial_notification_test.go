@@ -0,0 +1,151 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const denialNotificationConfigMapName = "csr-denials"
+
+func getDenialNotifications(t *testing.T, r *ReconcileCSR) map[string]denialNotification {
+	configMap, err := r.kubeClient.CoreV1().ConfigMaps(clusterName).Get(context.TODO(), denialNotificationConfigMapName,
+		metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifications := map[string]denialNotification{}
+	for name, v := range configMap.Data {
+		notification := denialNotification{}
+		if err := json.Unmarshal([]byte(v), &notification); err != nil {
+			t.Fatalf("invalid denial notification %q: %v", v, err)
+		}
+		notifications[name] = notification
+	}
+	return notifications
+}
+
+func TestReconcileCSR_ReconcileDenialNotification(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	// the cluster itself requests an invalid csr
+	denied := newDedupCSR(csrNameReconcile, nil)
+	foreign := newDedupCSR("csr-foreign", newCSRRequest(t, clusterCommonNamePrefix+clusterName, nil))
+	foreign.Spec.Username = fmt.Sprintf(userNameSignature, "othercluster", "othercluster")
+	approved := newDedupCSR("csr-approved", newCSRRequest(t, clusterCommonNamePrefix+clusterName, nil))
+
+	tests := []struct {
+		name          string
+		configMapName string
+		csr           *certificatesv1.CertificateSigningRequest
+		wantNotified  bool
+	}{
+		{
+			name:          "denied",
+			configMapName: denialNotificationConfigMapName,
+			csr:           denied,
+			wantNotified:  true,
+		},
+		{
+			name:          "denied csr of another requester",
+			configMapName: denialNotificationConfigMapName,
+			csr:           foreign,
+		},
+		{
+			name:          "approved",
+			configMapName: denialNotificationConfigMapName,
+			csr:           approved,
+		},
+		{
+			name: "disabled",
+			csr:  denied,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr := tt.csr.DeepCopy()
+			r := &ReconcileCSR{
+				client:                          fake.NewFakeClientWithScheme(testscheme, newAcceptedCluster(true), csr),
+				kubeClient:                      fakeclientset.NewSimpleClientset(csr),
+				scheme:                          testscheme,
+				denialNotificationConfigMapName: tt.configMapName,
+				invalidRequestAction:            invalidRequestActionDeny,
+			}
+			if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csr.Name}}); err != nil {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+			}
+
+			configMaps, err := r.kubeClient.CoreV1().ConfigMaps(clusterName).List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.wantNotified {
+				if len(configMaps.Items) != 0 {
+					t.Errorf("configmaps = %v, want no denial notification", configMaps.Items)
+				}
+				return
+			}
+			notification, ok := getDenialNotifications(t, r)[csr.Name]
+			if !ok {
+				t.Fatalf("the denial of %s is not notified", csr.Name)
+			}
+			if notification.Reason != string(ReasonInvalidCertificateRequest) || notification.Message == "" {
+				t.Errorf("denial notification = %+v, want the %s reason and its message", notification,
+					ReasonInvalidCertificateRequest)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_notifyDenialPruning(t *testing.T) {
+	existing := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      denialNotificationConfigMapName,
+			Namespace: clusterName,
+		},
+		Data: map[string]string{},
+	}
+	now := time.Now()
+	for i := 0; i < maxDenialNotifications; i++ {
+		b, _ := json.Marshal(denialNotification{
+			Reason:   string(ReasonClusterNamespaceMismatch),
+			DeniedAt: now.Add(time.Duration(i-maxDenialNotifications) * time.Minute).UTC().Format(time.RFC3339),
+		})
+		existing.Data[fmt.Sprintf("csr-%d", i)] = string(b)
+	}
+	r := &ReconcileCSR{
+		kubeClient:                      fakeclientset.NewSimpleClientset(existing),
+		denialNotificationConfigMapName: denialNotificationConfigMapName,
+	}
+
+	if err := r.notifyDenial(newDedupCSR("csr-new", nil), clusterName, ReasonInvalidCertificateRequest, "invalid",
+		now); err != nil {
+		t.Fatalf("notifyDenial() error = %v", err)
+	}
+	notifications := getDenialNotifications(t, r)
+	if len(notifications) != maxDenialNotifications {
+		t.Errorf("denial notifications = %d, want %d", len(notifications), maxDenialNotifications)
+	}
+	if _, ok := notifications["csr-0"]; ok {
+		t.Error("the oldest denial is not pruned")
+	}
+	if notifications["csr-new"].Reason != string(ReasonInvalidCertificateRequest) {
+		t.Errorf("denial notification = %+v, want the new denial", notifications["csr-new"])
+	}
+}
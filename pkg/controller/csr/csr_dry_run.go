@@ -0,0 +1,15 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import certificatesv1 "k8s.io/api/certificates/v1"
+
+// recordDryRunDecision logs and counts the decision the csr would get out of the dry run
+func recordDryRunDecision(
+	csr *certificatesv1.CertificateSigningRequest,
+	decision string,
+	reason ReasonCode,
+	message string) {
+	csrLogger(csr).Info("Dry run, CSR not updated", "decision", decision, "reason", reason, "message", message)
+	csrDryRunDecisionsTotal.WithLabelValues(getClusterName(csr), decision).Inc()
+}
@@ -0,0 +1,112 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"fmt"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcileCSR_ReconcileDryRun(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterName},
+		Spec:       clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+	}
+	newCSR := func(namespace string, request []byte) *certificatesv1.CertificateSigningRequest {
+		return &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   csrNameReconcile,
+				Labels: map[string]string{clusterLabel: clusterName},
+			},
+			Spec: certificatesv1.CertificateSigningRequestSpec{
+				Username:   fmt.Sprintf(userNameSignature, namespace, namespace),
+				Request:    request,
+				SignerName: certificatesv1.KubeAPIServerClientSignerName,
+				Usages:     []certificatesv1.KeyUsage{certificatesv1.UsageClientAuth},
+			},
+		}
+	}
+	validRequest := newCSRRequest(t, clusterCommonNamePrefix+clusterName, nil)
+
+	tests := []struct {
+		name     string
+		csr      *certificatesv1.CertificateSigningRequest
+		decision string
+	}{
+		{
+			name:     "approved",
+			csr:      newCSR(clusterName, validRequest),
+			decision: decisionApprove,
+		},
+		{
+			name:     "denied",
+			csr:      newCSR("othercluster", validRequest),
+			decision: decisionDeny,
+		},
+		{
+			name:     "skipped",
+			csr:      newCSR(clusterName, []byte("invalid")),
+			decision: decisionSkip,
+		},
+	}
+	for _, tt := range tests {
+		for _, dryRun := range []bool{true, false} {
+			t.Run(fmt.Sprintf("%s dry run %v", tt.name, dryRun), func(t *testing.T) {
+				kubeClient := fakeclientset.NewSimpleClientset(tt.csr)
+				approvalUpdates, updates := 0, 0
+				kubeClient.PrependReactor("update", "certificatesigningrequests",
+					func(action clienttesting.Action) (bool, runtime.Object, error) {
+						updates++
+						if action.GetSubresource() == "approval" {
+							approvalUpdates++
+						}
+						return false, nil, nil
+					})
+				r := &ReconcileCSR{
+					client:       fake.NewFakeClientWithScheme(testscheme, tt.csr, testManagedCluster),
+					kubeClient:   kubeClient,
+					scheme:       testscheme,
+					podNamespace: testPodNamespace,
+					dryRun:       dryRun,
+				}
+				decisions := csrDryRunDecisionsTotal.WithLabelValues(clusterName, tt.decision)
+				before := testutil.ToFloat64(decisions)
+				if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}}); err != nil {
+					t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+				}
+				counted := testutil.ToFloat64(decisions) - before
+				wantApprovalUpdates := 0
+				if !dryRun && tt.decision != decisionSkip {
+					wantApprovalUpdates = 1
+				}
+				if approvalUpdates != wantApprovalUpdates {
+					t.Errorf("UpdateApproval calls = %d, want %d", approvalUpdates, wantApprovalUpdates)
+				}
+				switch {
+				case dryRun && updates != 0:
+					t.Errorf("updates of the CSR in the dry run = %d, want none", updates)
+				case dryRun && counted != 1:
+					t.Errorf("dry run %s decisions = %v, want 1", tt.decision, counted)
+				case !dryRun && counted != 0:
+					t.Errorf("dry run %s decisions out of the dry run = %v, want 0", tt.decision, counted)
+				}
+			})
+		}
+	}
+}
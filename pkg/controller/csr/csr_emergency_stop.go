@@ -0,0 +1,128 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// defaultEmergencyStopLookback is the period before an emergency stop during which the approved csrs are
+// recorded as revocation candidates if the ImportControllerConfig does not set spec.emergencyStopLookback
+const defaultEmergencyStopLookback = 24 * time.Hour
+
+// revocationCandidate is a csr approved by the controller before an emergency stop, the certificate issued
+// for the cluster may need to be revoked
+type revocationCandidate struct {
+	ClusterName string `json:"clusterName"`
+	CSRName     string `json:"csrName"`
+	ApprovedAt  string `json:"approvedAt"`
+}
+
+// getEmergencyStop returns whether the ImportControllerConfig requests an emergency stop and its lookback
+func getEmergencyStop(config *unstructured.Unstructured) (stop bool, lookback time.Duration, err error) {
+	stop, _, err = unstructured.NestedBool(config.Object, "spec", "emergencyStop")
+	if err != nil {
+		return false, 0, err
+	}
+	v, found, err := unstructured.NestedString(config.Object, "spec", "emergencyStopLookback")
+	if err != nil {
+		return false, 0, err
+	}
+	if !found {
+		return stop, defaultEmergencyStopLookback, nil
+	}
+	lookback, err = time.ParseDuration(v)
+	if err != nil || lookback <= 0 {
+		return false, 0, fmt.Errorf("invalid spec.emergencyStopLookback %q, must be a positive duration", v)
+	}
+	return stop, lookback, nil
+}
+
+// listRevocationCandidates returns the csrs of the clusters approved by the controller since the given time,
+// sorted by cluster and csr names. The csrs approved by the controller carry the AutoApproved reason code, the reason
+// of their approval condition may be overridden with the approvalReasonAnnotation.
+func (r *ReconcileCSR) listRevocationCandidates(since time.Time) ([]revocationCandidate, error) {
+	csrs, err := r.listCSRs(metav1.ListOptions{
+		LabelSelector: clusterLabelKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	candidates := []revocationCandidate{}
+	for i := range csrs {
+		csr := &csrs[i]
+		for _, c := range csr.Status.Conditions {
+			if c.Type != certificatesv1.CertificateApproved ||
+				csr.Annotations[ReasonCodeAnnotation] != string(ReasonAutoApproved) ||
+				c.LastUpdateTime.Time.Before(since) {
+				continue
+			}
+			candidates = append(candidates, revocationCandidate{
+				ClusterName: getClusterName(csr),
+				CSRName:     csr.Name,
+				ApprovedAt:  c.LastUpdateTime.UTC().Format(time.RFC3339),
+			})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].ClusterName != candidates[j].ClusterName {
+			return candidates[i].ClusterName < candidates[j].ClusterName
+		}
+		return candidates[i].CSRName < candidates[j].CSRName
+	})
+	return candidates, nil
+}
+
+// setEmergencyStopStatus records the start of the emergency stop and the revocation candidates in the status of
+// the ImportControllerConfig, they are recorded once per emergency stop and removed once the stop is lifted.
+// It returns true if the status changed.
+func (r *ReconcileCSR) setEmergencyStopStatus(
+	config *unstructured.Unstructured,
+	stop bool,
+	lookback time.Duration,
+	now time.Time) (bool, error) {
+	_, stopped, err := unstructured.NestedString(config.Object, "status", "emergencyStoppedAt")
+	if err != nil {
+		return false, err
+	}
+	if !stop {
+		if !stopped {
+			return false, nil
+		}
+		log.Info("CSR approval emergency stop lifted", "name", config.GetName())
+		unstructured.RemoveNestedField(config.Object, "status", "emergencyStoppedAt")
+		unstructured.RemoveNestedField(config.Object, "status", "revocationCandidates")
+		return true, nil
+	}
+	if stopped {
+		return false, nil
+	}
+
+	candidates, err := r.listRevocationCandidates(now.Add(-lookback))
+	if err != nil {
+		return false, err
+	}
+	ucandidates := make([]interface{}, 0, len(candidates))
+	for _, c := range candidates {
+		ucandidates = append(ucandidates, map[string]interface{}{
+			"clusterName": c.ClusterName,
+			"csrName":     c.CSRName,
+			"approvedAt":  c.ApprovedAt,
+		})
+	}
+	log.Info("CSR approval emergency stop", "name", config.GetName(), "revocationCandidates", len(candidates))
+	if err := unstructured.SetNestedField(config.Object, now.UTC().Format(time.RFC3339),
+		"status", "emergencyStoppedAt"); err != nil {
+		return false, err
+	}
+	if err := unstructured.SetNestedSlice(config.Object, ucandidates, "status", "revocationCandidates"); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,225 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+)
+
+func newApprovedClusterCSR(name, cluster, reason string, approvedAt time.Time) *certificatesv1.CertificateSigningRequest {
+	csr := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				clusterLabel: cluster,
+			},
+		},
+	}
+	// the controller annotates the csrs it approves with their reason code
+	if reason == string(ReasonAutoApproved) {
+		csr.Annotations = map[string]string{ReasonCodeAnnotation: string(ReasonAutoApproved)}
+	}
+	if reason != "" {
+		csr.Status.Conditions = []certificatesv1.CertificateSigningRequestCondition{
+			{
+				Type:           certificatesv1.CertificateApproved,
+				Status:         corev1.ConditionTrue,
+				Reason:         reason,
+				LastUpdateTime: metav1.NewTime(approvedAt),
+			},
+		}
+	}
+	return csr
+}
+
+func newEmergencyStopConfig(stop bool, lookback string) *unstructured.Unstructured {
+	config := newControllerConfig(nil)
+	_ = unstructured.SetNestedField(config.Object, stop, "spec", "emergencyStop")
+	if lookback != "" {
+		_ = unstructured.SetNestedField(config.Object, lookback, "spec", "emergencyStopLookback")
+	}
+	return config
+}
+
+func TestReconcileCSR_reloadApprovalSwitchEmergencyStop(t *testing.T) {
+	now := time.Now()
+	// the reason of the approval condition is overridden with the approvalReasonAnnotation
+	overridden := newApprovedClusterCSR("csr-overridden", "cluster5", string(ReasonAutoApproved), now.Add(-time.Hour))
+	overridden.Status.Conditions[0].Reason = "TICKET_1234"
+	// a reason code set on a csr approved by another approver
+	manualAnnotated := newApprovedClusterCSR("csr-manual-annotated", "cluster6", "KubectlApprove", now.Add(-time.Hour))
+	manualAnnotated.Annotations = map[string]string{ReasonCodeAnnotation: string(ReasonClusterNotFound)}
+	kubeClient := fakeclientset.NewSimpleClientset(
+		overridden,
+		manualAnnotated,
+		newApprovedClusterCSR("csr-recent", "cluster1", string(ReasonAutoApproved), now.Add(-time.Hour)),
+		newApprovedClusterCSR("csr-old", "cluster2", string(ReasonAutoApproved), now.Add(-48*time.Hour)),
+		newApprovedClusterCSR("csr-manual", "cluster3", "KubectlApprove", now.Add(-time.Hour)),
+		newApprovedClusterCSR("csr-pending", "cluster4", "", now),
+	)
+	recent := revocationCandidate{
+		ClusterName: "cluster1",
+		CSRName:     "csr-recent",
+		ApprovedAt:  now.Add(-time.Hour).UTC().Format(time.RFC3339),
+	}
+	overriddenCandidate := revocationCandidate{
+		ClusterName: "cluster5",
+		CSRName:     "csr-overridden",
+		ApprovedAt:  now.Add(-time.Hour).UTC().Format(time.RFC3339),
+	}
+	old := revocationCandidate{
+		ClusterName: "cluster2",
+		CSRName:     "csr-old",
+		ApprovedAt:  now.Add(-48 * time.Hour).UTC().Format(time.RFC3339),
+	}
+
+	tests := []struct {
+		name           string
+		config         *unstructured.Unstructured
+		wantEnabled    bool
+		wantStopped    bool
+		wantCandidates []revocationCandidate
+		wantErr        bool
+	}{
+		{
+			name:        "no emergency stop",
+			config:      newEmergencyStopConfig(false, ""),
+			wantEnabled: true,
+		},
+		{
+			name:           "emergency stop",
+			config:         newEmergencyStopConfig(true, ""),
+			wantStopped:    true,
+			wantCandidates: []revocationCandidate{recent, overriddenCandidate},
+		},
+		{
+			name:           "emergency stop with a longer lookback",
+			config:         newEmergencyStopConfig(true, "72h"),
+			wantStopped:    true,
+			wantCandidates: []revocationCandidate{recent, old, overriddenCandidate},
+		},
+		{
+			name:    "invalid lookback",
+			config:  newEmergencyStopConfig(true, "one day"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ReconcileCSR{
+				kubeClient:           kubeClient,
+				controllerConfigName: controllerConfigName,
+				dynamicClient:        dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), tt.config),
+				approvalSwitch:       newApprovalSwitch(controllerConfigName),
+			}
+			err := r.reloadApprovalSwitch(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("reloadApprovalSwitch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if enabled, _ := r.approvalSwitch.enabled(); enabled != tt.wantEnabled {
+				t.Errorf("approval enabled = %v, want %v", enabled, tt.wantEnabled)
+			}
+			got, err := r.dynamicClient.Resource(controllerConfigGVR).Get(context.TODO(), controllerConfigName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, stopped, _ := unstructured.NestedString(got.Object, "status", "emergencyStoppedAt")
+			if stopped != tt.wantStopped {
+				t.Errorf("status.emergencyStoppedAt set = %v, want %v", stopped, tt.wantStopped)
+			}
+			if candidates := getRevocationCandidates(t, got); !reflect.DeepEqual(candidates, tt.wantCandidates) {
+				t.Errorf("status.revocationCandidates = %v, want %v", candidates, tt.wantCandidates)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_reloadApprovalSwitchEmergencyStopLifted(t *testing.T) {
+	now := time.Now()
+	kubeClient := fakeclientset.NewSimpleClientset(
+		newApprovedClusterCSR("csr-recent", "cluster1", string(ReasonAutoApproved), now.Add(-time.Hour)),
+	)
+	config := newEmergencyStopConfig(true, "")
+	r := &ReconcileCSR{
+		kubeClient:           kubeClient,
+		controllerConfigName: controllerConfigName,
+		dynamicClient:        dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), config),
+		approvalSwitch:       newApprovalSwitch(controllerConfigName),
+	}
+	reload := func(t *testing.T, stop bool) *unstructured.Unstructured {
+		current, err := r.dynamicClient.Resource(controllerConfigGVR).Get(context.TODO(), controllerConfigName, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = unstructured.SetNestedField(current.Object, stop, "spec", "emergencyStop")
+		if err := r.reloadApprovalSwitch(current); err != nil {
+			t.Fatalf("reloadApprovalSwitch() error = %v", err)
+		}
+		got, err := r.dynamicClient.Resource(controllerConfigGVR).Get(context.TODO(), controllerConfigName, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	stopped := reload(t, true)
+	stoppedAt, _, _ := unstructured.NestedString(stopped.Object, "status", "emergencyStoppedAt")
+	if len(getRevocationCandidates(t, stopped)) != 1 {
+		t.Fatalf("status.revocationCandidates = %v, want 1 candidate", getRevocationCandidates(t, stopped))
+	}
+
+	// the candidates are recorded once, a new approval after the stop is not recorded
+	if _, err := kubeClient.CertificatesV1().CertificateSigningRequests().Create(context.TODO(),
+		newApprovedClusterCSR("csr-new", "cluster2", string(ReasonAutoApproved), now), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	stillStopped := reload(t, true)
+	if at, _, _ := unstructured.NestedString(stillStopped.Object, "status", "emergencyStoppedAt"); at != stoppedAt {
+		t.Errorf("status.emergencyStoppedAt = %q, want %q", at, stoppedAt)
+	}
+	if len(getRevocationCandidates(t, stillStopped)) != 1 {
+		t.Errorf("status.revocationCandidates = %v, want 1 candidate", getRevocationCandidates(t, stillStopped))
+	}
+
+	lifted := reload(t, false)
+	if enabled, _ := r.approvalSwitch.enabled(); !enabled {
+		t.Error("approval should be enabled once the emergency stop is lifted")
+	}
+	if _, found, _ := unstructured.NestedFieldNoCopy(lifted.Object, "status", "revocationCandidates"); found {
+		t.Error("status.revocationCandidates should be removed once the emergency stop is lifted")
+	}
+	if _, found, _ := unstructured.NestedFieldNoCopy(lifted.Object, "status", "emergencyStoppedAt"); found {
+		t.Error("status.emergencyStoppedAt should be removed once the emergency stop is lifted")
+	}
+}
+
+// getRevocationCandidates returns the revocation candidates of the status of the ImportControllerConfig
+func getRevocationCandidates(t *testing.T, config *unstructured.Unstructured) []revocationCandidate {
+	ucandidates, _, err := unstructured.NestedSlice(config.Object, "status", "revocationCandidates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var candidates []revocationCandidate
+	for _, u := range ucandidates {
+		c := revocationCandidate{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.(map[string]interface{}), &c); err != nil {
+			t.Fatal(err)
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates
+}
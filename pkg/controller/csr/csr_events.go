@@ -0,0 +1,38 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"fmt"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// eventReasonAutoApproved is the reason of the events of the approved csrs and of their ManagedCluster
+	eventReasonAutoApproved = "CSRAutoApproved"
+	// eventReasonInvalidUsername is the reason of the events of the csrs skipped for their username
+	eventReasonInvalidUsername = "CSRInvalidUsername"
+)
+
+// event records an event on the object, nothing is recorded without recorder
+func (r *ReconcileCSR) event(object runtime.Object, eventType, reason, message string) {
+	if r.recorder == nil {
+		return
+	}
+	r.recorder.Event(object, eventType, reason, message)
+}
+
+// recordApprovalEvents records the approval of the csr on the csr and on its ManagedCluster, so the users without
+// access to the controller logs can follow the join of the cluster
+func (r *ReconcileCSR) recordApprovalEvents(
+	csr *certificatesv1.CertificateSigningRequest,
+	cluster *clusterv1.ManagedCluster) {
+	r.event(csr, corev1.EventTypeNormal, eventReasonAutoApproved,
+		fmt.Sprintf("The CSR of the cluster %s is approved", cluster.Name))
+	r.event(cluster, corev1.EventTypeNormal, eventReasonAutoApproved,
+		fmt.Sprintf("The CSR %s of the cluster is approved", csr.Name))
+}
@@ -0,0 +1,108 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"fmt"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// recordedEvents returns the events recorded by the recorder
+func recordedEvents(recorder *record.FakeRecorder) []string {
+	events := []string{}
+	for {
+		select {
+		case e := <-recorder.Events:
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+func TestReconcileCSR_ReconcileEvents(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	tests := []struct {
+		name       string
+		cluster    *clusterv1.ManagedCluster
+		wantEvents []string
+	}{
+		{
+			name:    "approved",
+			cluster: newAcceptedCluster(true),
+			wantEvents: []string{
+				fmt.Sprintf("Normal CSRAutoApproved The CSR of the cluster %s is approved", clusterName),
+				fmt.Sprintf("Normal CSRAutoApproved The CSR %s of the cluster is approved", csrNameReconcile),
+			},
+		},
+		{
+			name:       "not approved",
+			cluster:    newAcceptedCluster(false),
+			wantEvents: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr := newDedupCSR(csrNameReconcile, newCSRRequest(t, clusterCommonNamePrefix+clusterName, nil))
+			recorder := record.NewFakeRecorder(10)
+			r := &ReconcileCSR{
+				client:     fake.NewFakeClientWithScheme(testscheme, tt.cluster, csr),
+				kubeClient: fakeclientset.NewSimpleClientset(csr),
+				scheme:     testscheme,
+				recorder:   recorder,
+			}
+			if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}}); err != nil {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+			}
+			if got := recordedEvents(recorder); fmt.Sprint(got) != fmt.Sprint(tt.wantEvents) {
+				t.Errorf("events = %q, want %q", got, tt.wantEvents)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_recordSkippedByPredicateEvents(t *testing.T) {
+	invalid := newDedupCSR(csrNameReconcile, nil)
+	invalid.Spec.Username = "system:serviceaccount:default:other"
+	tests := []struct {
+		name       string
+		csr        *certificatesv1.CertificateSigningRequest
+		wantEvents []string
+	}{
+		{
+			name: "invalid username",
+			csr:  invalid,
+			wantEvents: []string{
+				fmt.Sprintf("Warning CSRInvalidUsername The CSR is not approved, its username %s is not a bootstrap "+
+					"service account of the cluster %s", invalid.Spec.Username, clusterName),
+			},
+		},
+		{
+			name:       "valid username",
+			csr:        newDedupCSR(csrNameReconcile, nil),
+			wantEvents: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			r := &ReconcileCSR{recorder: recorder}
+			r.recordSkippedByPredicate(tt.csr)
+			if got := recordedEvents(recorder); fmt.Sprint(got) != fmt.Sprint(tt.wantEvents) {
+				t.Errorf("events = %q, want %q", got, tt.wantEvents)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	certificatesv1beta1 "k8s.io/api/certificates/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+)
+
+// readyzCheckName is the name of the readiness check of the csr controller
+const readyzCheckName = "csr-controller"
+
+// cacheSyncCheck is a readiness check reporting the csr controller ready once the informer cache of the manager is
+// synced, the csrs are not evaluated from a partial cache before
+type cacheSyncCheck struct {
+	cache  cache.Cache
+	synced int32
+}
+
+func newCacheSyncCheck(c cache.Cache) *cacheSyncCheck {
+	return &cacheSyncCheck{cache: c}
+}
+
+// Start waits for the informer cache to be synced
+func (c *cacheSyncCheck) Start(stop <-chan struct{}) error {
+	if c.cache.WaitForCacheSync(stop) {
+		atomic.StoreInt32(&c.synced, 1)
+	}
+	<-stop
+	return nil
+}
+
+// check is the healthz.Checker of the readiness of the csr controller
+func (c *cacheSyncCheck) check(_ *http.Request) error {
+	if atomic.LoadInt32(&c.synced) == 0 {
+		return fmt.Errorf("the informer cache of the csr controller is not synced")
+	}
+	return nil
+}
+
+// newPendingCSRsGauge returns the gauge of the pending csrs the controller of r is responsible for, the csrs are
+// counted from the cache on each scrape so an approved csr is no longer counted
+func newPendingCSRsGauge(r *ReconcileCSR) prometheus.GaugeFunc {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "managedcluster_import_csr_pending",
+		Help: "Number of the pending CSRs the controller is responsible for",
+	}, func() float64 {
+		csrs, err := r.listCachedCSRs()
+		if err != nil {
+			log.Error(err, "failed to list the CSRs to count the pending CSRs")
+			return 0
+		}
+		pending := 0
+		for _, csr := range csrs {
+			if getApprovalType(csr) == "" && r.watchesSigner(csr) && r.watches(csr) {
+				pending++
+			}
+		}
+		return float64(pending)
+	})
+}
+
+// listCachedCSRs lists the csrs of the cache with the version of the certificates API they are watched with
+func (r *ReconcileCSR) listCachedCSRs() ([]*certificatesv1.CertificateSigningRequest, error) {
+	csrs := []*certificatesv1.CertificateSigningRequest{}
+	if r.watch.current() == certificatesV1beta1 {
+		list := &certificatesv1beta1.CertificateSigningRequestList{}
+		if err := r.client.List(context.TODO(), list); err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			csrs = append(csrs, fromV1beta1CSR(&list.Items[i]))
+		}
+		return csrs, nil
+	}
+	list := &certificatesv1.CertificateSigningRequestList{}
+	if err := r.client.List(context.TODO(), list); err != nil {
+		return nil, err
+	}
+	for i := range list.Items {
+		csrs = append(csrs, &list.Items[i])
+	}
+	return csrs, nil
+}
@@ -0,0 +1,115 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// syncedCache is a cache.Cache reporting its informers synced or not
+type syncedCache struct {
+	cache.Cache
+	synced bool
+}
+
+func (c *syncedCache) WaitForCacheSync(stop <-chan struct{}) bool {
+	return c.synced
+}
+
+func Test_cacheSyncCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		synced  bool
+		wantErr bool
+	}{
+		{
+			name:   "synced",
+			synced: true,
+		},
+		{
+			name:    "not synced",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCacheSyncCheck(&syncedCache{synced: tt.synced})
+			if err := c.check(nil); err == nil {
+				t.Fatalf("check() before the start error = nil, want not ready")
+			}
+			stop := make(chan struct{})
+			close(stop)
+			if err := c.Start(stop); err != nil {
+				t.Fatalf("Start() error = %v", err)
+			}
+			if err := c.check(nil); (err != nil) != tt.wantErr {
+				t.Errorf("check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_newPendingCSRsGauge(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+
+	newCSR := func(name, username string) *certificatesv1.CertificateSigningRequest {
+		return &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{clusterLabel: clusterName},
+			},
+			Spec: certificatesv1.CertificateSigningRequestSpec{
+				Username:   username,
+				SignerName: certificatesv1.KubeAPIServerClientSignerName,
+				Usages:     []certificatesv1.KeyUsage{certificatesv1.UsageClientAuth},
+			},
+		}
+	}
+	bootstrapUsername := fmt.Sprintf(userNameSignature, clusterName, clusterName)
+	approvedCondition := certificatesv1.CertificateSigningRequestCondition{
+		Type:   certificatesv1.CertificateApproved,
+		Status: corev1.ConditionTrue,
+	}
+	approved := newCSR("csr-approved", bootstrapUsername)
+	approved.Status.Conditions = []certificatesv1.CertificateSigningRequestCondition{approvedCondition}
+	objs := []runtime.Object{
+		newCSR("csr-1", bootstrapUsername),
+		newCSR("csr-2", bootstrapUsername),
+		newCSR("csr-3", bootstrapUsername),
+		// the csrs the controller is not responsible for and the decided csrs are not counted
+		newCSR("csr-unauthorized", unauthorizedUsername),
+		approved,
+	}
+
+	r := &ReconcileCSR{client: fake.NewFakeClientWithScheme(testscheme, objs...)}
+	gauge := newPendingCSRsGauge(r)
+	if got := testutil.ToFloat64(gauge); got != 3 {
+		t.Errorf("pending CSRs = %v, want 3", got)
+	}
+
+	// the approval of a csr reaches the cache
+	csr := &certificatesv1.CertificateSigningRequest{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: "csr-1"}, csr); err != nil {
+		t.Fatal(err)
+	}
+	csr.Status.Conditions = []certificatesv1.CertificateSigningRequestCondition{approvedCondition}
+	if err := r.client.Update(context.TODO(), csr); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ToFloat64(gauge); got != 2 {
+		t.Errorf("pending CSRs after an approval = %v, want 2", got)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// maxManagedClustersEnvVarName is the number of accepted ManagedClusters above which the csrs of the clusters not
+// joined yet are not approved, the number of clusters is not limited if not set
+const maxManagedClustersEnvVarName = "CSR_MAX_MANAGED_CLUSTERS"
+
+// hubCapacityRequeuePeriod is the period the csrs of the clusters beyond the hub capacity are evaluated again at
+const hubCapacityRequeuePeriod = 5 * time.Minute
+
+// getMaxManagedClusters returns the CSR_MAX_MANAGED_CLUSTERS value, 0 if not set
+func getMaxManagedClusters() (int, error) {
+	v := os.Getenv(maxManagedClustersEnvVarName)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid %s value %q, must be a positive integer", maxManagedClustersEnvVarName, v)
+	}
+	return n, nil
+}
+
+// withinHubCapacity checks the csrs of the accepted cluster can be approved with maxManagedClusters clusters at
+// most. The csrs of a cluster that joined the hub, its renewals and reconnections, are always approved. The
+// bootstrap csrs of a new cluster are approved if less than maxManagedClusters accepted clusters were created
+// before it, so the clusters accepted in a burst can not exceed the capacity before they join.
+// Any csr is approved if maxManagedClusters is not positive.
+func withinHubCapacity(
+	ctx context.Context,
+	c client.Client, cluster *clusterv1.ManagedCluster, maxManagedClusters int) (bool, error) {
+	if maxManagedClusters <= 0 ||
+		meta.IsStatusConditionTrue(cluster.Status.Conditions, clusterv1.ManagedClusterConditionJoined) {
+		return true, nil
+	}
+	clusters := &clusterv1.ManagedClusterList{}
+	if err := c.List(ctx, clusters); err != nil {
+		return false, err
+	}
+	before := 0
+	for i := range clusters.Items {
+		other := &clusters.Items[i]
+		if other.Name == cluster.Name || !other.Spec.HubAcceptsClient {
+			continue
+		}
+		if createdBefore(other, cluster) {
+			before++
+		}
+	}
+	return before < maxManagedClusters, nil
+}
+
+// createdBefore checks the cluster a is created before the cluster b, by name for the same creation time
+func createdBefore(a, b *clusterv1.ManagedCluster) bool {
+	if a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.Name < b.Name
+	}
+	return a.CreationTimestamp.Before(&b.CreationTimestamp)
+}
@@ -0,0 +1,169 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func newCapacityCluster(name string, createdAt time.Time, accepted, joined bool) *clusterv1.ManagedCluster {
+	cluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(createdAt),
+		},
+		Spec: clusterv1.ManagedClusterSpec{
+			HubAcceptsClient: accepted,
+		},
+	}
+	if joined {
+		cluster.Status.Conditions = []metav1.Condition{
+			{Type: clusterv1.ManagedClusterConditionJoined, Status: metav1.ConditionTrue},
+		}
+	}
+	return cluster
+}
+
+func Test_getMaxManagedClusters(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:  "limit",
+			value: "100",
+			want:  100,
+		},
+		{
+			name:    "zero",
+			value:   "0",
+			wantErr: true,
+		},
+		{
+			name:    "invalid",
+			value:   "many",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(maxManagedClustersEnvVarName, tt.value)
+			defer os.Unsetenv(maxManagedClustersEnvVarName)
+			got, err := getMaxManagedClusters()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getMaxManagedClusters() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("getMaxManagedClusters() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_ReconcileHubCapacity(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{},
+		&clusterv1.ManagedClusterList{})
+
+	now := time.Now()
+	oldest := newCapacityCluster("cluster-a", now.Add(-2*time.Hour), true, true)
+	older := newCapacityCluster("cluster-b", now.Add(-time.Hour), true, false)
+	notAccepted := newCapacityCluster("cluster-c", now.Add(-time.Hour), false, false)
+
+	tests := []struct {
+		name               string
+		maxManagedClusters int
+		cluster            *clusterv1.ManagedCluster
+		others             []runtime.Object
+		want               string
+		wantReason         ReasonCode
+	}{
+		{
+			name:               "new cluster at capacity",
+			maxManagedClusters: 2,
+			cluster:            newCapacityCluster(clusterName, now, true, false),
+			others:             []runtime.Object{oldest, older},
+			wantReason:         ReasonHubCapacityReached,
+		},
+		{
+			name:               "renewal at capacity",
+			maxManagedClusters: 2,
+			cluster:            newCapacityCluster(clusterName, now, true, true),
+			others:             []runtime.Object{oldest, older},
+			want:               string(certificatesv1.CertificateApproved),
+			wantReason:         ReasonAutoApproved,
+		},
+		{
+			name:               "new cluster below capacity",
+			maxManagedClusters: 2,
+			cluster:            newCapacityCluster(clusterName, now, true, false),
+			others:             []runtime.Object{oldest, notAccepted},
+			want:               string(certificatesv1.CertificateApproved),
+			wantReason:         ReasonAutoApproved,
+		},
+		{
+			name:               "new cluster accepted before the others",
+			maxManagedClusters: 2,
+			cluster:            newCapacityCluster(clusterName, now.Add(-3*time.Hour), true, false),
+			others:             []runtime.Object{oldest, older},
+			want:               string(certificatesv1.CertificateApproved),
+			wantReason:         ReasonAutoApproved,
+		},
+		{
+			name:       "not limited",
+			cluster:    newCapacityCluster(clusterName, now, true, false),
+			others:     []runtime.Object{oldest, older},
+			want:       string(certificatesv1.CertificateApproved),
+			wantReason: ReasonAutoApproved,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr := newDedupCSR(csrNameReconcile, newCSRRequest(t, clusterCommonNamePrefix+clusterName, nil))
+			objs := append([]runtime.Object{csr, tt.cluster}, tt.others...)
+			r := &ReconcileCSR{
+				client:             fake.NewFakeClientWithScheme(testscheme, objs...),
+				kubeClient:         fakeclientset.NewSimpleClientset(csr),
+				scheme:             testscheme,
+				maxManagedClusters: tt.maxManagedClusters,
+			}
+			res, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}})
+			if err != nil {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+			}
+			got, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), csrNameReconcile,
+				metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if getApprovalType(got) != tt.want {
+				t.Errorf("CSR approval = %q, want %q", getApprovalType(got), tt.want)
+			}
+			if code := got.Annotations[ReasonCodeAnnotation]; code != string(tt.wantReason) {
+				t.Errorf("reason code = %q, want %q", code, tt.wantReason)
+			}
+			if tt.wantReason == ReasonHubCapacityReached && res.RequeueAfter != hubCapacityRequeuePeriod {
+				t.Errorf("ReconcileCSR.Reconcile() = %v, want a requeue after %s", res, hubCapacityRequeuePeriod)
+			}
+		})
+	}
+}
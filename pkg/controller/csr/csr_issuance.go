@@ -0,0 +1,72 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// issuanceTimeoutEnvVarName is the duration after the approval of a csr within which its certificate must be
+// issued by the signer, the issuance is not verified if not set
+const issuanceTimeoutEnvVarName = "CSR_ISSUANCE_TIMEOUT"
+
+var issuanceStalledTotal = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "managedcluster_import_csr_issuance_stalled_total",
+	Help: "Number of approved CSRs whose certificate was not issued within the CSR_ISSUANCE_TIMEOUT",
+})
+
+func init() {
+	metrics.Registry.MustRegister(issuanceStalledTotal)
+}
+
+// getIssuanceTimeout returns the CSR_ISSUANCE_TIMEOUT value, 0 if not set
+func getIssuanceTimeout() (time.Duration, error) {
+	v := os.Getenv(issuanceTimeoutEnvVarName)
+	if v == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(v)
+	if err != nil || timeout < 0 {
+		return 0, fmt.Errorf("invalid %s value %q, must be a positive duration", issuanceTimeoutEnvVarName, v)
+	}
+	return timeout, nil
+}
+
+// getApprovedAt returns the last update time of the approved condition of the csr, false if the csr is not approved
+func getApprovedAt(csr *certificatesv1.CertificateSigningRequest) (time.Time, bool) {
+	for _, c := range csr.Status.Conditions {
+		if c.Type == certificatesv1.CertificateApproved {
+			return c.LastUpdateTime.Time, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// verifyIssuance checks the certificate of the approved csr is issued within the issuance timeout,
+// the csr is requeued until the timeout is passed. A csr whose certificate is not issued at the timeout
+// is logged as a warning and counted in the issuance stalled metric.
+func (r *ReconcileCSR) verifyIssuance(
+	csr *certificatesv1.CertificateSigningRequest,
+	now time.Time) (reconcile.Result, error) {
+	if r.issuanceTimeout <= 0 || len(csr.Status.Certificate) > 0 {
+		return reconcile.Result{}, nil
+	}
+	approvedAt, ok := getApprovedAt(csr)
+	if !ok {
+		return reconcile.Result{}, nil
+	}
+	if remaining := approvedAt.Add(r.issuanceTimeout).Sub(now); remaining > 0 {
+		return reconcile.Result{Requeue: true, RequeueAfter: remaining}, nil
+	}
+	csrLogger(csr).Error(fmt.Errorf("certificate not issued %s after the approval", r.issuanceTimeout),
+		"the approved CSR is not issued", "signer", csr.Spec.SignerName)
+	issuanceStalledTotal.Inc()
+	return reconcile.Result{}, nil
+}
@@ -0,0 +1,137 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func Test_getIssuanceTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:  "valid",
+			value: "5m",
+			want:  5 * time.Minute,
+		},
+		{
+			name:    "invalid",
+			value:   "five minutes",
+			wantErr: true,
+		},
+		{
+			name:    "negative",
+			value:   "-5m",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(issuanceTimeoutEnvVarName, tt.value)
+			defer os.Unsetenv(issuanceTimeoutEnvVarName)
+			got, err := getIssuanceTimeout()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getIssuanceTimeout() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("getIssuanceTimeout() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_ReconcileIssuance(t *testing.T) {
+	approvedAt := time.Now().Add(-time.Minute)
+	newApprovedCSR := func(certificate []byte) *certificatesv1.CertificateSigningRequest {
+		return &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: csrNameReconcile,
+				Labels: map[string]string{
+					clusterLabel: clusterName,
+				},
+			},
+			Status: certificatesv1.CertificateSigningRequestStatus{
+				Conditions: []certificatesv1.CertificateSigningRequestCondition{
+					{
+						Type:           certificatesv1.CertificateApproved,
+						Status:         corev1.ConditionTrue,
+						LastUpdateTime: metav1.NewTime(approvedAt),
+					},
+				},
+				Certificate: certificate,
+			},
+		}
+	}
+
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+
+	tests := []struct {
+		name            string
+		csr             *certificatesv1.CertificateSigningRequest
+		issuanceTimeout time.Duration
+		wantResult      reconcile.Result
+		wantStalled     float64
+	}{
+		{
+			name:            "issued",
+			csr:             newApprovedCSR([]byte("certificate")),
+			issuanceTimeout: 5 * time.Minute,
+		},
+		{
+			name:            "not issued yet",
+			csr:             newApprovedCSR(nil),
+			issuanceTimeout: 5 * time.Minute,
+			wantResult:      reconcile.Result{Requeue: true, RequeueAfter: 4 * time.Minute},
+		},
+		{
+			name:            "never issued",
+			csr:             newApprovedCSR(nil),
+			issuanceTimeout: 30 * time.Second,
+			wantStalled:     1,
+		},
+		{
+			name: "issuance not verified",
+			csr:  newApprovedCSR(nil),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ReconcileCSR{
+				client:          fake.NewFakeClientWithScheme(testscheme, tt.csr),
+				scheme:          testscheme,
+				issuanceTimeout: tt.issuanceTimeout,
+			}
+			before := testutil.ToFloat64(issuanceStalledTotal)
+			got, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}})
+			if err != nil {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+			}
+			if got.Requeue != tt.wantResult.Requeue ||
+				got.RequeueAfter.Round(time.Minute) != tt.wantResult.RequeueAfter {
+				t.Errorf("ReconcileCSR.Reconcile() = %v, want %v", got, tt.wantResult)
+			}
+			if stalled := testutil.ToFloat64(issuanceStalledTotal) - before; stalled != tt.wantStalled {
+				t.Errorf("stalled issuances = %v, want %v", stalled, tt.wantStalled)
+			}
+		})
+	}
+}
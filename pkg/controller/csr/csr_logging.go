@@ -0,0 +1,25 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"github.com/go-logr/logr"
+	certificatesv1 "k8s.io/api/certificates/v1"
+)
+
+// debugLevel is the verbosity of the logs of the routine steps of the reconciliation
+const debugLevel = 1
+
+// the decisions on the csrs, logged with the decision key
+const (
+	decisionApprove = "approve"
+	decisionDeny    = "deny"
+	decisionSkip    = "skip"
+	decisionDelete  = "delete"
+)
+
+// csrLogger returns the logger of the csr, every line carries the csr, cluster and username keys so the journey
+// of a csr can be followed across its reconciliations
+func csrLogger(csr *certificatesv1.CertificateSigningRequest) logr.Logger {
+	return log.WithValues("csr", csr.Name, "cluster", getClusterName(csr), "username", csr.Spec.Username)
+}
@@ -0,0 +1,177 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/go-logr/logr"
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// capturedLine is a line logged by the captureLogger
+type capturedLine struct {
+	level  int
+	msg    string
+	err    error
+	values map[string]interface{}
+}
+
+// captureSink holds the lines of a captureLogger and of the loggers derived from it
+type captureSink struct {
+	lock  sync.Mutex
+	lines []capturedLine
+}
+
+// captureLogger is a logr.Logger capturing its lines in its sink
+type captureLogger struct {
+	sink   *captureSink
+	level  int
+	values []interface{}
+}
+
+var _ logr.Logger = &captureLogger{}
+
+func (l *captureLogger) Enabled() bool { return true }
+
+func (l *captureLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.log(nil, msg, keysAndValues)
+}
+
+func (l *captureLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.log(err, msg, keysAndValues)
+}
+
+func (l *captureLogger) V(level int) logr.Logger {
+	return &captureLogger{sink: l.sink, level: l.level + level, values: l.values}
+}
+
+func (l *captureLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	values := append(append([]interface{}{}, l.values...), keysAndValues...)
+	return &captureLogger{sink: l.sink, level: l.level, values: values}
+}
+
+func (l *captureLogger) WithName(name string) logr.Logger { return l }
+
+func (l *captureLogger) log(err error, msg string, keysAndValues []interface{}) {
+	values := map[string]interface{}{}
+	kv := append(append([]interface{}{}, l.values...), keysAndValues...)
+	for i := 0; i+1 < len(kv); i += 2 {
+		values[fmt.Sprint(kv[i])] = kv[i+1]
+	}
+	l.sink.lock.Lock()
+	defer l.sink.lock.Unlock()
+	l.sink.lines = append(l.sink.lines, capturedLine{level: l.level, msg: msg, err: err, values: values})
+}
+
+func TestReconcileCSR_ReconcileLogging(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterName},
+		Spec:       clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+	}
+	username := fmt.Sprintf(userNameSignature, clusterName, clusterName)
+	newCSR := func(request []byte) *certificatesv1.CertificateSigningRequest {
+		return &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   csrNameReconcile,
+				Labels: map[string]string{clusterLabel: clusterName},
+			},
+			Spec: certificatesv1.CertificateSigningRequestSpec{
+				Username:   username,
+				Request:    request,
+				SignerName: certificatesv1.KubeAPIServerClientSignerName,
+				Usages:     []certificatesv1.KeyUsage{certificatesv1.UsageClientAuth},
+			},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		csr       *certificatesv1.CertificateSigningRequest
+		objs      []runtime.Object
+		wantMsg   string
+		wantLevel int
+		decision  string
+	}{
+		{
+			name:     "approved",
+			csr:      newCSR(newCSRRequest(t, clusterCommonNamePrefix+clusterName, nil)),
+			objs:     []runtime.Object{testManagedCluster},
+			wantMsg:  "Approving CSR",
+			decision: decisionApprove,
+		},
+		{
+			name:     "skipped",
+			csr:      newCSR([]byte("invalid")),
+			objs:     []runtime.Object{testManagedCluster},
+			wantMsg:  "CSR not approved",
+			decision: decisionSkip,
+		},
+		{
+			name:      "cluster not found",
+			csr:       newCSR(newCSRRequest(t, clusterCommonNamePrefix+clusterName, nil)),
+			wantMsg:   "CSR not approved, the ManagedCluster is not found",
+			wantLevel: debugLevel,
+			decision:  decisionSkip,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &captureSink{}
+			defaultLog := log
+			log = &captureLogger{sink: sink}
+			defer func() { log = defaultLog }()
+
+			r := &ReconcileCSR{
+				client:       fake.NewFakeClientWithScheme(testscheme, append(tt.objs, tt.csr)...),
+				kubeClient:   fakeclientset.NewSimpleClientset(tt.csr),
+				scheme:       testscheme,
+				podNamespace: testPodNamespace,
+			}
+			if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}}); err != nil {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+			}
+
+			var decisionLine *capturedLine
+			for i, line := range sink.lines {
+				if line.values["csr"] != csrNameReconcile {
+					t.Errorf("line %q csr = %v, want %s", line.msg, line.values["csr"], csrNameReconcile)
+				}
+				if line.msg == tt.wantMsg {
+					decisionLine = &sink.lines[i]
+				}
+			}
+			if decisionLine == nil {
+				t.Fatalf("lines = %v, want the line %q", sink.lines, tt.wantMsg)
+			}
+			want := map[string]interface{}{
+				"csr":      csrNameReconcile,
+				"cluster":  clusterName,
+				"username": username,
+				"decision": tt.decision,
+			}
+			for key, value := range want {
+				if decisionLine.values[key] != value {
+					t.Errorf("line %q %s = %v, want %v", decisionLine.msg, key, decisionLine.values[key], value)
+				}
+			}
+			if decisionLine.level != tt.wantLevel {
+				t.Errorf("line %q level = %d, want %d", decisionLine.msg, decisionLine.level, tt.wantLevel)
+			}
+		})
+	}
+}
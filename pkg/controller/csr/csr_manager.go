@@ -3,61 +3,201 @@
 package csr
 
 import (
-	libgoclient "github.com/open-cluster-management/library-go/pkg/client"
-	certificatesv1 "k8s.io/api/certificates/v1"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"github.com/open-cluster-management/managedcluster-import-controller/pkg/controller/hubkubeconfig"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
-	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
-// Add creates a new ManagedCluster Controller and adds it to the Manager. The Manager will set fields on the Controller
-// and Start it when the Manager is Started.
-func Add(mgr manager.Manager) error {
-	return add(mgr, newReconciler(mgr))
+// Add creates a new ManagedCluster Controller with the opts and adds it to the Manager, the zero opts are read from
+// the environment variables of the controller. The Manager will set fields on the Controller and Start it when the
+// Manager is Started.
+func Add(mgr manager.Manager, opts *CSRControllerOptions) error {
+	o := NewCSRControllerOptions()
+	if opts != nil {
+		// the options of the caller are not completed
+		copied := *opts
+		o = &copied
+	}
+	if err := o.complete(); err != nil {
+		return err
+	}
+	if o.outOfClusterConfig == nil && hubkubeconfig.Enabled() {
+		// the manager watches the hub of the hub kubeconfig secret
+		o.outOfClusterConfig = mgr.GetConfig()
+	}
+	clusterLabelKey, legacyClusterLabels = o.ClusterLabel, o.LegacyClusterLabels
+	otlpExporter, err := getOTLPExporter()
+	if err != nil {
+		return err
+	}
+	if otlpExporter != nil {
+		if err := mgr.Add(otlpExporter); err != nil {
+			return err
+		}
+	}
+	r, err := newReconciler(mgr, o)
+	if err != nil {
+		return err
+	}
+	if err := mgr.Add(r.apiCalls); err != nil {
+		return err
+	}
+	if r.audit != nil {
+		if err := mgr.Add(r.audit); err != nil {
+			return err
+		}
+	}
+	if collector := newCSRCollector(r, o.retention); collector != nil {
+		if err := mgr.Add(collector); err != nil {
+			return err
+		}
+	}
+	if err := add(mgr, r, r.discoverWatchVersion(time.Now())); err != nil {
+		return err
+	}
+	if err := mgr.Add(&csrWatchSwitch{r: r, refresh: o.apiVersionRefresh}); err != nil {
+		return err
+	}
+	cacheSync := newCacheSyncCheck(mgr.GetCache())
+	if err := mgr.Add(cacheSync); err != nil {
+		return err
+	}
+	if err := mgr.AddReadyzCheck(readyzCheckName, cacheSync.check); err != nil {
+		return err
+	}
+	if err := metrics.Registry.Register(newPendingCSRsGauge(r)); err != nil {
+		return err
+	}
+	if err := addApprovalRulesWatch(mgr, r); err != nil {
+		return err
+	}
+	return addApprovalSwitchWatch(mgr, r)
 }
 
-// newReconciler returns a new reconcile.Reconciler
-func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	kubeClient, err := libgoclient.NewDefaultKubeClient("")
+// newReconciler returns a new reconcile.Reconciler of the completed opts, an error if its kube or dynamic client can
+// not be built
+func newReconciler(mgr manager.Manager, o *CSRControllerOptions) (*ReconcileCSR, error) {
+	kubeClient, dynamicClient, err := newClients(o.outOfClusterConfig)
+	if err != nil {
+		return nil, err
+	}
+	apiCalls, err := newAPICallContext(o.APICallTimeout)
 	if err != nil {
-		kubeClient = nil
+		return nil, err
 	}
-	return &ReconcileCSR{client: mgr.GetClient(), kubeClient: kubeClient, scheme: mgr.GetScheme()}
+	apiVersions := newAPIVersionResolver(kubeClient.Discovery(), o.apiVersionRefresh)
+	audit := newAuditLog(kubeClient, o.podNamespace, o.auditConfigMapName, o.auditFlushInterval, o.auditFlushEntries)
+	return &ReconcileCSR{
+		client:              mgr.GetClient(),
+		kubeClient:          kubeClient,
+		scheme:              mgr.GetScheme(),
+		challengeSecretName: o.challengeSecretName,
+		podNamespace:        o.podNamespace,
+
+		policyCompatibilityWindow:  o.policyCompatibilityWindow,
+		denialCooldown:             newDenialCooldown(o.DenialCooldown),
+		approvalRulesConfigMapName: o.approvalRulesConfigMapName,
+		invalidRequestAction:       o.invalidRequestAction,
+		signerPolicies:             o.signerPolicies,
+		controllerConfigName:       o.controllerConfigName,
+		dynamicClient:              dynamicClient,
+		approvalSwitch:             newApprovalSwitch(o.controllerConfigName),
+		issuanceTimeout:            o.IssuanceTimeout,
+		dedup:                      newCSRDeduplicator(o.DedupWindow),
+		crossCheckAnnotation:       o.crossCheckAnnotation,
+		apiVersions:                apiVersions,
+		csrVersions:                newCSRVersions(o.apiVersionMode),
+		attestationKeySecretName:   o.attestationKeySecretName,
+		audit:                      audit,
+		clusterNotFound:            newClusterNotFoundRetry(o.clusterNotFoundBackoff, o.clusterNotFoundMaxAttempts),
+
+		denialNotificationConfigMapName: o.denialNotificationConfigMapName,
+		usernameTemplates:               o.usernameTemplates,
+		recorder:                        mgr.GetEventRecorderFor("csr-controller"),
+		maxManagedClusters:              o.maxManagedClusters,
+		denyUnauthorizedCSRs:            o.DenyUnauthorizedCSRs,
+		requestAllowlist:                o.requestAllowlist,
+		approvalRateLimiter:             newApprovalRateLimiter(o.ApprovalRate, o.ApprovalBurst),
+		addOnAllowlist:                  o.AddOnAllowlist,
+		dryRun:                          o.DryRun,
+		clusterNameFilter:               o.clusterNameFilter,
+		clusterSetAllowlist:             o.ClusterSetAllowlist,
+		batchApproval:                   o.BatchApproval,
+		verifyServiceAccounts:           o.VerifyServiceAccounts,
+		orphanedAction:                  o.orphanedAction,
+		maxConcurrentReconciles:         o.MaxConcurrentReconciles,
+		approvalWebhook:                 o.approvalWebhook,
+		apiCalls:                        apiCalls,
+		approvalWindow:                  o.approvalWindow,
+	}, nil
 }
 
-// add adds a new Controller to mgr with r as the reconcile.Reconciler
-func add(mgr manager.Manager, r reconcile.Reconciler) error {
-	// Create a new controller
-	c, err := controller.New("csr-controller", mgr, controller.Options{Reconciler: r})
+// add adds a new Controller to mgr with r as the reconcile.Reconciler, watching the csrs of the watchVersion of
+// the certificates API requested by the usernames of the username templates of r. The csrs of another version are
+// watched with the same controller once the watch of r switches to it.
+func add(mgr manager.Manager, r *ReconcileCSR, watchVersion string) error {
+	options, err := csrControllerOptions(r)
 	if err != nil {
 		return err
 	}
-
-	csrPredicateFuncs := predicate.Funcs{
-		GenericFunc: func(e event.GenericEvent) bool { return false },
-		DeleteFunc:  func(e event.DeleteEvent) bool { return false },
-		UpdateFunc: func(e event.UpdateEvent) bool {
-			return csrPredicate(e.ObjectNew.(*certificatesv1.CertificateSigningRequest))
-		},
-		CreateFunc: func(e event.CreateEvent) bool {
-			return csrPredicate(e.Object.(*certificatesv1.CertificateSigningRequest))
-		},
+	// Create a new controller
+	c, err := controller.New("csr-controller", mgr, options)
+	if err != nil {
+		return err
 	}
 
 	// Watch for changes to primary resource ManagedCluster
-	err = c.Watch(
-		&source.Kind{Type: &certificatesv1.CertificateSigningRequest{}},
-		&handler.EnqueueRequestForObject{},
-		csrPredicateFuncs,
-	)
+	err = r.watch.start(func(version string) error {
+		return c.Watch(
+			&source.Kind{Type: newWatchedCSR(version)},
+			&handler.EnqueueRequestForObject{},
+			csrPredicateFuncs(r),
+		)
+	})
+
+	if err != nil {
+		return err
+	}
 
+	// Enqueue the pending csrs of a ManagedCluster once the hub accepts it
+	pendingCSRs := &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+			return pendingCSRRequests(mgr.GetClient(), r.watch.current(), obj.Meta.GetName())
+		}),
+	}
+	err = c.Watch(&source.Kind{Type: &clusterv1.ManagedCluster{}}, pendingCSRs, clusterAcceptedPredicate())
 	if err != nil {
 		return err
 	}
 
-	return nil
+	// Enqueue the pending csrs of a ManagedCluster once it moves to another ManagedClusterSet
+	if len(r.clusterSetAllowlist) > 0 {
+		err = c.Watch(&source.Kind{Type: &clusterv1.ManagedCluster{}}, pendingCSRs, clusterSetChangedPredicate())
+		if err != nil {
+			return err
+		}
+	}
+
+	// Enqueue the csrs not allowed by the approval rules once the rules are reloaded
+	if r.approvalRulesConfigMapName != "" {
+		r.rulesReloaded = make(chan event.GenericEvent)
+		err = c.Watch(&source.Channel{Source: r.rulesReloaded}, &handler.EnqueueRequestForObject{})
+		if err != nil {
+			return err
+		}
+	}
+	if !r.cleansOrphanedCSRs() {
+		return nil
+	}
+
+	// Enqueue the pending csrs of a ManagedCluster once it is deleted, to clean them up
+	return c.Watch(&source.Kind{Type: &clusterv1.ManagedCluster{}}, pendingCSRs, clusterDeletedPredicate())
 }
@@ -0,0 +1,76 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// skipReasonInvalidUsername is the reason of the csrs labeled for a cluster but not requested by a bootstrap
+// service account, they are filtered out before being reconciled
+const skipReasonInvalidUsername = "InvalidUsername"
+
+var (
+	csrSeenTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "managedcluster_import_csr_seen_total",
+		Help: "Number of evaluations of the pending CSRs, a requeued CSR is counted on each evaluation",
+	}, []string{"cluster"})
+	csrApprovedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "managedcluster_import_csr_approved_total",
+		Help: "Number of CSRs approved",
+	}, []string{"cluster"})
+	csrDeniedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "managedcluster_import_csr_denied_total",
+		Help: "Number of CSRs denied by reason",
+	}, []string{"cluster", "reason"})
+	csrSkippedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "managedcluster_import_csr_skipped_total",
+		Help: "Number of CSRs left pending by reason",
+	}, []string{"cluster", "reason"})
+	updateApprovalErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "managedcluster_import_csr_update_approval_errors_total",
+		Help: "Number of failed updates of the approval of the CSRs",
+	}, []string{"cluster"})
+	csrDryRunDecisionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "managedcluster_import_csr_dry_run_decisions_total",
+		Help: "Number of decisions on the CSRs not applied in the dry run",
+	}, []string{"cluster", "decision"})
+	csrCollectedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "managedcluster_import_csr_collected_total",
+		Help: "Number of approved and denied CSRs deleted after the retention",
+	}, []string{"cluster"})
+	reconcileDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "managedcluster_import_csr_reconcile_duration_seconds",
+		Help:    "Duration of the reconciles of the CSRs",
+		Buckets: prometheus.DefBuckets,
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(csrSeenTotal, csrApprovedTotal, csrDeniedTotal, csrSkippedTotal,
+		updateApprovalErrorsTotal, csrDryRunDecisionsTotal, csrCollectedTotal, reconcileDuration)
+}
+
+// observeReconcileDuration records the duration of a reconcile started at start
+func observeReconcileDuration(start time.Time) {
+	reconcileDuration.Observe(time.Since(start).Seconds())
+}
+
+// recordSkippedByPredicate counts the csrs of a cluster filtered out for their username and records a warning
+// event on them
+func (r *ReconcileCSR) recordSkippedByPredicate(csr *certificatesv1.CertificateSigningRequest) {
+	clusterName := getClusterName(csr)
+	if clusterName == "" || getApprovalType(csr) != "" || r.watches(csr) {
+		return
+	}
+	csrSkippedTotal.WithLabelValues(clusterName, skipReasonInvalidUsername).Inc()
+	r.event(csr, corev1.EventTypeWarning, eventReasonInvalidUsername,
+		fmt.Sprintf("The CSR is not approved, its username %s is not a bootstrap service account of the cluster %s",
+			csr.Spec.Username, clusterName))
+}
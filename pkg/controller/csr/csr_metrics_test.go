@@ -0,0 +1,135 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// reconcileSamples returns the number of reconciles observed by the reconcile duration histogram
+func reconcileSamples(t *testing.T) uint64 {
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() == "managedcluster_import_csr_reconcile_duration_seconds" {
+			return family.GetMetric()[0].GetHistogram().GetSampleCount()
+		}
+	}
+	return 0
+}
+
+func TestReconcileCSR_ReconcileMetrics(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	tests := []struct {
+		name             string
+		objs             []runtime.Object
+		approvalFails    bool
+		wantApproved     float64
+		wantSkipReason   ReasonCode
+		wantUpdateErrors float64
+	}{
+		{
+			name:         "approved",
+			objs:         []runtime.Object{newAcceptedCluster(true)},
+			wantApproved: 1,
+		},
+		{
+			name:           "missing cluster",
+			wantSkipReason: ReasonClusterNotFound,
+		},
+		{
+			name:           "cluster not accepted",
+			objs:           []runtime.Object{newAcceptedCluster(false)},
+			wantSkipReason: ReasonClusterNotAccepted,
+		},
+		{
+			name:             "approval update failure",
+			objs:             []runtime.Object{newAcceptedCluster(true)},
+			approvalFails:    true,
+			wantUpdateErrors: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr := newDedupCSR(csrNameReconcile, newCSRRequest(t, clusterCommonNamePrefix+clusterName, nil))
+			kubeClient := fakeclientset.NewSimpleClientset(csr)
+			if tt.approvalFails {
+				kubeClient.PrependReactor("update", "certificatesigningrequests",
+					func(action clienttesting.Action) (bool, runtime.Object, error) {
+						if action.GetSubresource() == "approval" {
+							return true, nil, errors.NewServiceUnavailable("unavailable")
+						}
+						return false, nil, nil
+					})
+			}
+			r := &ReconcileCSR{
+				client:     fake.NewFakeClientWithScheme(testscheme, append(tt.objs, csr)...),
+				kubeClient: kubeClient,
+				scheme:     testscheme,
+			}
+
+			seen := testutil.ToFloat64(csrSeenTotal.WithLabelValues(clusterName))
+			approved := testutil.ToFloat64(csrApprovedTotal.WithLabelValues(clusterName))
+			updateErrors := testutil.ToFloat64(updateApprovalErrorsTotal.WithLabelValues(clusterName))
+			var skipped float64
+			if tt.wantSkipReason != "" {
+				skipped = testutil.ToFloat64(csrSkippedTotal.WithLabelValues(clusterName, string(tt.wantSkipReason)))
+			}
+			samples := reconcileSamples(t)
+
+			_, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}})
+			if (err != nil) != tt.approvalFails {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v, want error %v", err, tt.approvalFails)
+			}
+
+			if got := testutil.ToFloat64(csrSeenTotal.WithLabelValues(clusterName)) - seen; got != 1 {
+				t.Errorf("seen CSRs = %v, want 1", got)
+			}
+			if got := testutil.ToFloat64(csrApprovedTotal.WithLabelValues(clusterName)) - approved; got != tt.wantApproved {
+				t.Errorf("approved CSRs = %v, want %v", got, tt.wantApproved)
+			}
+			if got := testutil.ToFloat64(updateApprovalErrorsTotal.WithLabelValues(clusterName)) - updateErrors; got != tt.wantUpdateErrors {
+				t.Errorf("approval update errors = %v, want %v", got, tt.wantUpdateErrors)
+			}
+			if tt.wantSkipReason != "" {
+				if got := testutil.ToFloat64(csrSkippedTotal.WithLabelValues(clusterName, string(tt.wantSkipReason))) - skipped; got != 1 {
+					t.Errorf("skipped CSRs = %v, want 1 with reason %s", got, tt.wantSkipReason)
+				}
+			}
+			if got := reconcileSamples(t) - samples; got != 1 {
+				t.Errorf("observed reconciles = %d, want 1", got)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_recordSkippedByPredicate(t *testing.T) {
+	invalid := newDedupCSR(csrNameReconcile, nil)
+	invalid.Spec.Username = "system:serviceaccount:default:other"
+	skipped := testutil.ToFloat64(csrSkippedTotal.WithLabelValues(clusterName, skipReasonInvalidUsername))
+
+	r := &ReconcileCSR{}
+	r.recordSkippedByPredicate(invalid)
+	r.recordSkippedByPredicate(newDedupCSR(csrNameReconcile, nil))
+	if got := testutil.ToFloat64(csrSkippedTotal.WithLabelValues(clusterName, skipReasonInvalidUsername)) - skipped; got != 1 {
+		t.Errorf("skipped CSRs = %v, want 1 for the invalid username only", got)
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"flag"
+	"os"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+// CSRControllerOptions are the options of the csr controller. The zero fields are read from the environment
+// variables of the controller, for example the UsernameTemplates from CSR_BOOTSTRAP_USERNAME_TEMPLATES, so a field
+// set by the caller takes precedence over its environment variable.
+type CSRControllerOptions struct {
+	// ClusterLabel is the label key of the cluster name of the csrs, open-cluster-management.io/cluster-name if empty
+	ClusterLabel string
+	// LegacyClusterLabels are the label keys of the cluster name of the csrs of the previous releases, checked in
+	// order after the ClusterLabel
+	LegacyClusterLabels []string
+	// UsernameTemplates are the text/template templates of the usernames of the bootstrap service accounts of a
+	// cluster, the default template if empty
+	UsernameTemplates []string
+	// ClusterAllowlist are the names or glob patterns of the clusters whose csrs can be approved, all the clusters
+	// are allowed if empty
+	ClusterAllowlist []string
+	// ClusterDenylist are the names or glob patterns of the clusters whose csrs are never approved, it takes
+	// precedence over the ClusterAllowlist
+	ClusterDenylist []string
+	// AddOnAllowlist are the add-ons whose registration csrs are approved without a ManagedClusterAddOn
+	AddOnAllowlist []string
+	// ClusterSetAllowlist are the ManagedClusterSets whose clusters can get their csrs approved, the clusters of all
+	// the sets and without set are allowed if empty
+	ClusterSetAllowlist []string
+
+	// DryRun evaluates the csrs without updating them, the decisions are logged and counted only
+	DryRun bool
+	// DenyUnauthorizedCSRs denies the csrs of the accepted clusters requested by a username that is not a bootstrap
+	// service account of the cluster, such csrs are ignored if false
+	DenyUnauthorizedCSRs bool
+	// BatchApproval evaluates the other pending csrs of a cluster once one of its csrs is approved
+	BatchApproval bool
+	// VerifyServiceAccounts skips the approval of the csrs whose requesting service account does not exist on the hub
+	VerifyServiceAccounts bool
+	// MaxConcurrentReconciles is the number of workers of the csr controller. A csr is reconciled by a single worker
+	// at a time, the workers share the state of the reconciler which is safe for concurrent use.
+	MaxConcurrentReconciles int
+	// ApprovalRate is the number of csrs of a cluster approved per second, ApprovalBurst the number of csrs of a
+	// cluster approved at once, the approvals are not rate limited if ApprovalRate is not positive
+	ApprovalRate  float64
+	ApprovalBurst int
+
+	// APICallTimeout is the timeout of the API calls of the csr controller, a slow API server can not block a worker
+	APICallTimeout time.Duration
+	// IssuanceTimeout is the duration after the approval of a csr within which its certificate must be issued by the
+	// signer, the issuance is not verified if 0
+	IssuanceTimeout time.Duration
+	// DenialCooldown is the duration during which the new csrs of a cluster are not evaluated after a csr of the
+	// cluster was denied, there is no cooldown if 0
+	DenialCooldown time.Duration
+	// DedupWindow is the duration during which the csrs carrying the same request as an approved csr are not
+	// approved, the csrs are not deduplicated if 0
+	DedupWindow time.Duration
+
+	// the settings of the environment of the controller only
+	podNamespace                    string
+	challengeSecretName             string
+	approvalRulesConfigMapName      string
+	controllerConfigName            string
+	attestationKeySecretName        string
+	auditConfigMapName              string
+	denialNotificationConfigMapName string
+	policyCompatibilityWindow       time.Duration
+	invalidRequestAction            string
+	signerPolicies                  map[string]signerPolicy
+	crossCheckAnnotation            string
+	apiVersionRefresh               time.Duration
+	apiVersionMode                  string
+	outOfClusterConfig              *rest.Config
+	auditFlushInterval              time.Duration
+	auditFlushEntries               int
+	clusterNotFoundBackoff          time.Duration
+	clusterNotFoundMaxAttempts      int
+	maxManagedClusters              int
+	requestAllowlist                *requestAllowlist
+	orphanedAction                  string
+	approvalWebhook                 *approvalWebhook
+	approvalWindow                  *approvalWindow
+	retention                       time.Duration
+
+	// usernameTemplates and clusterNameFilter are the parsed UsernameTemplates, ClusterAllowlist and ClusterDenylist
+	usernameTemplates usernameTemplates
+	clusterNameFilter *clusterNameFilter
+}
+
+// NewCSRControllerOptions returns the options of the csr controller with the defaults of its flags
+func NewCSRControllerOptions() *CSRControllerOptions {
+	return &CSRControllerOptions{
+		ClusterLabel:            clusterLabel,
+		MaxConcurrentReconciles: 1,
+		ApprovalBurst:           10,
+		APICallTimeout:          30 * time.Second,
+	}
+}
+
+// AddFlags binds the flags of the csr controller to the options
+func (o *CSRControllerOptions) AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.ClusterLabel, "csr-cluster-label", o.ClusterLabel,
+		"Label key of the cluster name of the CSRs, the CSRs without the label are not reconciled")
+	fs.BoolVar(&o.DryRun, "dry-run", o.DryRun,
+		"Evaluate the CSRs and log the decisions without approving, denying or annotating them")
+	fs.BoolVar(&o.DenyUnauthorizedCSRs, "deny-unauthorized-csrs", o.DenyUnauthorizedCSRs,
+		"Deny the CSRs labeled for an accepted ManagedCluster but requested by a username that is not a bootstrap "+
+			"service account of the cluster, they are ignored by default")
+	fs.BoolVar(&o.BatchApproval, "batch-approval", o.BatchApproval,
+		"Evaluate all the pending CSRs of a ManagedCluster once one of its CSRs is approved, for example the CSRs "+
+			"left by the restarts of the agent, instead of evaluating each of them on its own reconcile")
+	fs.BoolVar(&o.VerifyServiceAccounts, "verify-bootstrap-service-accounts", o.VerifyServiceAccounts,
+		"Approve the CSRs requested by a service account only if the service account exists on the hub, so the CSRs "+
+			"left by a deleted bootstrap service account are not approved")
+	fs.IntVar(&o.MaxConcurrentReconciles, "csr-max-concurrent-reconciles", o.MaxConcurrentReconciles,
+		"Number of the CSRs reconciled concurrently by the csr controller, for example during the mass onboarding "+
+			"of clusters on a large hub")
+	fs.Float64Var(&o.ApprovalRate, "csr-approval-rate", o.ApprovalRate,
+		"Number of CSRs of a ManagedCluster approved per second, the CSRs over the rate are requeued. "+
+			"The approvals are not rate limited if not positive")
+	fs.IntVar(&o.ApprovalBurst, "csr-approval-burst", o.ApprovalBurst,
+		"Number of CSRs of a ManagedCluster approved at once with the --csr-approval-rate")
+	fs.DurationVar(&o.APICallTimeout, "csr-api-call-timeout", o.APICallTimeout,
+		"Timeout of the API calls of the csr controller, a reconcile whose call times out returns an error and is "+
+			"requeued. 0 disables the timeout")
+}
+
+// complete reads the zero fields and the settings of the environment of the controller from its environment
+// variables, and parses the options, an error if one of them is invalid
+func (o *CSRControllerOptions) complete() error {
+	var err error
+	if o.ClusterLabel == "" {
+		o.ClusterLabel = clusterLabel
+	}
+	if o.LegacyClusterLabels == nil {
+		if o.LegacyClusterLabels, err = getLegacyClusterLabels(); err != nil {
+			return err
+		}
+	}
+	if err := validateClusterLabels(append([]string{o.ClusterLabel}, o.LegacyClusterLabels...)...); err != nil {
+		return err
+	}
+	if len(o.UsernameTemplates) == 0 {
+		o.usernameTemplates, err = getUsernameTemplates()
+	} else {
+		o.usernameTemplates, err = parseUsernameTemplates("username", o.UsernameTemplates)
+	}
+	if err != nil {
+		return err
+	}
+	if len(o.ClusterAllowlist) == 0 && len(o.ClusterDenylist) == 0 {
+		o.clusterNameFilter, err = getClusterNameFilter()
+	} else {
+		o.clusterNameFilter, err = newClusterNameFilter(o.ClusterAllowlist, o.ClusterDenylist)
+	}
+	if err != nil {
+		return err
+	}
+	if o.AddOnAllowlist == nil {
+		if o.AddOnAllowlist, err = getAddOnAllowlist(); err != nil {
+			return err
+		}
+	}
+	if o.ClusterSetAllowlist == nil {
+		o.ClusterSetAllowlist, err = getClusterSetAllowlist()
+	} else {
+		o.ClusterSetAllowlist, err = validateClusterSets("ClusterSetAllowlist", o.ClusterSetAllowlist)
+	}
+	if err != nil {
+		return err
+	}
+	if o.IssuanceTimeout == 0 {
+		if o.IssuanceTimeout, err = getIssuanceTimeout(); err != nil {
+			return err
+		}
+	}
+	if o.DenialCooldown == 0 {
+		if o.DenialCooldown, err = getDenialCooldown(); err != nil {
+			return err
+		}
+	}
+	if o.DedupWindow == 0 {
+		if o.DedupWindow, err = getDedupWindow(); err != nil {
+			return err
+		}
+	}
+
+	o.podNamespace = os.Getenv("POD_NAMESPACE")
+	o.challengeSecretName = os.Getenv(challengeSecretEnvVarName)
+	o.approvalRulesConfigMapName = os.Getenv(approvalRulesConfigMapEnvVarName)
+	o.controllerConfigName = os.Getenv(controllerConfigEnvVarName)
+	o.attestationKeySecretName = os.Getenv(attestationKeySecretEnvVarName)
+	o.auditConfigMapName = os.Getenv(auditConfigMapEnvVarName)
+	o.denialNotificationConfigMapName = os.Getenv(denialNotificationConfigMapEnvVarName)
+	if o.policyCompatibilityWindow, err = getPolicyCompatibilityWindow(); err != nil {
+		return err
+	}
+	if o.invalidRequestAction, err = getInvalidRequestAction(); err != nil {
+		return err
+	}
+	if o.signerPolicies, err = getSignerPolicies(); err != nil {
+		return err
+	}
+	if o.crossCheckAnnotation, err = getCrossCheckAnnotation(); err != nil {
+		return err
+	}
+	if o.apiVersionRefresh, err = getAPIVersionRefresh(); err != nil {
+		return err
+	}
+	if o.apiVersionMode, err = getAPIVersionMode(); err != nil {
+		return err
+	}
+	if o.outOfClusterConfig, err = getOutOfClusterConfig(); err != nil {
+		return err
+	}
+	if o.auditFlushInterval, o.auditFlushEntries, err = getAuditFlush(); err != nil {
+		return err
+	}
+	if o.clusterNotFoundBackoff, o.clusterNotFoundMaxAttempts, err = getClusterNotFoundRetry(); err != nil {
+		return err
+	}
+	if o.maxManagedClusters, err = getMaxManagedClusters(); err != nil {
+		return err
+	}
+	if o.requestAllowlist, err = getRequestAllowlist(); err != nil {
+		return err
+	}
+	if o.orphanedAction, err = getOrphanedAction(); err != nil {
+		return err
+	}
+	if o.approvalWebhook, err = getApprovalWebhook(); err != nil {
+		return err
+	}
+	if o.approvalWindow, err = getApprovalWindow(); err != nil {
+		return err
+	}
+	o.retention, err = getCSRRetention()
+	return err
+}
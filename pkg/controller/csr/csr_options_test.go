@@ -0,0 +1,266 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCSRControllerOptions_AddFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want CSRControllerOptions
+	}{
+		{
+			name: "defaults",
+			want: *NewCSRControllerOptions(),
+		},
+		{
+			name: "flags",
+			args: []string{"--csr-cluster-label=example.com/cluster", "--dry-run", "--deny-unauthorized-csrs",
+				"--batch-approval", "--verify-bootstrap-service-accounts", "--csr-max-concurrent-reconciles=4",
+				"--csr-approval-rate=2.5", "--csr-approval-burst=5", "--csr-api-call-timeout=5s"},
+			want: CSRControllerOptions{
+				ClusterLabel:            "example.com/cluster",
+				DryRun:                  true,
+				DenyUnauthorizedCSRs:    true,
+				BatchApproval:           true,
+				VerifyServiceAccounts:   true,
+				MaxConcurrentReconciles: 4,
+				ApprovalRate:            2.5,
+				ApprovalBurst:           5,
+				APICallTimeout:          5 * time.Second,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := NewCSRControllerOptions()
+			fs := flag.NewFlagSet("csr", flag.ContinueOnError)
+			opts.AddFlags(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if fmt.Sprintf("%+v", *opts) != fmt.Sprintf("%+v", tt.want) {
+				t.Errorf("AddFlags() options = %+v, want %+v", *opts, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSRControllerOptions_complete(t *testing.T) {
+	bootstrapUsername := fmt.Sprintf(userNameSignature, clusterName, clusterName)
+	agentUsername := "system:serviceaccount:open-cluster-management-agent:" + clusterName + "-bootstrap"
+	agentUsernameTemplate := "system:serviceaccount:open-cluster-management-agent:{{.ClusterName}}-bootstrap"
+
+	tests := []struct {
+		name         string
+		opts         CSRControllerOptions
+		env          map[string]string
+		wantErr      bool
+		wantUsername string
+		wantDenied   string
+		wantAddOns   int
+		wantCooldown time.Duration
+	}{
+		{
+			name:         "defaults",
+			wantUsername: bootstrapUsername,
+		},
+		{
+			name:         "username templates of the environment",
+			env:          map[string]string{usernameTemplatesEnvVarName: agentUsernameTemplate},
+			wantUsername: agentUsername,
+		},
+		{
+			name:         "username templates of the options take precedence over the environment",
+			opts:         CSRControllerOptions{UsernameTemplates: []string{agentUsernameTemplate}},
+			env:          map[string]string{usernameTemplatesEnvVarName: defaultUsernameTemplate},
+			wantUsername: agentUsername,
+		},
+		{
+			name:    "invalid username template",
+			opts:    CSRControllerOptions{UsernameTemplates: []string{"{{.ClusterName"}},
+			wantErr: true,
+		},
+		{
+			name:         "cluster denylist of the options",
+			opts:         CSRControllerOptions{ClusterDenylist: []string{"dev-*"}},
+			env:          map[string]string{clusterDenylistEnvVarName: "prod-*"},
+			wantUsername: bootstrapUsername,
+			wantDenied:   "dev-cluster",
+		},
+		{
+			name:         "cluster denylist of the environment",
+			env:          map[string]string{clusterDenylistEnvVarName: "prod-*"},
+			wantUsername: bootstrapUsername,
+			wantDenied:   "prod-cluster",
+		},
+		{
+			name:    "invalid cluster pattern",
+			opts:    CSRControllerOptions{ClusterAllowlist: []string{"["}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid cluster label",
+			opts:    CSRControllerOptions{ClusterLabel: "invalid label"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid legacy cluster label",
+			opts:    CSRControllerOptions{LegacyClusterLabels: []string{"invalid/label/key"}},
+			wantErr: true,
+		},
+		{
+			name:         "add-on allowlist and cooldown of the options",
+			opts:         CSRControllerOptions{AddOnAllowlist: []string{"work-manager"}, DenialCooldown: time.Minute},
+			env:          map[string]string{denialCooldownEnvVarName: "1h"},
+			wantUsername: bootstrapUsername,
+			wantAddOns:   1,
+			wantCooldown: time.Minute,
+		},
+		{
+			name:    "invalid environment variable",
+			env:     map[string]string{orphanedActionEnvVarName: "revoke"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				os.Setenv(name, value)
+				defer os.Unsetenv(name)
+			}
+			opts := tt.opts
+			err := opts.complete()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("complete() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if opts.ClusterLabel != clusterLabel {
+				t.Errorf("complete() ClusterLabel = %q, want %q", opts.ClusterLabel, clusterLabel)
+			}
+			if !opts.usernameTemplates.matches(tt.wantUsername, clusterName) {
+				t.Errorf("complete() username templates do not match %s", tt.wantUsername)
+			}
+			if tt.wantDenied != "" && opts.clusterNameFilter.allows(tt.wantDenied) == nil {
+				t.Errorf("complete() cluster name filter allows %s", tt.wantDenied)
+			}
+			if len(opts.AddOnAllowlist) != tt.wantAddOns {
+				t.Errorf("complete() AddOnAllowlist = %v, want %d add-ons", opts.AddOnAllowlist, tt.wantAddOns)
+			}
+			if opts.DenialCooldown != tt.wantCooldown {
+				t.Errorf("complete() DenialCooldown = %v, want %v", opts.DenialCooldown, tt.wantCooldown)
+			}
+		})
+	}
+}
+
+func Test_newReconcilerOptions(t *testing.T) {
+	os.Setenv(kubeconfigEnvVarName, writeKubeconfig(t, testKubeconfig))
+	defer os.Unsetenv(kubeconfigEnvVarName)
+	agentUsernameTemplate := "system:serviceaccount:open-cluster-management-agent:{{.ClusterName}}-bootstrap"
+	newCSR := func(username string) *certificatesv1.CertificateSigningRequest {
+		return &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   csrNameReconcile,
+				Labels: map[string]string{clusterLabel: clusterName},
+			},
+			Spec: certificatesv1.CertificateSigningRequestSpec{Username: username},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		opts   *CSRControllerOptions
+		verify func(t *testing.T, r *ReconcileCSR)
+	}{
+		{
+			name: "defaults",
+			opts: NewCSRControllerOptions(),
+			verify: func(t *testing.T, r *ReconcileCSR) {
+				if r.dryRun || r.denyUnauthorizedCSRs || r.batchApproval || r.verifyServiceAccounts {
+					t.Errorf("newReconciler() enables an option by default")
+				}
+				if r.maxConcurrentReconciles != 1 || r.apiCalls.timeout != 30*time.Second {
+					t.Errorf("newReconciler() workers = %d and API call timeout = %v, want 1 and 30s",
+						r.maxConcurrentReconciles, r.apiCalls.timeout)
+				}
+				if r.approvalRateLimiter != nil || r.clusterNameFilter != nil {
+					t.Errorf("newReconciler() limits the approvals by default")
+				}
+				bootstrapUsername := fmt.Sprintf(userNameSignature, clusterName, clusterName)
+				if !csrPredicate(newCSR(bootstrapUsername), r.usernameTemplates) {
+					t.Errorf("newReconciler() does not watch the csrs of the default bootstrap username")
+				}
+			},
+		},
+		{
+			name: "options",
+			opts: &CSRControllerOptions{
+				UsernameTemplates:       []string{agentUsernameTemplate},
+				ClusterAllowlist:        []string{"prod-*"},
+				DryRun:                  true,
+				DenyUnauthorizedCSRs:    true,
+				BatchApproval:           true,
+				VerifyServiceAccounts:   true,
+				MaxConcurrentReconciles: 4,
+				ApprovalRate:            1,
+				ApprovalBurst:           2,
+				APICallTimeout:          5 * time.Second,
+				DedupWindow:             time.Hour,
+			},
+			verify: func(t *testing.T, r *ReconcileCSR) {
+				if !r.dryRun || !r.denyUnauthorizedCSRs || !r.batchApproval || !r.verifyServiceAccounts {
+					t.Errorf("newReconciler() does not enable the options")
+				}
+				if r.maxConcurrentReconciles != 4 || r.apiCalls.timeout != 5*time.Second {
+					t.Errorf("newReconciler() workers = %d and API call timeout = %v, want 4 and 5s",
+						r.maxConcurrentReconciles, r.apiCalls.timeout)
+				}
+				if r.approvalRateLimiter == nil || r.approvalRateLimiter.burst != 2 {
+					t.Errorf("newReconciler() rate limiter = %v, want a burst of 2", r.approvalRateLimiter)
+				}
+				if r.dedup == nil || r.dedup.window != time.Hour {
+					t.Errorf("newReconciler() dedup = %v, want a window of 1h", r.dedup)
+				}
+				if err := r.clusterNameFilter.allows(clusterName); err == nil {
+					t.Errorf("newReconciler() allows the cluster %s out of the allowlist", clusterName)
+				}
+				agentUsername := "system:serviceaccount:open-cluster-management-agent:" + clusterName + "-bootstrap"
+				if !csrPredicate(newCSR(agentUsername), r.usernameTemplates) {
+					t.Errorf("newReconciler() does not watch the csrs of the username template")
+				}
+				bootstrapUsername := fmt.Sprintf(userNameSignature, clusterName, clusterName)
+				if csrPredicate(newCSR(bootstrapUsername), r.usernameTemplates) {
+					t.Errorf("newReconciler() watches the csrs of the default bootstrap username")
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.complete(); err != nil {
+				t.Fatalf("complete() error = %v", err)
+			}
+			mgr := &fakeManager{client: fake.NewFakeClientWithScheme(scheme.Scheme)}
+			r, err := newReconciler(mgr, tt.opts)
+			if err != nil {
+				t.Fatalf("newReconciler() error = %v", err)
+			}
+			tt.verify(t, r)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"fmt"
+	"os"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// orphanedActionEnvVarName is the action taken on the pending csrs of a ManagedCluster deleted or being deleted
+	orphanedActionEnvVarName = "CSR_ORPHANED_ACTION"
+
+	// orphanedActionSkip leaves the csr pending
+	orphanedActionSkip = "skip"
+	// orphanedActionDeny denies the csr
+	orphanedActionDeny = "deny"
+	// orphanedActionDelete deletes the csr
+	orphanedActionDelete = "delete"
+)
+
+// getOrphanedAction returns the CSR_ORPHANED_ACTION value, skip if not set
+func getOrphanedAction() (string, error) {
+	switch v := os.Getenv(orphanedActionEnvVarName); v {
+	case "":
+		return orphanedActionSkip, nil
+	case orphanedActionSkip, orphanedActionDeny, orphanedActionDelete:
+		return v, nil
+	default:
+		return "", fmt.Errorf("invalid %s value %q, must be %s, %s or %s",
+			orphanedActionEnvVarName, v, orphanedActionSkip, orphanedActionDeny, orphanedActionDelete)
+	}
+}
+
+// clusterDeletedPredicate selects the ManagedClusters deleted or being deleted
+func clusterDeletedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		GenericFunc: func(e event.GenericEvent) bool { return false },
+		CreateFunc:  func(e event.CreateEvent) bool { return false },
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			_, ok := e.Object.(*clusterv1.ManagedCluster)
+			return ok
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			newCluster, okNew := e.ObjectNew.(*clusterv1.ManagedCluster)
+			oldCluster, okOld := e.ObjectOld.(*clusterv1.ManagedCluster)
+			return okNew && okOld && newCluster.DeletionTimestamp != nil && oldCluster.DeletionTimestamp == nil
+		},
+	}
+}
+
+// cleansOrphanedCSRs checks the pending csrs of the deleted ManagedClusters are denied or deleted
+func (r *ReconcileCSR) cleansOrphanedCSRs() bool {
+	return r.orphanedAction == orphanedActionDeny || r.orphanedAction == orphanedActionDelete
+}
+
+// cleanOrphanedCSR denies or deletes the pending csr of a ManagedCluster deleted or being deleted with the
+// orphanedAction, the csr is left pending with the ClusterNotFound reason with the skip action. The denial of an
+// orphaned csr neither starts the cooldown nor writes a notification of the deleted cluster.
+func (r *ReconcileCSR) cleanOrphanedCSR(csr *certificatesv1.CertificateSigningRequest, clusterName string) error {
+	message := fmt.Sprintf("The ManagedCluster %s is deleted", clusterName)
+	switch r.orphanedAction {
+	case orphanedActionDeny:
+		csrLogger(csr).Info("Denying CSR of a deleted cluster", "decision", decisionDeny)
+		_, err := r.updateDenial(csr, clusterName, ReasonClusterDeleted, message)
+		return err
+	case orphanedActionDelete:
+		csrLogger(csr).Info("Deleting CSR of a deleted cluster", "decision", decisionDelete)
+		if r.dryRun {
+			recordDryRunDecision(csr, decisionDelete, ReasonClusterDeleted, message)
+			return nil
+		}
+		return r.deleteCSR(csr)
+	default:
+		return r.markPending(csr, ReasonClusterNotFound, message)
+	}
+}
+
+// deleteCSR deletes the csr with its certificates API version, a csr already deleted is not an error
+func (r *ReconcileCSR) deleteCSR(csr *certificatesv1.CertificateSigningRequest) error {
+	version, err := r.csrAPIVersion(csr)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := r.apiCallContext()
+	defer cancel()
+	if version == certificatesV1beta1 {
+		err = r.kubeClient.CertificatesV1beta1().CertificateSigningRequests().Delete(ctx, csr.Name,
+			metav1.DeleteOptions{})
+	} else {
+		err = r.kubeClient.CertificatesV1().CertificateSigningRequests().Delete(ctx, csr.Name,
+			metav1.DeleteOptions{})
+	}
+	r.apiVersions.observe(err)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
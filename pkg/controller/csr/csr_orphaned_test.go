@@ -0,0 +1,210 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func Test_getOrphanedAction(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "not set",
+			want: orphanedActionSkip,
+		},
+		{
+			name:  "deny",
+			value: "deny",
+			want:  orphanedActionDeny,
+		},
+		{
+			name:  "delete",
+			value: "delete",
+			want:  orphanedActionDelete,
+		},
+		{
+			name:    "invalid",
+			value:   "purge",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(orphanedActionEnvVarName, tt.value)
+			defer os.Unsetenv(orphanedActionEnvVarName)
+			got, err := getOrphanedAction()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getOrphanedAction() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("getOrphanedAction() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_clusterDeletedPredicate(t *testing.T) {
+	cluster := &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: clusterName}}
+	deleting := cluster.DeepCopy()
+	now := metav1.Now()
+	deleting.DeletionTimestamp = &now
+
+	p := clusterDeletedPredicate()
+	if !p.Delete(event.DeleteEvent{Meta: cluster, Object: cluster}) {
+		t.Errorf("DeleteFunc() = false, want true")
+	}
+	if p.Create(event.CreateEvent{Meta: deleting, Object: deleting}) {
+		t.Errorf("CreateFunc() = true, want false")
+	}
+	if !p.Update(event.UpdateEvent{MetaOld: cluster, ObjectOld: cluster, MetaNew: deleting, ObjectNew: deleting}) {
+		t.Errorf("UpdateFunc() of a cluster being deleted = false, want true")
+	}
+	if p.Update(event.UpdateEvent{MetaOld: deleting, ObjectOld: deleting, MetaNew: deleting, ObjectNew: deleting}) {
+		t.Errorf("UpdateFunc() of a cluster already being deleted = true, want false")
+	}
+}
+
+func TestReconcileCSR_ReconcileOrphanedCSR(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	healthyCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterName},
+		Spec:       clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+	}
+	deletingCluster := healthyCluster.DeepCopy()
+	deletedAt := metav1.NewTime(time.Now())
+	deletingCluster.DeletionTimestamp = &deletedAt
+	csr := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   csrNameReconcile,
+			Labels: map[string]string{clusterLabel: clusterName},
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Username:   fmt.Sprintf(userNameSignature, clusterName, clusterName),
+			Request:    newCSRRequest(t, clusterCommonNamePrefix+clusterName, nil),
+			SignerName: certificatesv1.KubeAPIServerClientSignerName,
+			Usages:     []certificatesv1.KeyUsage{certificatesv1.UsageClientAuth},
+		},
+	}
+
+	tests := []struct {
+		name            string
+		orphanedAction  string
+		cluster         *clusterv1.ManagedCluster
+		clusterNotFound *clusterNotFoundRetry
+		wantDeleted     bool
+		wantRequeue     bool
+		wantCode        ReasonCode
+		wantApproval    string
+	}{
+		{
+			name:           "orphaned csr denied",
+			orphanedAction: orphanedActionDeny,
+			wantCode:       ReasonClusterDeleted,
+			wantApproval:   string(certificatesv1.CertificateDenied),
+		},
+		{
+			name:           "orphaned csr deleted",
+			orphanedAction: orphanedActionDelete,
+			wantDeleted:    true,
+		},
+		{
+			name:           "csr of a cluster being deleted denied",
+			orphanedAction: orphanedActionDeny,
+			cluster:        deletingCluster,
+			wantCode:       ReasonClusterDeleted,
+			wantApproval:   string(certificatesv1.CertificateDenied),
+		},
+		{
+			name:            "csr of a cluster not found yet",
+			orphanedAction:  orphanedActionDeny,
+			clusterNotFound: newClusterNotFoundRetry(time.Second, 1),
+			wantRequeue:     true,
+			wantCode:        ReasonClusterNotFound,
+		},
+		{
+			name:     "orphaned csr left pending by default",
+			wantCode: ReasonClusterNotFound,
+		},
+		{
+			name:           "healthy csr untouched",
+			orphanedAction: orphanedActionDelete,
+			cluster:        healthyCluster,
+			wantCode:       ReasonAutoApproved,
+			wantApproval:   string(certificatesv1.CertificateApproved),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := []runtime.Object{csr.DeepCopy()}
+			if tt.cluster != nil {
+				objs = append(objs, tt.cluster)
+			}
+			r := &ReconcileCSR{
+				client:          fake.NewFakeClientWithScheme(testscheme, objs...),
+				kubeClient:      fakeclientset.NewSimpleClientset(csr.DeepCopy()),
+				scheme:          testscheme,
+				podNamespace:    testPodNamespace,
+				clusterNotFound: tt.clusterNotFound,
+				orphanedAction:  tt.orphanedAction,
+				denialCooldown:  newDenialCooldown(time.Hour),
+
+				denialNotificationConfigMapName: "csr-denials",
+			}
+			result, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}})
+			if err != nil {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+			}
+			if result.Requeue != tt.wantRequeue {
+				t.Errorf("ReconcileCSR.Reconcile() requeue = %v, want %v", result.Requeue, tt.wantRequeue)
+			}
+			got, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), csrNameReconcile, metav1.GetOptions{})
+			if tt.wantDeleted {
+				if !errors.IsNotFound(err) {
+					t.Errorf("CSR get error = %v, want the CSR deleted", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code := got.Annotations[ReasonCodeAnnotation]; code != string(tt.wantCode) {
+				t.Errorf("reason code annotation = %q, want %q", code, tt.wantCode)
+			}
+			if approval := getApprovalType(got); approval != tt.wantApproval {
+				t.Errorf("CSR approval = %q, want %q", approval, tt.wantApproval)
+			}
+			// the denial of an orphaned csr is not a denial of the cluster
+			if remaining := r.denialCooldown.remaining(clusterName, time.Now()); remaining != 0 {
+				t.Errorf("denial cooldown remaining = %v, want no cooldown", remaining)
+			}
+			if _, err := r.kubeClient.CoreV1().ConfigMaps(clusterName).Get(context.TODO(), "csr-denials",
+				metav1.GetOptions{}); !errors.IsNotFound(err) {
+				t.Errorf("denial notification get error = %v, want no notification", err)
+			}
+		})
+	}
+}
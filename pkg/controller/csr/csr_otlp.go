@@ -0,0 +1,315 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// the standard OpenTelemetry exporter env vars, the approval metrics are exported to the OTLP/HTTP collector of the
+// metrics endpoint, or of the endpoint if not set. The metrics are not exported if none is set
+const (
+	otlpMetricsEndpointEnvVarName = "OTEL_EXPORTER_OTLP_METRICS_ENDPOINT"
+	otlpEndpointEnvVarName        = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	otlpHeadersEnvVarName         = "OTEL_EXPORTER_OTLP_HEADERS"
+	otlpExportIntervalEnvVarName  = "OTEL_METRIC_EXPORT_INTERVAL"
+)
+
+const (
+	defaultOTLPExportInterval = 60 * time.Second
+	otlpExportTimeout         = 10 * time.Second
+	otlpMetricsPath           = "/v1/metrics"
+	// otlpMetricPrefix is the prefix of the exported metrics of the registry, the approval metrics
+	otlpMetricPrefix = "managedcluster_import_csr_"
+	otlpServiceName  = "managedcluster-import-controller"
+	otlpScopeName    = "github.com/open-cluster-management/managedcluster-import-controller/pkg/controller/csr"
+)
+
+// aggregationTemporalityCumulative is the OTLP cumulative temporality of the prometheus counters and histograms
+const aggregationTemporalityCumulative = 2
+
+// otlpExporter periodically exports the approval metrics of the prometheus registry to an OTLP/HTTP collector with
+// the JSON encoding, alongside the metrics endpoint of the controller
+type otlpExporter struct {
+	endpoint   string
+	headers    map[string]string
+	interval   time.Duration
+	gatherer   prometheus.Gatherer
+	httpClient *http.Client
+	startTime  time.Time
+}
+
+// getOTLPExporter returns the exporter configured by the OpenTelemetry env vars, nil if no endpoint is set
+func getOTLPExporter() (*otlpExporter, error) {
+	endpoint := os.Getenv(otlpMetricsEndpointEnvVarName)
+	endpointEnvVarName := otlpMetricsEndpointEnvVarName
+	if endpoint == "" {
+		endpoint = os.Getenv(otlpEndpointEnvVarName)
+		endpointEnvVarName = otlpEndpointEnvVarName
+		if endpoint == "" {
+			return nil, nil
+		}
+		endpoint = strings.TrimSuffix(endpoint, "/") + otlpMetricsPath
+	}
+	if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid %s value %q, must be an http or https URL", endpointEnvVarName, endpoint)
+	}
+
+	headers := map[string]string{}
+	if v := os.Getenv(otlpHeadersEnvVarName); v != "" {
+		for _, header := range strings.Split(v, ",") {
+			kv := strings.SplitN(header, "=", 2)
+			if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+				return nil, fmt.Errorf("invalid %s value, must be a comma separated list of key=value", otlpHeadersEnvVarName)
+			}
+			value, err := url.QueryUnescape(strings.TrimSpace(kv[1]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s value: %v", otlpHeadersEnvVarName, err)
+			}
+			headers[strings.TrimSpace(kv[0])] = value
+		}
+	}
+
+	interval := defaultOTLPExportInterval
+	if v := os.Getenv(otlpExportIntervalEnvVarName); v != "" {
+		ms, err := strconv.Atoi(v)
+		if err != nil || ms <= 0 {
+			return nil, fmt.Errorf("invalid %s value %q, must be a positive number of milliseconds",
+				otlpExportIntervalEnvVarName, v)
+		}
+		interval = time.Duration(ms) * time.Millisecond
+	}
+
+	return &otlpExporter{
+		endpoint:   endpoint,
+		headers:    headers,
+		interval:   interval,
+		gatherer:   metrics.Registry,
+		httpClient: &http.Client{Timeout: otlpExportTimeout},
+		startTime:  time.Now(),
+	}, nil
+}
+
+// Start exports the metrics every interval until stop is closed, the metrics are exported a last time on stop.
+// A failed export is logged and retried at the next interval.
+func (e *otlpExporter) Start(stop <-chan struct{}) error {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := e.export(time.Now()); err != nil {
+				log.Error(err, "failed to export the approval metrics", "endpoint", e.endpoint)
+			}
+		case <-stop:
+			if err := e.export(time.Now()); err != nil {
+				log.Error(err, "failed to export the approval metrics", "endpoint", e.endpoint)
+			}
+			return nil
+		}
+	}
+}
+
+// export posts the current values of the approval metrics to the collector
+func (e *otlpExporter) export(now time.Time) error {
+	families, err := e.gatherer.Gather()
+	if err != nil {
+		return err
+	}
+
+	startTime := strconv.FormatInt(e.startTime.UnixNano(), 10)
+	timestamp := strconv.FormatInt(now.UnixNano(), 10)
+	otlpMetrics := []otlpMetric{}
+	for _, family := range families {
+		if !strings.HasPrefix(family.GetName(), otlpMetricPrefix) {
+			continue
+		}
+		metric := otlpMetric{Name: family.GetName(), Description: family.GetHelp()}
+		for _, m := range family.GetMetric() {
+			attributes := []otlpKeyValue{}
+			for _, label := range m.GetLabel() {
+				attributes = append(attributes, otlpKeyValue{
+					Key:   label.GetName(),
+					Value: otlpAnyValue{StringValue: label.GetValue()},
+				})
+			}
+			switch family.GetType().String() {
+			case "COUNTER":
+				if metric.Sum == nil {
+					metric.Sum = &otlpSum{AggregationTemporality: aggregationTemporalityCumulative, IsMonotonic: true}
+				}
+				metric.Sum.DataPoints = append(metric.Sum.DataPoints, otlpNumberDataPoint{
+					Attributes:        attributes,
+					StartTimeUnixNano: startTime,
+					TimeUnixNano:      timestamp,
+					AsDouble:          m.GetCounter().GetValue(),
+				})
+			case "GAUGE":
+				if metric.Gauge == nil {
+					metric.Gauge = &otlpGauge{}
+				}
+				metric.Gauge.DataPoints = append(metric.Gauge.DataPoints, otlpNumberDataPoint{
+					Attributes:   attributes,
+					TimeUnixNano: timestamp,
+					AsDouble:     m.GetGauge().GetValue(),
+				})
+			case "HISTOGRAM":
+				if metric.Histogram == nil {
+					metric.Histogram = &otlpHistogram{AggregationTemporality: aggregationTemporalityCumulative}
+				}
+				// the prometheus buckets are cumulative, the OTLP buckets count the samples of their bound only
+				// and the last bucket counts the samples above the last bound
+				h := m.GetHistogram()
+				dataPoint := otlpHistogramDataPoint{
+					Attributes:        attributes,
+					StartTimeUnixNano: startTime,
+					TimeUnixNano:      timestamp,
+					Count:             strconv.FormatUint(h.GetSampleCount(), 10),
+					Sum:               h.GetSampleSum(),
+					BucketCounts:      []string{},
+					ExplicitBounds:    []float64{},
+				}
+				var previous uint64
+				for _, bucket := range h.GetBucket() {
+					if math.IsInf(bucket.GetUpperBound(), 1) {
+						continue
+					}
+					dataPoint.ExplicitBounds = append(dataPoint.ExplicitBounds, bucket.GetUpperBound())
+					dataPoint.BucketCounts = append(dataPoint.BucketCounts,
+						strconv.FormatUint(bucket.GetCumulativeCount()-previous, 10))
+					previous = bucket.GetCumulativeCount()
+				}
+				dataPoint.BucketCounts = append(dataPoint.BucketCounts,
+					strconv.FormatUint(h.GetSampleCount()-previous, 10))
+				metric.Histogram.DataPoints = append(metric.Histogram.DataPoints, dataPoint)
+			}
+		}
+		if metric.Sum != nil || metric.Gauge != nil || metric.Histogram != nil {
+			otlpMetrics = append(otlpMetrics, metric)
+		}
+	}
+
+	body, err := json.Marshal(otlpExportMetricsServiceRequest{
+		ResourceMetrics: []otlpResourceMetrics{{
+			Resource: otlpResource{Attributes: []otlpKeyValue{{
+				Key:   "service.name",
+				Value: otlpAnyValue{StringValue: otlpServiceName},
+			}}},
+			ScopeMetrics: []otlpScopeMetrics{{
+				Scope:   otlpScope{Name: otlpScopeName},
+				Metrics: otlpMetrics,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.TODO(), otlpExportTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("the OTLP collector returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// the subset of the OTLP metrics JSON encoding used by the exporter, the 64 bits integers are encoded as strings
+type otlpExportMetricsServiceRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpMetric struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Sum         *otlpSum       `json:"sum,omitempty"`
+	Gauge       *otlpGauge     `json:"gauge,omitempty"`
+	Histogram   *otlpHistogram `json:"histogram,omitempty"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpNumberDataPoint `json:"dataPoints"`
+	AggregationTemporality int                   `json:"aggregationTemporality"`
+	IsMonotonic            bool                  `json:"isMonotonic"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpNumberDataPoint `json:"dataPoints"`
+}
+
+type otlpNumberDataPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	AsDouble          float64        `json:"asDouble"`
+}
+
+type otlpHistogram struct {
+	DataPoints             []otlpHistogramDataPoint `json:"dataPoints"`
+	AggregationTemporality int                      `json:"aggregationTemporality"`
+}
+
+type otlpHistogramDataPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	Count             string         `json:"count"`
+	Sum               float64        `json:"sum"`
+	BucketCounts      []string       `json:"bucketCounts"`
+	ExplicitBounds    []float64      `json:"explicitBounds"`
+}
@@ -0,0 +1,260 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// otlpCollector is an in-memory OTLP/HTTP collector recording the exported requests
+type otlpCollector struct {
+	sync.Mutex
+	server   *httptest.Server
+	requests []otlpExportMetricsServiceRequest
+	headers  []http.Header
+	status   int
+}
+
+func newOTLPCollector(t *testing.T) *otlpCollector {
+	c := &otlpCollector{status: http.StatusOK}
+	c.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		c.Lock()
+		defer c.Unlock()
+		if req.Method != http.MethodPost || req.URL.Path != otlpMetricsPath {
+			t.Errorf("unexpected request %s %s", req.Method, req.URL.Path)
+		}
+		if contentType := req.Header.Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("content type = %q, want application/json", contentType)
+		}
+		var request otlpExportMetricsServiceRequest
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			t.Errorf("failed to decode the export request: %v", err)
+		}
+		c.requests = append(c.requests, request)
+		c.headers = append(c.headers, req.Header.Clone())
+		w.WriteHeader(c.status)
+	}))
+	t.Cleanup(c.server.Close)
+	return c
+}
+
+// exported returns the metrics of the last export request by name
+func (c *otlpCollector) exported(t *testing.T) map[string]otlpMetric {
+	c.Lock()
+	defer c.Unlock()
+	if len(c.requests) == 0 {
+		t.Fatal("no metrics exported")
+	}
+	exported := map[string]otlpMetric{}
+	for _, rm := range c.requests[len(c.requests)-1].ResourceMetrics {
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				exported[m.Name] = m
+			}
+		}
+	}
+	return exported
+}
+
+func Test_getOTLPExporter(t *testing.T) {
+	tests := []struct {
+		name            string
+		metricsEndpoint string
+		endpoint        string
+		headers         string
+		interval        string
+		wantNil         bool
+		wantEndpoint    string
+		wantHeaders     map[string]string
+		wantInterval    time.Duration
+		wantErr         bool
+	}{
+		{
+			name:    "not set",
+			wantNil: true,
+		},
+		{
+			name:            "metrics endpoint",
+			metricsEndpoint: "https://collector.example.com:4318/custom",
+			endpoint:        "https://other.example.com:4318",
+			wantEndpoint:    "https://collector.example.com:4318/custom",
+			wantHeaders:     map[string]string{},
+			wantInterval:    defaultOTLPExportInterval,
+		},
+		{
+			name:         "endpoint",
+			endpoint:     "http://collector:4318/",
+			headers:      "Authorization=Bearer%20token, x-tenant=acme",
+			interval:     "15000",
+			wantEndpoint: "http://collector:4318/v1/metrics",
+			wantHeaders:  map[string]string{"Authorization": "Bearer token", "x-tenant": "acme"},
+			wantInterval: 15 * time.Second,
+		},
+		{
+			name:     "invalid endpoint",
+			endpoint: "collector:4318",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid headers",
+			endpoint: "http://collector:4318",
+			headers:  "Authorization",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid interval",
+			endpoint: "http://collector:4318",
+			interval: "15s",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(otlpMetricsEndpointEnvVarName, tt.metricsEndpoint)
+			os.Setenv(otlpEndpointEnvVarName, tt.endpoint)
+			os.Setenv(otlpHeadersEnvVarName, tt.headers)
+			os.Setenv(otlpExportIntervalEnvVarName, tt.interval)
+			defer os.Unsetenv(otlpMetricsEndpointEnvVarName)
+			defer os.Unsetenv(otlpEndpointEnvVarName)
+			defer os.Unsetenv(otlpHeadersEnvVarName)
+			defer os.Unsetenv(otlpExportIntervalEnvVarName)
+			got, err := getOTLPExporter()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getOTLPExporter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if (got == nil) != tt.wantNil {
+				t.Fatalf("getOTLPExporter() = %v, wantNil %v", got, tt.wantNil)
+			}
+			if got == nil {
+				return
+			}
+			if got.endpoint != tt.wantEndpoint {
+				t.Errorf("endpoint = %q, want %q", got.endpoint, tt.wantEndpoint)
+			}
+			if !reflect.DeepEqual(got.headers, tt.wantHeaders) {
+				t.Errorf("headers = %v, want %v", got.headers, tt.wantHeaders)
+			}
+			if got.interval != tt.wantInterval {
+				t.Errorf("interval = %v, want %v", got.interval, tt.wantInterval)
+			}
+		})
+	}
+}
+
+func Test_otlpExporter_export(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	approvals := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "managedcluster_import_csr_test_approvals_total",
+		Help: "test approvals",
+	}, []string{"decision"})
+	enabled := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "managedcluster_import_csr_test_enabled",
+	})
+	latency := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "managedcluster_import_csr_test_latency_seconds",
+		Buckets: []float64{1, 5},
+	})
+	other := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "managedcluster_import_secret_test_total",
+	})
+	registry.MustRegister(approvals, enabled, latency, other)
+	approvals.WithLabelValues("approved").Add(3)
+	enabled.Set(1)
+	other.Inc()
+	for _, v := range []float64{0.5, 2, 3, 10} {
+		latency.Observe(v)
+	}
+
+	collector := newOTLPCollector(t)
+	e := &otlpExporter{
+		endpoint:   collector.server.URL + otlpMetricsPath,
+		headers:    map[string]string{"x-tenant": "acme"},
+		interval:   time.Minute,
+		gatherer:   registry,
+		httpClient: collector.server.Client(),
+		startTime:  time.Now(),
+	}
+	if err := e.export(time.Now()); err != nil {
+		t.Fatalf("export() error = %v", err)
+	}
+	if tenant := collector.headers[0].Get("x-tenant"); tenant != "acme" {
+		t.Errorf("x-tenant header = %q, want acme", tenant)
+	}
+
+	exported := collector.exported(t)
+	if _, ok := exported["managedcluster_import_secret_test_total"]; ok {
+		t.Error("the metrics other than the approval metrics must not be exported")
+	}
+	sum := exported["managedcluster_import_csr_test_approvals_total"].Sum
+	if sum == nil || !sum.IsMonotonic || len(sum.DataPoints) != 1 || sum.DataPoints[0].AsDouble != 3 {
+		t.Errorf("approvals = %+v, want a monotonic sum of 3", sum)
+	} else if attrs := sum.DataPoints[0].Attributes; len(attrs) != 1 || attrs[0].Key != "decision" ||
+		attrs[0].Value.StringValue != "approved" {
+		t.Errorf("approvals attributes = %+v, want decision=approved", attrs)
+	}
+	gauge := exported["managedcluster_import_csr_test_enabled"].Gauge
+	if gauge == nil || len(gauge.DataPoints) != 1 || gauge.DataPoints[0].AsDouble != 1 {
+		t.Errorf("enabled = %+v, want a gauge of 1", gauge)
+	}
+	histogram := exported["managedcluster_import_csr_test_latency_seconds"].Histogram
+	if histogram == nil || len(histogram.DataPoints) != 1 {
+		t.Fatalf("latency = %+v, want a histogram", histogram)
+	}
+	dataPoint := histogram.DataPoints[0]
+	if dataPoint.Count != "4" || dataPoint.Sum != 15.5 {
+		t.Errorf("latency count, sum = %s, %v, want 4, 15.5", dataPoint.Count, dataPoint.Sum)
+	}
+	if !reflect.DeepEqual(dataPoint.ExplicitBounds, []float64{1, 5}) ||
+		!reflect.DeepEqual(dataPoint.BucketCounts, []string{"1", "2", "1"}) {
+		t.Errorf("latency buckets = %v %v, want [1 5] [1 2 1]", dataPoint.ExplicitBounds, dataPoint.BucketCounts)
+	}
+
+	collector.Lock()
+	collector.status = http.StatusServiceUnavailable
+	collector.Unlock()
+	if err := e.export(time.Now()); err == nil {
+		t.Error("export() must fail when the collector rejects the metrics")
+	}
+}
+
+func Test_otlpExporter_Start(t *testing.T) {
+	issuanceStalledTotal.Inc()
+	collector := newOTLPCollector(t)
+	os.Setenv(otlpEndpointEnvVarName, collector.server.URL)
+	os.Setenv(otlpExportIntervalEnvVarName, "10")
+	defer os.Unsetenv(otlpEndpointEnvVarName)
+	defer os.Unsetenv(otlpExportIntervalEnvVarName)
+	e, err := getOTLPExporter()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() { done <- e.Start(stop) }()
+	time.Sleep(50 * time.Millisecond)
+	close(stop)
+	if err := <-done; err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	exported := collector.exported(t)
+	for _, name := range []string{"managedcluster_import_csr_issuance_stalled_total",
+		"managedcluster_import_csr_approval_enabled"} {
+		if _, ok := exported[name]; !ok {
+			t.Errorf("metric %s not exported", name)
+		}
+	}
+}
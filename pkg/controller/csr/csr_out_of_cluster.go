@@ -0,0 +1,57 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"fmt"
+	"os"
+
+	libgoclient "github.com/open-cluster-management/library-go/pkg/client"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// kubeconfigEnvVarName is the path of the kubeconfig of the hub the controller approves the CSRs of when it runs
+// outside of a cluster, for example during the local development. The in-cluster config is used if not set
+const kubeconfigEnvVarName = "CSR_KUBECONFIG"
+
+// getOutOfClusterConfig returns the config of the kubeconfig of CSR_KUBECONFIG, nil if not set
+func getOutOfClusterConfig() (*rest.Config, error) {
+	kubeconfig := os.Getenv(kubeconfigEnvVarName)
+	if kubeconfig == "" {
+		return nil, nil
+	}
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s value %q: %v", kubeconfigEnvVarName, kubeconfig, err)
+	}
+	return config, nil
+}
+
+// newClients returns the kube and dynamic clients of the out-of-cluster config, or of the default config if
+// the config is nil, an error if a client can not be built
+func newClients(outOfClusterConfig *rest.Config) (kubernetes.Interface, dynamic.Interface, error) {
+	if outOfClusterConfig != nil {
+		kubeClient, err := kubernetes.NewForConfig(outOfClusterConfig)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to build the kube client of %s: %v", os.Getenv(kubeconfigEnvVarName), err)
+		}
+		dynamicClient, err := dynamic.NewForConfig(outOfClusterConfig)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to build the dynamic client of %s: %v", os.Getenv(kubeconfigEnvVarName), err)
+		}
+		return kubeClient, dynamicClient, nil
+	}
+
+	kubeClient, err := libgoclient.NewDefaultKubeClient("")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build the kube client: %v", err)
+	}
+	dynamicClient, err := libgoclient.NewDefaultKubeClientDynamic("")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build the dynamic client: %v", err)
+	}
+	return kubeClient, dynamicClient, nil
+}
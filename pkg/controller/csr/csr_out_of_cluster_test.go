@@ -0,0 +1,157 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: hub
+  cluster:
+    server: https://hub.example.com:6443
+contexts:
+- name: hub
+  context:
+    cluster: hub
+    user: admin
+current-context: hub
+users:
+- name: admin
+  user:
+    token: abcdef
+`
+
+// fakeManager is a manager providing the client and the scheme of the reconciler
+type fakeManager struct {
+	manager.Manager
+	client client.Client
+}
+
+func (m *fakeManager) GetClient() client.Client   { return m.client }
+func (m *fakeManager) GetScheme() *runtime.Scheme { return scheme.Scheme }
+func (m *fakeManager) GetEventRecorderFor(name string) record.EventRecorder {
+	return record.NewFakeRecorder(10)
+}
+
+func writeKubeconfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_getOutOfClusterConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		kubeconfig string
+		wantHost   string
+		wantErr    bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:       "kubeconfig",
+			kubeconfig: writeKubeconfig(t, testKubeconfig),
+			wantHost:   "https://hub.example.com:6443",
+		},
+		{
+			name:       "missing kubeconfig",
+			kubeconfig: filepath.Join(t.TempDir(), "missing"),
+			wantErr:    true,
+		},
+		{
+			name:       "invalid kubeconfig",
+			kubeconfig: writeKubeconfig(t, "clusters: ["),
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(kubeconfigEnvVarName, tt.kubeconfig)
+			defer os.Unsetenv(kubeconfigEnvVarName)
+			got, err := getOutOfClusterConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getOutOfClusterConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if tt.wantHost == "" {
+				if got != nil {
+					t.Errorf("getOutOfClusterConfig() = %v, want nil", got)
+				}
+				return
+			}
+			if got == nil || got.Host != tt.wantHost {
+				t.Errorf("getOutOfClusterConfig() = %v, want host %s", got, tt.wantHost)
+			}
+		})
+	}
+}
+
+func Test_newReconcilerOutOfCluster(t *testing.T) {
+	os.Setenv(kubeconfigEnvVarName, writeKubeconfig(t, testKubeconfig))
+	defer os.Unsetenv(kubeconfigEnvVarName)
+	opts := NewCSRControllerOptions()
+	if err := opts.complete(); err != nil {
+		t.Fatal(err)
+	}
+
+	mgr := &fakeManager{client: fake.NewFakeClientWithScheme(scheme.Scheme)}
+	r, err := newReconciler(mgr, opts)
+	if err != nil {
+		t.Fatalf("newReconciler() error = %v", err)
+	}
+	if r.kubeClient == nil {
+		t.Fatal("the kube client is not built from the kubeconfig")
+	}
+	if host := r.kubeClient.Discovery().RESTClient().Get().URL().Host; host != "hub.example.com:6443" {
+		t.Errorf("kube client host = %s, want hub.example.com:6443", host)
+	}
+	if r.dynamicClient == nil {
+		t.Error("the dynamic client is not built from the kubeconfig")
+	}
+	if r.apiVersions == nil {
+		t.Error("the certificates API version is not resolved with the kube client")
+	}
+	if r.client != mgr.client {
+		t.Error("the reconciler must use the client of the manager")
+	}
+}
+
+func Test_newReconcilerClientError(t *testing.T) {
+	// the kube client can not be built without its certificate authority
+	config := &rest.Config{
+		Host:            "https://hub.example.com:6443",
+		TLSClientConfig: rest.TLSClientConfig{CAFile: filepath.Join(t.TempDir(), "missing-ca.crt")},
+	}
+	opts := NewCSRControllerOptions()
+	if err := opts.complete(); err != nil {
+		t.Fatal(err)
+	}
+	opts.outOfClusterConfig = config
+	mgr := &fakeManager{client: fake.NewFakeClientWithScheme(scheme.Scheme)}
+	r, err := newReconciler(mgr, opts)
+	if err == nil {
+		t.Fatal("newReconciler() error = nil, want the kube client error")
+	}
+	if r != nil {
+		t.Errorf("newReconciler() = %v, want no reconciler with a nil kube client", r)
+	}
+}
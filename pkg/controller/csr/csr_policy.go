@@ -0,0 +1,185 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// policyCompatibilityWindowEnvVarName is the duration during which the csrs created before an approval
+	// policy change are still evaluated with the previous policy, the previous policy is ignored if not set
+	policyCompatibilityWindowEnvVarName = "CSR_POLICY_COMPATIBILITY_WINDOW"
+	// policyConfigMapName is the configmap, in the POD_NAMESPACE, recording the approval policy history
+	policyConfigMapName    = "managedcluster-import-controller-csr-policy"
+	policyKey              = "policy"
+	previousPolicyKey      = "previousPolicy"
+	policyEffectiveTimeKey = "effectiveSince"
+)
+
+// approvalPolicy is the configuration deciding whether a csr is approved
+type approvalPolicy struct {
+	ChallengeSecretName string                  `json:"challengeSecretName,omitempty"`
+	SignerPolicies      map[string]signerPolicy `json:"signerPolicies,omitempty"`
+	ApprovalRules       map[string]string       `json:"approvalRules,omitempty"`
+}
+
+// version returns a short hash identifying the policy, it stamps the approval decisions
+func (p approvalPolicy) version() string {
+	b, _ := json.Marshal(p)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])[:8]
+}
+
+// policyHistory is the approval policy in effect and the policy it replaced
+type policyHistory struct {
+	current        approvalPolicy
+	previous       *approvalPolicy
+	effectiveSince time.Time
+}
+
+// policyFor returns the policy the csr is evaluated with, a csr created under the previous policy
+// is evaluated with it until the compatibility window after the policy change ends.
+func (h *policyHistory) policyFor(
+	csr *certificatesv1.CertificateSigningRequest,
+	window time.Duration,
+	now time.Time) approvalPolicy {
+	if h.previous != nil && window > 0 &&
+		csr.CreationTimestamp.Time.Before(h.effectiveSince) &&
+		now.Before(h.effectiveSince.Add(window)) {
+		return *h.previous
+	}
+	return h.current
+}
+
+// getPolicyCompatibilityWindow returns the CSR_POLICY_COMPATIBILITY_WINDOW value, 0 if not set
+func getPolicyCompatibilityWindow() (time.Duration, error) {
+	v := os.Getenv(policyCompatibilityWindowEnvVarName)
+	if v == "" {
+		return 0, nil
+	}
+	window, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value %q: %v", policyCompatibilityWindowEnvVarName, v, err)
+	}
+	return window, nil
+}
+
+// currentPolicy returns the approval policy configured on the controller with the approval rules in effect
+func (r *ReconcileCSR) currentPolicy() approvalPolicy {
+	policy := approvalPolicy{
+		ChallengeSecretName: r.challengeSecretName,
+		SignerPolicies:      r.signerPolicies,
+	}
+	if rules := r.getApprovalRules(); rules != nil {
+		policy.ApprovalRules = rules.data
+	}
+	return policy
+}
+
+// approvalPolicyFor returns the approval policy the csr is evaluated with
+func (r *ReconcileCSR) approvalPolicyFor(csr *certificatesv1.CertificateSigningRequest) (approvalPolicy, error) {
+	if r.policyCompatibilityWindow <= 0 {
+		return r.currentPolicy(), nil
+	}
+	history, err := r.getPolicyHistory()
+	if err != nil {
+		return approvalPolicy{}, err
+	}
+	return history.policyFor(csr, r.policyCompatibilityWindow, time.Now()), nil
+}
+
+// resetPolicyHistory drops the loaded policy history, it is loaded again and rotated if the current policy changed
+func (r *ReconcileCSR) resetPolicyHistory() {
+	r.policyLock.Lock()
+	defer r.policyLock.Unlock()
+	r.policyHistory = nil
+}
+
+// getPolicyHistory loads the policy history from the policy configmap the first time it is called.
+// The history is rotated if the current policy differs from the recorded one, for example after an upgrade.
+func (r *ReconcileCSR) getPolicyHistory() (*policyHistory, error) {
+	r.policyLock.Lock()
+	defer r.policyLock.Unlock()
+	if r.policyHistory != nil {
+		return r.policyHistory, nil
+	}
+
+	current := r.currentPolicy()
+	currentData, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	configMaps := r.kubeClient.CoreV1().ConfigMaps(r.podNamespace)
+
+	ctx, cancel := r.apiCallContext()
+	defer cancel()
+	cm, err := configMaps.Get(ctx, policyConfigMapName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      policyConfigMapName,
+				Namespace: r.podNamespace,
+			},
+			Data: map[string]string{
+				policyKey:              string(currentData),
+				policyEffectiveTimeKey: now.Format(time.RFC3339),
+			},
+		}
+		if _, err := configMaps.Create(ctx, cm, metav1.CreateOptions{}); err != nil {
+			return nil, err
+		}
+		r.policyHistory = &policyHistory{current: current, effectiveSince: now}
+		return r.policyHistory, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	recorded := approvalPolicy{}
+	if err := json.Unmarshal([]byte(cm.Data[policyKey]), &recorded); err != nil {
+		return nil, fmt.Errorf("invalid %s in configmap %s/%s: %v", policyKey, r.podNamespace, policyConfigMapName, err)
+	}
+
+	if recorded.version() == current.version() {
+		history := &policyHistory{current: current}
+		history.effectiveSince, err = time.Parse(time.RFC3339, cm.Data[policyEffectiveTimeKey])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s in configmap %s/%s: %v",
+				policyEffectiveTimeKey, r.podNamespace, policyConfigMapName, err)
+		}
+		if previousData, ok := cm.Data[previousPolicyKey]; ok {
+			previous := approvalPolicy{}
+			if err := json.Unmarshal([]byte(previousData), &previous); err != nil {
+				return nil, fmt.Errorf("invalid %s in configmap %s/%s: %v",
+					previousPolicyKey, r.podNamespace, policyConfigMapName, err)
+			}
+			history.previous = &previous
+		}
+		r.policyHistory = history
+		return r.policyHistory, nil
+	}
+
+	log.Info("CSR approval policy changed", "previous", recorded.version(), "current", current.version())
+	cm.Data = map[string]string{
+		policyKey:              string(currentData),
+		previousPolicyKey:      cm.Data[policyKey],
+		policyEffectiveTimeKey: now.Format(time.RFC3339),
+	}
+	if _, err := configMaps.Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		return nil, err
+	}
+	r.policyHistory = &policyHistory{current: current, previous: &recorded, effectiveSince: now}
+	return r.policyHistory, nil
+}
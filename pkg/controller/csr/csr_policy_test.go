@@ -0,0 +1,199 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func Test_policyHistory_policyFor(t *testing.T) {
+	changedAt := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	previous := approvalPolicy{}
+	current := approvalPolicy{ChallengeSecretName: challengeSecretName}
+	newCSR := func(created time.Time) *certificatesv1.CertificateSigningRequest {
+		return &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				CreationTimestamp: metav1.NewTime(created),
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		history *policyHistory
+		csr     *certificatesv1.CertificateSigningRequest
+		window  time.Duration
+		now     time.Time
+		want    approvalPolicy
+	}{
+		{
+			name:    "no previous policy",
+			history: &policyHistory{current: current, effectiveSince: changedAt},
+			csr:     newCSR(changedAt.Add(-time.Minute)),
+			window:  time.Hour,
+			now:     changedAt.Add(time.Minute),
+			want:    current,
+		},
+		{
+			name:    "csr created under the previous policy within the window",
+			history: &policyHistory{current: current, previous: &previous, effectiveSince: changedAt},
+			csr:     newCSR(changedAt.Add(-time.Minute)),
+			window:  time.Hour,
+			now:     changedAt.Add(time.Minute),
+			want:    previous,
+		},
+		{
+			name:    "csr created under the previous policy after the window",
+			history: &policyHistory{current: current, previous: &previous, effectiveSince: changedAt},
+			csr:     newCSR(changedAt.Add(-time.Minute)),
+			window:  time.Hour,
+			now:     changedAt.Add(2 * time.Hour),
+			want:    current,
+		},
+		{
+			name:    "csr created under the current policy",
+			history: &policyHistory{current: current, previous: &previous, effectiveSince: changedAt},
+			csr:     newCSR(changedAt.Add(time.Minute)),
+			window:  time.Hour,
+			now:     changedAt.Add(2 * time.Minute),
+			want:    current,
+		},
+		{
+			name:    "no compatibility window",
+			history: &policyHistory{current: current, previous: &previous, effectiveSince: changedAt},
+			csr:     newCSR(changedAt.Add(-time.Minute)),
+			now:     changedAt.Add(time.Minute),
+			want:    current,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.history.policyFor(tt.csr, tt.window, tt.now); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("policyFor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_ReconcilePolicyChange(t *testing.T) {
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: clusterName,
+		},
+		Spec: clusterv1.ManagedClusterSpec{
+			HubAcceptsClient: true,
+		},
+	}
+	challengeSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      challengeSecretName,
+			Namespace: testPodNamespace,
+		},
+		Data: map[string][]byte{
+			challengeSecretKey: []byte("shared-key"),
+		},
+	}
+
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	// the configmap recording the policy history survives the controller pods
+	kubeClient := fakeclientset.NewSimpleClientset(challengeSecret)
+
+	// the old controller pod records its policy which does not require the challenge
+	oldPod := &ReconcileCSR{
+		kubeClient:                kubeClient,
+		podNamespace:              testPodNamespace,
+		policyCompatibilityWindow: time.Hour,
+	}
+	oldPolicy, err := oldPod.getPolicyHistory()
+	if err != nil {
+		t.Fatalf("getPolicyHistory() error = %v", err)
+	}
+
+	// the join in progress created its csr before the upgrade without challenge
+	inProgressCSR := newChallengeCSR(t)
+	inProgressCSR.CreationTimestamp = metav1.NewTime(oldPolicy.effectiveSince.Add(-time.Minute))
+
+	tests := []struct {
+		name         string
+		csr          *certificatesv1.CertificateSigningRequest
+		wantApproved bool
+	}{
+		{
+			name:         "csr created under the old policy is approved under it",
+			csr:          inProgressCSR,
+			wantApproved: true,
+		},
+		{
+			name: "csr created under the new policy requires the challenge",
+			csr: func() *certificatesv1.CertificateSigningRequest {
+				csr := newChallengeCSR(t)
+				csr.CreationTimestamp = metav1.NewTime(time.Now().Add(time.Minute))
+				return csr
+			}(),
+			wantApproved: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := kubeClient.CertificatesV1().CertificateSigningRequests().Create(
+				context.TODO(), tt.csr, metav1.CreateOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				_ = kubeClient.CertificatesV1().CertificateSigningRequests().Delete(
+					context.TODO(), tt.csr.Name, metav1.DeleteOptions{})
+			}()
+
+			// the new controller pod requires the challenge
+			newPod := &ReconcileCSR{
+				client:                    fake.NewFakeClientWithScheme(testscheme, testManagedCluster, tt.csr),
+				kubeClient:                kubeClient,
+				scheme:                    testscheme,
+				challengeSecretName:       challengeSecretName,
+				podNamespace:              testPodNamespace,
+				policyCompatibilityWindow: time.Hour,
+			}
+			if _, err := newPod.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}}); err != nil {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+			}
+
+			csr, err := kubeClient.CertificatesV1().CertificateSigningRequests().Get(
+				context.TODO(), csrNameReconcile, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if approved := getApprovalType(csr) == string(certificatesv1.CertificateApproved); approved != tt.wantApproved {
+				t.Fatalf("CSR approved = %v, want %v", approved, tt.wantApproved)
+			}
+			if tt.wantApproved && !strings.Contains(csr.Status.Conditions[0].Message, oldPolicy.current.version()) {
+				t.Errorf("approval %q is not stamped with the policy version %s",
+					csr.Status.Conditions[0].Message, oldPolicy.current.version())
+			}
+		})
+	}
+
+	cm, err := kubeClient.CoreV1().ConfigMaps(testPodNamespace).Get(context.TODO(), policyConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cm.Data[previousPolicyKey]; !ok {
+		t.Errorf("the policy change is not recorded in the configmap: %v", cm.Data)
+	}
+}
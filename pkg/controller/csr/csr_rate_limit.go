@@ -0,0 +1,56 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// approvalRateLimiter is a token bucket of each cluster limiting the rate its csrs are approved at, so a cluster
+// flooding the hub with csrs does not starve the other clusters. A nil approvalRateLimiter never limits an approval.
+type approvalRateLimiter struct {
+	limit    rate.Limit
+	burst    int
+	lock     sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+// newApprovalRateLimiter returns a rate limiter of limit approvals per second and burst approvals at once of each
+// cluster, nil if limit is not positive
+func newApprovalRateLimiter(limit float64, burst int) *approvalRateLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &approvalRateLimiter{
+		limit:    rate.Limit(limit),
+		burst:    burst,
+		limiters: map[string]*rate.Limiter{},
+	}
+}
+
+// reserve takes a token of the bucket of the cluster, it returns the delay until a token is available and takes
+// none if the cluster is over its rate
+func (l *approvalRateLimiter) reserve(clusterName string, now time.Time) time.Duration {
+	if l == nil {
+		return 0
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	limiter, ok := l.limiters[clusterName]
+	if !ok {
+		limiter = rate.NewLimiter(l.limit, l.burst)
+		l.limiters[clusterName] = limiter
+	}
+	reservation := limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return delay
+	}
+	return 0
+}
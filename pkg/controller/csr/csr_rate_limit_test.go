@@ -0,0 +1,131 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func Test_approvalRateLimiter_reserve(t *testing.T) {
+	now := time.Now()
+	l := newApprovalRateLimiter(1, 2)
+	for i := 0; i < 2; i++ {
+		if delay := l.reserve(clusterName, now); delay != 0 {
+			t.Fatalf("reserve() %d within the burst = %v, want 0", i, delay)
+		}
+	}
+	if delay := l.reserve(clusterName, now); delay <= 0 || delay > time.Second {
+		t.Errorf("reserve() over the burst = %v, want within 1s", delay)
+	}
+	// a rate limited approval takes no token
+	if delay := l.reserve(clusterName, now.Add(time.Second)); delay != 0 {
+		t.Errorf("reserve() after 1s = %v, want 0", delay)
+	}
+	if delay := l.reserve("othercluster", now); delay != 0 {
+		t.Errorf("reserve() of another cluster = %v, want 0", delay)
+	}
+
+	var disabled *approvalRateLimiter
+	if l := newApprovalRateLimiter(0, 2); l != disabled {
+		t.Errorf("newApprovalRateLimiter(0, 2) = %v, want nil", l)
+	}
+	if delay := disabled.reserve(clusterName, now); delay != 0 {
+		t.Errorf("nil reserve() = %v, want 0", delay)
+	}
+}
+
+func TestReconcileCSR_ReconcileApprovalRateLimit(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	const floodingCluster, otherCluster = "floodingcluster", "othercluster"
+	newCSR := func(name, cluster string) *certificatesv1.CertificateSigningRequest {
+		return &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{clusterLabel: cluster},
+			},
+			Spec: certificatesv1.CertificateSigningRequestSpec{
+				Username:   fmt.Sprintf(userNameSignature, cluster, cluster),
+				Request:    newCSRRequest(t, clusterCommonNamePrefix+cluster, nil),
+				SignerName: certificatesv1.KubeAPIServerClientSignerName,
+				Usages:     []certificatesv1.KeyUsage{certificatesv1.UsageClientAuth},
+			},
+		}
+	}
+	objs := []runtime.Object{}
+	for _, cluster := range []string{floodingCluster, otherCluster} {
+		objs = append(objs, &clusterv1.ManagedCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: cluster},
+			Spec:       clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+		})
+	}
+	// the flooding cluster requests 20 csrs before the csr of the other cluster is reconciled
+	csrs := []runtime.Object{}
+	names := []string{}
+	for i := 0; i < 20; i++ {
+		csr := newCSR(fmt.Sprintf("%s-%d", floodingCluster, i), floodingCluster)
+		csrs = append(csrs, csr)
+		names = append(names, csr.Name)
+	}
+	csrs = append(csrs, newCSR(otherCluster, otherCluster))
+	names = append(names, otherCluster)
+
+	r := &ReconcileCSR{
+		client:              fake.NewFakeClientWithScheme(testscheme, append(objs, csrs...)...),
+		kubeClient:          fakeclientset.NewSimpleClientset(csrs...),
+		scheme:              testscheme,
+		podNamespace:        testPodNamespace,
+		approvalRateLimiter: newApprovalRateLimiter(0.01, 3),
+	}
+	results := map[string]reconcile.Result{}
+	for _, name := range names {
+		result, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: name}})
+		if err != nil {
+			t.Fatalf("ReconcileCSR.Reconcile(%s) error = %v", name, err)
+		}
+		results[name] = result
+	}
+
+	approved := map[string]int{}
+	for _, name := range names {
+		csr, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cluster := getClusterName(csr)
+		switch getApprovalType(csr) {
+		case string(certificatesv1.CertificateApproved):
+			approved[cluster]++
+		case "":
+			if code := csr.Annotations[ReasonCodeAnnotation]; code != string(ReasonApprovalRateLimited) {
+				t.Errorf("csr %s reason code = %q, want %q", name, code, ReasonApprovalRateLimited)
+			}
+			if !results[name].Requeue || results[name].RequeueAfter <= 0 {
+				t.Errorf("csr %s result = %v, want a delayed requeue", name, results[name])
+			}
+		default:
+			t.Errorf("csr %s approval = %q", name, getApprovalType(csr))
+		}
+	}
+	if approved[floodingCluster] != 3 {
+		t.Errorf("approved csrs of %s = %d, want the burst of 3", floodingCluster, approved[floodingCluster])
+	}
+	if approved[otherCluster] != 1 {
+		t.Errorf("approved csrs of %s = %d, want 1", otherCluster, approved[otherCluster])
+	}
+}
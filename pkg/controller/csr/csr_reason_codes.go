@@ -0,0 +1,147 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	certificatesv1 "k8s.io/api/certificates/v1"
+)
+
+// ReasonCode is the machine readable outcome of the evaluation of a csr. The code of an approved or denied csr is
+// the reason of its Approved or Denied condition. Each evaluated csr, pending ones included, is annotated with its
+// code in the ReasonCodeAnnotation and with a human readable message in the ReasonMessageAnnotation.
+// The codes are consumed by the console and must not change.
+type ReasonCode string
+
+const (
+	// ReasonAutoApproved is the code of the approved csrs
+	ReasonAutoApproved ReasonCode = "AutoApprovedByCSRController"
+
+	// ReasonClusterNamespaceMismatch is the code of the csrs denied because they are requested by the bootstrap
+	// service account of another cluster namespace
+	ReasonClusterNamespaceMismatch ReasonCode = "ClusterNamespaceMismatch"
+	// ReasonInvalidCertificateRequest is the code of the csrs with an empty or unparsable request, they are
+	// denied or left pending depending on the CSR_INVALID_REQUEST_ACTION
+	ReasonInvalidCertificateRequest ReasonCode = "InvalidCertificateRequest"
+	// ReasonClusterNotOwned is the code of the csrs denied because their cluster has no owner
+	ReasonClusterNotOwned ReasonCode = "ClusterNotOwned"
+	// ReasonClusterOwnerNotAllowed is the code of the csrs denied because the owner of their cluster is not allowed
+	ReasonClusterOwnerNotAllowed ReasonCode = "ClusterOwnerNotAllowed"
+	// ReasonIdentityMismatch is the code of the csrs denied because the subject of their client certificate request
+	// is not an identity of their cluster
+	ReasonIdentityMismatch ReasonCode = "CertificateIdentityMismatch"
+	// ReasonUnauthorizedUsername is the code of the csrs denied because they are requested by a username that is
+	// not a bootstrap service account of their cluster, with the --deny-unauthorized-csrs flag
+	ReasonUnauthorizedUsername ReasonCode = "UnauthorizedUsername"
+
+	// ReasonManualApproval is the code of the csrs left pending for a manual approval
+	ReasonManualApproval ReasonCode = "ManualApprovalRequested"
+	// ReasonApprovalHalted is the code of the csrs pending while the approval is halted
+	ReasonApprovalHalted ReasonCode = "ApprovalHalted"
+	// ReasonConfigurationNotLoaded is the code of the csrs pending until the approval rules or the approval
+	// switch are loaded
+	ReasonConfigurationNotLoaded ReasonCode = "ConfigurationNotLoaded"
+	// ReasonDenialCooldown is the code of the csrs pending during the denial cooldown of their cluster
+	ReasonDenialCooldown ReasonCode = "DenialCooldown"
+	// ReasonClusterNameNotAllowed is the code of the csrs pending because the name of their cluster is denied by the
+	// CSR_CLUSTER_DENYLIST or not allowed by the CSR_CLUSTER_ALLOWLIST
+	ReasonClusterNameNotAllowed ReasonCode = "ClusterNameNotAllowed"
+	// ReasonClusterNotFound is the code of the csrs pending because their ManagedCluster does not exist
+	ReasonClusterNotFound ReasonCode = "ClusterNotFound"
+	// ReasonClusterNotAccepted is the code of the csrs pending because the hub does not accept their ManagedCluster
+	ReasonClusterNotAccepted ReasonCode = "ClusterNotAccepted"
+	// ReasonNotAllowedByRules is the code of the csrs pending because the approval rules do not allow them
+	ReasonNotAllowedByRules ReasonCode = "NotAllowedByApprovalRules"
+	// ReasonRequestNotAllowed is the code of the csrs pending because they request a signer or a key usage out of
+	// the CSR_ALLOWED_SIGNERS or the CSR_ALLOWED_USAGES
+	ReasonRequestNotAllowed ReasonCode = "RequestNotAllowed"
+	// ReasonNoSignerPolicy is the code of the csrs pending because their signer has no signer policy
+	ReasonNoSignerPolicy ReasonCode = "NoSignerPolicy"
+	// ReasonSignerPolicyViolation is the code of the csrs pending because they violate their signer policy
+	// or the policy is disabled
+	ReasonSignerPolicyViolation ReasonCode = "SignerPolicyViolation"
+	// ReasonChallengeFailed is the code of the csrs pending because their bootstrap challenge is not valid
+	ReasonChallengeFailed ReasonCode = "ChallengeVerificationFailed"
+	// ReasonDuplicateRequest is the code of the csrs pending because they carry the same request as a recently
+	// approved csr
+	ReasonDuplicateRequest ReasonCode = "DuplicateCertificateRequest"
+	// ReasonCrossCheckPending is the code of the csrs pending because they are not cross checked by the peer
+	// controller yet
+	ReasonCrossCheckPending ReasonCode = "CrossCheckPending"
+	// ReasonHubCapacityReached is the code of the csrs of the new clusters pending because the hub has the
+	// maximum number of accepted clusters
+	ReasonHubCapacityReached ReasonCode = "HubCapacityReached"
+	// ReasonAddOnNotFound is the code of the add-on csrs pending because their add-on is neither enabled on their
+	// cluster nor allowlisted
+	ReasonAddOnNotFound ReasonCode = "AddOnNotFound"
+	// ReasonAddOnIdentityMismatch is the code of the add-on csrs pending because the subject of their request is
+	// not an identity of their add-on
+	ReasonAddOnIdentityMismatch ReasonCode = "AddOnIdentityMismatch"
+	// ReasonApprovalRateLimited is the code of the csrs pending because their cluster is over its approval rate
+	ReasonApprovalRateLimited ReasonCode = "ApprovalRateLimited"
+	// ReasonServiceAccountNotFound is the code of the csrs pending because their requesting service account does not
+	// exist on the hub
+	ReasonServiceAccountNotFound ReasonCode = "ServiceAccountNotFound"
+	// ReasonClusterDeleted is the code of the csrs denied because their cluster is deleted
+	ReasonClusterDeleted ReasonCode = "ClusterDeleted"
+	// ReasonWebhookDenied is the code of the csrs denied by the approval webhook
+	ReasonWebhookDenied ReasonCode = "DeniedByApprovalWebhook"
+	// ReasonWebhookUnavailable is the code of the csrs pending because the call of the approval webhook failed
+	ReasonWebhookUnavailable ReasonCode = "ApprovalWebhookUnavailable"
+	// ReasonApprovalWindowExpired is the code of the csrs pending for a manual approval because they are created
+	// after the approval window of their cluster
+	ReasonApprovalWindowExpired ReasonCode = "ApprovalWindowExpired"
+	// ReasonClusterSetNotAllowed is the code of the csrs pending because their cluster belongs to no allowed
+	// ManagedClusterSet
+	ReasonClusterSetNotAllowed ReasonCode = "ClusterSetNotAllowed"
+)
+
+const (
+	// ReasonCodeAnnotation records the ReasonCode of the last evaluation of the csr
+	ReasonCodeAnnotation = "import.open-cluster-management.io/reason-code"
+	// ReasonMessageAnnotation records the human readable message of the last evaluation of the csr
+	ReasonMessageAnnotation = "import.open-cluster-management.io/reason-message"
+)
+
+// reasonAnnotations returns the annotations recording the outcome of the evaluation of a csr
+func reasonAnnotations(code ReasonCode, message string) map[string]string {
+	return map[string]string{
+		ReasonCodeAnnotation:    string(code),
+		ReasonMessageAnnotation: message,
+	}
+}
+
+// annotateCSR sets the annotations on the csr, the csr is only updated if an annotation changed.
+// It returns the updated csr.
+func (r *ReconcileCSR) annotateCSR(
+	csr *certificatesv1.CertificateSigningRequest,
+	annotations map[string]string) (*certificatesv1.CertificateSigningRequest, error) {
+	changed := false
+	for k, v := range annotations {
+		if current, ok := csr.Annotations[k]; !ok || current != v {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return csr, nil
+	}
+	csr = csr.DeepCopy()
+	if csr.Annotations == nil {
+		csr.Annotations = map[string]string{}
+	}
+	for k, v := range annotations {
+		csr.Annotations[k] = v
+	}
+	return r.updateCSR(csr)
+}
+
+// markPending records on the csr the code and message explaining why it is not approved yet
+func (r *ReconcileCSR) markPending(csr *certificatesv1.CertificateSigningRequest, code ReasonCode, message string) error {
+	if r.dryRun {
+		recordDryRunDecision(csr, decisionSkip, code, message)
+		return nil
+	}
+	csrSkippedTotal.WithLabelValues(getClusterName(csr), string(code)).Inc()
+	_, err := r.annotateCSR(csr, reasonAnnotations(code, message))
+	return err
+}
@@ -0,0 +1,151 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcileCSR_ReconcileReasonCodes(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: clusterName,
+		},
+		Spec: clusterv1.ManagedClusterSpec{
+			HubAcceptsClient: true,
+		},
+	}
+	challengeSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      challengeSecretName,
+			Namespace: testPodNamespace,
+		},
+		Data: map[string][]byte{
+			challengeSecretKey: []byte("shared-key"),
+		},
+	}
+	newCSR := func(namespace string, request []byte, signerName string) *certificatesv1.CertificateSigningRequest {
+		return &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: csrNameReconcile,
+				Labels: map[string]string{
+					clusterLabel: clusterName,
+				},
+			},
+			Spec: certificatesv1.CertificateSigningRequestSpec{
+				Username:   fmt.Sprintf(userNameSignature, namespace, namespace),
+				Request:    request,
+				SignerName: signerName,
+			},
+		}
+	}
+	validRequest := newCSRRequest(t, clusterCommonNamePrefix+clusterName, nil)
+
+	tests := []struct {
+		name                string
+		csr                 *certificatesv1.CertificateSigningRequest
+		noCluster           bool
+		signerPolicies      map[string]signerPolicy
+		challengeSecretName string
+		wantCode            ReasonCode
+		wantCondition       certificatesv1.RequestConditionType
+	}{
+		{
+			name:          "approved",
+			csr:           newCSR(clusterName, validRequest, certificatesv1.KubeAPIServerClientSignerName),
+			wantCode:      ReasonAutoApproved,
+			wantCondition: certificatesv1.CertificateApproved,
+		},
+		{
+			name:          "requested from another cluster namespace",
+			csr:           newCSR("othercluster", validRequest, certificatesv1.KubeAPIServerClientSignerName),
+			wantCode:      ReasonClusterNamespaceMismatch,
+			wantCondition: certificatesv1.CertificateDenied,
+		},
+		{
+			name:     "invalid request",
+			csr:      newCSR(clusterName, []byte("invalid"), certificatesv1.KubeAPIServerClientSignerName),
+			wantCode: ReasonInvalidCertificateRequest,
+		},
+		{
+			name:      "cluster not found",
+			csr:       newCSR(clusterName, validRequest, certificatesv1.KubeAPIServerClientSignerName),
+			noCluster: true,
+			wantCode:  ReasonClusterNotFound,
+		},
+		{
+			name:           "signer without policy",
+			csr:            newCSR(clusterName, validRequest, servingSignerName),
+			signerPolicies: map[string]signerPolicy{certificatesv1.KubeAPIServerClientSignerName: {}},
+			wantCode:       ReasonNoSignerPolicy,
+		},
+		{
+			name: "signer policy violated",
+			csr:  newCSR(clusterName, validRequest, servingSignerName),
+			signerPolicies: map[string]signerPolicy{
+				servingSignerName: {AllowedUsages: []certificatesv1.KeyUsage{certificatesv1.UsageServerAuth}},
+			},
+			wantCode: ReasonSignerPolicyViolation,
+		},
+		{
+			name:                "challenge failed",
+			csr:                 newCSR(clusterName, validRequest, certificatesv1.KubeAPIServerClientSignerName),
+			challengeSecretName: challengeSecretName,
+			wantCode:            ReasonChallengeFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the csr requests the client auth usage, the signer policy violation tests allow server auth only
+			tt.csr.Spec.Usages = []certificatesv1.KeyUsage{certificatesv1.UsageClientAuth}
+			objs := []runtime.Object{tt.csr}
+			if !tt.noCluster {
+				objs = append(objs, testManagedCluster)
+			}
+			r := &ReconcileCSR{
+				client:              fake.NewFakeClientWithScheme(testscheme, objs...),
+				kubeClient:          fakeclientset.NewSimpleClientset(tt.csr, challengeSecret),
+				scheme:              testscheme,
+				podNamespace:        testPodNamespace,
+				challengeSecretName: tt.challengeSecretName,
+				signerPolicies:      tt.signerPolicies,
+			}
+			if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}}); err != nil {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+			}
+			got, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), csrNameReconcile, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code := got.Annotations[ReasonCodeAnnotation]; code != string(tt.wantCode) {
+				t.Errorf("reason code annotation = %q, want %q", code, tt.wantCode)
+			}
+			if got.Annotations[ReasonMessageAnnotation] == "" {
+				t.Error("reason message annotation not set")
+			}
+			if approval := getApprovalType(got); approval != string(tt.wantCondition) {
+				t.Fatalf("CSR approval = %q, want %q", approval, tt.wantCondition)
+			}
+			if tt.wantCondition != "" && got.Status.Conditions[0].Reason != string(tt.wantCode) {
+				t.Errorf("condition reason = %q, want %q", got.Status.Conditions[0].Reason, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+)
+
+const (
+	// invalidRequestActionEnvVarName sets how the csrs with an empty or unparsable request payload
+	// are handled, they are skipped if not set
+	invalidRequestActionEnvVarName = "CSR_INVALID_REQUEST_ACTION"
+
+	// invalidRequestActionSkip leaves the csr pending
+	invalidRequestActionSkip = "skip"
+	// invalidRequestActionDeny denies the csr
+	invalidRequestActionDeny = "deny"
+
+	// managedClustersGroup is the group of all the managed clusters in the client certificates of the klusterlets
+	managedClustersGroup = "system:open-cluster-management:managed-clusters"
+)
+
+// getInvalidRequestAction returns the CSR_INVALID_REQUEST_ACTION value, skip if not set
+func getInvalidRequestAction() (string, error) {
+	switch v := os.Getenv(invalidRequestActionEnvVarName); v {
+	case "":
+		return invalidRequestActionSkip, nil
+	case invalidRequestActionSkip, invalidRequestActionDeny:
+		return v, nil
+	default:
+		return "", fmt.Errorf("invalid %s value %q, must be %s or %s",
+			invalidRequestActionEnvVarName, v, invalidRequestActionSkip, invalidRequestActionDeny)
+	}
+}
+
+// validateRequest returns the certificate request of the csr, an error if the request payload of the csr is empty or
+// is not a certificate request
+func validateRequest(csr *certificatesv1.CertificateSigningRequest) (*x509.CertificateRequest, error) {
+	if len(csr.Spec.Request) == 0 {
+		return nil, fmt.Errorf("the certificate request is empty")
+	}
+	x509cr, err := parseCertificateRequest(csr)
+	if err != nil {
+		return nil, fmt.Errorf("the certificate request can not be parsed: %v", err)
+	}
+	return x509cr, nil
+}
+
+// verifyIdentity checks the subject of the certificate request is the identity of the klusterlet of the cluster, its
+// common name must be system:open-cluster-management:<cluster> or prefixed by system:open-cluster-management:<cluster>:
+// and its organizations must be the cluster group or the managed clusters group.
+func verifyIdentity(x509cr *x509.CertificateRequest, clusterName string) error {
+	commonName := x509cr.Subject.CommonName
+	if !isClusterIdentity(commonName, clusterName) {
+		return fmt.Errorf("common name %q is not an identity of cluster %s", commonName, clusterName)
+	}
+	return verifyOrganizations(x509cr, clusterName)
+}
+
+// isClusterIdentity returns true if the common name is system:open-cluster-management:<cluster> or prefixed by
+// system:open-cluster-management:<cluster>:, the identity of a cluster is not the prefix of another cluster identity
+func isClusterIdentity(commonName, clusterName string) bool {
+	clusterIdentity := clusterCommonNamePrefix + clusterName
+	return commonName == clusterIdentity ||
+		(strings.HasPrefix(commonName, clusterIdentity+":") && commonName != clusterIdentity+":")
+}
+
+// verifyOrganizations checks the organizations of the certificate request are the cluster group or the managed
+// clusters group, the kube-apiserver-client signer grants the organizations as groups to the certificate
+func verifyOrganizations(x509cr *x509.CertificateRequest, clusterName string) error {
+	clusterIdentity := clusterCommonNamePrefix + clusterName
+	for _, organization := range x509cr.Subject.Organization {
+		if organization != clusterIdentity && organization != managedClustersGroup {
+			return fmt.Errorf("organization %q is not a group of cluster %s", organization, clusterName)
+		}
+	}
+	return nil
+}
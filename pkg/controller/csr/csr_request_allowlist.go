@@ -0,0 +1,119 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+)
+
+const (
+	// allowedUsagesEnvVarName is a comma separated list of the key usages the csrs approved with the default policy
+	// can request, the defaultAllowedUsages if not set
+	allowedUsagesEnvVarName = "CSR_ALLOWED_USAGES"
+	// allowedSignersEnvVarName is a comma separated list of the signers of the csrs approved with the default policy,
+	// the defaultAllowedSigners if not set
+	allowedSignersEnvVarName = "CSR_ALLOWED_SIGNERS"
+)
+
+// defaultAllowedUsages are the key usages of the client certificates of the klusterlet
+var defaultAllowedUsages = []certificatesv1.KeyUsage{
+	certificatesv1.UsageDigitalSignature,
+	certificatesv1.UsageKeyEncipherment,
+	certificatesv1.UsageClientAuth,
+}
+
+// defaultAllowedSigners is the signer of the client certificates of the klusterlet
+var defaultAllowedSigners = []string{certificatesv1.KubeAPIServerClientSignerName}
+
+// knownUsages are the key usages of the certificates API
+var knownUsages = []certificatesv1.KeyUsage{
+	certificatesv1.UsageSigning,
+	certificatesv1.UsageDigitalSignature,
+	certificatesv1.UsageContentCommitment,
+	certificatesv1.UsageKeyEncipherment,
+	certificatesv1.UsageKeyAgreement,
+	certificatesv1.UsageDataEncipherment,
+	certificatesv1.UsageCertSign,
+	certificatesv1.UsageCRLSign,
+	certificatesv1.UsageEncipherOnly,
+	certificatesv1.UsageDecipherOnly,
+	certificatesv1.UsageAny,
+	certificatesv1.UsageServerAuth,
+	certificatesv1.UsageClientAuth,
+	certificatesv1.UsageCodeSigning,
+	certificatesv1.UsageEmailProtection,
+	certificatesv1.UsageSMIME,
+	certificatesv1.UsageIPsecEndSystem,
+	certificatesv1.UsageIPsecTunnel,
+	certificatesv1.UsageIPsecUser,
+	certificatesv1.UsageTimestamping,
+	certificatesv1.UsageOCSPSigning,
+	certificatesv1.UsageMicrosoftSGC,
+	certificatesv1.UsageNetscapeSGC,
+}
+
+// requestAllowlist restricts the key usages and the signers of the csrs approved with the default policy,
+// the csrs of the signers with a signer policy are restricted by their policy
+type requestAllowlist struct {
+	usages  []certificatesv1.KeyUsage
+	signers []string
+}
+
+// getRequestAllowlist returns the allowlist of the CSR_ALLOWED_USAGES and CSR_ALLOWED_SIGNERS values
+func getRequestAllowlist() (*requestAllowlist, error) {
+	allowlist := &requestAllowlist{usages: defaultAllowedUsages, signers: defaultAllowedSigners}
+	if v := os.Getenv(allowedUsagesEnvVarName); v != "" {
+		allowlist.usages = []certificatesv1.KeyUsage{}
+		for _, usage := range splitList(v) {
+			if !containsUsage(knownUsages, certificatesv1.KeyUsage(usage)) {
+				return nil, fmt.Errorf("invalid %s usage %q", allowedUsagesEnvVarName, usage)
+			}
+			allowlist.usages = append(allowlist.usages, certificatesv1.KeyUsage(usage))
+		}
+	}
+	if v := os.Getenv(allowedSignersEnvVarName); v != "" {
+		allowlist.signers = splitList(v)
+	}
+	return allowlist, nil
+}
+
+// splitList returns the trimmed non empty items of a comma separated list
+func splitList(v string) []string {
+	items := []string{}
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// verify returns an error if the signer or one of the key usages of the csr is not allowed, a nil allowlist
+// allows all the csrs
+func (a *requestAllowlist) verify(csr *certificatesv1.CertificateSigningRequest) error {
+	if a == nil {
+		return nil
+	}
+	if !containsString(a.signers, csr.Spec.SignerName) {
+		return fmt.Errorf("signer %q not allowed", csr.Spec.SignerName)
+	}
+	for _, usage := range csr.Spec.Usages {
+		if !containsUsage(a.usages, usage) {
+			return fmt.Errorf("usage %q not allowed", usage)
+		}
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
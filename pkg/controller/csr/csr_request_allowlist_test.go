@@ -0,0 +1,199 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func Test_getRequestAllowlist(t *testing.T) {
+	tests := []struct {
+		name    string
+		usages  string
+		signers string
+		want    *requestAllowlist
+		wantErr bool
+	}{
+		{
+			name: "not set",
+			want: &requestAllowlist{usages: defaultAllowedUsages, signers: defaultAllowedSigners},
+		},
+		{
+			name:    "usages and signers",
+			usages:  "client auth, digital signature",
+			signers: certificatesv1.KubeAPIServerClientSignerName + ", example.com/signer",
+			want: &requestAllowlist{
+				usages:  []certificatesv1.KeyUsage{certificatesv1.UsageClientAuth, certificatesv1.UsageDigitalSignature},
+				signers: []string{certificatesv1.KubeAPIServerClientSignerName, "example.com/signer"},
+			},
+		},
+		{
+			name:    "unknown usage",
+			usages:  "client-auth",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(allowedUsagesEnvVarName, tt.usages)
+			os.Setenv(allowedSignersEnvVarName, tt.signers)
+			defer os.Unsetenv(allowedUsagesEnvVarName)
+			defer os.Unsetenv(allowedSignersEnvVarName)
+			got, err := getRequestAllowlist()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getRequestAllowlist() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getRequestAllowlist() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_requestAllowlist_verify(t *testing.T) {
+	allowlist := &requestAllowlist{usages: defaultAllowedUsages, signers: defaultAllowedSigners}
+	tests := []struct {
+		name       string
+		allowlist  *requestAllowlist
+		signerName string
+		usages     []certificatesv1.KeyUsage
+		wantErr    bool
+	}{
+		{
+			name:       "client certificate",
+			allowlist:  allowlist,
+			signerName: certificatesv1.KubeAPIServerClientSignerName,
+			usages: []certificatesv1.KeyUsage{certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageKeyEncipherment, certificatesv1.UsageClientAuth},
+		},
+		{
+			name:       "server auth",
+			allowlist:  allowlist,
+			signerName: certificatesv1.KubeAPIServerClientSignerName,
+			usages:     []certificatesv1.KeyUsage{certificatesv1.UsageClientAuth, certificatesv1.UsageServerAuth},
+			wantErr:    true,
+		},
+		{
+			name:       "cert sign",
+			allowlist:  allowlist,
+			signerName: certificatesv1.KubeAPIServerClientSignerName,
+			usages:     []certificatesv1.KeyUsage{certificatesv1.UsageCertSign},
+			wantErr:    true,
+		},
+		{
+			name:       "unexpected signer",
+			allowlist:  allowlist,
+			signerName: certificatesv1.KubeletServingSignerName,
+			usages:     []certificatesv1.KeyUsage{certificatesv1.UsageClientAuth},
+			wantErr:    true,
+		},
+		{
+			name:       "no allowlist",
+			signerName: certificatesv1.KubeletServingSignerName,
+			usages:     []certificatesv1.KeyUsage{certificatesv1.UsageServerAuth},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr := &certificatesv1.CertificateSigningRequest{
+				Spec: certificatesv1.CertificateSigningRequestSpec{SignerName: tt.signerName, Usages: tt.usages},
+			}
+			if err := tt.allowlist.verify(csr); (err != nil) != tt.wantErr {
+				t.Errorf("requestAllowlist.verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_ReconcileRequestAllowlist(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterName},
+		Spec:       clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+	}
+
+	tests := []struct {
+		name           string
+		usages         []certificatesv1.KeyUsage
+		signerPolicies map[string]signerPolicy
+		wantCode       ReasonCode
+		wantApproval   string
+	}{
+		{
+			name:         "client auth",
+			usages:       []certificatesv1.KeyUsage{certificatesv1.UsageDigitalSignature, certificatesv1.UsageClientAuth},
+			wantCode:     ReasonAutoApproved,
+			wantApproval: string(certificatesv1.CertificateApproved),
+		},
+		{
+			name:     "server auth",
+			usages:   []certificatesv1.KeyUsage{certificatesv1.UsageClientAuth, certificatesv1.UsageServerAuth},
+			wantCode: ReasonRequestNotAllowed,
+		},
+		{
+			// the signer policy of the signer restricts its csrs instead of the allowlist
+			name:   "server auth with signer policy",
+			usages: []certificatesv1.KeyUsage{certificatesv1.UsageClientAuth, certificatesv1.UsageServerAuth},
+			signerPolicies: map[string]signerPolicy{
+				certificatesv1.KubeAPIServerClientSignerName: {
+					AllowedUsages: []certificatesv1.KeyUsage{certificatesv1.UsageClientAuth, certificatesv1.UsageServerAuth},
+				},
+			},
+			wantCode:     ReasonAutoApproved,
+			wantApproval: string(certificatesv1.CertificateApproved),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr := &certificatesv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   csrNameReconcile,
+					Labels: map[string]string{clusterLabel: clusterName},
+				},
+				Spec: certificatesv1.CertificateSigningRequestSpec{
+					Username:   fmt.Sprintf(userNameSignature, clusterName, clusterName),
+					Request:    newCSRRequest(t, clusterCommonNamePrefix+clusterName, nil),
+					SignerName: certificatesv1.KubeAPIServerClientSignerName,
+					Usages:     tt.usages,
+				},
+			}
+			r := &ReconcileCSR{
+				client:           fake.NewFakeClientWithScheme(testscheme, csr, testManagedCluster),
+				kubeClient:       fakeclientset.NewSimpleClientset(csr),
+				scheme:           testscheme,
+				podNamespace:     testPodNamespace,
+				signerPolicies:   tt.signerPolicies,
+				requestAllowlist: &requestAllowlist{usages: defaultAllowedUsages, signers: defaultAllowedSigners},
+			}
+			if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}}); err != nil {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+			}
+			got, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), csrNameReconcile, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code := got.Annotations[ReasonCodeAnnotation]; code != string(tt.wantCode) {
+				t.Errorf("reason code annotation = %q, want %q", code, tt.wantCode)
+			}
+			if approval := getApprovalType(got); approval != tt.wantApproval {
+				t.Errorf("CSR approval = %q, want %q", approval, tt.wantApproval)
+			}
+		})
+	}
+}
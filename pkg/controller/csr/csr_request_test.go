@@ -0,0 +1,349 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcileCSR_ReconcileInvalidRequest(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: clusterName,
+		},
+		Spec: clusterv1.ManagedClusterSpec{
+			HubAcceptsClient: true,
+		},
+	}
+
+	tests := []struct {
+		name         string
+		request      []byte
+		action       string
+		wantApproval string
+	}{
+		{
+			name:         "valid request",
+			request:      newCSRRequest(t, "system:open-cluster-management:"+clusterName, nil),
+			wantApproval: string(certificatesv1.CertificateApproved),
+		},
+		{
+			name:         "empty request skipped",
+			action:       invalidRequestActionSkip,
+			wantApproval: "",
+		},
+		{
+			name:         "unparsable request skipped",
+			request:      []byte("not a certificate request"),
+			wantApproval: "",
+		},
+		{
+			name:         "empty request denied",
+			action:       invalidRequestActionDeny,
+			wantApproval: string(certificatesv1.CertificateDenied),
+		},
+		{
+			name:         "unparsable request denied",
+			request:      []byte("-----BEGIN CERTIFICATE REQUEST-----\nbm90IGEgY3Ny\n-----END CERTIFICATE REQUEST-----\n"),
+			action:       invalidRequestActionDeny,
+			wantApproval: string(certificatesv1.CertificateDenied),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr := &certificatesv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: csrNameReconcile,
+					Labels: map[string]string{
+						clusterLabel: clusterName,
+					},
+				},
+				Spec: certificatesv1.CertificateSigningRequestSpec{
+					Username: fmt.Sprintf(userNameSignature, clusterName, clusterName),
+					Request:  tt.request,
+				},
+			}
+			r := &ReconcileCSR{
+				client:               fake.NewFakeClientWithScheme(testscheme, testManagedCluster, csr),
+				kubeClient:           fakeclientset.NewSimpleClientset(csr),
+				scheme:               testscheme,
+				invalidRequestAction: tt.action,
+			}
+			if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}}); err != nil {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+			}
+			got, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), csrNameReconcile, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if approval := getApprovalType(got); approval != tt.wantApproval {
+				t.Errorf("CSR approval = %q, want %q", approval, tt.wantApproval)
+			}
+			if tt.wantApproval == string(certificatesv1.CertificateDenied) &&
+				got.Status.Conditions[0].Reason != string(ReasonInvalidCertificateRequest) {
+				t.Errorf("CSR denial reason = %q, want %q", got.Status.Conditions[0].Reason, ReasonInvalidCertificateRequest)
+			}
+		})
+	}
+}
+
+func Test_verifyIdentity(t *testing.T) {
+	clusterIdentity := clusterCommonNamePrefix + clusterName
+	tests := []struct {
+		name          string
+		commonName    string
+		organizations []string
+		wantErr       bool
+	}{
+		{
+			name:          "klusterlet agent",
+			commonName:    clusterIdentity + ":agent1",
+			organizations: []string{clusterIdentity, managedClustersGroup},
+		},
+		{
+			name:       "cluster",
+			commonName: clusterIdentity,
+		},
+		{
+			name:       "common name of another cluster",
+			commonName: clusterCommonNamePrefix + "othercluster:agent1",
+			wantErr:    true,
+		},
+		{
+			name:       "common name prefixed by the cluster name",
+			commonName: clusterIdentity + "2:agent1",
+			wantErr:    true,
+		},
+		{
+			name:       "empty agent name",
+			commonName: clusterIdentity + ":",
+			wantErr:    true,
+		},
+		{
+			name:       "arbitrary common name",
+			commonName: "system:admin",
+			wantErr:    true,
+		},
+		{
+			name:          "group of another cluster",
+			commonName:    clusterIdentity + ":agent1",
+			organizations: []string{clusterCommonNamePrefix + "othercluster"},
+			wantErr:       true,
+		},
+		{
+			name:          "arbitrary organization",
+			commonName:    clusterIdentity + ":agent1",
+			organizations: []string{clusterIdentity, "system:masters"},
+			wantErr:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x509cr := &x509.CertificateRequest{
+				Subject: pkix.Name{CommonName: tt.commonName, Organization: tt.organizations},
+			}
+			if err := verifyIdentity(x509cr, clusterName); (err != nil) != tt.wantErr {
+				t.Errorf("verifyIdentity() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_ReconcileIdentity(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: clusterName,
+		},
+		Spec: clusterv1.ManagedClusterSpec{
+			HubAcceptsClient: true,
+		},
+	}
+	clusterIdentity := clusterCommonNamePrefix + clusterName
+	validRequest := newCSRRequest(t, clusterIdentity+":agent1", []string{clusterIdentity, managedClustersGroup})
+	block, _ := pem.Decode(validRequest)
+
+	tests := []struct {
+		name         string
+		request      []byte
+		wantApproval string
+		wantReason   ReasonCode
+	}{
+		{
+			name:         "klusterlet identity",
+			request:      validRequest,
+			wantApproval: string(certificatesv1.CertificateApproved),
+			wantReason:   ReasonAutoApproved,
+		},
+		{
+			name:         "mismatched common name",
+			request:      newCSRRequest(t, clusterCommonNamePrefix+"othercluster:agent1", nil),
+			wantApproval: string(certificatesv1.CertificateDenied),
+			wantReason:   ReasonIdentityMismatch,
+		},
+		{
+			name:         "mismatched organization",
+			request:      newCSRRequest(t, clusterIdentity+":agent1", []string{"system:masters"}),
+			wantApproval: string(certificatesv1.CertificateDenied),
+			wantReason:   ReasonIdentityMismatch,
+		},
+		{
+			name:         "missing PEM block",
+			request:      block.Bytes,
+			wantApproval: string(certificatesv1.CertificateDenied),
+			wantReason:   ReasonInvalidCertificateRequest,
+		},
+		{
+			name: "malformed DER",
+			request: pem.EncodeToMemory(&pem.Block{
+				Type:  "CERTIFICATE REQUEST",
+				Bytes: block.Bytes[:len(block.Bytes)/2],
+			}),
+			wantApproval: string(certificatesv1.CertificateDenied),
+			wantReason:   ReasonInvalidCertificateRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr := &certificatesv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: csrNameReconcile,
+					Labels: map[string]string{
+						clusterLabel: clusterName,
+					},
+				},
+				Spec: certificatesv1.CertificateSigningRequestSpec{
+					Username:   fmt.Sprintf(userNameSignature, clusterName, clusterName),
+					SignerName: certificatesv1.KubeAPIServerClientSignerName,
+					Request:    tt.request,
+				},
+			}
+			r := &ReconcileCSR{
+				client:               fake.NewFakeClientWithScheme(testscheme, testManagedCluster, csr),
+				kubeClient:           fakeclientset.NewSimpleClientset(csr),
+				scheme:               testscheme,
+				invalidRequestAction: invalidRequestActionDeny,
+			}
+			if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}}); err != nil {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+			}
+			got, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), csrNameReconcile, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if approval := getApprovalType(got); approval != tt.wantApproval {
+				t.Fatalf("CSR approval = %q, want %q", approval, tt.wantApproval)
+			}
+			if reason := got.Status.Conditions[0].Reason; reason != string(tt.wantReason) {
+				t.Errorf("CSR reason = %q, want %q", reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_ReconcileOrganizations(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterName},
+		Spec:       clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+	}
+	clusterIdentity := clusterCommonNamePrefix + clusterName
+	// the signer policy of the kube-apiserver-client signer replaces the verification of the default policy
+	clientSignerPolicies := map[string]signerPolicy{
+		certificatesv1.KubeAPIServerClientSignerName: {RequireClusterCommonName: true},
+	}
+
+	tests := []struct {
+		name           string
+		organizations  []string
+		signerPolicies map[string]signerPolicy
+		wantApproval   string
+		wantReason     ReasonCode
+	}{
+		{
+			name:          "cluster group",
+			organizations: []string{clusterIdentity},
+			wantApproval:  string(certificatesv1.CertificateApproved),
+			wantReason:    ReasonAutoApproved,
+		},
+		{
+			name:          "system:masters",
+			organizations: []string{clusterIdentity, "system:masters"},
+			wantApproval:  string(certificatesv1.CertificateDenied),
+			wantReason:    ReasonIdentityMismatch,
+		},
+		{
+			name:           "cluster group with a signer policy",
+			organizations:  []string{clusterIdentity, managedClustersGroup},
+			signerPolicies: clientSignerPolicies,
+			wantApproval:   string(certificatesv1.CertificateApproved),
+			wantReason:     ReasonAutoApproved,
+		},
+		{
+			name:           "system:masters with a signer policy",
+			organizations:  []string{"system:masters"},
+			signerPolicies: clientSignerPolicies,
+			wantApproval:   string(certificatesv1.CertificateDenied),
+			wantReason:     ReasonIdentityMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr := &certificatesv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   csrNameReconcile,
+					Labels: map[string]string{clusterLabel: clusterName},
+				},
+				Spec: certificatesv1.CertificateSigningRequestSpec{
+					Username:   fmt.Sprintf(userNameSignature, clusterName, clusterName),
+					SignerName: certificatesv1.KubeAPIServerClientSignerName,
+					Request:    newCSRRequest(t, clusterIdentity+":agent1", tt.organizations),
+					Usages:     []certificatesv1.KeyUsage{certificatesv1.UsageClientAuth},
+				},
+			}
+			r := &ReconcileCSR{
+				client:         fake.NewFakeClientWithScheme(testscheme, testManagedCluster, csr),
+				kubeClient:     fakeclientset.NewSimpleClientset(csr),
+				scheme:         testscheme,
+				signerPolicies: tt.signerPolicies,
+			}
+			if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}}); err != nil {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+			}
+			got, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), csrNameReconcile, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if approval := getApprovalType(got); approval != tt.wantApproval {
+				t.Fatalf("CSR approval = %q, want %q", approval, tt.wantApproval)
+			}
+			if reason := got.Status.Conditions[0].Reason; reason != string(tt.wantReason) {
+				t.Errorf("CSR reason = %q, want %q", reason, tt.wantReason)
+			}
+		})
+	}
+}
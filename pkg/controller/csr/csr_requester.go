@@ -0,0 +1,71 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"encoding/json"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+)
+
+// extras of the user info of a bound service account token, the apiserver copies the claims of the token
+// binding in the extras of the csr requested with the token
+const (
+	podNameExtraKey  = "authentication.kubernetes.io/pod-name"
+	podUIDExtraKey   = "authentication.kubernetes.io/pod-uid"
+	nodeNameExtraKey = "authentication.kubernetes.io/node-name"
+	nodeUIDExtraKey  = "authentication.kubernetes.io/node-uid"
+)
+
+// requesterAnnotation records on the approved csr the identity of the pod which requested it
+const requesterAnnotation = "import.open-cluster-management.io/requester"
+
+// requesterIdentity is the pod and node identity a csr was requested from
+type requesterIdentity struct {
+	PodNamespace string `json:"podNamespace,omitempty"`
+	PodName      string `json:"podName,omitempty"`
+	PodUID       string `json:"podUID,omitempty"`
+	NodeName     string `json:"nodeName,omitempty"`
+	NodeUID      string `json:"nodeUID,omitempty"`
+}
+
+// getRequesterIdentity returns the identity of the requester of the csr from the bound token extras,
+// nil if the csr was not requested with a bound token
+func getRequesterIdentity(csr *certificatesv1.CertificateSigningRequest) *requesterIdentity {
+	extra := func(key string) string {
+		if values := csr.Spec.Extra[key]; len(values) != 0 {
+			return values[0]
+		}
+		return ""
+	}
+	identity := &requesterIdentity{
+		PodName:  extra(podNameExtraKey),
+		PodUID:   extra(podUIDExtraKey),
+		NodeName: extra(nodeNameExtraKey),
+		NodeUID:  extra(nodeUIDExtraKey),
+	}
+	if *identity == (requesterIdentity{}) {
+		return nil
+	}
+	if identity.PodName != "" {
+		identity.PodNamespace, _, _ = parseServiceAccountUsername(csr.Spec.Username)
+	}
+	return identity
+}
+
+// recordRequesterIdentity adds the identity of the requester of the csr, if known, to the annotations
+func recordRequesterIdentity(csr *certificatesv1.CertificateSigningRequest, annotations map[string]string) error {
+	identity := getRequesterIdentity(csr)
+	if identity == nil {
+		return nil
+	}
+	b, err := json.Marshal(identity)
+	if err != nil {
+		return err
+	}
+	if csr.Annotations[requesterAnnotation] != string(b) {
+		log.Info("CSR requested by a bound token", "name", csr.Name, "requester", string(b))
+	}
+	annotations[requesterAnnotation] = string(b)
+	return nil
+}
@@ -0,0 +1,102 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcileCSR_ReconcileRequesterIdentity(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: clusterName,
+		},
+		Spec: clusterv1.ManagedClusterSpec{
+			HubAcceptsClient: true,
+		},
+	}
+
+	tests := []struct {
+		name           string
+		extra          map[string]certificatesv1.ExtraValue
+		wantAnnotation string
+	}{
+		{
+			name: "no extras",
+		},
+		{
+			name:  "extras without bound token claims",
+			extra: map[string]certificatesv1.ExtraValue{"scopes.authorization.openshift.io": {"user:full"}},
+		},
+		{
+			name: "pod bound token",
+			extra: map[string]certificatesv1.ExtraValue{
+				podNameExtraKey: {"klusterlet-registration-agent-abcde"},
+				podUIDExtraKey:  {"5d5b1c4e-0c36-4e4c-9f0c-6a4a1b1f6b1f"},
+			},
+			wantAnnotation: `{"podNamespace":"mycluster","podName":"klusterlet-registration-agent-abcde",` +
+				`"podUID":"5d5b1c4e-0c36-4e4c-9f0c-6a4a1b1f6b1f"}`,
+		},
+		{
+			name: "pod bound token with node",
+			extra: map[string]certificatesv1.ExtraValue{
+				podNameExtraKey:  {"klusterlet-registration-agent-abcde"},
+				nodeNameExtraKey: {"worker-0"},
+				nodeUIDExtraKey:  {"0b7e2a4c-7b1e-4c23-8f7e-1f2d8b9a6c3d"},
+			},
+			wantAnnotation: `{"podNamespace":"mycluster","podName":"klusterlet-registration-agent-abcde",` +
+				`"nodeName":"worker-0","nodeUID":"0b7e2a4c-7b1e-4c23-8f7e-1f2d8b9a6c3d"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr := &certificatesv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: csrNameReconcile,
+					Labels: map[string]string{
+						clusterLabel: clusterName,
+					},
+				},
+				Spec: certificatesv1.CertificateSigningRequestSpec{
+					Username: fmt.Sprintf(userNameSignature, clusterName, clusterName),
+					Request:  newCSRRequest(t, clusterCommonNamePrefix+clusterName, nil),
+					Extra:    tt.extra,
+				},
+			}
+			r := &ReconcileCSR{
+				client:     fake.NewFakeClientWithScheme(testscheme, testManagedCluster, csr),
+				kubeClient: fakeclientset.NewSimpleClientset(csr),
+				scheme:     testscheme,
+			}
+			if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}}); err != nil {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+			}
+			got, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), csrNameReconcile, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if approval := getApprovalType(got); approval != string(certificatesv1.CertificateApproved) {
+				t.Fatalf("CSR approval = %q, want approved", approval)
+			}
+			annotation, ok := got.Annotations[requesterAnnotation]
+			if ok != (tt.wantAnnotation != "") || annotation != tt.wantAnnotation {
+				t.Errorf("requester annotation = %q, want %q", annotation, tt.wantAnnotation)
+			}
+		})
+	}
+}
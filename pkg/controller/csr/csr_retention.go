@@ -0,0 +1,106 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// csrRetentionEnvVarName is the age after which the approved and denied csrs of the clusters are deleted,
+	// they are kept if not set
+	csrRetentionEnvVarName = "CSR_RETENTION"
+	// csrRetentionSweepInterval is the period of the sweeps of the csrs, no event fires for an idle csr
+	csrRetentionSweepInterval = 10 * time.Minute
+)
+
+// getCSRRetention returns the CSR_RETENTION value, 0 if not set
+func getCSRRetention() (time.Duration, error) {
+	v := os.Getenv(csrRetentionEnvVarName)
+	if v == "" {
+		return 0, nil
+	}
+	retention, err := time.ParseDuration(v)
+	if err != nil || retention <= 0 {
+		return 0, fmt.Errorf("invalid %s value %q, must be a positive duration", csrRetentionEnvVarName, v)
+	}
+	return retention, nil
+}
+
+// csrCollector periodically deletes the approved and denied csrs of the clusters older than the retention. Only
+// the csrs labeled for a cluster and requested by a bootstrap service account or an add-on agent of the cluster are
+// deleted, the other certificates of the hub are never touched.
+type csrCollector struct {
+	r         *ReconcileCSR
+	retention time.Duration
+	interval  time.Duration
+}
+
+// newCSRCollector returns the collector of the csrs of r older than retention, nil if retention is not positive
+func newCSRCollector(r *ReconcileCSR, retention time.Duration) *csrCollector {
+	if retention <= 0 {
+		return nil
+	}
+	return &csrCollector{r: r, retention: retention, interval: csrRetentionSweepInterval}
+}
+
+// Start sweeps the csrs every interval until stop is closed, a failed sweep is logged and retried at the next
+// interval
+func (c *csrCollector) Start(stop <-chan struct{}) error {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := c.sweep(time.Now()); err != nil {
+				log.Error(err, "failed to delete the expired CSRs")
+			}
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+// sweep deletes the collected csrs older than the retention at now and returns the number of deleted csrs
+func (c *csrCollector) sweep(now time.Time) (int, error) {
+	csrs, err := c.r.listCSRs(metav1.ListOptions{})
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for i := range csrs {
+		csr := &csrs[i]
+		if !c.collects(csr, now) {
+			continue
+		}
+		message := fmt.Sprintf("The CSR is %s for more than %s", getApprovalType(csr), c.retention)
+		csrLogger(csr).Info("Deleting expired CSR", "decision", decisionDelete, "reason", message)
+		if c.r.dryRun {
+			recordDryRunDecision(csr, decisionDelete, "", message)
+			continue
+		}
+		if err := c.r.deleteCSR(csr); err != nil {
+			return deleted, err
+		}
+		csrCollectedTotal.WithLabelValues(getClusterName(csr)).Inc()
+		deleted++
+	}
+	return deleted, nil
+}
+
+// collects checks the csr is an approved or denied csr of a cluster older than the retention at now
+func (c *csrCollector) collects(csr *certificatesv1.CertificateSigningRequest, now time.Time) bool {
+	clusterName := getClusterName(csr)
+	if clusterName == "" || getApprovalType(csr) == "" || now.Sub(csr.CreationTimestamp.Time) <= c.retention {
+		return false
+	}
+	if addOnName := getAddOnName(csr); addOnName != "" {
+		return validAddOnUsername(csr, clusterName, addOnName)
+	}
+	return validUsername(csr, clusterName, c.r.usernameTemplates) || crossNamespaceRequest(csr, clusterName)
+}
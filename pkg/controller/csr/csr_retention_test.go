@@ -0,0 +1,135 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_getCSRRetention(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "not set"},
+		{name: "retention", value: "168h", want: 168 * time.Hour},
+		{name: "invalid duration", value: "week", wantErr: true},
+		{name: "zero", value: "0s", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(csrRetentionEnvVarName, tt.value)
+			defer os.Unsetenv(csrRetentionEnvVarName)
+			got, err := getCSRRetention()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getCSRRetention() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("getCSRRetention() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_csrCollector_sweep(t *testing.T) {
+	now := time.Now()
+	newCSR := func(name, username string, created time.Time,
+		approval certificatesv1.RequestConditionType) *certificatesv1.CertificateSigningRequest {
+		csr := &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Labels:            map[string]string{clusterLabel: clusterName},
+				CreationTimestamp: metav1.NewTime(created),
+			},
+			Spec: certificatesv1.CertificateSigningRequestSpec{Username: username},
+		}
+		if approval != "" {
+			csr.Status.Conditions = []certificatesv1.CertificateSigningRequestCondition{
+				{Type: approval, Status: "True"},
+			}
+		}
+		return csr
+	}
+	bootstrapUsername := fmt.Sprintf(userNameSignature, clusterName, clusterName)
+	old := now.Add(-48 * time.Hour)
+	unlabeled := newCSR("old-unlabeled", bootstrapUsername, old, certificatesv1.CertificateApproved)
+	unlabeled.Labels = nil
+
+	tests := []struct {
+		name        string
+		csr         *certificatesv1.CertificateSigningRequest
+		dryRun      bool
+		wantDeleted bool
+	}{
+		{
+			name:        "old approved csr",
+			csr:         newCSR("old-approved", bootstrapUsername, old, certificatesv1.CertificateApproved),
+			wantDeleted: true,
+		},
+		{
+			name:        "old denied csr",
+			csr:         newCSR("old-denied", bootstrapUsername, old, certificatesv1.CertificateDenied),
+			wantDeleted: true,
+		},
+		{
+			name: "recent approved csr",
+			csr: newCSR("recent-approved", bootstrapUsername, now.Add(-time.Hour),
+				certificatesv1.CertificateApproved),
+		},
+		{
+			name: "old pending csr",
+			csr:  newCSR("old-pending", bootstrapUsername, old, ""),
+		},
+		{
+			name: "old approved csr of another user",
+			csr: newCSR("old-other-user", "system:serviceaccount:kube-system:default", old,
+				certificatesv1.CertificateApproved),
+		},
+		{
+			name: "old approved csr without cluster label",
+			csr:  unlabeled,
+		},
+		{
+			name:   "old approved csr in dry run",
+			csr:    newCSR("old-dry-run", bootstrapUsername, old, certificatesv1.CertificateApproved),
+			dryRun: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ReconcileCSR{
+				kubeClient: fakeclientset.NewSimpleClientset(tt.csr.DeepCopy()),
+				dryRun:     tt.dryRun,
+			}
+			c := newCSRCollector(r, 24*time.Hour)
+			deleted, err := c.sweep(now)
+			if err != nil {
+				t.Fatalf("csrCollector.sweep() error = %v", err)
+			}
+			if (deleted == 1) != tt.wantDeleted {
+				t.Errorf("csrCollector.sweep() deleted %d CSRs, wantDeleted %v", deleted, tt.wantDeleted)
+			}
+			_, err = r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), tt.csr.Name,
+				metav1.GetOptions{})
+			if (err != nil) != tt.wantDeleted {
+				t.Errorf("CSR get error = %v, wantDeleted %v", err, tt.wantDeleted)
+			}
+		})
+	}
+}
+
+func Test_newCSRCollector(t *testing.T) {
+	if c := newCSRCollector(&ReconcileCSR{}, 0); c != nil {
+		t.Errorf("newCSRCollector() without retention = %v, want nil", c)
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const (
+	// approvalRulesConfigMapEnvVarName is the name of the configmap, in the POD_NAMESPACE, holding the
+	// approval rules. The rules are reloaded when the configmap changes.
+	approvalRulesConfigMapEnvVarName = "CSR_APPROVAL_RULES_CONFIGMAP"
+
+	// keys of the approval rules configmap
+	signerNamesKey     = "signerNames"
+	csrNameRegexKey    = "csrNameRegex"
+	clusterSelectorKey = "clusterSelector"
+	allowedOwnersKey   = "allowedOwners"
+
+	// ownerAnnotation is the ManagedCluster annotation naming the team or user accountable for the cluster
+	ownerAnnotation = "import.open-cluster-management.io/owner"
+)
+
+// approvalRules restrict the csrs approved by the controller, an empty rule does not restrict the csrs
+type approvalRules struct {
+	// signerNames is the list of the signers allowed for the csrs
+	signerNames []string
+	// csrNameRegex must match the csr name
+	csrNameRegex *regexp.Regexp
+	// clusterSelector must match the labels of the ManagedCluster
+	clusterSelector labels.Selector
+	// allowedOwners is the allow-list of the owners of the ManagedClusters, the csrs of the clusters
+	// without an allowed ownerAnnotation are denied
+	allowedOwners map[string]bool
+	// data are the rules of the configmap, they are part of the approval policy
+	data map[string]string
+}
+
+// parseApprovalRules validates and parses the approval rules of the configmap
+func parseApprovalRules(cm *corev1.ConfigMap) (*approvalRules, error) {
+	rules := &approvalRules{}
+	for _, key := range []string{signerNamesKey, csrNameRegexKey, clusterSelectorKey, allowedOwnersKey} {
+		if v, ok := cm.Data[key]; ok {
+			if rules.data == nil {
+				rules.data = map[string]string{}
+			}
+			rules.data[key] = v
+		}
+	}
+	for _, signerName := range strings.Split(cm.Data[signerNamesKey], ",") {
+		signerName = strings.TrimSpace(signerName)
+		if signerName == "" {
+			continue
+		}
+		if !strings.Contains(signerName, "/") {
+			return nil, fmt.Errorf("invalid signer name %q, signer names must be of the form <domain>/<path>", signerName)
+		}
+		rules.signerNames = append(rules.signerNames, signerName)
+	}
+	if v := cm.Data[csrNameRegexKey]; v != "" {
+		re, err := regexp.Compile(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", csrNameRegexKey, err)
+		}
+		rules.csrNameRegex = re
+	}
+	if v := cm.Data[clusterSelectorKey]; v != "" {
+		selector, err := labels.Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", clusterSelectorKey, err)
+		}
+		rules.clusterSelector = selector
+	}
+	for _, owner := range strings.Split(cm.Data[allowedOwnersKey], ",") {
+		owner = strings.TrimSpace(owner)
+		if owner == "" {
+			continue
+		}
+		if rules.allowedOwners == nil {
+			rules.allowedOwners = map[string]bool{}
+		}
+		rules.allowedOwners[owner] = true
+	}
+	return rules, nil
+}
+
+// verifyOwnership returns the denial reason and an error if the cluster has no allowed owner
+func (rules *approvalRules) verifyOwnership(cluster *clusterv1.ManagedCluster) (ReasonCode, error) {
+	if rules.allowedOwners == nil {
+		return "", nil
+	}
+	owner := cluster.GetAnnotations()[ownerAnnotation]
+	if owner == "" {
+		return ReasonClusterNotOwned, fmt.Errorf("the cluster %s has no %s annotation", cluster.Name, ownerAnnotation)
+	}
+	if !rules.allowedOwners[owner] {
+		return ReasonClusterOwnerNotAllowed, fmt.Errorf("the owner %q of the cluster %s is not allowed", owner, cluster.Name)
+	}
+	return "", nil
+}
+
+// allows returns an error if the csr of the cluster is not allowed by the rules
+func (rules *approvalRules) allows(
+	csr *certificatesv1.CertificateSigningRequest,
+	cluster *clusterv1.ManagedCluster) error {
+	if len(rules.signerNames) != 0 {
+		allowed := false
+		for _, signerName := range rules.signerNames {
+			if csr.Spec.SignerName == signerName {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("signer %q is not allowed", csr.Spec.SignerName)
+		}
+	}
+	if rules.csrNameRegex != nil && !rules.csrNameRegex.MatchString(csr.Name) {
+		return fmt.Errorf("csr name does not match %q", rules.csrNameRegex.String())
+	}
+	if rules.clusterSelector != nil && !rules.clusterSelector.Matches(labels.Set(cluster.Labels)) {
+		return fmt.Errorf("cluster %s does not match the selector %q", cluster.Name, rules.clusterSelector.String())
+	}
+	return nil
+}
+
+// getApprovalRules returns the approval rules in effect, nil if they are not loaded
+func (r *ReconcileCSR) getApprovalRules() *approvalRules {
+	rules, _ := r.approvalRules.Load().(*approvalRules)
+	return rules
+}
+
+// reloadApprovalRules swaps the approval rules with the rules of the configmap,
+// the rules in effect are kept if the configmap is invalid. A nil configmap unloads the rules.
+// The reloaded rules change the approval policy and the csrs left pending by the previous rules are enqueued to be
+// evaluated with the reloaded rules.
+func (r *ReconcileCSR) reloadApprovalRules(cm *corev1.ConfigMap) error {
+	if cm == nil {
+		log.Info("Approval rules configmap deleted, the CSRs are not approved until it is recreated",
+			"configmap", r.approvalRulesConfigMapName)
+		r.approvalRules.Store((*approvalRules)(nil))
+		return nil
+	}
+	rules, err := parseApprovalRules(cm)
+	if err != nil {
+		log.Error(err, "Invalid approval rules, keep the rules in effect", "configmap", cm.Name)
+		return err
+	}
+	log.Info("Approval rules reloaded", "configmap", cm.Name, "resourceVersion", cm.ResourceVersion)
+	r.approvalRules.Store(rules)
+	r.resetPolicyHistory()
+	r.requeueNotAllowedCSRs()
+	return nil
+}
+
+// requeueNotAllowedCSRs enqueues the pending csrs not allowed by the approval rules
+func (r *ReconcileCSR) requeueNotAllowedCSRs() {
+	if r.rulesReloaded == nil {
+		return
+	}
+	csrs, err := r.listCachedCSRs()
+	if err != nil {
+		log.Error(err, "failed to list the CSRs not allowed by the approval rules")
+		return
+	}
+	for _, csr := range csrs {
+		if getApprovalType(csr) != "" || csr.Annotations[ReasonCodeAnnotation] != string(ReasonNotAllowedByRules) {
+			continue
+		}
+		r.rulesReloaded <- event.GenericEvent{Meta: &csr.ObjectMeta, Object: csr}
+	}
+}
+
+// addApprovalRulesWatch watches the approval rules configmap to reload the rules without restart
+func addApprovalRulesWatch(mgr manager.Manager, r *ReconcileCSR) error {
+	if r.approvalRulesConfigMapName == "" {
+		return nil
+	}
+	if r.kubeClient == nil {
+		return fmt.Errorf("a kube client is required to watch the approval rules configmap %s", r.approvalRulesConfigMapName)
+	}
+	lw := toolscache.NewListWatchFromClient(r.kubeClient.CoreV1().RESTClient(), "configmaps", r.podNamespace,
+		fields.OneTermEqualSelector("metadata.name", r.approvalRulesConfigMapName))
+	_, informer := toolscache.NewInformer(lw, &corev1.ConfigMap{}, 0, toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if cm, ok := obj.(*corev1.ConfigMap); ok {
+				_ = r.reloadApprovalRules(cm)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			if cm, ok := newObj.(*corev1.ConfigMap); ok {
+				_ = r.reloadApprovalRules(cm)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			_ = r.reloadApprovalRules(nil)
+		},
+	})
+	return mgr.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+		informer.Run(stop)
+		return nil
+	}))
+}
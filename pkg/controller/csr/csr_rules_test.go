@@ -0,0 +1,356 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const approvalRulesConfigMapName = "csr-approval-rules"
+
+func newApprovalRulesConfigMap(data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      approvalRulesConfigMapName,
+			Namespace: testPodNamespace,
+		},
+		Data: data,
+	}
+}
+
+func Test_approvalRules_allows(t *testing.T) {
+	csr := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "csr-mycluster-abcde",
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			SignerName: certificatesv1.KubeAPIServerClientSignerName,
+		},
+	}
+	cluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   clusterName,
+			Labels: map[string]string{"env": "prod"},
+		},
+	}
+	tests := []struct {
+		name    string
+		data    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "no rules",
+		},
+		{
+			name: "matching rules",
+			data: map[string]string{
+				signerNamesKey:     "example.com/signer, " + certificatesv1.KubeAPIServerClientSignerName,
+				csrNameRegexKey:    "^csr-mycluster-",
+				clusterSelectorKey: "env in (prod,staging)",
+			},
+		},
+		{
+			name:    "signer not allowed",
+			data:    map[string]string{signerNamesKey: "example.com/signer"},
+			wantErr: true,
+		},
+		{
+			name:    "name not matching",
+			data:    map[string]string{csrNameRegexKey: "^addon-"},
+			wantErr: true,
+		},
+		{
+			name:    "cluster not selected",
+			data:    map[string]string{clusterSelectorKey: "env=dev"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := parseApprovalRules(newApprovalRulesConfigMap(tt.data))
+			if err != nil {
+				t.Fatalf("parseApprovalRules() error = %v", err)
+			}
+			if err := rules.allows(csr, cluster); (err != nil) != tt.wantErr {
+				t.Errorf("allows() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_parseApprovalRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			data: map[string]string{
+				signerNamesKey:     certificatesv1.KubeAPIServerClientSignerName,
+				csrNameRegexKey:    "^csr-",
+				clusterSelectorKey: "env=prod",
+			},
+		},
+		{
+			name:    "invalid signer name",
+			data:    map[string]string{signerNamesKey: "signer"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid regex",
+			data:    map[string]string{csrNameRegexKey: "csr-("},
+			wantErr: true,
+		},
+		{
+			name:    "invalid selector",
+			data:    map[string]string{clusterSelectorKey: "env in prod"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseApprovalRules(newApprovalRulesConfigMap(tt.data)); (err != nil) != tt.wantErr {
+				t.Errorf("parseApprovalRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_ReconcileApprovalRulesReload(t *testing.T) {
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: clusterName,
+		},
+		Spec: clusterv1.ManagedClusterSpec{
+			HubAcceptsClient: true,
+		},
+	}
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	r := &ReconcileCSR{
+		podNamespace:               testPodNamespace,
+		approvalRulesConfigMapName: approvalRulesConfigMapName,
+	}
+	reconcileCSR := func(t *testing.T, name string) bool {
+		csr := &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+				Labels: map[string]string{
+					clusterLabel: clusterName,
+				},
+			},
+			Spec: certificatesv1.CertificateSigningRequestSpec{
+				Username:   fmt.Sprintf(userNameSignature, clusterName, clusterName),
+				Request:    newCSRRequest(t, "system:open-cluster-management:"+clusterName, nil),
+				SignerName: certificatesv1.KubeAPIServerClientSignerName,
+			},
+		}
+		r.client = fake.NewFakeClientWithScheme(testscheme, testManagedCluster, csr)
+		r.kubeClient = fakeclientset.NewSimpleClientset(csr)
+		if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: name}}); err != nil {
+			t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+		}
+		got, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return getApprovalType(got) == string(certificatesv1.CertificateApproved)
+	}
+
+	steps := []struct {
+		name         string
+		configMap    *corev1.ConfigMap
+		wantErr      bool
+		csrName      string
+		wantApproved bool
+	}{
+		{
+			name:         "rules not loaded",
+			csrName:      "csr-mycluster",
+			wantApproved: false,
+		},
+		{
+			name:         "rules loaded",
+			configMap:    newApprovalRulesConfigMap(map[string]string{csrNameRegexKey: "^csr-"}),
+			csrName:      "csr-mycluster",
+			wantApproved: true,
+		},
+		{
+			name:         "new rules applied",
+			configMap:    newApprovalRulesConfigMap(map[string]string{csrNameRegexKey: "^agent-"}),
+			csrName:      "csr-mycluster",
+			wantApproved: false,
+		},
+		{
+			name:         "invalid rules rejected and previous rules kept",
+			configMap:    newApprovalRulesConfigMap(map[string]string{csrNameRegexKey: "agent-("}),
+			wantErr:      true,
+			csrName:      "agent-mycluster",
+			wantApproved: true,
+		},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if step.configMap != nil {
+				if err := r.reloadApprovalRules(step.configMap); (err != nil) != step.wantErr {
+					t.Fatalf("reloadApprovalRules() error = %v, wantErr %v", err, step.wantErr)
+				}
+			}
+			if approved := reconcileCSR(t, step.csrName); approved != step.wantApproved {
+				t.Errorf("CSR approved = %v, want %v", approved, step.wantApproved)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_reloadApprovalRulesRequeue(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+
+	newCSR := func(name string, code ReasonCode, approved bool) *certificatesv1.CertificateSigningRequest {
+		csr := newDedupCSR(name, nil)
+		csr.Annotations = map[string]string{ReasonCodeAnnotation: string(code)}
+		if approved {
+			csr.Status.Conditions = []certificatesv1.CertificateSigningRequestCondition{
+				{Type: certificatesv1.CertificateApproved, Status: corev1.ConditionTrue},
+			}
+		}
+		return csr
+	}
+	r := &ReconcileCSR{
+		client: fake.NewFakeClientWithScheme(testscheme,
+			newCSR("csr-not-allowed", ReasonNotAllowedByRules, false),
+			newCSR("csr-not-accepted", ReasonClusterNotAccepted, false),
+			newCSR("csr-approved", ReasonNotAllowedByRules, true),
+		),
+		podNamespace:               testPodNamespace,
+		approvalRulesConfigMapName: approvalRulesConfigMapName,
+		rulesReloaded:              make(chan event.GenericEvent, 3),
+		policyHistory:              &policyHistory{},
+	}
+	versions := map[string]bool{r.currentPolicy().version(): true}
+	for _, regex := range []string{"^csr-", "^agent-"} {
+		if err := r.reloadApprovalRules(newApprovalRulesConfigMap(map[string]string{csrNameRegexKey: regex})); err != nil {
+			t.Fatalf("reloadApprovalRules() error = %v", err)
+		}
+		if versions[r.currentPolicy().version()] {
+			t.Errorf("policy version %s not changed by the rules %q", r.currentPolicy().version(), regex)
+		}
+		versions[r.currentPolicy().version()] = true
+		if r.policyHistory != nil {
+			t.Errorf("the policy history is not reloaded with the rules %q", regex)
+		}
+
+		enqueued := []string{}
+		for len(r.rulesReloaded) > 0 {
+			enqueued = append(enqueued, (<-r.rulesReloaded).Meta.GetName())
+		}
+		if want := []string{"csr-not-allowed"}; !reflect.DeepEqual(enqueued, want) {
+			t.Errorf("enqueued CSRs = %v, want %v", enqueued, want)
+		}
+	}
+}
+
+func TestReconcileCSR_ReconcileOwnership(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	newManagedCluster := func(owner string) *clusterv1.ManagedCluster {
+		managedCluster := &clusterv1.ManagedCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: clusterName,
+			},
+			Spec: clusterv1.ManagedClusterSpec{
+				HubAcceptsClient: true,
+			},
+		}
+		if owner != "" {
+			managedCluster.Annotations = map[string]string{ownerAnnotation: owner}
+		}
+		return managedCluster
+	}
+
+	tests := []struct {
+		name           string
+		managedCluster *clusterv1.ManagedCluster
+		wantApproval   string
+		wantReason     ReasonCode
+	}{
+		{
+			name:           "owned by an allowed team",
+			managedCluster: newManagedCluster("team:platform"),
+			wantApproval:   string(certificatesv1.CertificateApproved),
+		},
+		{
+			name:           "owned by a team not allowed",
+			managedCluster: newManagedCluster("team:sandbox"),
+			wantApproval:   string(certificatesv1.CertificateDenied),
+			wantReason:     ReasonClusterOwnerNotAllowed,
+		},
+		{
+			name:           "unowned",
+			managedCluster: newManagedCluster(""),
+			wantApproval:   string(certificatesv1.CertificateDenied),
+			wantReason:     ReasonClusterNotOwned,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr := &certificatesv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: csrNameReconcile,
+					Labels: map[string]string{
+						clusterLabel: clusterName,
+					},
+				},
+				Spec: certificatesv1.CertificateSigningRequestSpec{
+					Username: fmt.Sprintf(userNameSignature, clusterName, clusterName),
+					Request:  newCSRRequest(t, "system:open-cluster-management:"+clusterName, nil),
+				},
+			}
+			r := &ReconcileCSR{
+				client:                     fake.NewFakeClientWithScheme(testscheme, tt.managedCluster, csr),
+				kubeClient:                 fakeclientset.NewSimpleClientset(csr),
+				scheme:                     testscheme,
+				podNamespace:               testPodNamespace,
+				approvalRulesConfigMapName: approvalRulesConfigMapName,
+			}
+			if err := r.reloadApprovalRules(newApprovalRulesConfigMap(map[string]string{
+				allowedOwnersKey: "team:platform, user:admin",
+			})); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}}); err != nil {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+			}
+			got, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), csrNameReconcile, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if approval := getApprovalType(got); approval != tt.wantApproval {
+				t.Fatalf("CSR approval = %q, want %q", approval, tt.wantApproval)
+			}
+			if tt.wantReason != "" && got.Status.Conditions[0].Reason != string(tt.wantReason) {
+				t.Errorf("CSR denial reason = %q, want %q", got.Status.Conditions[0].Reason, tt.wantReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"fmt"
+	"strconv"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+)
+
+// selfManagedClusterLabel is set to true on the ManagedCluster of the hub importing itself, the label of the
+// self-import of the managedcluster controller
+const selfManagedClusterLabel = "local-cluster"
+
+// isSelfManagedCluster checks the ManagedCluster is the hub importing itself
+func isSelfManagedCluster(cluster *clusterv1.ManagedCluster) bool {
+	selfManaged, err := strconv.ParseBool(cluster.GetLabels()[selfManagedClusterLabel])
+	return err == nil && selfManaged
+}
+
+// selfManagedUsername checks the csr is requested by the bootstrap service account of the cluster living in the
+// namespace of the controller on the hub
+func selfManagedUsername(csr *certificatesv1.CertificateSigningRequest, clusterName, podNamespace string) bool {
+	return podNamespace != "" && csr.Spec.Username == fmt.Sprintf(userNameSignature, podNamespace, clusterName)
+}
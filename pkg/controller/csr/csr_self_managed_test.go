@@ -0,0 +1,123 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcileCSR_ReconcileSelfManagedCluster(t *testing.T) {
+	const localClusterName = "local-cluster"
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	newCluster := func(name string, labels map[string]string) *clusterv1.ManagedCluster {
+		return &clusterv1.ManagedCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+			Spec:       clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+		}
+	}
+	newCSR := func(cluster, namespace string) *certificatesv1.CertificateSigningRequest {
+		return &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   csrNameReconcile,
+				Labels: map[string]string{clusterLabel: cluster},
+			},
+			Spec: certificatesv1.CertificateSigningRequestSpec{
+				Username:   fmt.Sprintf(userNameSignature, namespace, cluster),
+				Request:    newCSRRequest(t, clusterCommonNamePrefix+cluster, nil),
+				SignerName: certificatesv1.KubeAPIServerClientSignerName,
+				Usages:     []certificatesv1.KeyUsage{certificatesv1.UsageClientAuth},
+			},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		cluster      *clusterv1.ManagedCluster
+		csr          *certificatesv1.CertificateSigningRequest
+		wantCode     ReasonCode
+		wantApproval string
+	}{
+		{
+			name:         "self managed cluster",
+			cluster:      newCluster(localClusterName, map[string]string{selfManagedClusterLabel: "true"}),
+			csr:          newCSR(localClusterName, testPodNamespace),
+			wantCode:     ReasonAutoApproved,
+			wantApproval: string(certificatesv1.CertificateApproved),
+		},
+		{
+			name:         "self managed cluster in its namespace",
+			cluster:      newCluster(localClusterName, map[string]string{selfManagedClusterLabel: "true"}),
+			csr:          newCSR(localClusterName, localClusterName),
+			wantCode:     ReasonAutoApproved,
+			wantApproval: string(certificatesv1.CertificateApproved),
+		},
+		{
+			name:         "self-managed label false",
+			cluster:      newCluster(localClusterName, map[string]string{selfManagedClusterLabel: "false"}),
+			csr:          newCSR(localClusterName, testPodNamespace),
+			wantCode:     ReasonClusterNamespaceMismatch,
+			wantApproval: string(certificatesv1.CertificateDenied),
+		},
+		{
+			name:         "remote cluster with the controller namespace",
+			cluster:      newCluster(clusterName, nil),
+			csr:          newCSR(clusterName, testPodNamespace),
+			wantCode:     ReasonClusterNamespaceMismatch,
+			wantApproval: string(certificatesv1.CertificateDenied),
+		},
+		{
+			name:     "self managed cluster not found",
+			csr:      newCSR(localClusterName, testPodNamespace),
+			wantCode: ReasonClusterNotFound,
+		},
+		{
+			name:         "remote cluster",
+			cluster:      newCluster(clusterName, nil),
+			csr:          newCSR(clusterName, clusterName),
+			wantCode:     ReasonAutoApproved,
+			wantApproval: string(certificatesv1.CertificateApproved),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := []runtime.Object{tt.csr.DeepCopy()}
+			if tt.cluster != nil {
+				objs = append(objs, tt.cluster)
+			}
+			r := &ReconcileCSR{
+				client:       fake.NewFakeClientWithScheme(testscheme, objs...),
+				kubeClient:   fakeclientset.NewSimpleClientset(tt.csr.DeepCopy()),
+				scheme:       testscheme,
+				podNamespace: testPodNamespace,
+			}
+			if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}}); err != nil {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+			}
+			got, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), csrNameReconcile, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code := got.Annotations[ReasonCodeAnnotation]; code != string(tt.wantCode) {
+				t.Errorf("reason code annotation = %q, want %q", code, tt.wantCode)
+			}
+			if approval := getApprovalType(got); approval != tt.wantApproval {
+				t.Errorf("CSR approval = %q, want %q", approval, tt.wantApproval)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	certificatesv1 "k8s.io/api/certificates/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// serviceAccountExists checks the service account requesting the csr exists on the hub,
+// the csrs not requested by a service account are not verified
+func (r *ReconcileCSR) serviceAccountExists(csr *certificatesv1.CertificateSigningRequest) (bool, error) {
+	namespace, name, ok := parseServiceAccountUsername(csr.Spec.Username)
+	if !ok {
+		return true, nil
+	}
+	ctx, cancel := r.apiCallContext()
+	defer cancel()
+	_, err := r.kubeClient.CoreV1().ServiceAccounts(namespace).Get(ctx, name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
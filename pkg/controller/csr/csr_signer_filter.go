@@ -0,0 +1,49 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	certificatesv1 "k8s.io/api/certificates/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// watchesSigner checks the signer of the csr is one of the CSR_ALLOWED_SIGNERS or has a signer policy, the csrs of
+// the other signers are never approved and are dropped before they are queued. All the signers are watched without
+// allowlist and signer policies.
+func (r *ReconcileCSR) watchesSigner(csr *certificatesv1.CertificateSigningRequest) bool {
+	if r.requestAllowlist == nil && r.signerPolicies == nil {
+		return true
+	}
+	if r.requestAllowlist != nil && containsString(r.requestAllowlist.signers, csr.Spec.SignerName) {
+		return true
+	}
+	_, ok := r.signerPolicies[csr.Spec.SignerName]
+	return ok
+}
+
+// csrPredicateFuncs filters the events of the watched csrs, only the csrs of the watched signers reconciled by r
+// are queued, on their creation and on their updates changing more than their resourceVersion and managedFields
+func csrPredicateFuncs(r *ReconcileCSR) predicate.Funcs {
+	return predicate.Funcs{
+		GenericFunc: func(e event.GenericEvent) bool { return false },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if !changesCSR(e.ObjectOld, e.ObjectNew) {
+				return false
+			}
+			// the new version is evaluated regardless of the old one, so a csr whose creation was dropped because
+			// its cluster name label was set after its creation is queued once it gets the label
+			csr := toV1CSR(e.ObjectNew)
+			return r.watchesSigner(csr) && r.watches(csr)
+		},
+		CreateFunc: func(e event.CreateEvent) bool {
+			csr := toV1CSR(e.Object)
+			if !r.watchesSigner(csr) {
+				return false
+			}
+			r.recordSkippedByPredicate(csr)
+			return r.watches(csr)
+		},
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"testing"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func Test_csrPredicateFuncs(t *testing.T) {
+	const addOnSignerName = "example.com/addon-signer"
+	allowlist := &requestAllowlist{usages: defaultAllowedUsages, signers: defaultAllowedSigners}
+
+	tests := []struct {
+		name             string
+		signerName       string
+		requestAllowlist *requestAllowlist
+		signerPolicies   map[string]signerPolicy
+		want             bool
+	}{
+		{
+			name:             "allowed signer",
+			signerName:       certificatesv1.KubeAPIServerClientSignerName,
+			requestAllowlist: allowlist,
+			want:             true,
+		},
+		{
+			name:             "kubelet serving signer",
+			signerName:       certificatesv1.KubeletServingSignerName,
+			requestAllowlist: allowlist,
+		},
+		{
+			name:             "kube apiserver client kubelet signer",
+			signerName:       certificatesv1.KubeAPIServerClientKubeletSignerName,
+			requestAllowlist: allowlist,
+		},
+		{
+			name:             "signer with a signer policy",
+			signerName:       addOnSignerName,
+			requestAllowlist: allowlist,
+			signerPolicies:   map[string]signerPolicy{addOnSignerName: {}},
+			want:             true,
+		},
+		{
+			name:       "without allowlist and signer policies",
+			signerName: certificatesv1.KubeletServingSignerName,
+			want:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ReconcileCSR{requestAllowlist: tt.requestAllowlist, signerPolicies: tt.signerPolicies}
+			p := csrPredicateFuncs(r)
+			csr := newDedupCSR(csrNameReconcile, nil)
+			csr.Spec.SignerName = tt.signerName
+			if got := p.Create(event.CreateEvent{Meta: csr, Object: csr}); got != tt.want {
+				t.Errorf("CreateFunc() = %v, want %v", got, tt.want)
+			}
+			old := csr.DeepCopy()
+			csr.Annotations = map[string]string{ReasonCodeAnnotation: string(ReasonAutoApproved)}
+			if got := p.Update(event.UpdateEvent{MetaOld: old, ObjectOld: old, MetaNew: csr, ObjectNew: csr}); got != tt.want {
+				t.Errorf("UpdateFunc() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+)
+
+// signerPoliciesEnvVarName is the JSON map of the signer names to the policy of the signer csrs,
+// the csrs of all the signers are evaluated with the same checks if not set
+const signerPoliciesEnvVarName = "CSR_SIGNER_POLICIES"
+
+// clusterCommonNamePrefix prefixes the common name of the certificates requested by the klusterlet of a cluster
+const clusterCommonNamePrefix = "system:open-cluster-management:"
+
+// signerPolicy is the validation of the csrs of a signer, its zero value approves the csrs as
+// the other approval checks decide
+type signerPolicy struct {
+	// Disabled stops the approval of the csrs of the signer
+	Disabled bool `json:"disabled,omitempty"`
+	// AllowedUsages restricts the key usages the csrs can request
+	AllowedUsages []certificatesv1.KeyUsage `json:"allowedUsages,omitempty"`
+	// RequireClusterCommonName requires the common name of the csrs to be system:open-cluster-management:<cluster_name>
+	// or prefixed by system:open-cluster-management:<cluster_name>:
+	RequireClusterCommonName bool `json:"requireClusterCommonName,omitempty"`
+	// SkipChallenge does not verify the bootstrap challenge of the csrs
+	SkipChallenge bool `json:"skipChallenge,omitempty"`
+}
+
+// getSignerPolicies returns the CSR_SIGNER_POLICIES value, nil if not set
+func getSignerPolicies() (map[string]signerPolicy, error) {
+	v := os.Getenv(signerPoliciesEnvVarName)
+	if v == "" {
+		return nil, nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(v))
+	decoder.DisallowUnknownFields()
+	policies := map[string]signerPolicy{}
+	if err := decoder.Decode(&policies); err != nil {
+		return nil, fmt.Errorf("invalid %s value: %v", signerPoliciesEnvVarName, err)
+	}
+	return policies, nil
+}
+
+// signerPolicy returns the policy of the csrs of the signer, false if the csrs of the signer are not approved.
+// Without signer policies all the signers have the default policy, with signer policies the signers
+// without policy are not approved except the bootstrap signer kubernetes.io/kube-apiserver-client
+// which keeps the default policy.
+func (p approvalPolicy) signerPolicy(signerName string) (signerPolicy, bool) {
+	if p.SignerPolicies == nil {
+		return signerPolicy{}, true
+	}
+	if policy, ok := p.SignerPolicies[signerName]; ok {
+		return policy, true
+	}
+	return signerPolicy{}, signerName == certificatesv1.KubeAPIServerClientSignerName
+}
+
+// verify returns an error if the csr of the cluster is not allowed by the signer policy
+func (sp signerPolicy) verify(csr *certificatesv1.CertificateSigningRequest, clusterName string) error {
+	if sp.Disabled {
+		return fmt.Errorf("approval disabled for signer %q", csr.Spec.SignerName)
+	}
+	if len(sp.AllowedUsages) != 0 {
+		for _, usage := range csr.Spec.Usages {
+			if !containsUsage(sp.AllowedUsages, usage) {
+				return fmt.Errorf("usage %q not allowed for signer %q", usage, csr.Spec.SignerName)
+			}
+		}
+	}
+	if sp.RequireClusterCommonName {
+		x509cr, err := parseCertificateRequest(csr)
+		if err != nil {
+			return err
+		}
+		if !isClusterIdentity(x509cr.Subject.CommonName, clusterName) {
+			return fmt.Errorf("common name %q does not belong to cluster %s", x509cr.Subject.CommonName, clusterName)
+		}
+	}
+	return nil
+}
+
+func containsUsage(usages []certificatesv1.KeyUsage, usage certificatesv1.KeyUsage) bool {
+	for _, u := range usages {
+		if u == usage {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,234 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const servingSignerName = "example.com/serving"
+
+func Test_signerPolicy_verify(t *testing.T) {
+	newCSR := func(commonName string, usages ...certificatesv1.KeyUsage) *certificatesv1.CertificateSigningRequest {
+		return &certificatesv1.CertificateSigningRequest{
+			Spec: certificatesv1.CertificateSigningRequestSpec{
+				SignerName: certificatesv1.KubeAPIServerClientSignerName,
+				Request:    newCSRRequest(t, commonName, nil),
+				Usages:     usages,
+			},
+		}
+	}
+	tests := []struct {
+		name    string
+		policy  signerPolicy
+		csr     *certificatesv1.CertificateSigningRequest
+		wantErr bool
+	}{
+		{
+			name:   "default policy",
+			policy: signerPolicy{},
+			csr:    newCSR("anything", certificatesv1.UsageServerAuth),
+		},
+		{
+			name:    "disabled",
+			policy:  signerPolicy{Disabled: true},
+			csr:     newCSR(clusterCommonNamePrefix + clusterName),
+			wantErr: true,
+		},
+		{
+			name:   "allowed usages",
+			policy: signerPolicy{AllowedUsages: []certificatesv1.KeyUsage{certificatesv1.UsageClientAuth, certificatesv1.UsageDigitalSignature}},
+			csr:    newCSR(clusterCommonNamePrefix+clusterName, certificatesv1.UsageClientAuth),
+		},
+		{
+			name:    "usage not allowed",
+			policy:  signerPolicy{AllowedUsages: []certificatesv1.KeyUsage{certificatesv1.UsageClientAuth}},
+			csr:     newCSR(clusterCommonNamePrefix+clusterName, certificatesv1.UsageClientAuth, certificatesv1.UsageServerAuth),
+			wantErr: true,
+		},
+		{
+			name:   "cluster common name",
+			policy: signerPolicy{RequireClusterCommonName: true},
+			csr:    newCSR(clusterCommonNamePrefix + clusterName + ":agent"),
+		},
+		{
+			name:    "common name of another cluster",
+			policy:  signerPolicy{RequireClusterCommonName: true},
+			csr:     newCSR(clusterCommonNamePrefix + "othercluster:agent"),
+			wantErr: true,
+		},
+		{
+			name:    "common name of a cluster prefixed by the cluster name",
+			policy:  signerPolicy{RequireClusterCommonName: true},
+			csr:     newCSR(clusterCommonNamePrefix + clusterName + "bar:agent"),
+			wantErr: true,
+		},
+		{
+			name:   "common name of the cluster identity",
+			policy: signerPolicy{RequireClusterCommonName: true},
+			csr:    newCSR(clusterCommonNamePrefix + clusterName),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.verify(tt.csr, clusterName); (err != nil) != tt.wantErr {
+				t.Errorf("verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_getSignerPolicies(t *testing.T) {
+	defer os.Unsetenv(signerPoliciesEnvVarName)
+	tests := []struct {
+		name    string
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:  "policies",
+			value: `{"kubernetes.io/kube-apiserver-client":{"allowedUsages":["client auth"]},"example.com/serving":{"disabled":true}}`,
+			want:  2,
+		},
+		{
+			name:    "unknown field",
+			value:   `{"example.com/serving":{"enabled":false}}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(signerPoliciesEnvVarName, tt.value)
+			got, err := getSignerPolicies()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getSignerPolicies() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.want {
+				t.Errorf("getSignerPolicies() = %v, want %d policies", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_ReconcileSignerPolicies(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: clusterName,
+		},
+		Spec: clusterv1.ManagedClusterSpec{
+			HubAcceptsClient: true,
+		},
+	}
+
+	tests := []struct {
+		name           string
+		signerPolicies map[string]signerPolicy
+		signerName     string
+		// challengeSecretName has no secret, the challenge verification fails if not skipped
+		challengeSecretName string
+		wantApproved        bool
+	}{
+		{
+			name:         "no signer policies",
+			signerName:   servingSignerName,
+			wantApproved: true,
+		},
+		{
+			name:           "bootstrap signer default policy",
+			signerPolicies: map[string]signerPolicy{servingSignerName: {}},
+			signerName:     certificatesv1.KubeAPIServerClientSignerName,
+			wantApproved:   true,
+		},
+		{
+			name:           "signer without policy",
+			signerPolicies: map[string]signerPolicy{certificatesv1.KubeAPIServerClientSignerName: {}},
+			signerName:     servingSignerName,
+			wantApproved:   false,
+		},
+		{
+			name:           "signer disabled",
+			signerPolicies: map[string]signerPolicy{servingSignerName: {Disabled: true}},
+			signerName:     servingSignerName,
+			wantApproved:   false,
+		},
+		{
+			name: "bootstrap signer disabled",
+			signerPolicies: map[string]signerPolicy{
+				certificatesv1.KubeAPIServerClientSignerName: {Disabled: true},
+			},
+			signerName:   certificatesv1.KubeAPIServerClientSignerName,
+			wantApproved: false,
+		},
+		{
+			name: "signer rules satisfied",
+			signerPolicies: map[string]signerPolicy{
+				servingSignerName: {RequireClusterCommonName: true, AllowedUsages: []certificatesv1.KeyUsage{certificatesv1.UsageServerAuth}},
+			},
+			signerName:   servingSignerName,
+			wantApproved: true,
+		},
+		{
+			name:                "signer skipping the challenge",
+			signerPolicies:      map[string]signerPolicy{servingSignerName: {SkipChallenge: true}},
+			signerName:          servingSignerName,
+			challengeSecretName: challengeSecretName,
+			wantApproved:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr := &certificatesv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: csrNameReconcile,
+					Labels: map[string]string{
+						clusterLabel: clusterName,
+					},
+				},
+				Spec: certificatesv1.CertificateSigningRequestSpec{
+					Username:   fmt.Sprintf(userNameSignature, clusterName, clusterName),
+					SignerName: tt.signerName,
+					Request:    newCSRRequest(t, clusterCommonNamePrefix+clusterName, nil),
+					Usages:     []certificatesv1.KeyUsage{certificatesv1.UsageServerAuth},
+				},
+			}
+			r := &ReconcileCSR{
+				client:              fake.NewFakeClientWithScheme(testscheme, testManagedCluster, csr),
+				kubeClient:          fakeclientset.NewSimpleClientset(csr),
+				scheme:              testscheme,
+				signerPolicies:      tt.signerPolicies,
+				challengeSecretName: tt.challengeSecretName,
+				podNamespace:        testPodNamespace,
+			}
+			if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}}); err != nil {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+			}
+			got, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), csrNameReconcile, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if approved := getApprovalType(got) == string(certificatesv1.CertificateApproved); approved != tt.wantApproved {
+				t.Errorf("CSR approved = %v, want %v", approved, tt.wantApproved)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import certificatesv1 "k8s.io/api/certificates/v1"
+
+// unauthorizedRequest checks the pending csr is labeled for a cluster but its username is neither a bootstrap service
+// account of the cluster nor a bootstrap service account of another cluster namespace
+func unauthorizedRequest(csr *certificatesv1.CertificateSigningRequest, templates usernameTemplates) bool {
+	clusterName := getClusterName(csr)
+	return clusterName != "" &&
+		getAddOnName(csr) == "" &&
+		getApprovalType(csr) == "" &&
+		!validUsername(csr, clusterName, templates) &&
+		!crossNamespaceRequest(csr, clusterName)
+}
+
+// deniableRequest checks the unauthorized request can be denied with denyUnauthorizedCSRs, only the requests of the
+// service accounts are denied. The other users, for example the klusterlet agents renewing their certificates with
+// their cluster identity, are left pending for their approvers.
+func deniableRequest(csr *certificatesv1.CertificateSigningRequest) bool {
+	_, _, ok := parseServiceAccountUsername(csr.Spec.Username)
+	return ok
+}
+
+// watches checks the csr is reconciled, the add-on csrs are reconciled apart and the unauthorized requests are
+// reconciled to be denied with denyUnauthorizedCSRs
+func (r *ReconcileCSR) watches(csr *certificatesv1.CertificateSigningRequest) bool {
+	return csrPredicate(csr, r.usernameTemplates) ||
+		addOnPredicate(csr) ||
+		(r.denyUnauthorizedCSRs && unauthorizedRequest(csr, r.usernameTemplates) && deniableRequest(csr))
+}
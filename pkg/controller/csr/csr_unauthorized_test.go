@@ -0,0 +1,145 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const unauthorizedUsername = "system:serviceaccount:default:intruder"
+
+func TestReconcileCSR_watches(t *testing.T) {
+	unauthorized := newDedupCSR(csrNameReconcile, nil)
+	unauthorized.Spec.Username = unauthorizedUsername
+	crossNamespace := newDedupCSR(csrNameReconcile, nil)
+	crossNamespace.Spec.Username = fmt.Sprintf(userNameSignature, "othercluster", "othercluster")
+	unlabeled := newDedupCSR(csrNameReconcile, nil)
+	unlabeled.Spec.Username = unauthorizedUsername
+	unlabeled.Labels = nil
+	renewal := newDedupCSR(csrNameReconcile, nil)
+	renewal.Spec.Username = clusterCommonNamePrefix + clusterName + ":agent"
+
+	tests := []struct {
+		name                 string
+		csr                  *certificatesv1.CertificateSigningRequest
+		denyUnauthorizedCSRs bool
+		want                 bool
+	}{
+		{
+			name: "bootstrap service account",
+			csr:  newDedupCSR(csrNameReconcile, nil),
+			want: true,
+		},
+		{
+			name: "bootstrap service account of another cluster namespace",
+			csr:  crossNamespace,
+			want: true,
+		},
+		{
+			name: "unauthorized username",
+			csr:  unauthorized,
+		},
+		{
+			name:                 "unauthorized username denied",
+			csr:                  unauthorized,
+			denyUnauthorizedCSRs: true,
+			want:                 true,
+		},
+		{
+			name:                 "unauthorized username without cluster label",
+			csr:                  unlabeled,
+			denyUnauthorizedCSRs: true,
+		},
+		{
+			name:                 "renewal csr of the klusterlet agent",
+			csr:                  renewal,
+			denyUnauthorizedCSRs: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ReconcileCSR{denyUnauthorizedCSRs: tt.denyUnauthorizedCSRs}
+			if got := r.watches(tt.csr); got != tt.want {
+				t.Errorf("watches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_ReconcileUnauthorized(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	tests := []struct {
+		name                 string
+		username             string
+		denyUnauthorizedCSRs bool
+		accepted             bool
+		want                 string
+		wantReason           ReasonCode
+	}{
+		{
+			name:     "ignored by default",
+			accepted: true,
+		},
+		{
+			name:                 "denied",
+			denyUnauthorizedCSRs: true,
+			accepted:             true,
+			want:                 string(certificatesv1.CertificateDenied),
+			wantReason:           ReasonUnauthorizedUsername,
+		},
+		{
+			name:                 "cluster not accepted",
+			denyUnauthorizedCSRs: true,
+			wantReason:           ReasonClusterNotAccepted,
+		},
+		{
+			name:                 "renewal csr of the klusterlet agent left pending",
+			username:             clusterCommonNamePrefix + clusterName + ":agent",
+			denyUnauthorizedCSRs: true,
+			accepted:             true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr := newDedupCSR(csrNameReconcile, newCSRRequest(t, clusterCommonNamePrefix+clusterName, nil))
+			csr.Spec.Username = unauthorizedUsername
+			if tt.username != "" {
+				csr.Spec.Username = tt.username
+			}
+			r := &ReconcileCSR{
+				client:               fake.NewFakeClientWithScheme(testscheme, newAcceptedCluster(tt.accepted), csr),
+				kubeClient:           fakeclientset.NewSimpleClientset(csr),
+				scheme:               testscheme,
+				denyUnauthorizedCSRs: tt.denyUnauthorizedCSRs,
+			}
+			if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}}); err != nil {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+			}
+			got, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), csrNameReconcile,
+				metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if getApprovalType(got) != tt.want {
+				t.Errorf("CSR approval = %q, want %q", getApprovalType(got), tt.want)
+			}
+			if code := got.Annotations[ReasonCodeAnnotation]; code != string(tt.wantReason) {
+				t.Errorf("reason code = %q, want %q", code, tt.wantReason)
+			}
+		})
+	}
+}
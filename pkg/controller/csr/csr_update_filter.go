@@ -0,0 +1,20 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// changesCSR checks the update of the csr from oldObj to newObj changes more than its resourceVersion and its
+// managedFields. The no-op updates, for example the bursts of the resyncs and of the server side applies during a
+// certificate rotation, are dropped before they are queued. The updates of a csr already queued are coalesced by the
+// queue, the csr is reconciled once with its latest version.
+func changesCSR(oldObj, newObj runtime.Object) bool {
+	oldCSR := toV1CSR(oldObj).DeepCopy()
+	newCSR := toV1CSR(newObj).DeepCopy()
+	oldCSR.ResourceVersion, newCSR.ResourceVersion = "", ""
+	oldCSR.ManagedFields, newCSR.ManagedFields = nil, nil
+	return !equality.Semantic.DeepEqual(oldCSR, newCSR)
+}
@@ -0,0 +1,154 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	certificatesv1beta1 "k8s.io/api/certificates/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func Test_changesCSR(t *testing.T) {
+	csr := newDedupCSR(csrNameReconcile, nil)
+	csr.ResourceVersion = "1"
+	csr.Spec.SignerName = certificatesv1.KubeAPIServerClientSignerName
+
+	withResourceVersion := csr.DeepCopy()
+	withResourceVersion.ResourceVersion = "2"
+	withManagedFields := withResourceVersion.DeepCopy()
+	withManagedFields.ManagedFields = []metav1.ManagedFieldsEntry{{Manager: "kube-controller-manager"}}
+	withAnnotation := withResourceVersion.DeepCopy()
+	withAnnotation.Annotations = map[string]string{ReasonCodeAnnotation: string(ReasonClusterNotFound)}
+	withCondition := withResourceVersion.DeepCopy()
+	withCondition.Status.Conditions = []certificatesv1.CertificateSigningRequestCondition{
+		{Type: certificatesv1.CertificateApproved, Status: "True"},
+	}
+	v1beta1CSR := &certificatesv1beta1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: csrNameReconcile, ResourceVersion: "1"},
+	}
+	v1beta1WithResourceVersion := v1beta1CSR.DeepCopy()
+	v1beta1WithResourceVersion.ResourceVersion = "2"
+
+	tests := []struct {
+		name   string
+		oldObj runtime.Object
+		newObj runtime.Object
+		want   bool
+	}{
+		{
+			name:   "resourceVersion only",
+			oldObj: csr,
+			newObj: withResourceVersion,
+		},
+		{
+			name:   "managedFields only",
+			oldObj: csr,
+			newObj: withManagedFields,
+		},
+		{
+			name:   "annotation changed",
+			oldObj: csr,
+			newObj: withAnnotation,
+			want:   true,
+		},
+		{
+			name:   "status changed",
+			oldObj: csr,
+			newObj: withCondition,
+			want:   true,
+		},
+		{
+			name:   "v1beta1 resourceVersion only",
+			oldObj: v1beta1CSR,
+			newObj: v1beta1WithResourceVersion,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := changesCSR(tt.oldObj, tt.newObj); got != tt.want {
+				t.Errorf("changesCSR() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if csr.ResourceVersion != "1" || withManagedFields.ManagedFields == nil {
+		t.Errorf("changesCSR() modified the compared csrs")
+	}
+}
+
+func Test_csrPredicateFuncs_noOpUpdates(t *testing.T) {
+	p := csrPredicateFuncs(&ReconcileCSR{})
+	csr := newDedupCSR(csrNameReconcile, nil)
+	csr.Spec.SignerName = certificatesv1.KubeAPIServerClientSignerName
+	for i := 1; i <= 5; i++ {
+		old := csr.DeepCopy()
+		csr = csr.DeepCopy()
+		csr.ResourceVersion = fmt.Sprintf("%d", i)
+		if p.Update(event.UpdateEvent{MetaOld: old, ObjectOld: old, MetaNew: csr, ObjectNew: csr}) {
+			t.Errorf("UpdateFunc() of the resourceVersion %s = true, want false", csr.ResourceVersion)
+		}
+	}
+}
+
+func Test_csrPredicateFuncs_clusterLabelAddedAfterCreation(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	testManagedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterName},
+		Spec:       clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+	}
+	created := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            csrNameReconcile,
+			ResourceVersion: "1",
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Username:   fmt.Sprintf(userNameSignature, clusterName, clusterName),
+			Request:    newCSRRequest(t, clusterCommonNamePrefix+clusterName, nil),
+			SignerName: certificatesv1.KubeAPIServerClientSignerName,
+			Usages:     []certificatesv1.KeyUsage{certificatesv1.UsageClientAuth},
+		},
+	}
+	labeled := created.DeepCopy()
+	labeled.ResourceVersion = "2"
+	labeled.Labels = map[string]string{clusterLabel: clusterName}
+
+	r := &ReconcileCSR{
+		client:       fake.NewFakeClientWithScheme(testscheme, labeled.DeepCopy(), testManagedCluster),
+		kubeClient:   fakeclientset.NewSimpleClientset(labeled.DeepCopy()),
+		scheme:       testscheme,
+		podNamespace: testPodNamespace,
+	}
+	p := csrPredicateFuncs(r)
+	if p.Create(event.CreateEvent{Meta: created, Object: created}) {
+		t.Errorf("CreateFunc() of the csr without cluster name label = true, want false")
+	}
+	if !p.Update(event.UpdateEvent{MetaOld: created, ObjectOld: created, MetaNew: labeled, ObjectNew: labeled}) {
+		t.Fatalf("UpdateFunc() of the csr getting its cluster name label = false, want true")
+	}
+
+	if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}}); err != nil {
+		t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+	}
+	got, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), csrNameReconcile,
+		metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if approval := getApprovalType(got); approval != string(certificatesv1.CertificateApproved) {
+		t.Errorf("CSR approval = %q, want %q", approval, certificatesv1.CertificateApproved)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// usernameTemplatesEnvVarName is a comma separated list of the templates of the usernames allowed to request the
+// csrs of a cluster, a csr is requested by a bootstrap service account if its username matches one of them
+const usernameTemplatesEnvVarName = "CSR_BOOTSTRAP_USERNAME_TEMPLATES"
+
+// defaultUsernameTemplate is the username of the bootstrap service account living in the cluster namespace
+const defaultUsernameTemplate = "system:serviceaccount:{{.ClusterName}}:{{.ClusterName}}-bootstrap-sa"
+
+var defaultUsernameTemplates = usernameTemplates{
+	template.Must(parseUsernameTemplate(defaultUsernameTemplate)),
+}
+
+// usernameTemplateData holds the variables of the username templates
+type usernameTemplateData struct {
+	ClusterName string
+}
+
+// usernameTemplates are the templates of the usernames of the bootstrap service accounts,
+// nil usernameTemplates are the defaultUsernameTemplates
+type usernameTemplates []*template.Template
+
+// getUsernameTemplates returns the templates of the CSR_BOOTSTRAP_USERNAME_TEMPLATES, nil if not set
+func getUsernameTemplates() (usernameTemplates, error) {
+	return getUsernameTemplatesEnv(usernameTemplatesEnvVarName)
+}
+
+// getUsernameTemplatesEnv returns the templates of the comma separated list of the env var, nil if not set
+func getUsernameTemplatesEnv(envVarName string) (usernameTemplates, error) {
+	v := os.Getenv(envVarName)
+	if v == "" {
+		return nil, nil
+	}
+	templates, err := parseUsernameTemplates(envVarName, strings.Split(v, ","))
+	if err != nil {
+		return nil, err
+	}
+	if len(templates) == 0 {
+		return nil, fmt.Errorf("invalid %s value %q, no template", envVarName, v)
+	}
+	return templates, nil
+}
+
+// parseUsernameTemplates returns the templates of the texts of the source setting, the empty texts are ignored
+func parseUsernameTemplates(source string, texts []string) (usernameTemplates, error) {
+	templates := usernameTemplates{}
+	for _, text := range texts {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		t, err := parseUsernameTemplate(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s template %q: %v", source, text, err)
+		}
+		if _, err := executeUsernameTemplate(t, "cluster"); err != nil {
+			return nil, fmt.Errorf("invalid %s template %q: %v", source, text, err)
+		}
+		templates = append(templates, t)
+	}
+	return templates, nil
+}
+
+func parseUsernameTemplate(text string) (*template.Template, error) {
+	return template.New("username").Option("missingkey=error").Parse(text)
+}
+
+func executeUsernameTemplate(t *template.Template, clusterName string) (string, error) {
+	var b bytes.Buffer
+	if err := t.Execute(&b, usernameTemplateData{ClusterName: clusterName}); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// matches checks the username is the username of one of the templates for the cluster
+func (u usernameTemplates) matches(username, clusterName string) bool {
+	if u == nil {
+		u = defaultUsernameTemplates
+	}
+	for _, t := range u {
+		if expected, err := executeUsernameTemplate(t, clusterName); err == nil && expected == username {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	addOnUsernameTemplate          = "system:serviceaccount:open-cluster-management-addon:{{.ClusterName}}-addon-sa"
+	fixedNamespaceUsernameTemplate = "system:serviceaccount:bootstrap:{{.ClusterName}}-bootstrap-sa"
+)
+
+func newUsernameTemplates(t *testing.T, texts ...string) usernameTemplates {
+	templates := usernameTemplates{}
+	for _, text := range texts {
+		tpl, err := parseUsernameTemplate(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		templates = append(templates, tpl)
+	}
+	return templates
+}
+
+func Test_getUsernameTemplates(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:  "multiple templates",
+			value: defaultUsernameTemplate + ", " + addOnUsernameTemplate,
+			want:  2,
+		},
+		{
+			name:    "unparsable template",
+			value:   "system:serviceaccount:{{.ClusterName",
+			wantErr: true,
+		},
+		{
+			name:    "unknown variable",
+			value:   "system:serviceaccount:{{.Namespace}}:bootstrap-sa",
+			wantErr: true,
+		},
+		{
+			name:    "no template",
+			value:   " , ",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(usernameTemplatesEnvVarName, tt.value)
+			defer os.Unsetenv(usernameTemplatesEnvVarName)
+			got, err := getUsernameTemplates()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getUsernameTemplates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.want {
+				t.Errorf("getUsernameTemplates() = %d templates, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func Test_usernameTemplates_matches(t *testing.T) {
+	templates := newUsernameTemplates(t, defaultUsernameTemplate, addOnUsernameTemplate)
+	tests := []struct {
+		name      string
+		templates usernameTemplates
+		username  string
+		want      bool
+	}{
+		{
+			name:     "default template",
+			username: fmt.Sprintf(userNameSignature, clusterName, clusterName),
+			want:     true,
+		},
+		{
+			name:     "default template other service account",
+			username: "system:serviceaccount:open-cluster-management-addon:" + clusterName + "-addon-sa",
+		},
+		{
+			name:      "first template",
+			templates: templates,
+			username:  fmt.Sprintf(userNameSignature, clusterName, clusterName),
+			want:      true,
+		},
+		{
+			name:      "second template",
+			templates: templates,
+			username:  "system:serviceaccount:open-cluster-management-addon:" + clusterName + "-addon-sa",
+			want:      true,
+		},
+		{
+			name:      "service account of another cluster",
+			templates: templates,
+			username:  "system:serviceaccount:open-cluster-management-addon:othercluster-addon-sa",
+		},
+		{
+			name:      "not a service account",
+			templates: templates,
+			username:  "system:admin",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.templates.matches(tt.username, clusterName); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileCSR_ReconcileUsernameTemplates(t *testing.T) {
+	testscheme := scheme.Scheme
+	testscheme.AddKnownTypes(certificatesv1.SchemeGroupVersion, &certificatesv1.CertificateSigningRequest{})
+	testscheme.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	templates := newUsernameTemplates(t, addOnUsernameTemplate, fixedNamespaceUsernameTemplate)
+	tests := []struct {
+		name       string
+		username   string
+		wantCalled bool
+		want       string
+	}{
+		{
+			name:       "addon service account",
+			username:   "system:serviceaccount:open-cluster-management-addon:" + clusterName + "-addon-sa",
+			wantCalled: true,
+			want:       string(certificatesv1.CertificateApproved),
+		},
+		{
+			name:       "bootstrap service account of a fixed namespace",
+			username:   "system:serviceaccount:bootstrap:" + clusterName + "-bootstrap-sa",
+			wantCalled: true,
+			want:       string(certificatesv1.CertificateApproved),
+		},
+		{
+			name:       "bootstrap service account of another cluster namespace",
+			username:   fmt.Sprintf(userNameSignature, "othercluster", "othercluster"),
+			wantCalled: true,
+			want:       string(certificatesv1.CertificateDenied),
+		},
+		{
+			name:     "bootstrap service account of the cluster namespace",
+			username: fmt.Sprintf(userNameSignature, clusterName, clusterName),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr := newDedupCSR(csrNameReconcile, newCSRRequest(t, clusterCommonNamePrefix+clusterName, nil))
+			csr.Spec.Username = tt.username
+			if got := csrPredicate(csr, templates); got != tt.wantCalled {
+				t.Fatalf("csrPredicate() = %v, want %v", got, tt.wantCalled)
+			}
+			if !tt.wantCalled {
+				return
+			}
+
+			r := &ReconcileCSR{
+				client:            fake.NewFakeClientWithScheme(testscheme, newAcceptedCluster(true), csr),
+				kubeClient:        fakeclientset.NewSimpleClientset(csr),
+				scheme:            testscheme,
+				usernameTemplates: templates,
+			}
+			if _, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: csrNameReconcile}}); err != nil {
+				t.Fatalf("ReconcileCSR.Reconcile() error = %v", err)
+			}
+			got, err := r.kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), csrNameReconcile,
+				metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if getApprovalType(got) != tt.want {
+				t.Errorf("CSR approval = %q, want %q", getApprovalType(got), tt.want)
+			}
+		})
+	}
+}
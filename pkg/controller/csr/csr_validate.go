@@ -0,0 +1,141 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"crypto/x509"
+	"fmt"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+)
+
+// Decision is the outcome of the validation of a bootstrap csr
+type Decision string
+
+const (
+	// DecisionApprove is the decision of the csrs passing all the checks of the validation
+	DecisionApprove Decision = "Approve"
+	// DecisionDeny is the decision of the csrs which must never be approved
+	DecisionDeny Decision = "Deny"
+	// DecisionSkip is the decision of the csrs left pending, they may be approved once their cluster changes or they
+	// are not bootstrap csrs of a cluster
+	DecisionSkip Decision = "Skip"
+)
+
+// Reason explains a Decision, a skipped csr without code is not a bootstrap csr of a cluster
+type Reason struct {
+	Code    ReasonCode
+	Message string
+}
+
+// ValidateOptions are the options of the validation of the bootstrap csrs
+type ValidateOptions struct {
+	// UsernameTemplates are the text/template templates of the usernames of the bootstrap service accounts of a
+	// cluster, for example system:serviceaccount:{{.ClusterName}}:{{.ClusterName}}-bootstrap-sa the default
+	// template if empty
+	UsernameTemplates []string
+	// PodNamespace is the namespace of the bootstrap service account of the self-import of the hub
+	PodNamespace string
+	// DenyUnauthorized denies the csrs of an accepted cluster requested by a service account which is not a bootstrap
+	// service account of the cluster, they are skipped otherwise
+	DenyUnauthorized bool
+	// DenyInvalidRequests denies the csrs with an empty or unparsable request, they are skipped otherwise
+	DenyInvalidRequests bool
+
+	// templates are the parsed UsernameTemplates of the controller
+	templates usernameTemplates
+}
+
+// ValidateBootstrapCSR validates the bootstrap csr of the cluster with the checks of the controller needing no API
+// call: the requesting username, the certificate request and the acceptance of the cluster. cluster is nil if the
+// ManagedCluster of the csr does not exist. The controller approves a csr with the Approve decision once its
+// other checks, for example the approval rules and the signer policies, pass too.
+func ValidateBootstrapCSR(
+	csr *certificatesv1.CertificateSigningRequest,
+	cluster *clusterv1.ManagedCluster,
+	opts ValidateOptions) (Decision, Reason) {
+	decision, reason, _ := validateBootstrapCSR(csr, cluster, opts)
+	return decision, reason
+}
+
+// validateBootstrapCSR returns the decision of ValidateBootstrapCSR and the parsed request of the csr, nil if the
+// csr is not approved
+func validateBootstrapCSR(
+	csr *certificatesv1.CertificateSigningRequest,
+	cluster *clusterv1.ManagedCluster,
+	opts ValidateOptions) (Decision, Reason, *x509.CertificateRequest) {
+	templates := opts.templates
+	if templates == nil && len(opts.UsernameTemplates) > 0 {
+		for _, text := range opts.UsernameTemplates {
+			t, err := parseUsernameTemplate(text)
+			if err != nil {
+				return DecisionSkip, Reason{ReasonConfigurationNotLoaded,
+					fmt.Sprintf("The username template %q is invalid: %v", text, err)}, nil
+			}
+			templates = append(templates, t)
+		}
+	}
+
+	clusterName := getClusterName(csr)
+	switch {
+	case clusterName == "":
+		return DecisionSkip, Reason{Message: "The CSR has no cluster name label"}, nil
+	case getAddOnName(csr) != "":
+		return DecisionSkip, Reason{Message: "The CSR is an add-on CSR"}, nil
+	case getApprovalType(csr) != "":
+		return DecisionSkip, Reason{Message: "The CSR is already " + getApprovalType(csr)}, nil
+	}
+
+	// the bootstrap service account of the hub importing itself lives in the namespace of the controller, its csrs
+	// wait for the cluster to be found to verify it is self-managed
+	if !validUsername(csr, clusterName, templates) && crossNamespaceRequest(csr, clusterName) &&
+		!(selfManagedUsername(csr, clusterName, opts.PodNamespace) && (cluster == nil || isSelfManagedCluster(cluster))) {
+		return DecisionDeny, Reason{ReasonClusterNamespaceMismatch,
+			fmt.Sprintf("The requesting service account %s does not belong to the namespace of cluster %s",
+				csr.Spec.Username, clusterName)}, nil
+	}
+
+	unauthorized := unauthorizedRequest(csr, templates)
+	if unauthorized && !(opts.DenyUnauthorized && deniableRequest(csr)) {
+		return DecisionSkip, Reason{Message: fmt.Sprintf(
+			"The requesting user %s is not a bootstrap service account of cluster %s", csr.Spec.Username, clusterName)}, nil
+	}
+
+	x509cr, err := validateRequest(csr)
+	if err != nil {
+		if opts.DenyInvalidRequests {
+			return DecisionDeny, Reason{ReasonInvalidCertificateRequest, err.Error()}, nil
+		}
+		return DecisionSkip, Reason{ReasonInvalidCertificateRequest, err.Error()}, nil
+	}
+
+	if cluster == nil {
+		return DecisionSkip, Reason{ReasonClusterNotFound,
+			fmt.Sprintf("The ManagedCluster %s does not exist", clusterName)}, nil
+	}
+
+	// the certificates of a cluster are signed once the hub admin accepted it
+	if !cluster.Spec.HubAcceptsClient {
+		return DecisionSkip, Reason{ReasonClusterNotAccepted,
+			fmt.Sprintf("The ManagedCluster %s is not accepted by the hub", clusterName)}, nil
+	}
+
+	if unauthorized {
+		return DecisionDeny, Reason{ReasonUnauthorizedUsername,
+			fmt.Sprintf("The requesting user %s is not a bootstrap service account of cluster %s",
+				csr.Spec.Username, clusterName)}, nil
+	}
+
+	return DecisionApprove, Reason{ReasonAutoApproved, "The CSR is a valid bootstrap CSR of the cluster"}, x509cr
+}
+
+// validateOptions returns the options of the validation of the bootstrap csrs of r
+func (r *ReconcileCSR) validateOptions() ValidateOptions {
+	return ValidateOptions{
+		PodNamespace:        r.podNamespace,
+		DenyUnauthorized:    r.denyUnauthorizedCSRs,
+		DenyInvalidRequests: r.invalidRequestAction == invalidRequestActionDeny,
+		templates:           r.usernameTemplates,
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package csr
+
+import (
+	"fmt"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateBootstrapCSR(t *testing.T) {
+	acceptedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterName},
+		Spec:       clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+	}
+	notAcceptedCluster := &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: clusterName}}
+	selfManagedCluster := acceptedCluster.DeepCopy()
+	selfManagedCluster.Labels = map[string]string{selfManagedClusterLabel: "true"}
+
+	newCSR := func(username string, request []byte) *certificatesv1.CertificateSigningRequest {
+		return &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   csrNameReconcile,
+				Labels: map[string]string{clusterLabel: clusterName},
+			},
+			Spec: certificatesv1.CertificateSigningRequestSpec{
+				Username:   username,
+				Request:    request,
+				SignerName: certificatesv1.KubeAPIServerClientSignerName,
+				Usages:     []certificatesv1.KeyUsage{certificatesv1.UsageClientAuth},
+			},
+		}
+	}
+	request := newCSRRequest(t, clusterCommonNamePrefix+clusterName, nil)
+	bootstrapUsername := fmt.Sprintf(userNameSignature, clusterName, clusterName)
+	crossNamespaceUsername := fmt.Sprintf(userNameSignature, "cluster2", "cluster2")
+	selfImportUsername := fmt.Sprintf(userNameSignature, testPodNamespace, clusterName)
+	unauthorizedUsername := "system:serviceaccount:cluster1:default"
+	approved := newCSR(bootstrapUsername, request)
+	approved.Status.Conditions = []certificatesv1.CertificateSigningRequestCondition{
+		{Type: certificatesv1.CertificateApproved, Status: "True"},
+	}
+	unlabeled := newCSR(bootstrapUsername, request)
+	unlabeled.Labels = nil
+	agentUsernameTemplate := "system:serviceaccount:open-cluster-management-agent:{{.ClusterName}}-bootstrap"
+	agentUsername := "system:serviceaccount:open-cluster-management-agent:" + clusterName + "-bootstrap"
+
+	tests := []struct {
+		name         string
+		csr          *certificatesv1.CertificateSigningRequest
+		cluster      *clusterv1.ManagedCluster
+		opts         ValidateOptions
+		wantDecision Decision
+		wantCode     ReasonCode
+	}{
+		{
+			name:         "valid bootstrap csr",
+			csr:          newCSR(bootstrapUsername, request),
+			cluster:      acceptedCluster,
+			wantDecision: DecisionApprove,
+			wantCode:     ReasonAutoApproved,
+		},
+		{
+			name:         "csr without cluster label",
+			csr:          unlabeled,
+			cluster:      acceptedCluster,
+			wantDecision: DecisionSkip,
+		},
+		{
+			name:         "csr already approved",
+			csr:          approved,
+			cluster:      acceptedCluster,
+			wantDecision: DecisionSkip,
+		},
+		{
+			name:         "csr requested from another cluster namespace",
+			csr:          newCSR(crossNamespaceUsername, request),
+			cluster:      acceptedCluster,
+			wantDecision: DecisionDeny,
+			wantCode:     ReasonClusterNamespaceMismatch,
+		},
+		{
+			name:         "csr of the self-import of the hub",
+			csr:          newCSR(selfImportUsername, request),
+			cluster:      selfManagedCluster,
+			opts:         ValidateOptions{PodNamespace: testPodNamespace},
+			wantDecision: DecisionApprove,
+			wantCode:     ReasonAutoApproved,
+		},
+		{
+			name:         "csr of the self-import of the hub before its cluster is found",
+			csr:          newCSR(selfImportUsername, request),
+			opts:         ValidateOptions{PodNamespace: testPodNamespace},
+			wantDecision: DecisionSkip,
+			wantCode:     ReasonClusterNotFound,
+		},
+		{
+			name:         "csr requested by an unauthorized username",
+			csr:          newCSR(unauthorizedUsername, request),
+			cluster:      acceptedCluster,
+			wantDecision: DecisionSkip,
+		},
+		{
+			name:         "csr requested by an unauthorized username denied",
+			csr:          newCSR(unauthorizedUsername, request),
+			cluster:      acceptedCluster,
+			opts:         ValidateOptions{DenyUnauthorized: true},
+			wantDecision: DecisionDeny,
+			wantCode:     ReasonUnauthorizedUsername,
+		},
+		{
+			name:         "csr with an invalid request",
+			csr:          newCSR(bootstrapUsername, []byte("invalid")),
+			cluster:      acceptedCluster,
+			wantDecision: DecisionSkip,
+			wantCode:     ReasonInvalidCertificateRequest,
+		},
+		{
+			name:         "csr with an invalid request denied",
+			csr:          newCSR(bootstrapUsername, nil),
+			cluster:      acceptedCluster,
+			opts:         ValidateOptions{DenyInvalidRequests: true},
+			wantDecision: DecisionDeny,
+			wantCode:     ReasonInvalidCertificateRequest,
+		},
+		{
+			name:         "cluster not found",
+			csr:          newCSR(bootstrapUsername, request),
+			wantDecision: DecisionSkip,
+			wantCode:     ReasonClusterNotFound,
+		},
+		{
+			name:         "cluster not accepted",
+			csr:          newCSR(bootstrapUsername, request),
+			cluster:      notAcceptedCluster,
+			wantDecision: DecisionSkip,
+			wantCode:     ReasonClusterNotAccepted,
+		},
+		{
+			name:         "username of a custom template",
+			csr:          newCSR(agentUsername, request),
+			cluster:      acceptedCluster,
+			opts:         ValidateOptions{UsernameTemplates: []string{agentUsernameTemplate}},
+			wantDecision: DecisionApprove,
+			wantCode:     ReasonAutoApproved,
+		},
+		{
+			name:         "invalid custom template",
+			csr:          newCSR(bootstrapUsername, request),
+			cluster:      acceptedCluster,
+			opts:         ValidateOptions{UsernameTemplates: []string{"{{.ClusterName"}},
+			wantDecision: DecisionSkip,
+			wantCode:     ReasonConfigurationNotLoaded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, reason := ValidateBootstrapCSR(tt.csr, tt.cluster, tt.opts)
+			if decision != tt.wantDecision {
+				t.Errorf("ValidateBootstrapCSR() decision = %q, want %q (%s)", decision, tt.wantDecision, reason.Message)
+			}
+			if reason.Code != tt.wantCode {
+				t.Errorf("ValidateBootstrapCSR() code = %q, want %q", reason.Code, tt.wantCode)
+			}
+			if reason.Message == "" {
+				t.Errorf("ValidateBootstrapCSR() message is empty")
+			}
+		})
+	}
+}
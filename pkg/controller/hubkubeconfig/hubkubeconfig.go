@@ -0,0 +1,49 @@
+// Copyright Contributors to the Open Cluster Management project
+
+// Package hubkubeconfig loads the kubeconfig of the hub the controllers watch when it is not the cluster the
+// controller runs on, for example in the hosted or multi-hub setups
+package hubkubeconfig
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// SecretEnvVarName is the name of a secret of the namespace of the controller holding the kubeconfig of the hub
+// under the kubeconfig data key, the controllers watch the cluster they run on if not set
+const SecretEnvVarName = "HUB_KUBECONFIG_SECRET"
+
+const kubeconfigSecretKey = "kubeconfig"
+
+// Enabled returns true if the controllers watch the hub of the hub kubeconfig secret
+func Enabled() bool {
+	return os.Getenv(SecretEnvVarName) != ""
+}
+
+// Load returns the config of the kubeconfig of the hub kubeconfig secret of the namespace, read with the
+// kubeClient of the cluster the controller runs on. It returns nil if no secret is set.
+func Load(kubeClient kubernetes.Interface, namespace string) (*rest.Config, error) {
+	name := os.Getenv(SecretEnvVarName)
+	if name == "" {
+		return nil, nil
+	}
+	secret, err := kubeClient.CoreV1().Secrets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the hub kubeconfig secret %s/%s: %v", namespace, name, err)
+	}
+	kubeconfig, ok := secret.Data[kubeconfigSecretKey]
+	if !ok || len(kubeconfig) == 0 {
+		return nil, fmt.Errorf("the hub kubeconfig secret %s/%s has no %s data", namespace, name, kubeconfigSecretKey)
+	}
+	config, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig in the hub kubeconfig secret %s/%s: %v", namespace, name, err)
+	}
+	return config, nil
+}
@@ -0,0 +1,114 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package hubkubeconfig
+
+import (
+	"os"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: hub
+  cluster:
+    server: https://hub.example.com:6443
+contexts:
+- name: hub
+  context:
+    cluster: hub
+    user: admin
+current-context: hub
+users:
+- name: admin
+  user:
+    token: abcdef
+`
+
+func newSecret(name string, data map[string][]byte) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "open-cluster-management",
+		},
+		Data: data,
+	}
+}
+
+func TestLoad(t *testing.T) {
+	kubeClient := fakeclientset.NewSimpleClientset(
+		newSecret("hub-kubeconfig", map[string][]byte{"kubeconfig": []byte(testKubeconfig)}),
+		newSecret("no-kubeconfig", map[string][]byte{"token": []byte("abcdef")}),
+		newSecret("invalid-kubeconfig", map[string][]byte{"kubeconfig": []byte("clusters: [")}),
+	)
+	tests := []struct {
+		name       string
+		secretName string
+		wantHost   string
+		wantErr    bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:       "kubeconfig",
+			secretName: "hub-kubeconfig",
+			wantHost:   "https://hub.example.com:6443",
+		},
+		{
+			name:       "missing secret",
+			secretName: "missing",
+			wantErr:    true,
+		},
+		{
+			name:       "missing kubeconfig",
+			secretName: "no-kubeconfig",
+			wantErr:    true,
+		},
+		{
+			name:       "invalid kubeconfig",
+			secretName: "invalid-kubeconfig",
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(SecretEnvVarName, tt.secretName)
+			defer os.Unsetenv(SecretEnvVarName)
+			if Enabled() != (tt.secretName != "") {
+				t.Errorf("Enabled() = %v, want %v", Enabled(), tt.secretName != "")
+			}
+			got, err := Load(kubeClient, "open-cluster-management")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if tt.wantHost == "" {
+				if got != nil {
+					t.Errorf("Load() = %v, want nil", got)
+				}
+				return
+			}
+			if got == nil || got.Host != tt.wantHost {
+				t.Fatalf("Load() = %v, want host %s", got, tt.wantHost)
+			}
+			if got.BearerToken != "abcdef" {
+				t.Errorf("bearer token = %q, want the token of the kubeconfig", got.BearerToken)
+			}
+			hubClient, err := kubernetes.NewForConfig(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if host := hubClient.Discovery().RESTClient().Get().URL().Host; host != "hub.example.com:6443" {
+				t.Errorf("hub client host = %s, want hub.example.com:6443", host)
+			}
+		})
+	}
+}
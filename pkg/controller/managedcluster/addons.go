@@ -0,0 +1,71 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	addonv1alpha1 "github.com/open-cluster-management/api/addon/v1alpha1"
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// addOnsAnnotation is the comma separated list of the add-ons enabled on the managed cluster once it is joined,
+// a ManagedClusterAddOn is created in the cluster namespace for each of them
+const addOnsAnnotation = "import.open-cluster-management.io/addons"
+
+// getAddOns returns the add-ons of the addOnsAnnotation of the managed cluster, none if not set
+func getAddOns(managedCluster *clusterv1.ManagedCluster) ([]string, error) {
+	v := managedCluster.GetAnnotations()[addOnsAnnotation]
+	addOns := []string{}
+	for _, name := range strings.Split(v, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if errs := validation.IsDNS1123Subdomain(name); len(errs) != 0 {
+			return nil, fmt.Errorf("invalid %s add-on %q: %s", addOnsAnnotation, name, strings.Join(errs, ", "))
+		}
+		addOns = append(addOns, name)
+	}
+	return addOns, nil
+}
+
+// enableAddOns creates the ManagedClusterAddOns of the add-ons of the joined managed cluster which do not exist.
+// The add-ons removed from the annotation are left untouched, they are disabled by deleting their ManagedClusterAddOn.
+func enableAddOns(c client.Client, managedCluster *clusterv1.ManagedCluster) error {
+	if !isJoined(managedCluster) {
+		return nil
+	}
+	addOns, err := getAddOns(managedCluster)
+	if err != nil {
+		return err
+	}
+	for _, name := range addOns {
+		addOn := &addonv1alpha1.ManagedClusterAddOn{}
+		err := c.Get(context.TODO(), types.NamespacedName{Namespace: managedCluster.Name, Name: name}, addOn)
+		if err == nil {
+			continue
+		}
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		addOn = &addonv1alpha1.ManagedClusterAddOn{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: managedCluster.Name,
+			},
+		}
+		if err := c.Create(context.TODO(), addOn); err != nil && !errors.IsAlreadyExists(err) {
+			return err
+		}
+		log.Info("Enabled add-on", "cluster", managedCluster.Name, "addon", name)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	addonv1alpha1 "github.com/open-cluster-management/api/addon/v1alpha1"
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_getAddOns(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        []string
+		wantErr     bool
+	}{
+		{
+			name: "no annotation",
+			want: []string{},
+		},
+		{
+			name:        "add-ons",
+			annotations: map[string]string{addOnsAnnotation: "governance-policy-framework, ,observability-controller"},
+			want:        []string{"governance-policy-framework", "observability-controller"},
+		},
+		{
+			name:        "invalid add-on",
+			annotations: map[string]string{addOnsAnnotation: "Policy_Framework"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			managedCluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "cluster-addons",
+					Annotations: tt.annotations,
+				},
+			}
+			got, err := getAddOns(managedCluster)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getAddOns() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getAddOns() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_enableAddOns(t *testing.T) {
+	s := scheme.Scheme
+	s.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+	s.AddKnownTypes(addonv1alpha1.GroupVersion, &addonv1alpha1.ManagedClusterAddOn{})
+
+	joined := metav1.Condition{
+		Type:   clusterv1.ManagedClusterConditionJoined,
+		Status: metav1.ConditionTrue,
+		Reason: "Test",
+	}
+	existing := &addonv1alpha1.ManagedClusterAddOn{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "observability-controller",
+			Namespace: "cluster-addons",
+			Labels:    map[string]string{"existing": "true"},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		addOns     string
+		conditions []metav1.Condition
+		objects    []runtime.Object
+		want       []string
+		wantAbsent []string
+		wantErr    bool
+	}{
+		{
+			name:       "not joined",
+			addOns:     "governance-policy-framework",
+			wantAbsent: []string{"governance-policy-framework"},
+		},
+		{
+			name:       "joined",
+			addOns:     "governance-policy-framework,observability-controller",
+			conditions: []metav1.Condition{joined},
+			want:       []string{"governance-policy-framework", "observability-controller"},
+		},
+		{
+			name:       "existing add-on",
+			addOns:     "observability-controller",
+			conditions: []metav1.Condition{joined},
+			objects:    []runtime.Object{existing},
+			want:       []string{"observability-controller"},
+		},
+		{
+			name:       "no add-on",
+			conditions: []metav1.Condition{joined},
+		},
+		{
+			name:       "invalid add-on",
+			addOns:     "Policy_Framework",
+			conditions: []metav1.Condition{joined},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			managedCluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "cluster-addons",
+					Annotations: map[string]string{addOnsAnnotation: tt.addOns},
+				},
+				Status: clusterv1.ManagedClusterStatus{
+					Conditions: tt.conditions,
+				},
+			}
+			c := fake.NewFakeClientWithScheme(s, append(tt.objects, managedCluster)...)
+			if err := enableAddOns(c, managedCluster); (err != nil) != tt.wantErr {
+				t.Fatalf("enableAddOns() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, name := range tt.want {
+				addOn := &addonv1alpha1.ManagedClusterAddOn{}
+				if err := c.Get(context.TODO(), types.NamespacedName{Namespace: managedCluster.Name, Name: name},
+					addOn); err != nil {
+					t.Errorf("add-on %s not created: %v", name, err)
+				}
+				if name == existing.Name && tt.objects != nil && addOn.Labels["existing"] != "true" {
+					t.Errorf("the existing add-on %s is replaced", name)
+				}
+			}
+			for _, name := range tt.wantAbsent {
+				err := c.Get(context.TODO(), types.NamespacedName{Namespace: managedCluster.Name, Name: name},
+					&addonv1alpha1.ManagedClusterAddOn{})
+				if !errors.IsNotFound(err) {
+					t.Errorf("add-on %s created before the cluster is joined, error = %v", name, err)
+				}
+			}
+		})
+	}
+}
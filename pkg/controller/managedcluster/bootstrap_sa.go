@@ -11,6 +11,7 @@ import (
 
 	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -39,6 +40,9 @@ func getBootstrapSecret(
 	}
 
 	if err := client.Get(context.TODO(), saNsN, sa); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, &missingPrerequisiteError{reason: reasonMissingBootstrapServiceAccount, err: err}
+		}
 		return nil, err
 	}
 	var secret *corev1.Secret
@@ -66,11 +70,14 @@ func getBootstrapSecret(
 		}
 	}
 	if secret == nil {
-		return nil, fmt.Errorf("secret with prefix %s amd type %s not found in service account %s/%s",
-			managedCluster.Name+bootstrapServiceAccountNamePostfix,
-			corev1.SecretTypeServiceAccountToken,
-			saNsN.Name,
-			managedCluster.Name)
+		return nil, &missingPrerequisiteError{
+			reason: reasonMissingBootstrapServiceAccount,
+			err: fmt.Errorf("secret with prefix %s amd type %s not found in service account %s/%s",
+				managedCluster.Name+bootstrapServiceAccountNamePostfix,
+				corev1.SecretTypeServiceAccountToken,
+				saNsN.Name,
+				managedCluster.Name),
+		}
 	}
 	return secret, nil
 }
@@ -0,0 +1,282 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// bootstrapTokenTTLAnnotation requests a bootstrap token expiring after the given duration for the
+	// ManagedCluster instead of the non expiring token of the bootstrap service account secret
+	bootstrapTokenTTLAnnotation = "import.open-cluster-management.io/bootstrap-token-ttl"
+
+	// bounds of the bootstrap token TTL requested by the bootstrapTokenTTLAnnotation
+	bootstrapTokenMinTTLEnvVarName = "BOOTSTRAP_TOKEN_MIN_TTL"
+	bootstrapTokenMaxTTLEnvVarName = "BOOTSTRAP_TOKEN_MAX_TTL"
+
+	// defaultBootstrapTokenMinTTL is the minimum expiration of a token request accepted by the apiserver
+	defaultBootstrapTokenMinTTL = 10 * time.Minute
+	defaultBootstrapTokenMaxTTL = 30 * 24 * time.Hour
+)
+
+// bootstrapTokenRefreshRatio is the part of the TTL of a bootstrap token after which a new token is requested,
+// the token is reused until then so the import manifests, and the klusterlet, are stable
+const bootstrapTokenRefreshRatio = 0.8
+
+const (
+	// bootstrapTokenSecretPostfix names the secret of the cluster namespace holding the bootstrap token requested for
+	// the ManagedCluster, so the token is reused after a restart of the controller
+	bootstrapTokenSecretPostfix = "-bootstrap-token"
+	// bootstrapTokenRefreshAtAnnotation is the time, in RFC3339, after which a new token is requested, the TTL of the
+	// token is recorded with the bootstrapTokenTTLAnnotation
+	bootstrapTokenRefreshAtAnnotation = "import.open-cluster-management.io/bootstrap-token-refresh-at"
+	bootstrapTokenKey                 = "token"
+)
+
+// bootstrapTokenRequester requests the bootstrap tokens of the ManagedClusters with a bootstrapTokenTTLAnnotation.
+// The bootstrap tokens can not be requested from a nil bootstrapTokenRequester.
+type bootstrapTokenRequester struct {
+	kubeClient kubernetes.Interface
+	minTTL     time.Duration
+	maxTTL     time.Duration
+}
+
+// bootstrapToken is a token requested for a ManagedCluster
+type bootstrapToken struct {
+	token     string
+	ttl       time.Duration
+	refreshAt time.Time
+}
+
+// bootstrapTokenSecretNsN returns the namespace and the name of the bootstrap token secret of the ManagedCluster
+func bootstrapTokenSecretNsN(clusterName string) types.NamespacedName {
+	return types.NamespacedName{Name: clusterName + bootstrapTokenSecretPostfix, Namespace: clusterName}
+}
+
+// parseBootstrapTokenSecret returns the token of the bootstrap token secret, false if the secret is incomplete
+func parseBootstrapTokenSecret(secret *corev1.Secret) (bootstrapToken, bool) {
+	ttl, err := time.ParseDuration(secret.Annotations[bootstrapTokenTTLAnnotation])
+	if err != nil {
+		return bootstrapToken{}, false
+	}
+	refreshAt, err := time.Parse(time.RFC3339, secret.Annotations[bootstrapTokenRefreshAtAnnotation])
+	if err != nil {
+		return bootstrapToken{}, false
+	}
+	token := string(secret.Data[bootstrapTokenKey])
+	if token == "" {
+		return bootstrapToken{}, false
+	}
+	return bootstrapToken{token: token, ttl: ttl, refreshAt: refreshAt}, true
+}
+
+// newBootstrapTokenRequester returns a requester clamping the requested TTLs between minTTL and maxTTL,
+// nil if kubeClient is nil
+func newBootstrapTokenRequester(kubeClient kubernetes.Interface, minTTL, maxTTL time.Duration) *bootstrapTokenRequester {
+	if kubeClient == nil {
+		return nil
+	}
+	return &bootstrapTokenRequester{
+		kubeClient: kubeClient,
+		minTTL:     minTTL,
+		maxTTL:     maxTTL,
+	}
+}
+
+// getBootstrapTokenTTLBounds returns the BOOTSTRAP_TOKEN_MIN_TTL and BOOTSTRAP_TOKEN_MAX_TTL values,
+// the defaults if not set
+func getBootstrapTokenTTLBounds() (minTTL, maxTTL time.Duration, err error) {
+	parse := func(envVarName string, defaultTTL time.Duration) (time.Duration, error) {
+		v := os.Getenv(envVarName)
+		if v == "" {
+			return defaultTTL, nil
+		}
+		ttl, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s value %q: %v", envVarName, v, err)
+		}
+		return ttl, nil
+	}
+	if minTTL, err = parse(bootstrapTokenMinTTLEnvVarName, defaultBootstrapTokenMinTTL); err != nil {
+		return 0, 0, err
+	}
+	if maxTTL, err = parse(bootstrapTokenMaxTTLEnvVarName, defaultBootstrapTokenMaxTTL); err != nil {
+		return 0, 0, err
+	}
+	if minTTL < defaultBootstrapTokenMinTTL {
+		return 0, 0, fmt.Errorf("invalid %s value %s, must be at least %s",
+			bootstrapTokenMinTTLEnvVarName, minTTL, defaultBootstrapTokenMinTTL)
+	}
+	if maxTTL < minTTL {
+		return 0, 0, fmt.Errorf("invalid %s value %s, must be at least %s %s",
+			bootstrapTokenMaxTTLEnvVarName, maxTTL, bootstrapTokenMinTTLEnvVarName, minTTL)
+	}
+	return minTTL, maxTTL, nil
+}
+
+// bootstrapTokenTTL returns the TTL of the bootstrap token of the ManagedCluster clamped between the bounds,
+// 0 if the ManagedCluster has no bootstrapTokenTTLAnnotation
+func (r *bootstrapTokenRequester) bootstrapTokenTTL(managedCluster *clusterv1.ManagedCluster) (time.Duration, error) {
+	v, ok := managedCluster.GetAnnotations()[bootstrapTokenTTLAnnotation]
+	if !ok {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(v)
+	if err != nil || ttl <= 0 {
+		return 0, fmt.Errorf("invalid %s annotation %q, must be a positive duration", bootstrapTokenTTLAnnotation, v)
+	}
+	if ttl < r.minTTL {
+		log.Info("Bootstrap token TTL raised to the minimum", "cluster", managedCluster.Name, "ttl", v, "min", r.minTTL.String())
+		return r.minTTL, nil
+	}
+	if ttl > r.maxTTL {
+		log.Info("Bootstrap token TTL lowered to the maximum", "cluster", managedCluster.Name, "ttl", v, "max", r.maxTTL.String())
+		return r.maxTTL, nil
+	}
+	return ttl, nil
+}
+
+// bootstrapToken returns the bootstrap secret with the token replaced by a token of the bootstrap service account
+// expiring after the TTL of the bootstrapTokenTTLAnnotation, the bootstrap secret if the ManagedCluster has no
+// bootstrapTokenTTLAnnotation
+func (r *bootstrapTokenRequester) bootstrapToken(
+	managedCluster *clusterv1.ManagedCluster,
+	bootstrapSecret *corev1.Secret,
+	now time.Time) (*corev1.Secret, error) {
+	if _, ok := managedCluster.GetAnnotations()[bootstrapTokenTTLAnnotation]; !ok {
+		return bootstrapSecret, nil
+	}
+	if r == nil {
+		return nil, fmt.Errorf("unable to request the bootstrap token of the %s annotation, no kube client",
+			bootstrapTokenTTLAnnotation)
+	}
+	ttl, err := r.bootstrapTokenTTL(managedCluster)
+	if err != nil {
+		return nil, err
+	}
+	token, err := r.requestToken(managedCluster, ttl, now)
+	if err != nil {
+		return nil, err
+	}
+	secret := bootstrapSecret.DeepCopy()
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data["token"] = []byte(token)
+	return secret, nil
+}
+
+// requestToken returns the token of the bootstrap service account of the ManagedCluster, a new token is requested
+// if the TTL changed or the refresh time of the previous token is passed. The token is recorded in the bootstrap
+// token secret of the ManagedCluster, owned by the ManagedCluster.
+func (r *bootstrapTokenRequester) requestToken(
+	managedCluster *clusterv1.ManagedCluster,
+	ttl time.Duration,
+	now time.Time) (string, error) {
+	saNsN, err := bootstrapServiceAccountNsN(managedCluster)
+	if err != nil {
+		return "", err
+	}
+	secretNsN := bootstrapTokenSecretNsN(managedCluster.Name)
+	secrets := r.kubeClient.CoreV1().Secrets(secretNsN.Namespace)
+	secret, err := secrets.Get(context.TODO(), secretNsN.Name, metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return "", err
+	}
+	found := err == nil
+	if found {
+		if cached, ok := parseBootstrapTokenSecret(secret); ok && cached.ttl == ttl && now.Before(cached.refreshAt) {
+			return cached.token, nil
+		}
+	}
+
+	expirationSeconds := int64(ttl.Seconds())
+	tokenRequest, err := r.kubeClient.CoreV1().ServiceAccounts(saNsN.Namespace).CreateToken(
+		context.TODO(),
+		saNsN.Name,
+		&authenticationv1.TokenRequest{
+			Spec: authenticationv1.TokenRequestSpec{
+				ExpirationSeconds: &expirationSeconds,
+			},
+		},
+		metav1.CreateOptions{},
+	)
+	if err != nil {
+		return "", err
+	}
+	log.Info("Bootstrap token requested", "cluster", managedCluster.Name, "ttl", ttl.String())
+
+	refreshAt := now.Add(time.Duration(float64(ttl) * bootstrapTokenRefreshRatio))
+	if !found {
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      secretNsN.Name,
+				Namespace: secretNsN.Namespace,
+				Labels:    map[string]string{clusterLabel: managedCluster.Name},
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(managedCluster, clusterv1.SchemeGroupVersion.WithKind("ManagedCluster")),
+				},
+			},
+		}
+	}
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[bootstrapTokenTTLAnnotation] = ttl.String()
+	secret.Annotations[bootstrapTokenRefreshAtAnnotation] = refreshAt.UTC().Format(time.RFC3339)
+	secret.Data = map[string][]byte{bootstrapTokenKey: []byte(tokenRequest.Status.Token)}
+	if !found {
+		_, err = secrets.Create(context.TODO(), secret, metav1.CreateOptions{})
+	} else {
+		_, err = secrets.Update(context.TODO(), secret, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return "", err
+	}
+	return tokenRequest.Status.Token, nil
+}
+
+// bootstrapTokenRefreshAfter returns the duration after which the bootstrap token of the ManagedCluster is refreshed,
+// 0 if the ManagedCluster has no bootstrap token or if its refresh time is passed
+func bootstrapTokenRefreshAfter(c client.Client, clusterName string, now time.Time) (time.Duration, error) {
+	secret := &corev1.Secret{}
+	if err := c.Get(context.TODO(), bootstrapTokenSecretNsN(clusterName), secret); err != nil {
+		if errors.IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	token, ok := parseBootstrapTokenSecret(secret)
+	if !ok || !now.Before(token.refreshAt) {
+		return 0, nil
+	}
+	return token.refreshAt.Sub(now), nil
+}
+
+// requeueAtBootstrapTokenRefresh requeues the ManagedCluster at the latest at the refresh of its bootstrap token,
+// so its import manifests get a new token before the token expires
+func (r *ReconcileManagedCluster) requeueAtBootstrapTokenRefresh(clusterName string, result reconcile.Result) reconcile.Result {
+	refreshAfter, err := bootstrapTokenRefreshAfter(r.client, clusterName, time.Now())
+	if err != nil {
+		log.Error(err, "failed to get the refresh time of the bootstrap token", "cluster", clusterName)
+		return result
+	}
+	if refreshAfter > 0 && (result.RequeueAfter == 0 || refreshAfter < result.RequeueAfter) {
+		result.RequeueAfter = refreshAfter
+	}
+	return result
+}
@@ -0,0 +1,325 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func Test_getBootstrapTokenTTLBounds(t *testing.T) {
+	tests := []struct {
+		name    string
+		minTTL  string
+		maxTTL  string
+		wantMin time.Duration
+		wantMax time.Duration
+		wantErr bool
+	}{
+		{
+			name:    "defaults",
+			wantMin: defaultBootstrapTokenMinTTL,
+			wantMax: defaultBootstrapTokenMaxTTL,
+		},
+		{
+			name:    "valid",
+			minTTL:  "1h",
+			maxTTL:  "48h",
+			wantMin: time.Hour,
+			wantMax: 48 * time.Hour,
+		},
+		{
+			name:    "invalid duration",
+			minTTL:  "one hour",
+			wantErr: true,
+		},
+		{
+			name:    "minimum below the apiserver minimum",
+			minTTL:  "1m",
+			wantErr: true,
+		},
+		{
+			name:    "maximum below the minimum",
+			minTTL:  "2h",
+			maxTTL:  "1h",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(bootstrapTokenMinTTLEnvVarName, tt.minTTL)
+			os.Setenv(bootstrapTokenMaxTTLEnvVarName, tt.maxTTL)
+			defer os.Unsetenv(bootstrapTokenMinTTLEnvVarName)
+			defer os.Unsetenv(bootstrapTokenMaxTTLEnvVarName)
+			gotMin, gotMax, err := getBootstrapTokenTTLBounds()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getBootstrapTokenTTLBounds() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if gotMin != tt.wantMin || gotMax != tt.wantMax {
+				t.Errorf("getBootstrapTokenTTLBounds() = %v, %v, want %v, %v", gotMin, gotMax, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
+
+func Test_bootstrapTokenRequester_bootstrapToken(t *testing.T) {
+	bootstrapSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "cluster1-bootstrap-sa-token-abcde",
+			Namespace: "cluster1",
+		},
+		Data: map[string][]byte{
+			"token":  []byte("legacy-token"),
+			"ca.crt": []byte("ca"),
+		},
+		Type: corev1.SecretTypeServiceAccountToken,
+	}
+	newManagedCluster := func(ttl string) *clusterv1.ManagedCluster {
+		managedCluster := &clusterv1.ManagedCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "cluster1",
+			},
+		}
+		if ttl != "" {
+			managedCluster.Annotations = map[string]string{bootstrapTokenTTLAnnotation: ttl}
+		}
+		return managedCluster
+	}
+
+	tests := []struct {
+		name           string
+		managedCluster *clusterv1.ManagedCluster
+		noKubeClient   bool
+		wantTTL        time.Duration
+		wantToken      string
+		wantErr        bool
+	}{
+		{
+			name:           "no ttl",
+			managedCluster: newManagedCluster(""),
+			wantToken:      "legacy-token",
+		},
+		{
+			name:           "ttl",
+			managedCluster: newManagedCluster("2h"),
+			wantTTL:        2 * time.Hour,
+			wantToken:      "bound-token",
+		},
+		{
+			name:           "ttl clamped to the minimum",
+			managedCluster: newManagedCluster("1m"),
+			wantTTL:        30 * time.Minute,
+			wantToken:      "bound-token",
+		},
+		{
+			name:           "ttl clamped to the maximum",
+			managedCluster: newManagedCluster("240h"),
+			wantTTL:        24 * time.Hour,
+			wantToken:      "bound-token",
+		},
+		{
+			name:           "invalid ttl",
+			managedCluster: newManagedCluster("-1h"),
+			wantErr:        true,
+		},
+		{
+			name:           "no kube client",
+			managedCluster: newManagedCluster("2h"),
+			noKubeClient:   true,
+			wantErr:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requestedTTL time.Duration
+			kubeClient := fakeclientset.NewSimpleClientset()
+			kubeClient.PrependReactor("create", "serviceaccounts",
+				func(action clienttesting.Action) (bool, runtime.Object, error) {
+					create := action.(clienttesting.CreateAction)
+					if create.GetSubresource() != "token" || create.GetNamespace() != "cluster1" {
+						t.Errorf("unexpected action %v", action)
+					}
+					tokenRequest := create.GetObject().(*authenticationv1.TokenRequest)
+					requestedTTL = time.Duration(*tokenRequest.Spec.ExpirationSeconds) * time.Second
+					tokenRequest.Status.Token = "bound-token"
+					return true, tokenRequest, nil
+				})
+			requester := newBootstrapTokenRequester(kubeClient, 30*time.Minute, 24*time.Hour)
+			if tt.noKubeClient {
+				requester = newBootstrapTokenRequester(nil, 30*time.Minute, 24*time.Hour)
+			}
+
+			got, err := requester.bootstrapToken(tt.managedCluster, bootstrapSecret, time.Now())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("bootstrapToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if token := string(got.Data["token"]); token != tt.wantToken {
+				t.Errorf("bootstrap token = %q, want %q", token, tt.wantToken)
+			}
+			if requestedTTL != tt.wantTTL {
+				t.Errorf("requested ttl = %v, want %v", requestedTTL, tt.wantTTL)
+			}
+			if string(bootstrapSecret.Data["token"]) != "legacy-token" {
+				t.Error("the bootstrap secret must not be modified")
+			}
+		})
+	}
+}
+
+func Test_bootstrapTokenRequester_requestToken(t *testing.T) {
+	requests := 0
+	kubeClient := fakeclientset.NewSimpleClientset()
+	kubeClient.PrependReactor("create", "serviceaccounts",
+		func(action clienttesting.Action) (bool, runtime.Object, error) {
+			requests++
+			tokenRequest := action.(clienttesting.CreateAction).GetObject().(*authenticationv1.TokenRequest)
+			tokenRequest.Status.Token = fmt.Sprintf("token-%d", requests)
+			return true, tokenRequest, nil
+		})
+	requester := newBootstrapTokenRequester(kubeClient, defaultBootstrapTokenMinTTL, defaultBootstrapTokenMaxTTL)
+	managedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster1",
+		},
+	}
+	issuedAt := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		name      string
+		restart   bool
+		ttl       time.Duration
+		now       time.Time
+		wantToken string
+	}{
+		{
+			name:      "first request",
+			ttl:       10 * time.Hour,
+			now:       issuedAt,
+			wantToken: "token-1",
+		},
+		{
+			name:      "reused before the refresh",
+			ttl:       10 * time.Hour,
+			now:       issuedAt.Add(7 * time.Hour),
+			wantToken: "token-1",
+		},
+		{
+			name:      "reused after a restart of the controller",
+			restart:   true,
+			ttl:       10 * time.Hour,
+			now:       issuedAt.Add(7 * time.Hour),
+			wantToken: "token-1",
+		},
+		{
+			name:      "refreshed",
+			ttl:       10 * time.Hour,
+			now:       issuedAt.Add(8 * time.Hour),
+			wantToken: "token-2",
+		},
+		{
+			name:      "ttl changed",
+			ttl:       time.Hour,
+			now:       issuedAt.Add(8 * time.Hour),
+			wantToken: "token-3",
+		},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if step.restart {
+				requester = newBootstrapTokenRequester(kubeClient, defaultBootstrapTokenMinTTL, defaultBootstrapTokenMaxTTL)
+			}
+			got, err := requester.requestToken(managedCluster, step.ttl, step.now)
+			if err != nil {
+				t.Fatalf("requestToken() error = %v", err)
+			}
+			if got != step.wantToken {
+				t.Errorf("requestToken() = %q, want %q", got, step.wantToken)
+			}
+			secret, err := kubeClient.CoreV1().Secrets("cluster1").Get(context.TODO(),
+				"cluster1"+bootstrapTokenSecretPostfix, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get the bootstrap token secret: %v", err)
+			}
+			if token, ok := parseBootstrapTokenSecret(secret); !ok || token.token != step.wantToken || token.ttl != step.ttl {
+				t.Errorf("bootstrap token secret = %v, want the token %q of ttl %v", secret, step.wantToken, step.ttl)
+			}
+		})
+	}
+}
+
+func TestReconcileManagedCluster_requeueAtBootstrapTokenRefresh(t *testing.T) {
+	now := time.Now()
+	newTokenSecret := func(refreshAt time.Time) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "cluster1" + bootstrapTokenSecretPostfix,
+				Namespace: "cluster1",
+				Annotations: map[string]string{
+					bootstrapTokenTTLAnnotation:       "10h",
+					bootstrapTokenRefreshAtAnnotation: refreshAt.UTC().Format(time.RFC3339),
+				},
+			},
+			Data: map[string][]byte{bootstrapTokenKey: []byte("token-1")},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		secret *corev1.Secret
+		result reconcile.Result
+		want   reconcile.Result
+	}{
+		{
+			name: "no bootstrap token",
+			want: reconcile.Result{},
+		},
+		{
+			name:   "requeued at the refresh",
+			secret: newTokenSecret(now.Add(2 * time.Hour)),
+			want:   reconcile.Result{RequeueAfter: 2 * time.Hour},
+		},
+		{
+			name:   "requeued earlier",
+			secret: newTokenSecret(now.Add(2 * time.Hour)),
+			result: reconcile.Result{Requeue: true, RequeueAfter: time.Minute},
+			want:   reconcile.Result{Requeue: true, RequeueAfter: time.Minute},
+		},
+		{
+			name:   "refresh passed",
+			secret: newTokenSecret(now.Add(-time.Hour)),
+			want:   reconcile.Result{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := []runtime.Object{}
+			if tt.secret != nil {
+				objs = append(objs, tt.secret)
+			}
+			r := &ReconcileManagedCluster{client: fake.NewFakeClientWithScheme(scheme.Scheme, objs...)}
+			got := r.requeueAtBootstrapTokenRefresh("cluster1", tt.result)
+			// the refresh time is recorded to the second
+			if got.Requeue != tt.want.Requeue || got.RequeueAfter < tt.want.RequeueAfter-time.Second ||
+				got.RequeueAfter > tt.want.RequeueAfter {
+				t.Errorf("requeueAtBootstrapTokenRefresh() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// clockSkewThresholdEnvVarName is the maximum hub-spoke clock skew tolerated during the import,
+// the clock skew is not checked if not set
+const clockSkewThresholdEnvVarName = "CLOCK_SKEW_THRESHOLD"
+
+// ClockSkewDetected is a warning condition of the ManagedCluster set when the clock of the managed cluster
+// differs from the hub clock by more than the threshold, certificates and tokens may then be rejected
+const ClockSkewDetected = "ClockSkewDetected"
+
+const (
+	reasonClockSkewed = "ClockSkewed"
+	reasonClockSynced = "ClockSynchronized"
+)
+
+// getClockSkewThreshold returns the CLOCK_SKEW_THRESHOLD value, 0 if not set
+func getClockSkewThreshold() (time.Duration, error) {
+	v := os.Getenv(clockSkewThresholdEnvVarName)
+	if v == "" {
+		return 0, nil
+	}
+	threshold, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value %q: %v", clockSkewThresholdEnvVarName, v, err)
+	}
+	return threshold, nil
+}
+
+// measureClockSkew returns the skew of the managed cluster clock relative to the hub clock, using the Date
+// header of the managed cluster apiserver. The skew is measured at the second, the Date header resolution.
+func measureClockSkew(rConfig *rest.Config) (time.Duration, error) {
+	transport, err := rest.TransportFor(rConfig)
+	if err != nil {
+		return 0, err
+	}
+	httpClient := &http.Client{Transport: transport, Timeout: 10 * time.Second}
+
+	sent := time.Now()
+	resp, err := httpClient.Get(strings.TrimSuffix(rConfig.Host, "/") + "/version")
+	if err != nil {
+		return 0, err
+	}
+	received := time.Now()
+	resp.Body.Close()
+
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid Date header %q: %v", resp.Header.Get("Date"), err)
+	}
+	// the managed cluster stamped the response between sent and received
+	hubTime := sent.Add(received.Sub(sent) / 2).Truncate(time.Second)
+	return date.Sub(hubTime), nil
+}
+
+// clockSkewCondition returns the ClockSkewDetected condition of the measured skew
+func clockSkewCondition(skew, threshold time.Duration) metav1.Condition {
+	abs := skew
+	if abs < 0 {
+		abs = -abs
+	}
+	if abs > threshold {
+		return metav1.Condition{
+			Type:   ClockSkewDetected,
+			Status: metav1.ConditionTrue,
+			Message: fmt.Sprintf("The managed cluster clock differs from the hub clock by %s, more than %s",
+				skew, threshold),
+			Reason: reasonClockSkewed,
+		}
+	}
+	return metav1.Condition{
+		Type:    ClockSkewDetected,
+		Status:  metav1.ConditionFalse,
+		Message: "The managed cluster clock is synchronized with the hub clock",
+		Reason:  reasonClockSynced,
+	}
+}
+
+// setConditionClockSkew updates the ClockSkewDetected condition of the managed cluster
+func (r *ReconcileManagedCluster) setConditionClockSkew(managedCluster *clusterv1.ManagedCluster, skew time.Duration) error {
+	newCondition := clockSkewCondition(skew, r.clockSkewThreshold)
+	if condition := meta.FindStatusCondition(managedCluster.Status.Conditions, newCondition.Type); condition != nil &&
+		condition.Status == newCondition.Status && condition.Reason == newCondition.Reason {
+		return nil
+	}
+
+	patch := client.MergeFrom(managedCluster.DeepCopy())
+	meta.SetStatusCondition(&managedCluster.Status.Conditions, newCondition)
+	return r.client.Status().Patch(context.TODO(), managedCluster, patch)
+}
+
+// checkClockSkew measures the clock skew of the managed cluster and updates its ClockSkewDetected condition,
+// the check is a warning and never fails the import
+func (r *ReconcileManagedCluster) checkClockSkew(managedCluster *clusterv1.ManagedCluster, rConfig *rest.Config) {
+	if r.clockSkewThreshold <= 0 {
+		return
+	}
+	skew, err := measureClockSkew(rConfig)
+	if err != nil {
+		log.Error(err, "Unable to measure the clock skew", "cluster", managedCluster.Name)
+		return
+	}
+	if err := r.setConditionClockSkew(managedCluster, skew); err != nil {
+		log.Error(err, "Unable to update the clock skew condition", "cluster", managedCluster.Name)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newSkewedAPIServer returns a server answering with a Date header skewed by skew
+func newSkewedAPIServer(skew time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Date", time.Now().Add(skew).UTC().Format(http.TimeFormat))
+		w.Write([]byte(`{"gitVersion":"v1.20.0"}`))
+	}))
+}
+
+func TestReconcileManagedCluster_checkClockSkew(t *testing.T) {
+	s := scheme.Scheme
+	s.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	tests := []struct {
+		name       string
+		skew       time.Duration
+		threshold  time.Duration
+		wantStatus metav1.ConditionStatus
+		wantReason string
+	}{
+		{
+			name:      "check disabled",
+			skew:      time.Hour,
+			threshold: 0,
+		},
+		{
+			name:       "synced clocks",
+			skew:       0,
+			threshold:  30 * time.Second,
+			wantStatus: metav1.ConditionFalse,
+			wantReason: reasonClockSynced,
+		},
+		{
+			name:       "spoke clock ahead",
+			skew:       5 * time.Minute,
+			threshold:  30 * time.Second,
+			wantStatus: metav1.ConditionTrue,
+			wantReason: reasonClockSkewed,
+		},
+		{
+			name:       "spoke clock behind",
+			skew:       -5 * time.Minute,
+			threshold:  30 * time.Second,
+			wantStatus: metav1.ConditionTrue,
+			wantReason: reasonClockSkewed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newSkewedAPIServer(tt.skew)
+			defer server.Close()
+
+			managedCluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "cluster-clock-skew",
+				},
+			}
+			r := &ReconcileManagedCluster{
+				client:             fake.NewFakeClientWithScheme(s, managedCluster),
+				scheme:             s,
+				clockSkewThreshold: tt.threshold,
+			}
+			r.checkClockSkew(managedCluster, &rest.Config{Host: server.URL})
+
+			mc := &clusterv1.ManagedCluster{}
+			if err := r.client.Get(context.TODO(), types.NamespacedName{Name: managedCluster.Name}, mc); err != nil {
+				t.Fatal(err)
+			}
+			condition := meta.FindStatusCondition(mc.Status.Conditions, ClockSkewDetected)
+			if tt.wantStatus == "" {
+				if condition != nil {
+					t.Errorf("unexpected condition %s", ClockSkewDetected)
+				}
+				return
+			}
+			if condition == nil {
+				t.Fatalf("condition %s not found", ClockSkewDetected)
+			}
+			if condition.Status != tt.wantStatus || condition.Reason != tt.wantReason {
+				t.Errorf("condition = %s/%s, want %s/%s", condition.Status, condition.Reason, tt.wantStatus, tt.wantReason)
+			}
+		})
+	}
+}
+
+func Test_getClockSkewThreshold(t *testing.T) {
+	defer os.Unsetenv(clockSkewThresholdEnvVarName)
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "", want: 0},
+		{value: "1m", want: time.Minute},
+		{value: "one minute", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			os.Setenv(clockSkewThresholdEnvVarName, tt.value)
+			got, err := getClockSkewThreshold()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getClockSkewThreshold() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("getClockSkewThreshold() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+)
+
+// importSecretLabel marks the import secrets generated by the controller, their clusterLabel is the name of the
+// managed cluster they were generated for
+const importSecretLabel = "import.open-cluster-management.io/import-secret"
+
+// clusterRenamed returns the previous cluster name associated to the cluster namespace
+// and true if it differs from the current managedCluster name
+func clusterRenamed(managedCluster *clusterv1.ManagedCluster, ns *corev1.Namespace) (string, bool) {
+	oldName := ns.GetLabels()[clusterLabel]
+	return oldName, oldName != "" && oldName != managedCluster.Name
+}
+
+// deleteStaleImportSecrets deletes the import secrets which were generated for another cluster name than
+// the current managedCluster name.
+// oldName is the previous cluster name if known, its import secret is deleted even if it is not labeled.
+// The labeled import secrets of the cluster namespace are only looked up when the import secrets are stored
+// in the cluster namespaces, a shared namespace holds the import secrets of the other clusters. Only the secrets
+// carrying the importSecretLabel and named after the cluster of their clusterLabel are deleted, the other secrets
+// of the namespace are not owned by the controller.
+func deleteStaleImportSecrets(
+	c client.Client,
+	location *importSecretLocation,
+	managedCluster *clusterv1.ManagedCluster,
+	oldName string) error {
+	current, err := location.importSecretNsN(managedCluster)
+	if err != nil {
+		return err
+	}
+
+	staleSecrets := map[types.NamespacedName]bool{}
+	if oldName != "" && oldName != managedCluster.Name {
+		stale, err := location.staleImportSecretNsN(managedCluster, oldName)
+		if err != nil {
+			return err
+		}
+		staleSecrets[stale] = true
+	}
+
+	if location.inClusterNamespace(managedCluster) {
+		secrets := &corev1.SecretList{}
+		if err := c.List(context.TODO(), secrets,
+			client.InNamespace(managedCluster.Name),
+			client.MatchingLabels{importSecretLabel: "true"},
+			client.HasLabels{clusterLabel}); err != nil {
+			return err
+		}
+		for _, secret := range secrets.Items {
+			name := secret.Labels[clusterLabel]
+			if name != managedCluster.Name && secret.Name == name+importSecretNamePostfix {
+				staleSecrets[types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace}] = true
+			}
+		}
+	}
+
+	for nsN := range staleSecrets {
+		if nsN == current {
+			continue
+		}
+		log.Info("Delete stale import secret", "name", nsN.Name, "namespace", nsN.Namespace)
+		secret := &corev1.Secret{}
+		secret.Name = nsN.Name
+		secret.Namespace = nsN.Namespace
+		if err := c.Delete(context.TODO(), secret); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
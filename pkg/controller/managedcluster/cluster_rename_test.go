@@ -0,0 +1,160 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"context"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func Test_clusterRenamed(t *testing.T) {
+	managedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster-new",
+		},
+	}
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		wantOldName string
+		wantRenamed bool
+	}{
+		{
+			name:        "namespace not labeled",
+			wantOldName: "",
+			wantRenamed: false,
+		},
+		{
+			name:        "same name",
+			labels:      map[string]string{clusterLabel: "cluster-new"},
+			wantOldName: "cluster-new",
+			wantRenamed: false,
+		},
+		{
+			name:        "renamed",
+			labels:      map[string]string{clusterLabel: "cluster-old"},
+			wantOldName: "cluster-old",
+			wantRenamed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   managedCluster.Name,
+					Labels: tt.labels,
+				},
+			}
+			oldName, renamed := clusterRenamed(managedCluster, ns)
+			if oldName != tt.wantOldName || renamed != tt.wantRenamed {
+				t.Errorf("clusterRenamed() = %s, %v, want %s, %v", oldName, renamed, tt.wantOldName, tt.wantRenamed)
+			}
+		})
+	}
+}
+
+func Test_deleteStaleImportSecretsRename(t *testing.T) {
+	managedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster-renamed",
+		},
+	}
+	newSecret := func(name string, labels map[string]string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: managedCluster.Name,
+				Labels:    labels,
+			},
+		}
+	}
+	oldImportSecret := newSecret("cluster-old"+importSecretNamePostfix, nil)
+	labeledImportSecret := newSecret("cluster-older"+importSecretNamePostfix,
+		map[string]string{clusterLabel: "cluster-older", importSecretLabel: "true"})
+	otherSecret := newSecret("other", nil)
+	// the secrets of other owners labeled with a cluster name but not generated by the controller
+	clusterNameSecret := newSecret("cluster-other"+importSecretNamePostfix,
+		map[string]string{"open-cluster-management.io/cluster-name": "cluster-other"})
+	renamedSecret := newSecret("credentials", map[string]string{clusterLabel: "cluster-other", importSecretLabel: "true"})
+
+	c := newRenderFakeClient(t, managedCluster, oldImportSecret, labeledImportSecret, otherSecret, clusterNameSecret,
+		renamedSecret)
+	if err := deleteStaleImportSecrets(c, nil, managedCluster, "cluster-old"); err != nil {
+		t.Fatalf("deleteStaleImportSecrets() error = %v", err)
+	}
+	crds, yamls, err := generateImportYAMLs(c, nil, nil, managedCluster, []string{})
+	if err != nil {
+		t.Fatalf("generateImportYAMLs() error = %v", err)
+	}
+	if _, err := createOrUpdateImportSecret(c, scheme.Scheme, nil, managedCluster, crds, yamls); err != nil {
+		t.Fatalf("createOrUpdateImportSecret() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		secretName string
+		wantExist  bool
+	}{
+		{
+			name:       "import secret of the previous name is deleted",
+			secretName: oldImportSecret.Name,
+			wantExist:  false,
+		},
+		{
+			name:       "import secret labeled for another name is deleted",
+			secretName: labeledImportSecret.Name,
+			wantExist:  false,
+		},
+		{
+			name:       "other secrets are kept",
+			secretName: otherSecret.Name,
+			wantExist:  true,
+		},
+		{
+			name:       "secret labeled with another cluster name but not an import secret is kept",
+			secretName: clusterNameSecret.Name,
+			wantExist:  true,
+		},
+		{
+			name:       "import labeled secret not named after its cluster is kept",
+			secretName: renamedSecret.Name,
+			wantExist:  true,
+		},
+		{
+			name:       "import secret of the new name is created",
+			secretName: managedCluster.Name + importSecretNamePostfix,
+			wantExist:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := &corev1.Secret{}
+			err := c.Get(context.TODO(), types.NamespacedName{Name: tt.secretName, Namespace: managedCluster.Name}, secret)
+			if err != nil && !errors.IsNotFound(err) {
+				t.Fatal(err)
+			}
+			if exist := err == nil; exist != tt.wantExist {
+				t.Errorf("secret %s exist = %v, want %v", tt.secretName, exist, tt.wantExist)
+			}
+		})
+	}
+
+	importSecret := &corev1.Secret{}
+	if err := c.Get(context.TODO(), types.NamespacedName{
+		Name:      managedCluster.Name + importSecretNamePostfix,
+		Namespace: managedCluster.Name,
+	}, importSecret); err != nil {
+		t.Fatal(err)
+	}
+	if importSecret.Labels[clusterLabel] != managedCluster.Name || importSecret.Labels[importSecretLabel] != "true" {
+		t.Errorf("import secret labels = %v, want %s=%s and %s=true", importSecret.Labels, clusterLabel,
+			managedCluster.Name, importSecretLabel)
+	}
+}
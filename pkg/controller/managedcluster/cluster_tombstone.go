@@ -0,0 +1,127 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// clusterTombstoneTTLEnvVarName is the duration during which the client of the import kubeconfig of a cluster is
+// kept after its import to clean up the cluster if it is force deleted, the force deleted clusters are not cleaned
+// up if not set
+const clusterTombstoneTTLEnvVarName = "CLUSTER_TOMBSTONE_TTL"
+
+// klusterletGVK is the kind of the Klusterlet applied on the managed clusters
+var klusterletGVK = schema.GroupVersionKind{
+	Group:   "operator.open-cluster-management.io",
+	Version: "v1",
+	Kind:    "Klusterlet",
+}
+
+// clusterTombstones keeps the client of the import kubeconfig of the recently imported clusters. A cluster
+// force deleted, its finalizer removed externally, is not detached by the controller, its tombstone allows
+// a best-effort cleanup of the klusterlet once the cluster is gone.
+// A nil clusterTombstones keeps no tombstone.
+type clusterTombstones struct {
+	ttl     time.Duration
+	lock    sync.Mutex
+	entries map[string]clusterTombstone
+}
+
+// clusterTombstone is the client of the import kubeconfig of a cluster and its import time
+type clusterTombstone struct {
+	client     client.Client
+	importedAt time.Time
+}
+
+// newClusterTombstones returns tombstones expiring after ttl, nil if ttl is not positive
+func newClusterTombstones(ttl time.Duration) *clusterTombstones {
+	if ttl <= 0 {
+		return nil
+	}
+	return &clusterTombstones{
+		ttl:     ttl,
+		entries: map[string]clusterTombstone{},
+	}
+}
+
+// getClusterTombstoneTTL returns the CLUSTER_TOMBSTONE_TTL value, 0 if not set
+func getClusterTombstoneTTL() (time.Duration, error) {
+	v := os.Getenv(clusterTombstoneTTLEnvVarName)
+	if v == "" {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value %q: %v", clusterTombstoneTTLEnvVarName, v, err)
+	}
+	return ttl, nil
+}
+
+// record keeps the import client of the cluster
+func (t *clusterTombstones) record(clusterName string, managedClusterClient client.Client, now time.Time) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.entries[clusterName] = clusterTombstone{
+		client:     managedClusterClient,
+		importedAt: now,
+	}
+}
+
+// forget drops the tombstone of the cluster, the cluster is detached by the controller
+func (t *clusterTombstones) forget(clusterName string) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.entries, clusterName)
+}
+
+// take removes and returns the import client of the cluster, false if the cluster has no tombstone
+// or its tombstone expired
+func (t *clusterTombstones) take(clusterName string, now time.Time) (client.Client, bool) {
+	if t == nil {
+		return nil, false
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	tombstone, ok := t.entries[clusterName]
+	if !ok {
+		return nil, false
+	}
+	delete(t.entries, clusterName)
+	if now.Sub(tombstone.importedAt) > t.ttl {
+		return nil, false
+	}
+	return tombstone.client, true
+}
+
+// cleanupForceDeletedCluster deletes the Klusterlet of a force deleted cluster with the client of its tombstone,
+// the klusterlet operator then removes the klusterlet agents. The cleanup is not done if the cluster has no tombstone.
+func (r *ReconcileManagedCluster) cleanupForceDeletedCluster(clusterName string) error {
+	managedClusterClient, ok := r.tombstones.take(clusterName, time.Now())
+	if !ok {
+		return nil
+	}
+	log.Info("Cleaning up the klusterlet of the force deleted cluster", "cluster", clusterName)
+	klusterlet := &unstructured.Unstructured{}
+	klusterlet.SetGroupVersionKind(klusterletGVK)
+	klusterlet.SetName(klusterletDeploymentName)
+	if err := managedClusterClient.Delete(context.TODO(), klusterlet); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
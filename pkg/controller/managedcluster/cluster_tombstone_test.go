@@ -0,0 +1,212 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// newRemoteKlusterletClient returns a fake client of a managed cluster running the klusterlet
+func newRemoteKlusterletClient() client.Client {
+	s := runtime.NewScheme()
+	s.AddKnownTypeWithName(klusterletGVK, &unstructured.Unstructured{})
+	klusterlet := &unstructured.Unstructured{}
+	klusterlet.SetGroupVersionKind(klusterletGVK)
+	klusterlet.SetName(klusterletDeploymentName)
+	return fake.NewFakeClientWithScheme(s, klusterlet)
+}
+
+func Test_getClusterTombstoneTTL(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:  "valid",
+			value: "1h",
+			want:  time.Hour,
+		},
+		{
+			name:    "invalid",
+			value:   "one hour",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(clusterTombstoneTTLEnvVarName, tt.value)
+			defer os.Unsetenv(clusterTombstoneTTLEnvVarName)
+			got, err := getClusterTombstoneTTL()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getClusterTombstoneTTL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("getClusterTombstoneTTL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_clusterTombstones_take(t *testing.T) {
+	importedAt := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		tombstones *clusterTombstones
+		forget     bool
+		now        time.Time
+		wantOK     bool
+	}{
+		{
+			name: "no tombstones",
+			now:  importedAt,
+		},
+		{
+			name:       "recently imported",
+			tombstones: newClusterTombstones(time.Hour),
+			now:        importedAt.Add(30 * time.Minute),
+			wantOK:     true,
+		},
+		{
+			name:       "expired",
+			tombstones: newClusterTombstones(time.Hour),
+			now:        importedAt.Add(2 * time.Hour),
+		},
+		{
+			name:       "detached by the controller",
+			tombstones: newClusterTombstones(time.Hour),
+			forget:     true,
+			now:        importedAt.Add(30 * time.Minute),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.tombstones.record("cluster1", newRemoteKlusterletClient(), importedAt)
+			if tt.forget {
+				tt.tombstones.forget("cluster1")
+			}
+			if _, ok := tt.tombstones.take("cluster1", tt.now); ok != tt.wantOK {
+				t.Errorf("take() = %v, want %v", ok, tt.wantOK)
+			}
+			if _, ok := tt.tombstones.take("cluster1", tt.now); ok {
+				t.Error("take() must remove the tombstone")
+			}
+		})
+	}
+}
+
+func TestReconcileManagedCluster_ReconcileForceDeleted(t *testing.T) {
+	s := scheme.Scheme
+	s.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	tests := []struct {
+		name           string
+		tombstone      bool
+		importedAt     time.Time
+		wantKlusterlet bool
+	}{
+		{
+			name:           "no tombstone",
+			wantKlusterlet: true,
+		},
+		{
+			name:       "recently imported",
+			tombstone:  true,
+			importedAt: time.Now(),
+		},
+		{
+			name:           "tombstone expired",
+			tombstone:      true,
+			importedAt:     time.Now().Add(-2 * time.Hour),
+			wantKlusterlet: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remoteClient := newRemoteKlusterletClient()
+			r := &ReconcileManagedCluster{
+				client:     fake.NewFakeClientWithScheme(s),
+				scheme:     s,
+				tombstones: newClusterTombstones(time.Hour),
+			}
+			if tt.tombstone {
+				r.tombstones.record("cluster1", remoteClient, tt.importedAt)
+			}
+
+			_, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "cluster1"}})
+			if err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+
+			klusterlet := &unstructured.Unstructured{}
+			klusterlet.SetGroupVersionKind(klusterletGVK)
+			err = remoteClient.Get(context.TODO(), types.NamespacedName{Name: klusterletDeploymentName}, klusterlet)
+			if err != nil && !errors.IsNotFound(err) {
+				t.Fatal(err)
+			}
+			if exists := err == nil; exists != tt.wantKlusterlet {
+				t.Errorf("klusterlet exists = %v, want %v", exists, tt.wantKlusterlet)
+			}
+		})
+	}
+}
+
+func TestReconcileManagedCluster_ReconcileForceDeletedWhileTerminating(t *testing.T) {
+	s := scheme.Scheme
+	s.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	now := metav1.Now()
+	managedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "cluster1",
+			DeletionTimestamp: &now,
+			// the finalizer of another controller defers the detach of the cluster
+			Finalizers: []string{managedClusterFinalizer, "example.com/cleanup"},
+		},
+	}
+	remoteClient := newRemoteKlusterletClient()
+	r := &ReconcileManagedCluster{
+		client:     fake.NewFakeClientWithScheme(s, managedCluster),
+		scheme:     s,
+		tombstones: newClusterTombstones(time.Hour),
+	}
+	r.tombstones.record(managedCluster.Name, remoteClient, time.Now())
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: managedCluster.Name}}
+
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("Reconcile() of the terminating cluster error = %v", err)
+	}
+
+	// the finalizers are removed externally, the cluster is gone before the controller detaches it
+	if err := r.client.Delete(context.TODO(), managedCluster); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(request); err != nil {
+		t.Fatalf("Reconcile() of the force deleted cluster error = %v", err)
+	}
+
+	klusterlet := &unstructured.Unstructured{}
+	klusterlet.SetGroupVersionKind(klusterletGVK)
+	err := remoteClient.Get(context.TODO(), types.NamespacedName{Name: klusterletDeploymentName}, klusterlet)
+	if !errors.IsNotFound(err) {
+		t.Errorf("klusterlet of the force deleted cluster get error = %v, want not found", err)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// propagatedLabelsEnvVarName is the comma separated list of the ClusterDeployment labels copied on the
+// ManagedCluster of the hive provisioned clusters, the defaultPropagatedLabels if not set
+const propagatedLabelsEnvVarName = "CLUSTERDEPLOYMENT_PROPAGATED_LABELS"
+
+// baseDomainLabel is the propagated label set from the ClusterDeployment spec.baseDomain when the
+// ClusterDeployment has no such label
+const baseDomainLabel = "hive.openshift.io/cluster-base-domain"
+
+// defaultPropagatedLabels are the platform and region labels set by hive on the ClusterDeployments and the
+// base domain of the ClusterDeployments
+var defaultPropagatedLabels = []string{
+	"hive.openshift.io/cluster-platform",
+	"hive.openshift.io/cluster-region",
+	baseDomainLabel,
+}
+
+// getPropagatedLabels returns the CLUSTERDEPLOYMENT_PROPAGATED_LABELS value, the defaultPropagatedLabels if not set,
+// no label if set to an empty value
+func getPropagatedLabels() ([]string, error) {
+	v, ok := os.LookupEnv(propagatedLabelsEnvVarName)
+	if !ok {
+		return defaultPropagatedLabels, nil
+	}
+	labels := []string{}
+	for _, key := range strings.Split(v, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		if errs := validation.IsQualifiedName(key); len(errs) != 0 {
+			return nil, fmt.Errorf("invalid %s label %q: %s", propagatedLabelsEnvVarName, key, strings.Join(errs, ", "))
+		}
+		labels = append(labels, key)
+	}
+	return labels, nil
+}
+
+// propagateClusterDeploymentLabels copies the propagated labels of the ClusterDeployment on the ManagedCluster,
+// the labels missing on the ClusterDeployment are left untouched on the ManagedCluster, the baseDomainLabel is
+// taken from the ClusterDeployment spec.baseDomain if not set on the ClusterDeployment
+func propagateClusterDeploymentLabels(
+	managedCluster *clusterv1.ManagedCluster,
+	clusterDeployment *hivev1.ClusterDeployment,
+	propagatedLabels []string) {
+	if clusterDeployment == nil {
+		return
+	}
+	for _, key := range propagatedLabels {
+		v, ok := clusterDeployment.GetLabels()[key]
+		if !ok && key == baseDomainLabel {
+			v = clusterDeployment.Spec.BaseDomain
+			ok = v != "" && len(validation.IsValidLabelValue(v)) == 0
+		}
+		if !ok {
+			continue
+		}
+		if managedCluster.Labels == nil {
+			managedCluster.Labels = make(map[string]string)
+		}
+		managedCluster.Labels[key] = v
+	}
+}
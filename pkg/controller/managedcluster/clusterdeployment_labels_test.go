@@ -0,0 +1,166 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_getPropagatedLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   *string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "not set",
+			want: defaultPropagatedLabels,
+		},
+		{
+			name:  "empty",
+			value: newString(""),
+			want:  []string{},
+		},
+		{
+			name:  "labels",
+			value: newString("hive.openshift.io/cluster-region, env"),
+			want:  []string{"hive.openshift.io/cluster-region", "env"},
+		},
+		{
+			name:    "invalid label",
+			value:   newString("hive.openshift.io/cluster region"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Unsetenv(propagatedLabelsEnvVarName)
+			if tt.value != nil {
+				os.Setenv(propagatedLabelsEnvVarName, *tt.value)
+			}
+			defer os.Unsetenv(propagatedLabelsEnvVarName)
+			got, err := getPropagatedLabels()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getPropagatedLabels() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getPropagatedLabels() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func newString(s string) *string {
+	return &s
+}
+
+func Test_propagateClusterDeploymentLabels(t *testing.T) {
+	clusterDeployment := &hivev1.ClusterDeployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "cluster1",
+			Namespace: "cluster1",
+			Labels: map[string]string{
+				"hive.openshift.io/cluster-platform": "aws",
+				"hive.openshift.io/cluster-region":   "us-east-1",
+				"hive.openshift.io/version-major":    "4",
+			},
+		},
+		Spec: hivev1.ClusterDeploymentSpec{
+			BaseDomain: "example.com",
+		},
+	}
+
+	tests := []struct {
+		name              string
+		labels            map[string]string
+		clusterDeployment *hivev1.ClusterDeployment
+		propagatedLabels  []string
+		want              map[string]string
+	}{
+		{
+			name:              "default labels",
+			clusterDeployment: clusterDeployment,
+			propagatedLabels:  defaultPropagatedLabels,
+			want: map[string]string{
+				"hive.openshift.io/cluster-platform":    "aws",
+				"hive.openshift.io/cluster-region":      "us-east-1",
+				"hive.openshift.io/cluster-base-domain": "example.com",
+			},
+		},
+		{
+			name: "base domain label of the clusterdeployment",
+			clusterDeployment: &hivev1.ClusterDeployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cluster1",
+					Namespace: "cluster1",
+					Labels:    map[string]string{"hive.openshift.io/cluster-base-domain": "example.org"},
+				},
+				Spec: hivev1.ClusterDeploymentSpec{
+					BaseDomain: "example.com",
+				},
+			},
+			propagatedLabels: []string{"hive.openshift.io/cluster-base-domain"},
+			want:             map[string]string{"hive.openshift.io/cluster-base-domain": "example.org"},
+		},
+		{
+			name:              "no base domain",
+			labels:            map[string]string{"env": "prod"},
+			clusterDeployment: &hivev1.ClusterDeployment{},
+			propagatedLabels:  defaultPropagatedLabels,
+			want:              map[string]string{"env": "prod"},
+		},
+		{
+			name:              "selected labels only",
+			labels:            map[string]string{"name": "cluster1"},
+			clusterDeployment: clusterDeployment,
+			propagatedLabels:  []string{"hive.openshift.io/cluster-region"},
+			want: map[string]string{
+				"name":                             "cluster1",
+				"hive.openshift.io/cluster-region": "us-east-1",
+			},
+		},
+		{
+			name:              "label updated",
+			labels:            map[string]string{"hive.openshift.io/cluster-region": "us-west-1"},
+			clusterDeployment: clusterDeployment,
+			propagatedLabels:  []string{"hive.openshift.io/cluster-region"},
+			want:              map[string]string{"hive.openshift.io/cluster-region": "us-east-1"},
+		},
+		{
+			name:              "label missing on the clusterdeployment",
+			labels:            map[string]string{"env": "prod"},
+			clusterDeployment: clusterDeployment,
+			propagatedLabels:  []string{"env"},
+			want:              map[string]string{"env": "prod"},
+		},
+		{
+			name:             "no clusterdeployment",
+			propagatedLabels: defaultPropagatedLabels,
+		},
+		{
+			name:              "no propagated labels",
+			clusterDeployment: clusterDeployment,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			managedCluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "cluster1",
+					Labels: tt.labels,
+				},
+			}
+			propagateClusterDeploymentLabels(managedCluster, tt.clusterDeployment, tt.propagatedLabels)
+			if !reflect.DeepEqual(managedCluster.Labels, tt.want) {
+				t.Errorf("ManagedCluster labels = %v, want %v", managedCluster.Labels, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// caRotationOverlapEnvVarName is the duration during which the bootstrap kubeconfigs carry both the
+	// previous and the new hub CA after a rotation of the hub CA, only the new CA is used if not set
+	caRotationOverlapEnvVarName = "HUB_CA_ROTATION_OVERLAP"
+	// hubCAConfigMapName is the configmap, in the POD_NAMESPACE, recording the hub CA history
+	hubCAConfigMapName = "managedcluster-import-controller-hub-ca"
+	hubCAKey           = "ca.crt"
+	previousHubCAKey   = "previous-ca.crt"
+	hubCARotatedAtKey  = "rotatedAt"
+)
+
+// getCARotationOverlap returns the HUB_CA_ROTATION_OVERLAP value, 0 if not set
+func getCARotationOverlap() (time.Duration, error) {
+	v := os.Getenv(caRotationOverlapEnvVarName)
+	if v == "" {
+		return 0, nil
+	}
+	overlap, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value %q: %v", caRotationOverlapEnvVarName, v, err)
+	}
+	return overlap, nil
+}
+
+// hubCABundle returns the hub CA of the bootstrap kubeconfigs. The hub CA is recorded in the hub CA configmap,
+// when it changes the previous CA is appended to the new one until the overlap window ends so the klusterlets
+// joining with a kubeconfig generated before the rotation, or trusting the previous CA, are not broken.
+func hubCABundle(c client.Client, ca []byte, now time.Time) ([]byte, error) {
+	overlap, err := getCARotationOverlap()
+	if err != nil {
+		return nil, err
+	}
+	if overlap <= 0 || len(ca) == 0 {
+		return ca, nil
+	}
+
+	cm := &corev1.ConfigMap{}
+	nsn := types.NamespacedName{Namespace: os.Getenv("POD_NAMESPACE"), Name: hubCAConfigMapName}
+	err = c.Get(context.TODO(), nsn, cm)
+	if errors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      nsn.Name,
+				Namespace: nsn.Namespace,
+			},
+			Data: map[string]string{hubCAKey: string(ca)},
+		}
+		return ca, c.Create(context.TODO(), cm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if cm.Data[hubCAKey] != string(ca) {
+		log.Info("Hub CA rotated, the bootstrap kubeconfigs carry the previous CA during the overlap",
+			"overlap", overlap.String())
+		previous := cm.Data[hubCAKey]
+		cm.Data = map[string]string{
+			hubCAKey:          string(ca),
+			previousHubCAKey:  previous,
+			hubCARotatedAtKey: now.UTC().Format(time.RFC3339),
+		}
+		if err := c.Update(context.TODO(), cm); err != nil {
+			return nil, err
+		}
+	}
+
+	previous := cm.Data[previousHubCAKey]
+	if previous == "" {
+		return ca, nil
+	}
+	rotatedAt, err := time.Parse(time.RFC3339, cm.Data[hubCARotatedAtKey])
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s in configmap %s: %v", hubCARotatedAtKey, nsn, err)
+	}
+	if !now.Before(rotatedAt.Add(overlap)) {
+		return ca, nil
+	}
+	bundle := append([]byte{}, bytes.TrimRight(ca, "\n")...)
+	bundle = append(bundle, '\n')
+	return append(bundle, previous...), nil
+}
@@ -0,0 +1,114 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_getCARotationOverlap(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{
+			name: "not set",
+			want: 0,
+		},
+		{
+			name:  "valid",
+			value: "24h",
+			want:  24 * time.Hour,
+		},
+		{
+			name:    "invalid",
+			value:   "one day",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(caRotationOverlapEnvVarName, tt.value)
+			defer os.Unsetenv(caRotationOverlapEnvVarName)
+			got, err := getCARotationOverlap()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getCARotationOverlap() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("getCARotationOverlap() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_hubCABundle(t *testing.T) {
+	os.Setenv("POD_NAMESPACE", "open-cluster-management")
+	os.Setenv(caRotationOverlapEnvVarName, "1h")
+	defer os.Unsetenv(caRotationOverlapEnvVarName)
+
+	oldCA := "-----BEGIN CERTIFICATE-----\nold\n-----END CERTIFICATE-----\n"
+	newCA := "-----BEGIN CERTIFICATE-----\nnew\n-----END CERTIFICATE-----\n"
+	rotatedAt := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	c := fake.NewFakeClientWithScheme(scheme.Scheme)
+
+	steps := []struct {
+		name string
+		ca   string
+		now  time.Time
+		want string
+	}{
+		{
+			name: "first CA recorded",
+			ca:   oldCA,
+			now:  rotatedAt.Add(-time.Hour),
+			want: oldCA,
+		},
+		{
+			name: "CA rotated",
+			ca:   newCA,
+			now:  rotatedAt,
+			want: newCA + oldCA,
+		},
+		{
+			name: "during the overlap",
+			ca:   newCA,
+			now:  rotatedAt.Add(59 * time.Minute),
+			want: newCA + oldCA,
+		},
+		{
+			name: "after the overlap",
+			ca:   newCA,
+			now:  rotatedAt.Add(time.Hour),
+			want: newCA,
+		},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			got, err := hubCABundle(c, []byte(step.ca), step.now)
+			if err != nil {
+				t.Fatalf("hubCABundle() error = %v", err)
+			}
+			if string(got) != step.want {
+				t.Errorf("hubCABundle() = %q, want %q", got, step.want)
+			}
+		})
+	}
+}
+
+func Test_hubCABundleNoOverlap(t *testing.T) {
+	ca := []byte("ca")
+	got, err := hubCABundle(fake.NewFakeClientWithScheme(scheme.Scheme), ca, time.Now())
+	if err != nil {
+		t.Fatalf("hubCABundle() error = %v", err)
+	}
+	if string(got) != string(ca) {
+		t.Errorf("hubCABundle() = %q, want %q", got, ca)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/open-cluster-management/applier/pkg/applier"
+	"github.com/open-cluster-management/applier/pkg/templateprocessor"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// applyOrderEnvVarName is the comma separated list of the kinds of the import resources applied first on the
+// managed cluster, in that order, the other resources are applied next. The defaultApplyOrder if not set, no kind
+// if set to an empty value
+const applyOrderEnvVarName = "IMPORT_APPLY_ORDER"
+
+// defaultApplyOrder applies the namespaces then the crds before the resources depending on them
+var defaultApplyOrder = []string{
+	"Namespace",
+	"CustomResourceDefinition",
+}
+
+// crdEstablishBackoff is the backoff of the import resources failing while their crds or namespaces are not
+// established yet on the managed cluster
+var crdEstablishBackoff = wait.Backoff{
+	Steps:    6,
+	Duration: 500 * time.Millisecond,
+	Factor:   2.0,
+}
+
+// getApplyOrder returns the IMPORT_APPLY_ORDER value, the defaultApplyOrder if not set
+func getApplyOrder() ([]string, error) {
+	v, ok := os.LookupEnv(applyOrderEnvVarName)
+	if !ok {
+		return defaultApplyOrder, nil
+	}
+	kinds := []string{}
+	for _, kind := range strings.Split(v, ",") {
+		kind = strings.TrimSpace(kind)
+		if kind == "" {
+			continue
+		}
+		if errs := validation.IsCIdentifier(kind); len(errs) != 0 {
+			return nil, fmt.Errorf("invalid %s kind %q: %s", applyOrderEnvVarName, kind, strings.Join(errs, ", "))
+		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, nil
+}
+
+// splitByApplyOrder returns the resources of the kinds of the apply order sorted in that order, and the
+// other resources
+func splitByApplyOrder(
+	resources []*unstructured.Unstructured,
+	applyOrder []string) ([]*unstructured.Unstructured, []*unstructured.Unstructured) {
+	first := []*unstructured.Unstructured{}
+	for _, kind := range applyOrder {
+		for _, u := range resources {
+			if u.GetKind() == kind {
+				first = append(first, u)
+			}
+		}
+	}
+	rest := []*unstructured.Unstructured{}
+	for _, u := range resources {
+		ordered := false
+		for _, kind := range applyOrder {
+			if u.GetKind() == kind {
+				ordered = true
+				break
+			}
+		}
+		if !ordered {
+			rest = append(rest, u)
+		}
+	}
+	return first, rest
+}
+
+// notEstablished returns true if the error is caused by a crd or a namespace not established yet
+func notEstablished(err error) bool {
+	return meta.IsNoMatchError(err) || errors.IsNotFound(err)
+}
+
+// applyImportResources applies the import resources on the managed cluster, the resources of the kinds of the
+// apply order first. The resources failing because of a crd or a namespace not established yet are applied
+// again with the crdEstablishBackoff.
+func applyImportResources(
+	managedClusterClient client.Client,
+	applyOrder []string,
+	resources []*unstructured.Unstructured) error {
+	first, rest := splitByApplyOrder(resources, applyOrder)
+
+	a, err := applier.NewApplier(
+		templateprocessor.NewYamlStringReader("", templateprocessor.KubernetesYamlsDelimiter),
+		nil,
+		managedClusterClient,
+		nil,
+		nil,
+		nil)
+	if err != nil {
+		return err
+	}
+	for _, u := range first {
+		if err := retry.OnError(crdEstablishBackoff, notEstablished, func() error {
+			return a.CreateOrUpdate(u)
+		}); err != nil {
+			return err
+		}
+	}
+
+	if len(rest) == 0 {
+		return nil
+	}
+	bb, err := templateprocessor.ToYAMLsUnstructured(rest)
+	if err != nil {
+		return err
+	}
+	a, err = applier.NewApplier(
+		templateprocessor.NewYamlStringReader(templateprocessor.ConvertArrayOfBytesToString(bb),
+			templateprocessor.KubernetesYamlsDelimiter),
+		nil,
+		managedClusterClient,
+		nil,
+		nil,
+		nil)
+	if err != nil {
+		return err
+	}
+	return retry.OnError(crdEstablishBackoff, notEstablished, func() error {
+		return a.CreateOrUpdateInPath(".", nil, false, nil)
+	})
+}
@@ -0,0 +1,183 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// establishingClient is a managed cluster client on which the namespaced resources can not be created before
+// their namespace, and the klusterlets can not be applied before their crd is established, which takes
+// establishAttempts attempts once the crd is created
+type establishingClient struct {
+	client.Client
+	establishAttempts int
+	crdCreated        bool
+	namespaces        map[string]bool
+	created           []string
+}
+
+func (c *establishingClient) notEstablished(obj runtime.Object) error {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if gvk.Kind != "Klusterlet" {
+		return nil
+	}
+	if !c.crdCreated || c.establishAttempts > 0 {
+		c.establishAttempts--
+		return &meta.NoKindMatchError{GroupKind: gvk.GroupKind(), SearchedVersions: []string{gvk.Version}}
+	}
+	return nil
+}
+
+func (c *establishingClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	if err := c.notEstablished(obj); err != nil {
+		return err
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func (c *establishingClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	if err := c.notEstablished(obj); err != nil {
+		return err
+	}
+	u := obj.(*unstructured.Unstructured)
+	if u.GetNamespace() != "" && !c.namespaces[u.GetNamespace()] {
+		return errors.NewNotFound(schema.GroupResource{Resource: "namespaces"}, u.GetNamespace())
+	}
+	switch u.GetKind() {
+	case "Namespace":
+		c.namespaces[u.GetName()] = true
+	case "CustomResourceDefinition":
+		c.crdCreated = true
+	}
+	c.created = append(c.created, u.GetKind())
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func newUnstructured(apiVersion, kind, namespace, name string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion(apiVersion)
+	u.SetKind(kind)
+	u.SetNamespace(namespace)
+	u.SetName(name)
+	return u
+}
+
+func Test_getApplyOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   *string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "not set",
+			want: defaultApplyOrder,
+		},
+		{
+			name:  "empty",
+			value: newString(""),
+			want:  []string{},
+		},
+		{
+			name:  "kinds",
+			value: newString("Namespace, CustomResourceDefinition,ClusterRole"),
+			want:  []string{"Namespace", "CustomResourceDefinition", "ClusterRole"},
+		},
+		{
+			name:    "invalid kind",
+			value:   newString("Custom Resource"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Unsetenv(applyOrderEnvVarName)
+			if tt.value != nil {
+				os.Setenv(applyOrderEnvVarName, *tt.value)
+			}
+			defer os.Unsetenv(applyOrderEnvVarName)
+			got, err := getApplyOrder()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getApplyOrder() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getApplyOrder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_applyImportResources(t *testing.T) {
+	defer func(backoff wait.Backoff) { crdEstablishBackoff = backoff }(crdEstablishBackoff)
+	crdEstablishBackoff = wait.Backoff{Steps: 5, Duration: time.Millisecond, Factor: 1.0}
+
+	// an out of order bundle, the klusterlet depends on its crd and the deployment on its namespace
+	bundle := func() []*unstructured.Unstructured {
+		return []*unstructured.Unstructured{
+			newUnstructured("operator.open-cluster-management.io/v1", "Klusterlet", "", "klusterlet"),
+			newUnstructured("apps/v1", "Deployment", klusterletNamespace, "klusterlet"),
+			newUnstructured("apiextensions.k8s.io/v1", "CustomResourceDefinition", "",
+				"klusterlets.operator.open-cluster-management.io"),
+			newUnstructured("v1", "Namespace", "", klusterletNamespace),
+		}
+	}
+
+	tests := []struct {
+		name              string
+		applyOrder        []string
+		establishAttempts int
+		wantCreated       []string
+		wantErr           bool
+	}{
+		{
+			name:              "default order",
+			applyOrder:        defaultApplyOrder,
+			establishAttempts: 2,
+			wantCreated:       []string{"Namespace", "CustomResourceDefinition", "Deployment", "Klusterlet"},
+		},
+		{
+			name:              "crd never established",
+			applyOrder:        defaultApplyOrder,
+			establishAttempts: 100,
+			wantCreated:       []string{"Namespace", "CustomResourceDefinition", "Deployment"},
+			wantErr:           true,
+		},
+		{
+			name:              "custom order",
+			applyOrder:        []string{"Namespace", "Deployment", "CustomResourceDefinition"},
+			establishAttempts: 1,
+			wantCreated:       []string{"Namespace", "Deployment", "CustomResourceDefinition", "Klusterlet"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &establishingClient{
+				Client:            fake.NewFakeClientWithScheme(scheme.Scheme),
+				establishAttempts: tt.establishAttempts,
+				namespaces:        map[string]bool{},
+			}
+			err := applyImportResources(c, tt.applyOrder, bundle())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applyImportResources() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(c.created, tt.wantCreated) {
+				t.Errorf("created = %v, want %v", c.created, tt.wantCreated)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// credentialsWaitTimeoutEnvVarName is the duration during which the import waits for the admin kubeconfig secret
+// referenced by the ClusterDeployment to appear, the import fails at once if the secret is missing if not set
+const credentialsWaitTimeoutEnvVarName = "IMPORT_CREDENTIALS_WAIT_TIMEOUT"
+
+// credentialsWaitRequeuePeriod is the requeue period of an import waiting for its credentials
+const credentialsWaitRequeuePeriod = 15 * time.Second
+
+// WaitingForCredentials is a condition of the ManagedCluster set while its import waits for the admin kubeconfig
+// secret referenced by its ClusterDeployment, for example when the secret is created asynchronously by another tool
+const WaitingForCredentials = "WaitingForCredentials"
+
+const (
+	reasonCredentialsNotFound      = "CredentialsSecretNotFound"
+	reasonCredentialsWaitTimedOut  = "CredentialsWaitTimedOut"
+	reasonCredentialsSecretPresent = "CredentialsSecretFound"
+)
+
+// getCredentialsWaitTimeout returns the IMPORT_CREDENTIALS_WAIT_TIMEOUT value, 0 if not set
+func getCredentialsWaitTimeout() (time.Duration, error) {
+	v := os.Getenv(credentialsWaitTimeoutEnvVarName)
+	if v == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value %q: %v", credentialsWaitTimeoutEnvVarName, v, err)
+	}
+	return timeout, nil
+}
+
+// setConditionWaitingForCredentials updates the WaitingForCredentials condition of the managed cluster
+func (r *ReconcileManagedCluster) setConditionWaitingForCredentials(
+	managedCluster *clusterv1.ManagedCluster,
+	newCondition metav1.Condition) error {
+	if condition := meta.FindStatusCondition(managedCluster.Status.Conditions, newCondition.Type); condition != nil &&
+		condition.Status == newCondition.Status && condition.Reason == newCondition.Reason {
+		return nil
+	}
+
+	patch := client.MergeFrom(managedCluster.DeepCopy())
+	meta.SetStatusCondition(&managedCluster.Status.Conditions, newCondition)
+	return r.client.Status().Patch(context.TODO(), managedCluster, patch)
+}
+
+// waitForCredentials checks the admin kubeconfig secret referenced by the ClusterDeployment exists. While it does
+// not, the import is requeued until credentialsWaitTimeout since the start of the wait, recorded as the transition
+// time of the WaitingForCredentials condition, and fails afterwards. It returns true while the import waits.
+func (r *ReconcileManagedCluster) waitForCredentials(
+	managedCluster *clusterv1.ManagedCluster,
+	clusterDeployment *hivev1.ClusterDeployment,
+	now time.Time) (reconcile.Result, bool, error) {
+	if r.credentialsWaitTimeout <= 0 || clusterDeployment == nil || clusterDeployment.Spec.ClusterMetadata == nil {
+		return reconcile.Result{}, false, nil
+	}
+	secretName := clusterDeployment.Spec.ClusterMetadata.AdminKubeconfigSecretRef.Name
+	condition := meta.FindStatusCondition(managedCluster.Status.Conditions, WaitingForCredentials)
+
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: secretName, Namespace: managedCluster.Name},
+		&corev1.Secret{})
+	if err == nil {
+		if condition == nil {
+			return reconcile.Result{}, false, nil
+		}
+		return reconcile.Result{}, false, r.setConditionWaitingForCredentials(managedCluster, metav1.Condition{
+			Type:               WaitingForCredentials,
+			Status:             metav1.ConditionFalse,
+			Message:            fmt.Sprintf("The admin kubeconfig secret %s is found", secretName),
+			Reason:             reasonCredentialsSecretPresent,
+			LastTransitionTime: metav1.NewTime(now),
+		})
+	}
+	if !errors.IsNotFound(err) {
+		return reconcile.Result{}, false, err
+	}
+
+	timedOut := fmt.Errorf("the admin kubeconfig secret %s/%s did not appear within %s",
+		managedCluster.Name, secretName, r.credentialsWaitTimeout)
+	if condition != nil && condition.Reason == reasonCredentialsWaitTimedOut {
+		return reconcile.Result{}, false, timedOut
+	}
+	start := now
+	if condition != nil && condition.Status == metav1.ConditionTrue {
+		start = condition.LastTransitionTime.Time
+	}
+	remaining := start.Add(r.credentialsWaitTimeout).Sub(now)
+	if remaining <= 0 {
+		if err := r.setConditionWaitingForCredentials(managedCluster, metav1.Condition{
+			Type:               WaitingForCredentials,
+			Status:             metav1.ConditionFalse,
+			Message:            timedOut.Error(),
+			Reason:             reasonCredentialsWaitTimedOut,
+			LastTransitionTime: metav1.NewTime(now),
+		}); err != nil {
+			return reconcile.Result{}, false, err
+		}
+		return reconcile.Result{}, false, timedOut
+	}
+
+	if err := r.setConditionWaitingForCredentials(managedCluster, metav1.Condition{
+		Type:   WaitingForCredentials,
+		Status: metav1.ConditionTrue,
+		Message: fmt.Sprintf("Waiting for the admin kubeconfig secret %s, the import fails in %s",
+			secretName, remaining.Round(time.Second)),
+		Reason:             reasonCredentialsNotFound,
+		LastTransitionTime: metav1.NewTime(start),
+	}); err != nil {
+		return reconcile.Result{}, false, err
+	}
+	requeueAfter := credentialsWaitRequeuePeriod
+	if remaining < requeueAfter {
+		requeueAfter = remaining
+	}
+	return reconcile.Result{Requeue: true, RequeueAfter: requeueAfter}, true, nil
+}
@@ -0,0 +1,199 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func Test_getCredentialsWaitTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:  "valid",
+			value: "30m",
+			want:  30 * time.Minute,
+		},
+		{
+			name:    "invalid",
+			value:   "thirty minutes",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(credentialsWaitTimeoutEnvVarName, tt.value)
+			defer os.Unsetenv(credentialsWaitTimeoutEnvVarName)
+			got, err := getCredentialsWaitTimeout()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getCredentialsWaitTimeout() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("getCredentialsWaitTimeout() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileManagedCluster_waitForCredentials(t *testing.T) {
+	clusterDeployment := &hivev1.ClusterDeployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "cluster-credentials",
+			Namespace: "cluster-credentials",
+		},
+		Spec: hivev1.ClusterDeploymentSpec{
+			ClusterMetadata: &hivev1.ClusterMetadata{
+				AdminKubeconfigSecretRef: corev1.LocalObjectReference{
+					Name: "admin-kubeconfig",
+				},
+			},
+		},
+	}
+	adminKubeconfig := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "admin-kubeconfig",
+			Namespace: "cluster-credentials",
+		},
+	}
+	start := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	type step struct {
+		now           time.Time
+		createSecret  bool
+		wantWait      bool
+		wantErr       bool
+		wantCondition *metav1.Condition
+	}
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		steps   []step
+	}{
+		{
+			name: "wait disabled",
+			steps: []step{
+				{now: start},
+			},
+		},
+		{
+			name:    "secret present",
+			timeout: time.Hour,
+			steps: []step{
+				{now: start, createSecret: true},
+			},
+		},
+		{
+			name:    "wait then import",
+			timeout: time.Hour,
+			steps: []step{
+				{
+					now:      start,
+					wantWait: true,
+					wantCondition: &metav1.Condition{Status: metav1.ConditionTrue, Reason: reasonCredentialsNotFound,
+						LastTransitionTime: metav1.NewTime(start)},
+				},
+				{
+					now:      start.Add(30 * time.Minute),
+					wantWait: true,
+					wantCondition: &metav1.Condition{Status: metav1.ConditionTrue, Reason: reasonCredentialsNotFound,
+						LastTransitionTime: metav1.NewTime(start)},
+				},
+				{
+					now:          start.Add(40 * time.Minute),
+					createSecret: true,
+					wantCondition: &metav1.Condition{Status: metav1.ConditionFalse, Reason: reasonCredentialsSecretPresent,
+						LastTransitionTime: metav1.NewTime(start.Add(40 * time.Minute))},
+				},
+			},
+		},
+		{
+			name:    "timeout",
+			timeout: time.Hour,
+			steps: []step{
+				{
+					now:      start,
+					wantWait: true,
+					wantCondition: &metav1.Condition{Status: metav1.ConditionTrue, Reason: reasonCredentialsNotFound,
+						LastTransitionTime: metav1.NewTime(start)},
+				},
+				{
+					now:     start.Add(time.Hour),
+					wantErr: true,
+					wantCondition: &metav1.Condition{Status: metav1.ConditionFalse, Reason: reasonCredentialsWaitTimedOut,
+						LastTransitionTime: metav1.NewTime(start.Add(time.Hour))},
+				},
+				{
+					now:     start.Add(2 * time.Hour),
+					wantErr: true,
+					wantCondition: &metav1.Condition{Status: metav1.ConditionFalse, Reason: reasonCredentialsWaitTimedOut,
+						LastTransitionTime: metav1.NewTime(start.Add(time.Hour))},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			managedCluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "cluster-credentials",
+				},
+			}
+			r := &ReconcileManagedCluster{
+				client:                 newRenderFakeClient(t, managedCluster),
+				scheme:                 scheme.Scheme,
+				credentialsWaitTimeout: tt.timeout,
+			}
+			for i, step := range tt.steps {
+				if step.createSecret {
+					if err := r.client.Create(context.TODO(), adminKubeconfig.DeepCopy()); err != nil {
+						t.Fatal(err)
+					}
+				}
+				current := &clusterv1.ManagedCluster{}
+				if err := r.client.Get(context.TODO(), types.NamespacedName{Name: managedCluster.Name}, current); err != nil {
+					t.Fatal(err)
+				}
+				res, wait, err := r.waitForCredentials(current, clusterDeployment, step.now)
+				if (err != nil) != step.wantErr {
+					t.Fatalf("step %d: waitForCredentials() error = %v, wantErr %v", i, err, step.wantErr)
+				}
+				if wait != step.wantWait {
+					t.Errorf("step %d: waitForCredentials() wait = %v, want %v", i, wait, step.wantWait)
+				}
+				if wait && (!res.Requeue || res.RequeueAfter <= 0) {
+					t.Errorf("step %d: waitForCredentials() = %v, want requeue", i, res)
+				}
+				condition := meta.FindStatusCondition(current.Status.Conditions, WaitingForCredentials)
+				if step.wantCondition == nil {
+					if condition != nil {
+						t.Errorf("step %d: %s condition = %v, want none", i, WaitingForCredentials, condition)
+					}
+					continue
+				}
+				if condition == nil || condition.Status != step.wantCondition.Status ||
+					condition.Reason != step.wantCondition.Reason ||
+					!condition.LastTransitionTime.Equal(&step.wantCondition.LastTransitionTime) {
+					t.Errorf("step %d: %s condition = %v, want %v", i, WaitingForCredentials, condition, step.wantCondition)
+				}
+			}
+		})
+	}
+}
@@ -184,7 +184,7 @@ func Test_newManifestWorks(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Logf("Test name: %s", tt.name)
-			crds, yamls, err := generateImportYAMLs(testClient, tt.args.managedCluster, []string{})
+			crds, yamls, err := generateImportYAMLs(testClient, nil, nil, tt.args.managedCluster, []string{})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("generateImportYAMLs error=%v, wantErr %v", err, tt.wantErr)
 			}
@@ -443,7 +443,7 @@ func Test_createOrUpdateManifestWork(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Logf("Test name: %s", tt.name)
-			crds, yamls, err := generateImportYAMLs(tt.args.client, tt.args.managedCluster, []string{})
+			crds, yamls, err := generateImportYAMLs(tt.args.client, nil, nil, tt.args.managedCluster, []string{})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("generateImportYAMLs error=%v, wantErr %v", err, tt.wantErr)
 			}
@@ -42,6 +42,7 @@ func importSecretNsN(managedCluster *clusterv1.ManagedCluster) (types.Namespaced
 }
 
 func newImportSecret(
+	location *importSecretLocation,
 	managedCluster *clusterv1.ManagedCluster,
 	crds map[string][]*unstructured.Unstructured,
 	yamls []*unstructured.Unstructured,
@@ -50,7 +51,7 @@ func newImportSecret(
 	crdsV1YAML := new(bytes.Buffer)
 	crdsV1beta1YAML := new(bytes.Buffer)
 
-	secretNsN, err := importSecretNsN(managedCluster)
+	secretNsN, err := location.importSecretNsN(managedCluster)
 	if err != nil {
 		return nil, err
 	}
@@ -84,6 +85,10 @@ func newImportSecret(
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      secretNsN.Name,
 			Namespace: secretNsN.Namespace,
+			Labels: map[string]string{
+				clusterLabel:      managedCluster.Name,
+				importSecretLabel: "true",
+			},
 		},
 		Data: map[string][]byte{
 			importYAMLKey:      importYAML.Bytes(),
@@ -99,11 +104,12 @@ func newImportSecret(
 func createOrUpdateImportSecret(
 	client client.Client,
 	scheme *runtime.Scheme,
+	location *importSecretLocation,
 	managedCluster *clusterv1.ManagedCluster,
 	crds map[string][]*unstructured.Unstructured,
 	yamls []*unstructured.Unstructured,
 ) (*corev1.Secret, error) {
-	secret, err := newImportSecret(managedCluster, crds, yamls)
+	secret, err := newImportSecret(location, managedCluster, crds, yamls)
 	if err != nil {
 		return nil, err
 	}
@@ -127,8 +133,15 @@ func createOrUpdateImportSecret(
 		if !bytes.Equal(oldImportSecret.Data[importYAMLKey], secret.Data[importYAMLKey]) ||
 			!bytes.Equal(oldImportSecret.Data[crdsYAMLKey], secret.Data[crdsYAMLKey]) ||
 			!bytes.Equal(oldImportSecret.Data[crdsV1beta1YAMLKey], secret.Data[crdsV1beta1YAMLKey]) ||
-			!bytes.Equal(oldImportSecret.Data[crdsV1YAMLKey], secret.Data[crdsV1YAMLKey]) {
+			!bytes.Equal(oldImportSecret.Data[crdsV1YAMLKey], secret.Data[crdsV1YAMLKey]) ||
+			oldImportSecret.Labels[clusterLabel] != managedCluster.Name ||
+			oldImportSecret.Labels[importSecretLabel] != "true" {
 			oldImportSecret.Data = secret.Data
+			if oldImportSecret.Labels == nil {
+				oldImportSecret.Labels = map[string]string{}
+			}
+			oldImportSecret.Labels[clusterLabel] = managedCluster.Name
+			oldImportSecret.Labels[importSecretLabel] = "true"
 			if err := client.Update(context.TODO(), oldImportSecret); err != nil {
 				return nil, err
 			}
@@ -0,0 +1,126 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// importSecretNamespaceTemplateEnvVarName is the template of the namespace of the import secrets,
+	// the import secret of a cluster is stored in the cluster namespace if not set
+	importSecretNamespaceTemplateEnvVarName = "IMPORT_SECRET_NAMESPACE_TEMPLATE"
+	// importSecretNameTemplateEnvVarName is the template of the name of the import secrets,
+	// the import secret of a cluster is named <cluster_name>-import if not set
+	importSecretNameTemplateEnvVarName = "IMPORT_SECRET_NAME_TEMPLATE"
+
+	defaultImportSecretNamespaceTemplate = "{{ .ClusterName }}"
+	defaultImportSecretNameTemplate      = "{{ .ClusterName }}" + importSecretNamePostfix
+)
+
+// importSecretLocation renders the namespace and the name of the import secret of a cluster,
+// for example to store the import secrets in a dedicated namespace.
+// A nil importSecretLocation stores the import secret in the cluster namespace.
+type importSecretLocation struct {
+	namespace *template.Template
+	name      *template.Template
+}
+
+// importSecretTemplateData is the data of the import secret location templates
+type importSecretTemplateData struct {
+	ClusterName string
+}
+
+// newImportSecretLocation returns the location rendered by the templates, nil if both are empty
+func newImportSecretLocation(namespaceTemplate, nameTemplate string) (*importSecretLocation, error) {
+	if namespaceTemplate == "" && nameTemplate == "" {
+		return nil, nil
+	}
+	if namespaceTemplate == "" {
+		namespaceTemplate = defaultImportSecretNamespaceTemplate
+	}
+	if nameTemplate == "" {
+		nameTemplate = defaultImportSecretNameTemplate
+	}
+	namespace, err := template.New("namespace").Option("missingkey=error").Parse(namespaceTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid import secret namespace template %q: %v", namespaceTemplate, err)
+	}
+	name, err := template.New("name").Option("missingkey=error").Parse(nameTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid import secret name template %q: %v", nameTemplate, err)
+	}
+	return &importSecretLocation{namespace: namespace, name: name}, nil
+}
+
+// getImportSecretLocation returns the location of the IMPORT_SECRET_NAMESPACE_TEMPLATE and
+// IMPORT_SECRET_NAME_TEMPLATE templates, nil if not set
+func getImportSecretLocation() (*importSecretLocation, error) {
+	return newImportSecretLocation(
+		os.Getenv(importSecretNamespaceTemplateEnvVarName),
+		os.Getenv(importSecretNameTemplateEnvVarName))
+}
+
+// importSecretNsN returns the namespace and the name of the import secret of the managedCluster
+func (l *importSecretLocation) importSecretNsN(managedCluster *clusterv1.ManagedCluster) (types.NamespacedName, error) {
+	if l == nil {
+		return importSecretNsN(managedCluster)
+	}
+	if managedCluster == nil {
+		return types.NamespacedName{}, fmt.Errorf("managedCluster is nil")
+	}
+	return l.render(managedCluster.Name)
+}
+
+// staleImportSecretNsN returns the namespace and the name of the import secret generated for
+// the previous name of the managedCluster
+func (l *importSecretLocation) staleImportSecretNsN(
+	managedCluster *clusterv1.ManagedCluster,
+	oldName string) (types.NamespacedName, error) {
+	if l == nil {
+		// the cluster namespace is kept on rename
+		return types.NamespacedName{Name: oldName + importSecretNamePostfix, Namespace: managedCluster.Name}, nil
+	}
+	return l.render(oldName)
+}
+
+// inClusterNamespace returns true if the import secrets are stored in the namespace of their cluster
+func (l *importSecretLocation) inClusterNamespace(managedCluster *clusterv1.ManagedCluster) bool {
+	nsN, err := l.importSecretNsN(managedCluster)
+	return err == nil && nsN.Namespace == managedCluster.Name
+}
+
+func (l *importSecretLocation) render(clusterName string) (types.NamespacedName, error) {
+	if clusterName == "" {
+		return types.NamespacedName{}, fmt.Errorf("managedCluster.Name is blank")
+	}
+	data := importSecretTemplateData{ClusterName: clusterName}
+	namespace := new(bytes.Buffer)
+	if err := l.namespace.Execute(namespace, data); err != nil {
+		return types.NamespacedName{}, err
+	}
+	name := new(bytes.Buffer)
+	if err := l.name.Execute(name, data); err != nil {
+		return types.NamespacedName{}, err
+	}
+	nsN := types.NamespacedName{
+		Namespace: strings.TrimSpace(namespace.String()),
+		Name:      strings.TrimSpace(name.String()),
+	}
+	if errs := validation.IsDNS1123Label(nsN.Namespace); len(errs) != 0 {
+		return types.NamespacedName{}, fmt.Errorf("invalid import secret namespace %q: %s",
+			nsN.Namespace, strings.Join(errs, ", "))
+	}
+	if errs := validation.IsDNS1123Subdomain(nsN.Name); len(errs) != 0 {
+		return types.NamespacedName{}, fmt.Errorf("invalid import secret name %q: %s", nsN.Name, strings.Join(errs, ", "))
+	}
+	return nsN, nil
+}
@@ -0,0 +1,158 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"context"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+const centralImportSecretNamespace = "import-secrets"
+
+func Test_importSecretLocation_importSecretNsN(t *testing.T) {
+	managedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster-location",
+		},
+	}
+	tests := []struct {
+		name              string
+		namespaceTemplate string
+		nameTemplate      string
+		want              types.NamespacedName
+		wantParseErr      bool
+		wantErr           bool
+	}{
+		{
+			name: "cluster namespace",
+			want: types.NamespacedName{Name: "cluster-location-import", Namespace: "cluster-location"},
+		},
+		{
+			name:              "central namespace",
+			namespaceTemplate: centralImportSecretNamespace,
+			want:              types.NamespacedName{Name: "cluster-location-import", Namespace: centralImportSecretNamespace},
+		},
+		{
+			name:              "central namespace and name template",
+			namespaceTemplate: centralImportSecretNamespace,
+			nameTemplate:      "import-{{ .ClusterName }}",
+			want:              types.NamespacedName{Name: "import-cluster-location", Namespace: centralImportSecretNamespace},
+		},
+		{
+			name:              "invalid template",
+			namespaceTemplate: "{{ .ClusterName",
+			wantParseErr:      true,
+		},
+		{
+			name:              "unknown template field",
+			namespaceTemplate: "{{ .Namespace }}",
+			wantErr:           true,
+		},
+		{
+			name:              "invalid namespace",
+			namespaceTemplate: "Import_Secrets",
+			wantErr:           true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			location, err := newImportSecretLocation(tt.namespaceTemplate, tt.nameTemplate)
+			if (err != nil) != tt.wantParseErr {
+				t.Fatalf("newImportSecretLocation() error = %v, wantErr %v", err, tt.wantParseErr)
+			}
+			if err != nil {
+				return
+			}
+			got, err := location.importSecretNsN(managedCluster)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("importSecretNsN() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("importSecretNsN() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_createOrUpdateImportSecretCentralNamespace(t *testing.T) {
+	managedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster-central",
+		},
+	}
+	location, err := newImportSecretLocation(centralImportSecretNamespace, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldImportSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "cluster-old" + importSecretNamePostfix,
+			Namespace: centralImportSecretNamespace,
+			Labels:    map[string]string{clusterLabel: "cluster-old", importSecretLabel: "true"},
+		},
+	}
+	otherClusterImportSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "cluster-other" + importSecretNamePostfix,
+			Namespace: centralImportSecretNamespace,
+			Labels:    map[string]string{clusterLabel: "cluster-other", importSecretLabel: "true"},
+		},
+	}
+
+	c := newRenderFakeClient(t, managedCluster, oldImportSecret, otherClusterImportSecret)
+	crds, yamls, err := generateImportYAMLs(c, nil, nil, managedCluster, []string{})
+	if err != nil {
+		t.Fatalf("generateImportYAMLs() error = %v", err)
+	}
+	if _, err := createOrUpdateImportSecret(c, scheme.Scheme, location, managedCluster, crds, yamls); err != nil {
+		t.Fatalf("createOrUpdateImportSecret() error = %v", err)
+	}
+	if err := deleteStaleImportSecrets(c, location, managedCluster, "cluster-old"); err != nil {
+		t.Fatalf("deleteStaleImportSecrets() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		nsN       types.NamespacedName
+		wantExist bool
+	}{
+		{
+			name:      "import secret in the central namespace",
+			nsN:       types.NamespacedName{Name: "cluster-central-import", Namespace: centralImportSecretNamespace},
+			wantExist: true,
+		},
+		{
+			name:      "no import secret in the cluster namespace",
+			nsN:       types.NamespacedName{Name: "cluster-central-import", Namespace: managedCluster.Name},
+			wantExist: false,
+		},
+		{
+			name:      "import secret of the previous name is deleted",
+			nsN:       types.NamespacedName{Name: oldImportSecret.Name, Namespace: centralImportSecretNamespace},
+			wantExist: false,
+		},
+		{
+			name:      "import secret of another cluster is kept",
+			nsN:       types.NamespacedName{Name: otherClusterImportSecret.Name, Namespace: centralImportSecretNamespace},
+			wantExist: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := c.Get(context.TODO(), tt.nsN, &corev1.Secret{})
+			if err != nil && !errors.IsNotFound(err) {
+				t.Fatal(err)
+			}
+			if exist := err == nil; exist != tt.wantExist {
+				t.Errorf("secret %s exists = %v, want %v", tt.nsN, exist, tt.wantExist)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// outcomes of the import secret generation
+const (
+	importSecretGenerationSucceeded   = "success"
+	importSecretGenerationRenderError = "render_error"
+	importSecretGenerationWriteError  = "write_error"
+)
+
+var importSecretGenerationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "managedcluster_import_secret_generation_seconds",
+	Help:    "Duration of the generation of the import secrets, render of the import manifests and write of the secret",
+	Buckets: prometheus.ExponentialBuckets(0.01, 2, 12),
+}, []string{"outcome"})
+
+func init() {
+	metrics.Registry.MustRegister(importSecretGenerationSeconds)
+}
+
+// generateImportSecret renders the import manifests of the managed cluster and writes them in its import secret,
+// the duration of the generation is recorded by outcome. It returns the rendered manifests.
+func (r *ReconcileManagedCluster) generateImportSecret(
+	managedCluster *clusterv1.ManagedCluster,
+) (crds map[string][]*unstructured.Unstructured, yamls []*unstructured.Unstructured, err error) {
+	start := time.Now()
+	observe := func(outcome string) {
+		importSecretGenerationSeconds.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+	}
+
+	crds, yamls, err = generateImportYAMLs(r.client, r.bootstrapTokens, r.manifestsWebhook, managedCluster,
+		[]string{})
+	if err != nil {
+		observe(importSecretGenerationRenderError)
+		return nil, nil, err
+	}
+
+	log.Info("createOrUpdateImportSecret", "cluster", managedCluster.Name)
+	if _, err := createOrUpdateImportSecret(r.client, r.scheme, r.importSecretLocation, managedCluster, crds, yamls); err != nil {
+		log.Error(err, "create ManagedCluster Import Secret")
+		observe(importSecretGenerationWriteError)
+		return nil, nil, err
+	}
+	observe(importSecretGenerationSucceeded)
+	return crds, yamls, nil
+}
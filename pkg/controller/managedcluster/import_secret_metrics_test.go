@@ -0,0 +1,99 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"os"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// importSecretGenerations returns the number of import secret generations recorded for the outcome
+func importSecretGenerations(t *testing.T, outcome string) uint64 {
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != "managedcluster_import_secret_generation_seconds" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if label.GetName() == "outcome" && label.GetValue() == outcome {
+					return m.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestReconcileManagedCluster_generateImportSecret(t *testing.T) {
+	os.Setenv(registrationOperatorImageEnvVarName, "quay.io/open-cluster-management/registration-operator:latest")
+	os.Setenv(registrationImageEnvVarName, "quay.io/open-cluster-management/registration:latest")
+	os.Setenv(workImageEnvVarName, "quay.io/open-cluster-management/work:latest")
+
+	managedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster1",
+		},
+	}
+	invalidLocation, err := newImportSecretLocation("Invalid_Namespace", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		client   client.Client
+		location *importSecretLocation
+		outcome  string
+		wantErr  bool
+	}{
+		{
+			name:    "generated",
+			client:  newRenderFakeClient(t, managedCluster),
+			outcome: importSecretGenerationSucceeded,
+		},
+		{
+			name:    "render failed",
+			client:  fake.NewFakeClientWithScheme(scheme.Scheme, managedCluster),
+			outcome: importSecretGenerationRenderError,
+			wantErr: true,
+		},
+		{
+			name:     "write failed",
+			client:   newRenderFakeClient(t, managedCluster),
+			location: invalidLocation,
+			outcome:  importSecretGenerationWriteError,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ReconcileManagedCluster{
+				client:               tt.client,
+				scheme:               scheme.Scheme,
+				importSecretLocation: tt.location,
+			}
+			before := importSecretGenerations(t, tt.outcome)
+			_, yamls, err := r.generateImportSecret(managedCluster)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("generateImportSecret() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && len(yamls) == 0 {
+				t.Error("generateImportSecret() returned no import manifests")
+			}
+			if got := importSecretGenerations(t, tt.outcome); got != before+1 {
+				t.Errorf("%s generations = %d, want %d", tt.outcome, got, before+1)
+			}
+		})
+	}
+}
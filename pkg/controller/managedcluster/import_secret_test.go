@@ -218,12 +218,12 @@ func Test_newImportSecret(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Logf("Test name: %s", tt.name)
-			crds, yamls, err := generateImportYAMLs(tt.args.client, tt.args.managedCluster, []string{})
+			crds, yamls, err := generateImportYAMLs(tt.args.client, nil, nil, tt.args.managedCluster, []string{})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("generateImportYAMLs error=%v, wantErr %v", err, tt.wantErr)
 			}
 
-			got, err := newImportSecret(tt.args.managedCluster, crds, yamls)
+			got, err := newImportSecret(nil, tt.args.managedCluster, crds, yamls)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("newImportSecret() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -315,22 +315,22 @@ func Test_createOrUpdateImportSecret(t *testing.T) {
 		imagePullSecret,
 	)
 
-	crds, yamls, err := generateImportYAMLs(fakeClient, managedCluster, []string{})
+	crds, yamls, err := generateImportYAMLs(fakeClient, nil, nil, managedCluster, []string{})
 	if err != nil {
 		t.Errorf("generateImportYAMLs error=%v", err)
 	}
 
-	importSecret, err := newImportSecret(managedCluster, crds, yamls)
+	importSecret, err := newImportSecret(nil, managedCluster, crds, yamls)
 	if err != nil {
 		t.Errorf("fail to initialize import secret, error = %v", err)
 	}
 
-	crdsUpdate, yamlsUpdate, err := generateImportYAMLs(fakeClient, managedCluster, []string{})
+	crdsUpdate, yamlsUpdate, err := generateImportYAMLs(fakeClient, nil, nil, managedCluster, []string{})
 	if err != nil {
 		t.Errorf("generateImportYAMLs error=%v", err)
 	}
 
-	importSecretUpdate, err := newImportSecret(managedCluster, crdsUpdate, yamlsUpdate)
+	importSecretUpdate, err := newImportSecret(nil, managedCluster, crdsUpdate, yamlsUpdate)
 	if err != nil {
 		t.Errorf("fail to initialize import secret, error = %v", err)
 	}
@@ -420,6 +420,7 @@ func Test_createOrUpdateImportSecret(t *testing.T) {
 			t.Logf("Test name: %s", tt.name)
 			got, err := createOrUpdateImportSecret(tt.args.client,
 				tt.args.scheme,
+				nil,
 				tt.args.managedCluster,
 				tt.args.crds,
 				tt.args.yamls)
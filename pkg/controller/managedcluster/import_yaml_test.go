@@ -6,15 +6,19 @@ package managedcluster
 
 import (
 	"crypto/x509"
+	"encoding/base64"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"reflect"
 	"testing"
 
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
 	ocinfrav1 "github.com/openshift/api/config/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
@@ -350,3 +354,182 @@ func Test_getValidCertificatesFromURL(t *testing.T) {
 		})
 	}
 }
+
+func Test_createKubeconfigDataInsecureSkipTLSVerify(t *testing.T) {
+	s := scheme.Scheme
+	s.AddKnownTypes(ocinfrav1.SchemeGroupVersion, &ocinfrav1.Infrastructure{}, &ocinfrav1.APIServer{})
+
+	infraConfig := &ocinfrav1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster",
+		},
+		Status: ocinfrav1.InfrastructureStatus{
+			APIServerURL: "https://127.0.0.1:6443",
+		},
+	}
+	tokenSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-sa-token",
+			Namespace: "test-namespace",
+		},
+		Data: map[string][]byte{
+			"token":  []byte("fake-token"),
+			"ca.crt": []byte("default-cert-data"),
+		},
+		Type: corev1.SecretTypeServiceAccountToken,
+	}
+
+	tests := []struct {
+		name         string
+		insecure     string
+		devMode      string
+		wantInsecure bool
+	}{
+		{
+			name:         "default",
+			wantInsecure: false,
+		},
+		{
+			name:         "insecure requested in production mode",
+			insecure:     "true",
+			wantInsecure: false,
+		},
+		{
+			name:         "dev mode only",
+			devMode:      "true",
+			wantInsecure: false,
+		},
+		{
+			name:         "insecure requested in dev mode",
+			insecure:     "true",
+			devMode:      "true",
+			wantInsecure: true,
+		},
+		{
+			name:         "insecure disabled in dev mode",
+			insecure:     "false",
+			devMode:      "true",
+			wantInsecure: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(insecureSkipTLSVerifyEnvVarName, tt.insecure)
+			os.Setenv(devModeEnvVarName, tt.devMode)
+			defer os.Unsetenv(insecureSkipTLSVerifyEnvVarName)
+			defer os.Unsetenv(devModeEnvVarName)
+
+			got, err := createKubeconfigData(fake.NewFakeClientWithScheme(s, infraConfig), tokenSecret)
+			if err != nil {
+				t.Fatalf("createKubeconfigData() error = %v", err)
+			}
+			bootstrapConfig := &clientcmdapi.Config{}
+			if err := runtime.DecodeInto(clientcmdlatest.Codec, got, bootstrapConfig); err != nil {
+				t.Fatal(err)
+			}
+			cluster := bootstrapConfig.Clusters["default-cluster"]
+			if cluster.InsecureSkipTLSVerify != tt.wantInsecure {
+				t.Errorf("insecure-skip-tls-verify = %v, want %v", cluster.InsecureSkipTLSVerify, tt.wantInsecure)
+			}
+			if tt.wantInsecure && len(cluster.CertificateAuthorityData) != 0 {
+				t.Error("an insecure kubeconfig must not carry a certificate authority")
+			}
+			if !tt.wantInsecure && string(cluster.CertificateAuthorityData) != "default-cert-data" {
+				t.Errorf("certificate authority = %s, want default-cert-data", cluster.CertificateAuthorityData)
+			}
+		})
+	}
+}
+
+func Test_generateImportYAMLsClusterImagePullSecret(t *testing.T) {
+	dockerConfig := []byte(`{"auths":{"registry.example.com":{"auth":"dXNlcjpwYXNzd29yZA=="}}}`)
+	newPullSecret := func(name, namespace string, secretType corev1.SecretType, data map[string][]byte) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Type: secretType,
+			Data: data,
+		}
+	}
+	newManagedCluster := func(name string) *clusterv1.ManagedCluster {
+		return &clusterv1.ManagedCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+				Annotations: map[string]string{
+					imagePullSecretAnnotation: "private-registry",
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name           string
+		managedCluster *clusterv1.ManagedCluster
+		secret         *corev1.Secret
+		wantErr        bool
+	}{
+		{
+			name:           "cluster image pull secret",
+			managedCluster: newManagedCluster("cluster-pull-secret"),
+			secret: newPullSecret("private-registry", "cluster-pull-secret", corev1.SecretTypeDockerConfigJson,
+				map[string][]byte{corev1.DockerConfigJsonKey: dockerConfig}),
+		},
+		{
+			name:           "cluster image pull secret not found",
+			managedCluster: newManagedCluster("cluster-pull-secret-missing"),
+			wantErr:        true,
+		},
+		{
+			name:           "cluster image pull secret of another namespace",
+			managedCluster: newManagedCluster("cluster-pull-secret-other-ns"),
+			secret: newPullSecret("private-registry", "other", corev1.SecretTypeDockerConfigJson,
+				map[string][]byte{corev1.DockerConfigJsonKey: dockerConfig}),
+			wantErr: true,
+		},
+		{
+			name:           "invalid cluster image pull secret",
+			managedCluster: newManagedCluster("cluster-pull-secret-invalid"),
+			secret: newPullSecret("private-registry", "cluster-pull-secret-invalid", corev1.SecretTypeOpaque,
+				map[string][]byte{"password": []byte("password")}),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := []runtime.Object{}
+			if tt.secret != nil {
+				objs = append(objs, tt.secret)
+			}
+			_, yamls, err := generateImportYAMLs(newRenderFakeClient(t, tt.managedCluster, objs...), nil, nil, tt.managedCluster, []string{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("generateImportYAMLs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			var pullSecretData, serviceAccountPullSecret string
+			for _, y := range yamls {
+				switch {
+				case y.GetKind() == "Secret" && y.GetName() == managedClusterImagePullSecretName:
+					pullSecretData, _, _ = unstructured.NestedString(y.Object, "data", corev1.DockerConfigJsonKey)
+				case y.GetKind() == "ServiceAccount" && y.GetName() == "klusterlet":
+					pullSecrets, _, _ := unstructured.NestedSlice(y.Object, "imagePullSecrets")
+					if len(pullSecrets) == 1 {
+						serviceAccountPullSecret, _, _ = unstructured.NestedString(
+							pullSecrets[0].(map[string]interface{}), "name")
+					}
+				}
+			}
+			if want := base64.StdEncoding.EncodeToString(dockerConfig); pullSecretData != want {
+				t.Errorf("image pull secret data = %q, want %q", pullSecretData, want)
+			}
+			if serviceAccountPullSecret != managedClusterImagePullSecretName {
+				t.Errorf("klusterlet service account image pull secret = %q, want %q",
+					serviceAccountPullSecret, managedClusterImagePullSecretName)
+			}
+		})
+	}
+}
@@ -11,6 +11,8 @@ import (
 	"encoding/base64"
 	"fmt"
 	"os"
+	"strconv"
+	"time"
 
 	"k8s.io/klog"
 
@@ -36,10 +38,19 @@ const (
 	klusterletNamespace                 = "open-cluster-management-agent"
 	envVarNotDefined                    = "environment variable %s not defined"
 	managedClusterImagePullSecretName   = "open-cluster-management-image-pull-credentials"
+	// imagePullSecretAnnotation names a secret of the cluster namespace used as image pull secret
+	// of the klusterlet instead of the default one
+	imagePullSecretAnnotation = "import.open-cluster-management.io/image-pull-secret"
+	// insecureSkipTLSVerifyEnvVarName requests bootstrap kubeconfigs skipping the hub certificate verification,
+	// it is only honored when the controller runs in development mode (devModeEnvVarName=true)
+	insecureSkipTLSVerifyEnvVarName = "BOOTSTRAP_INSECURE_SKIP_TLS_VERIFY"
+	devModeEnvVarName               = "DEV_MODE"
 )
 
 func generateImportYAMLs(
 	client client.Client,
+	bootstrapTokens *bootstrapTokenRequester,
+	manifestsWebhook *manifestsWebhook,
 	managedCluster *clusterv1.ManagedCluster,
 	excluded []string,
 ) (crds map[string][]*unstructured.Unstructured, yamls []*unstructured.Unstructured, err error) {
@@ -67,6 +78,10 @@ func generateImportYAMLs(
 	if err != nil {
 		return nil, nil, err
 	}
+	bootStrapSecret, err = bootstrapTokens.bootstrapToken(managedCluster, bootStrapSecret, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
 
 	klog.V(4).Infof("createKubeconfigData for bootsrapSecret %s", bootStrapSecret.Name)
 	bootstrapKubeconfigData, err := createKubeconfigData(client, bootStrapSecret)
@@ -76,7 +91,7 @@ func generateImportYAMLs(
 
 	useImagePullSecret := false
 	imagePullSecretDataBase64 := ""
-	imagePullSecret, err := getImagePullSecret(client)
+	imagePullSecret, err := getImagePullSecret(client, managedCluster)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -142,12 +157,38 @@ func generateImportYAMLs(
 		return nil, nil, err
 	}
 
+	if err := customizeKlusterletDeployment(managedCluster, klusterletYAMLs); err != nil {
+		return nil, nil, err
+	}
+
+	klusterletYAMLs, err = manifestsWebhook.mutate(managedCluster, klusterletYAMLs)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	yamls = append(yamls, klusterletYAMLs...)
 
 	return crds, yamls, nil
 }
 
-func getImagePullSecret(client client.Client) (*corev1.Secret, error) {
+// getImagePullSecret returns the image pull secret copied in the agent namespace of the managed cluster,
+// the secret named by the imagePullSecretAnnotation in the cluster namespace if set on the managedCluster,
+// otherwise the DEFAULT_IMAGE_PULL_SECRET of the POD_NAMESPACE.
+func getImagePullSecret(client client.Client, managedCluster *clusterv1.ManagedCluster) (*corev1.Secret, error) {
+	if name := managedCluster.GetAnnotations()[imagePullSecretAnnotation]; name != "" {
+		secret := &corev1.Secret{}
+		if err := client.Get(context.TODO(), types.NamespacedName{
+			Name:      name,
+			Namespace: managedCluster.Name,
+		}, secret); err != nil {
+			return nil, err
+		}
+		if secret.Type != corev1.SecretTypeDockerConfigJson || len(secret.Data[corev1.DockerConfigJsonKey]) == 0 {
+			return nil, fmt.Errorf("image pull secret %s/%s must be of type %s with a %s key",
+				managedCluster.Name, name, corev1.SecretTypeDockerConfigJson, corev1.DockerConfigJsonKey)
+		}
+		return secret, nil
+	}
 	if os.Getenv("DEFAULT_IMAGE_PULL_SECRET") == "" {
 		return nil, nil
 	}
@@ -208,6 +249,24 @@ func getValidCertificatesFromURL(serverURL string, rootCAs *x509.CertPool) ([]*x
 	return retCerts, nil
 }
 
+// bootstrapInsecureSkipTLSVerify returns true if the bootstrap kubeconfig must skip the hub certificate verification.
+// This is meant for development hubs using self-signed certificates and is never allowed outside the development mode.
+func bootstrapInsecureSkipTLSVerify() bool {
+	insecure, err := strconv.ParseBool(os.Getenv(insecureSkipTLSVerifyEnvVarName))
+	if err != nil || !insecure {
+		return false
+	}
+	devMode, err := strconv.ParseBool(os.Getenv(devModeEnvVarName))
+	if err != nil || !devMode {
+		log.Error(fmt.Errorf("%s is only allowed in development mode", insecureSkipTLSVerifyEnvVarName),
+			"Ignoring insecure bootstrap kubeconfig request, set "+devModeEnvVarName+"=true to enable it")
+		return false
+	}
+	log.Info("WARNING: DEVELOPMENT MODE, the bootstrap kubeconfig skips the hub TLS certificate verification, " +
+		"never use it in production")
+	return true
+}
+
 func createKubeconfigData(client client.Client, bootStrapSecret *corev1.Secret) ([]byte, error) {
 	saToken := bootStrapSecret.Data["token"]
 
@@ -216,8 +275,10 @@ func createKubeconfigData(client client.Client, bootStrapSecret *corev1.Secret)
 		return nil, err
 	}
 
+	insecureSkipTLSVerify := bootstrapInsecureSkipTLSVerify()
+
 	var certData []byte
-	if u, err := url.Parse(kubeAPIServer); err == nil {
+	if u, err := url.Parse(kubeAPIServer); err == nil && !insecureSkipTLSVerify {
 		apiServerCertSecretName, err := getKubeAPIServerSecretName(client, u.Hostname())
 		if err != nil {
 			return nil, err
@@ -230,7 +291,7 @@ func createKubeconfigData(client client.Client, bootStrapSecret *corev1.Secret)
 			certData = apiServerCert
 		}
 	}
-	if len(certData) == 0 {
+	if len(certData) == 0 && !insecureSkipTLSVerify {
 		// fallback to service account token ca.crt
 		if _, ok := bootStrapSecret.Data["ca.crt"]; ok {
 			certData = bootStrapSecret.Data["ca.crt"]
@@ -256,11 +317,16 @@ func createKubeconfigData(client client.Client, bootStrapSecret *corev1.Secret)
 		}
 	}
 
+	certData, err = hubCABundle(client, certData, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
 	bootstrapConfig := clientcmdapi.Config{
 		// Define a cluster stanza based on the bootstrap kubeconfig.
 		Clusters: map[string]*clientcmdapi.Cluster{"default-cluster": {
 			Server:                   kubeAPIServer,
-			InsecureSkipTLSVerify:    false,
+			InsecureSkipTLSVerify:    insecureSkipTLSVerify,
 			CertificateAuthorityData: certData,
 		}},
 		// Define auth based on the obtained client cert.
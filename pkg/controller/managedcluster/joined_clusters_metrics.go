@@ -0,0 +1,122 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"context"
+	"sync"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var joinedClustersGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "managedcluster_import_joined_clusters",
+	Help: "Number of managed clusters which completed their import and joined the hub",
+})
+
+func init() {
+	metrics.Registry.MustRegister(joinedClustersGauge)
+}
+
+// joinedClusters tracks the managed clusters having the joined condition and maintains the gauge
+type joinedClusters struct {
+	lock     sync.Mutex
+	clusters map[string]bool
+	gauge    prometheus.Gauge
+}
+
+func newJoinedClusters(gauge prometheus.Gauge) *joinedClusters {
+	return &joinedClusters{
+		clusters: map[string]bool{},
+		gauge:    gauge,
+	}
+}
+
+func isJoined(managedCluster *clusterv1.ManagedCluster) bool {
+	return meta.IsStatusConditionTrue(managedCluster.Status.Conditions, clusterv1.ManagedClusterConditionJoined)
+}
+
+// update records whether the managed cluster is joined
+func (j *joinedClusters) update(managedCluster *clusterv1.ManagedCluster) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if isJoined(managedCluster) {
+		j.clusters[managedCluster.Name] = true
+	} else {
+		delete(j.clusters, managedCluster.Name)
+	}
+	j.gauge.Set(float64(len(j.clusters)))
+}
+
+// remove forgets a deleted managed cluster
+func (j *joinedClusters) remove(name string) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	delete(j.clusters, name)
+	j.gauge.Set(float64(len(j.clusters)))
+}
+
+// reset recomputes the joined clusters from all managed clusters
+func (j *joinedClusters) reset(managedClusters []clusterv1.ManagedCluster) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.clusters = map[string]bool{}
+	for i := range managedClusters {
+		if isJoined(&managedClusters[i]) {
+			j.clusters[managedClusters[i].Name] = true
+		}
+	}
+	j.gauge.Set(float64(len(j.clusters)))
+}
+
+// OnAdd implements toolscache.ResourceEventHandler
+func (j *joinedClusters) OnAdd(obj interface{}) {
+	if managedCluster, ok := obj.(*clusterv1.ManagedCluster); ok {
+		j.update(managedCluster)
+	}
+}
+
+// OnUpdate implements toolscache.ResourceEventHandler
+func (j *joinedClusters) OnUpdate(oldObj, newObj interface{}) {
+	j.OnAdd(newObj)
+}
+
+// OnDelete implements toolscache.ResourceEventHandler
+func (j *joinedClusters) OnDelete(obj interface{}) {
+	if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if managedCluster, ok := obj.(*clusterv1.ManagedCluster); ok {
+		j.remove(managedCluster.Name)
+	}
+}
+
+// addJoinedClustersMetrics maintains the joined clusters gauge from the ManagedCluster informer,
+// the gauge is recomputed from all managed clusters once the cache is synced on startup.
+func addJoinedClustersMetrics(mgr manager.Manager) error {
+	joined := newJoinedClusters(joinedClustersGauge)
+	informer, err := mgr.GetCache().GetInformer(context.TODO(), &clusterv1.ManagedCluster{})
+	if err != nil {
+		return err
+	}
+	informer.AddEventHandler(joined)
+
+	return mgr.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+		if !mgr.GetCache().WaitForCacheSync(stop) {
+			return nil
+		}
+		managedClusters := &clusterv1.ManagedClusterList{}
+		if err := mgr.GetClient().List(context.TODO(), managedClusters, &client.ListOptions{}); err != nil {
+			log.Error(err, "Failed to list managed clusters to compute the joined clusters metrics")
+			return nil
+		}
+		joined.reset(managedClusters.Items)
+		return nil
+	}))
+}
@@ -0,0 +1,100 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+)
+
+func newJoinedTestCluster(name string, joined metav1.ConditionStatus) *clusterv1.ManagedCluster {
+	managedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+	}
+	if joined != "" {
+		managedCluster.Status.Conditions = []metav1.Condition{
+			{
+				Type:   clusterv1.ManagedClusterConditionJoined,
+				Status: joined,
+				Reason: "Test",
+			},
+		}
+	}
+	return managedCluster
+}
+
+func Test_joinedClusters(t *testing.T) {
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_joined_clusters"})
+	joined := newJoinedClusters(gauge)
+
+	tests := []struct {
+		name  string
+		event func()
+		want  float64
+	}{
+		{
+			name:  "cluster created not joined",
+			event: func() { joined.OnAdd(newJoinedTestCluster("cluster1", "")) },
+			want:  0,
+		},
+		{
+			name: "cluster joins",
+			event: func() {
+				joined.OnUpdate(newJoinedTestCluster("cluster1", ""), newJoinedTestCluster("cluster1", metav1.ConditionTrue))
+			},
+			want: 1,
+		},
+		{
+			name:  "joined cluster added",
+			event: func() { joined.OnAdd(newJoinedTestCluster("cluster2", metav1.ConditionTrue)) },
+			want:  2,
+		},
+		{
+			name: "joined cluster updated",
+			event: func() {
+				joined.OnUpdate(newJoinedTestCluster("cluster2", metav1.ConditionTrue), newJoinedTestCluster("cluster2", metav1.ConditionTrue))
+			},
+			want: 2,
+		},
+		{
+			name: "cluster detached",
+			event: func() {
+				joined.OnUpdate(newJoinedTestCluster("cluster1", metav1.ConditionTrue), newJoinedTestCluster("cluster1", metav1.ConditionFalse))
+			},
+			want: 1,
+		},
+		{
+			name: "joined cluster deleted",
+			event: func() {
+				joined.OnDelete(toolscache.DeletedFinalStateUnknown{Obj: newJoinedTestCluster("cluster2", metav1.ConditionTrue)})
+			},
+			want: 0,
+		},
+		{
+			name: "recomputed on startup",
+			event: func() {
+				joined.reset([]clusterv1.ManagedCluster{
+					*newJoinedTestCluster("cluster1", metav1.ConditionTrue),
+					*newJoinedTestCluster("cluster2", metav1.ConditionFalse),
+					*newJoinedTestCluster("cluster3", metav1.ConditionTrue),
+				})
+			},
+			want: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.event()
+			if got := testutil.ToFloat64(gauge); got != tt.want {
+				t.Errorf("joined clusters = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
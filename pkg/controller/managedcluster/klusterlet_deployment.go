@@ -0,0 +1,285 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	klusterletDeploymentName = "klusterlet"
+
+	// klusterletRestartAnnotation is the ManagedCluster annotation an operator can change to force
+	// the restart of the klusterlet on the next apply of the import manifests
+	klusterletRestartAnnotation = "import.open-cluster-management.io/klusterlet-restart"
+	// klusterletConfigHashAnnotation is stamped on the klusterlet pod template so that the klusterlet
+	// pods are rolled when its configuration changes
+	klusterletConfigHashAnnotation = "import.open-cluster-management.io/config-hash"
+	// klusterletLogLevelAnnotation is the ManagedCluster annotation setting the klog verbosity of the
+	// klusterlet, the klusterlet runs with the standard verbosity if not set
+	klusterletLogLevelAnnotation = "import.open-cluster-management.io/klusterlet-log-level"
+
+	maxKlusterletLogLevel = 10
+
+	// klusterletTopologySpreadConstraintsAnnotation is the ManagedCluster annotation holding the JSON list of
+	// the topology spread constraints of the klusterlet pods
+	klusterletTopologySpreadConstraintsAnnotation = "import.open-cluster-management.io/klusterlet-topology-spread-constraints"
+
+	// klusterletDNSConfigAnnotation is the ManagedCluster annotation holding the JSON DNS config of the
+	// klusterlet pods, for example extra search domains to resolve the hub
+	klusterletDNSConfigAnnotation = "import.open-cluster-management.io/klusterlet-dns-config"
+
+	// klusterletDeploymentStrategyAnnotation is the ManagedCluster annotation setting the strategy of the
+	// klusterlet deployment, Recreate or RollingUpdate, the klusterlet is rolled if not set
+	klusterletDeploymentStrategyAnnotation = "import.open-cluster-management.io/klusterlet-deployment-strategy"
+
+	// limits of the DNS config of a pod enforced by the apiserver
+	maxDNSNameservers    = 3
+	maxDNSSearches       = 6
+	maxDNSSearchListSize = 256
+)
+
+// getKlusterletDeployment returns the klusterlet deployment of the import yamls, nil if not found
+func getKlusterletDeployment(yamls []*unstructured.Unstructured) *unstructured.Unstructured {
+	for _, y := range yamls {
+		if y.GetKind() == "Deployment" &&
+			y.GetName() == klusterletDeploymentName &&
+			y.GetNamespace() == klusterletNamespace {
+			return y
+		}
+	}
+	return nil
+}
+
+// customizeKlusterletDeployment applies the ManagedCluster customizations on the klusterlet deployment
+func customizeKlusterletDeployment(managedCluster *clusterv1.ManagedCluster, yamls []*unstructured.Unstructured) error {
+	deployment := getKlusterletDeployment(yamls)
+	if deployment == nil {
+		return nil
+	}
+	if err := setKlusterletLogLevel(managedCluster, deployment); err != nil {
+		return err
+	}
+	if err := setKlusterletTopologySpreadConstraints(managedCluster, deployment); err != nil {
+		return err
+	}
+	if err := setKlusterletResourceProfile(managedCluster, deployment); err != nil {
+		return err
+	}
+	if err := setKlusterletDNSConfig(managedCluster, deployment); err != nil {
+		return err
+	}
+	if err := setKlusterletProbes(managedCluster, deployment); err != nil {
+		return err
+	}
+	if err := setKlusterletVolumes(managedCluster, deployment); err != nil {
+		return err
+	}
+	if err := setKlusterletInitContainers(managedCluster, deployment); err != nil {
+		return err
+	}
+	if err := setKlusterletDeploymentStrategy(managedCluster, deployment); err != nil {
+		return err
+	}
+	return setKlusterletRestartTrigger(managedCluster, deployment, yamls)
+}
+
+// setKlusterletLogLevel sets the --v flag of the klusterlet containers to the log level annotation
+// of the ManagedCluster
+func setKlusterletLogLevel(managedCluster *clusterv1.ManagedCluster, deployment *unstructured.Unstructured) error {
+	v, ok := managedCluster.GetAnnotations()[klusterletLogLevelAnnotation]
+	if !ok {
+		return nil
+	}
+	level, err := strconv.Atoi(v)
+	if err != nil || level < 0 || level > maxKlusterletLogLevel {
+		return fmt.Errorf("invalid %s annotation %q, must be an integer between 0 and %d",
+			klusterletLogLevelAnnotation, v, maxKlusterletLogLevel)
+	}
+
+	containers, _, err := unstructured.NestedSlice(deployment.Object, "spec", "template", "spec", "containers")
+	if err != nil {
+		return err
+	}
+	for i := range containers {
+		container, ok := containers[i].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		args, _, err := unstructured.NestedStringSlice(container, "args")
+		if err != nil {
+			return err
+		}
+		levelArgs := make([]string, 0, len(args)+1)
+		for _, arg := range args {
+			if !strings.HasPrefix(arg, "-v=") && !strings.HasPrefix(arg, "--v=") {
+				levelArgs = append(levelArgs, arg)
+			}
+		}
+		levelArgs = append(levelArgs, fmt.Sprintf("--v=%d", level))
+		if err := unstructured.SetNestedStringSlice(container, levelArgs, "args"); err != nil {
+			return err
+		}
+		containers[i] = container
+	}
+	return unstructured.SetNestedSlice(deployment.Object, containers, "spec", "template", "spec", "containers")
+}
+
+// parseTopologySpreadConstraints validates and parses the JSON list of topology spread constraints
+func parseTopologySpreadConstraints(v string) ([]corev1.TopologySpreadConstraint, error) {
+	decoder := json.NewDecoder(strings.NewReader(v))
+	decoder.DisallowUnknownFields()
+	constraints := []corev1.TopologySpreadConstraint{}
+	if err := decoder.Decode(&constraints); err != nil {
+		return nil, err
+	}
+	for i, constraint := range constraints {
+		if constraint.MaxSkew < 1 {
+			return nil, fmt.Errorf("constraint %d: maxSkew must be greater than zero", i)
+		}
+		if constraint.TopologyKey == "" {
+			return nil, fmt.Errorf("constraint %d: topologyKey is required", i)
+		}
+		if constraint.WhenUnsatisfiable != corev1.DoNotSchedule && constraint.WhenUnsatisfiable != corev1.ScheduleAnyway {
+			return nil, fmt.Errorf("constraint %d: whenUnsatisfiable must be %s or %s",
+				i, corev1.DoNotSchedule, corev1.ScheduleAnyway)
+		}
+	}
+	return constraints, nil
+}
+
+// setKlusterletTopologySpreadConstraints sets the topology spread constraints of the klusterlet pods to the
+// constraints annotation of the ManagedCluster
+func setKlusterletTopologySpreadConstraints(
+	managedCluster *clusterv1.ManagedCluster,
+	deployment *unstructured.Unstructured) error {
+	v, ok := managedCluster.GetAnnotations()[klusterletTopologySpreadConstraintsAnnotation]
+	if !ok {
+		return nil
+	}
+	constraints, err := parseTopologySpreadConstraints(v)
+	if err != nil {
+		return fmt.Errorf("invalid %s annotation: %v", klusterletTopologySpreadConstraintsAnnotation, err)
+	}
+	uconstraints := make([]interface{}, 0, len(constraints))
+	for i := range constraints {
+		uconstraint, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&constraints[i])
+		if err != nil {
+			return err
+		}
+		uconstraints = append(uconstraints, uconstraint)
+	}
+	return unstructured.SetNestedSlice(deployment.Object, uconstraints, "spec", "template", "spec", "topologySpreadConstraints")
+}
+
+// parseDNSConfig validates and parses the JSON DNS config of a pod
+func parseDNSConfig(v string) (*corev1.PodDNSConfig, error) {
+	decoder := json.NewDecoder(strings.NewReader(v))
+	decoder.DisallowUnknownFields()
+	dnsConfig := &corev1.PodDNSConfig{}
+	if err := decoder.Decode(dnsConfig); err != nil {
+		return nil, err
+	}
+	if len(dnsConfig.Nameservers) > maxDNSNameservers {
+		return nil, fmt.Errorf("at most %d nameservers are allowed", maxDNSNameservers)
+	}
+	for _, nameserver := range dnsConfig.Nameservers {
+		if net.ParseIP(nameserver) == nil {
+			return nil, fmt.Errorf("nameserver %q must be an IP address", nameserver)
+		}
+	}
+	if len(dnsConfig.Searches) > maxDNSSearches {
+		return nil, fmt.Errorf("at most %d search domains are allowed", maxDNSSearches)
+	}
+	if size := len(strings.Join(dnsConfig.Searches, " ")); size > maxDNSSearchListSize {
+		return nil, fmt.Errorf("the search domains must not exceed %d characters", maxDNSSearchListSize)
+	}
+	for _, search := range dnsConfig.Searches {
+		if errs := validation.IsDNS1123Subdomain(strings.TrimSuffix(search, ".")); len(errs) != 0 {
+			return nil, fmt.Errorf("search domain %q: %s", search, strings.Join(errs, ", "))
+		}
+	}
+	for i, option := range dnsConfig.Options {
+		if option.Name == "" {
+			return nil, fmt.Errorf("option %d: name is required", i)
+		}
+	}
+	return dnsConfig, nil
+}
+
+// setKlusterletDNSConfig sets the DNS config of the klusterlet pods to the DNS config annotation of the ManagedCluster
+func setKlusterletDNSConfig(managedCluster *clusterv1.ManagedCluster, deployment *unstructured.Unstructured) error {
+	v, ok := managedCluster.GetAnnotations()[klusterletDNSConfigAnnotation]
+	if !ok {
+		return nil
+	}
+	dnsConfig, err := parseDNSConfig(v)
+	if err != nil {
+		return fmt.Errorf("invalid %s annotation: %v", klusterletDNSConfigAnnotation, err)
+	}
+	udnsConfig, err := runtime.DefaultUnstructuredConverter.ToUnstructured(dnsConfig)
+	if err != nil {
+		return err
+	}
+	return unstructured.SetNestedMap(deployment.Object, udnsConfig, "spec", "template", "spec", "dnsConfig")
+}
+
+// setKlusterletDeploymentStrategy sets the strategy type of the klusterlet deployment to the deployment strategy
+// annotation of the ManagedCluster, RollingUpdate if not set. The strategy is always set so that removing the
+// annotation restores the rolling update on the managed cluster.
+func setKlusterletDeploymentStrategy(managedCluster *clusterv1.ManagedCluster, deployment *unstructured.Unstructured) error {
+	strategyType := appsv1.RollingUpdateDeploymentStrategyType
+	if v, ok := managedCluster.GetAnnotations()[klusterletDeploymentStrategyAnnotation]; ok {
+		strategyType = appsv1.DeploymentStrategyType(v)
+		if strategyType != appsv1.RecreateDeploymentStrategyType &&
+			strategyType != appsv1.RollingUpdateDeploymentStrategyType {
+			return fmt.Errorf("invalid %s annotation %q, must be %s or %s", klusterletDeploymentStrategyAnnotation, v,
+				appsv1.RecreateDeploymentStrategyType, appsv1.RollingUpdateDeploymentStrategyType)
+		}
+	}
+	return unstructured.SetNestedMap(deployment.Object, map[string]interface{}{"type": string(strategyType)},
+		"spec", "strategy")
+}
+
+// setKlusterletRestartTrigger stamps the klusterlet pod template with a hash of the klusterlet
+// configuration (secrets and Klusterlet CR) and of the restart annotation of the ManagedCluster.
+func setKlusterletRestartTrigger(
+	managedCluster *clusterv1.ManagedCluster,
+	deployment *unstructured.Unstructured,
+	yamls []*unstructured.Unstructured) error {
+	h := sha256.New()
+	for _, y := range yamls {
+		if y.GetKind() != "Secret" && y.GetKind() != "Klusterlet" {
+			continue
+		}
+		b, err := json.Marshal(y.Object)
+		if err != nil {
+			return err
+		}
+		h.Write(b)
+	}
+	h.Write([]byte(managedCluster.GetAnnotations()[klusterletRestartAnnotation]))
+
+	annotations, _, err := unstructured.NestedStringMap(deployment.Object, "spec", "template", "metadata", "annotations")
+	if err != nil {
+		return err
+	}
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[klusterletConfigHashAnnotation] = hex.EncodeToString(h.Sum(nil))
+	return unstructured.SetNestedStringMap(deployment.Object, annotations, "spec", "template", "metadata", "annotations")
+}
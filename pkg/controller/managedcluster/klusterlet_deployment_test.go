@@ -0,0 +1,492 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	ocinfrav1 "github.com/openshift/api/config/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newRenderFakeClient returns a fake client holding the resources required to generate
+// the import yamls of the managedCluster
+func newRenderFakeClient(t *testing.T, managedCluster *clusterv1.ManagedCluster, objs ...runtime.Object) client.Client {
+	os.Setenv("DEFAULT_IMAGE_PULL_SECRET", imagePullSecretNameSecret)
+	os.Setenv("POD_NAMESPACE", managedClusterNameSecret)
+
+	s := scheme.Scheme
+	s.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+	s.AddKnownTypes(ocinfrav1.SchemeGroupVersion, &ocinfrav1.Infrastructure{}, &ocinfrav1.APIServer{})
+
+	serviceAccount, err := newBootstrapServiceAccount(managedCluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenSecret, err := serviceAccountTokenSecret(serviceAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serviceAccount.Secrets = append(serviceAccount.Secrets, corev1.ObjectReference{
+		Name: tokenSecret.Name,
+	})
+	infraConfig := &ocinfrav1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster",
+		},
+		Status: ocinfrav1.InfrastructureStatus{
+			APIServerURL: "http://127.0.0.1:6443",
+		},
+	}
+
+	objs = append(objs, managedCluster, serviceAccount, tokenSecret, infraConfig, newFakeImagePullSecret())
+	return fake.NewFakeClientWithScheme(s, objs...)
+}
+
+// renderKlusterletDeployment generates the import yamls of the managedCluster and returns the klusterlet deployment
+func renderKlusterletDeployment(t *testing.T, managedCluster *clusterv1.ManagedCluster) *appsv1.Deployment {
+	_, yamls, err := generateImportYAMLs(newRenderFakeClient(t, managedCluster), nil, nil, managedCluster, []string{})
+	if err != nil {
+		t.Fatalf("generateImportYAMLs() error = %v", err)
+	}
+	u := getKlusterletDeployment(yamls)
+	if u == nil {
+		t.Fatal("klusterlet deployment not found")
+	}
+	deployment := &appsv1.Deployment{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, deployment); err != nil {
+		t.Fatal(err)
+	}
+	return deployment
+}
+
+func newKlusterletYAMLs(bootstrapKubeconfig string) []*unstructured.Unstructured {
+	return []*unstructured.Unstructured{
+		{
+			Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Secret",
+				"metadata": map[string]interface{}{
+					"name":      "bootstrap-hub-kubeconfig",
+					"namespace": klusterletNamespace,
+				},
+				"data": map[string]interface{}{
+					"kubeconfig": bootstrapKubeconfig,
+				},
+			},
+		},
+		{
+			Object: map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       "Deployment",
+				"metadata": map[string]interface{}{
+					"name":      klusterletDeploymentName,
+					"namespace": klusterletNamespace,
+				},
+			},
+		},
+	}
+}
+
+func Test_setKlusterletRestartTrigger(t *testing.T) {
+	managedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster-restart",
+		},
+	}
+	restartedManagedCluster := managedCluster.DeepCopy()
+	restartedManagedCluster.SetAnnotations(map[string]string{
+		klusterletRestartAnnotation: "2021-06-01T00:00:00Z",
+	})
+
+	configHash := func(managedCluster *clusterv1.ManagedCluster, yamls []*unstructured.Unstructured) string {
+		if err := customizeKlusterletDeployment(managedCluster, yamls); err != nil {
+			t.Fatal(err)
+		}
+		hash, _, _ := unstructured.NestedString(getKlusterletDeployment(yamls).Object,
+			"spec", "template", "metadata", "annotations", klusterletConfigHashAnnotation)
+		if hash == "" {
+			t.Fatal("config hash annotation not found")
+		}
+		return hash
+	}
+
+	reference := configHash(managedCluster, newKlusterletYAMLs("kubeconfig"))
+
+	tests := []struct {
+		name           string
+		managedCluster *clusterv1.ManagedCluster
+		yamls          []*unstructured.Unstructured
+		wantChanged    bool
+	}{
+		{
+			name:           "same configuration",
+			managedCluster: managedCluster,
+			yamls:          newKlusterletYAMLs("kubeconfig"),
+			wantChanged:    false,
+		},
+		{
+			name:           "bootstrap kubeconfig changed",
+			managedCluster: managedCluster,
+			yamls:          newKlusterletYAMLs("new-kubeconfig"),
+			wantChanged:    true,
+		},
+		{
+			name:           "restart requested",
+			managedCluster: restartedManagedCluster,
+			yamls:          newKlusterletYAMLs("kubeconfig"),
+			wantChanged:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if changed := configHash(tt.managedCluster, tt.yamls) != reference; changed != tt.wantChanged {
+				t.Errorf("config hash changed = %v, want %v", changed, tt.wantChanged)
+			}
+		})
+	}
+}
+
+func Test_generateImportYAMLsRestartTrigger(t *testing.T) {
+	managedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster-restart-render",
+		},
+	}
+	first := renderKlusterletDeployment(t, managedCluster)
+	second := renderKlusterletDeployment(t, managedCluster)
+	hash := first.Spec.Template.Annotations[klusterletConfigHashAnnotation]
+	if hash == "" {
+		t.Fatal("config hash annotation not found")
+	}
+	if hash != second.Spec.Template.Annotations[klusterletConfigHashAnnotation] {
+		t.Error("config hash is not stable across renders")
+	}
+	if first.Spec.Template.Annotations["target.workload.openshift.io/management"] == "" {
+		t.Error("existing pod template annotations must be kept")
+	}
+}
+
+func Test_generateImportYAMLsLogLevel(t *testing.T) {
+	tests := []struct {
+		name     string
+		logLevel string
+		wantArgs []string
+		wantErr  bool
+	}{
+		{
+			name:     "standard verbosity",
+			wantArgs: []string{"/registration-operator", "klusterlet"},
+		},
+		{
+			name:     "custom verbosity",
+			logLevel: "4",
+			wantArgs: []string{"/registration-operator", "klusterlet", "--v=4"},
+		},
+		{
+			name:     "invalid verbosity",
+			logLevel: "debug",
+			wantErr:  true,
+		},
+		{
+			name:     "verbosity out of range",
+			logLevel: "11",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			managedCluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "cluster-log-level",
+				},
+			}
+			if tt.logLevel != "" {
+				managedCluster.SetAnnotations(map[string]string{klusterletLogLevelAnnotation: tt.logLevel})
+			}
+			if tt.wantErr {
+				_, _, err := generateImportYAMLs(newRenderFakeClient(t, managedCluster), nil, nil, managedCluster, []string{})
+				if err == nil {
+					t.Error("generateImportYAMLs() expected an error")
+				}
+				return
+			}
+			deployment := renderKlusterletDeployment(t, managedCluster)
+			if args := deployment.Spec.Template.Spec.Containers[0].Args; !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("klusterlet args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func Test_setKlusterletLogLevel(t *testing.T) {
+	managedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "cluster-log-level",
+			Annotations: map[string]string{klusterletLogLevelAnnotation: "6"},
+		},
+	}
+	deployment := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"containers": []interface{}{
+							map[string]interface{}{
+								"name": "klusterlet",
+								"args": []interface{}{"klusterlet", "--v=2"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if err := setKlusterletLogLevel(managedCluster, deployment); err != nil {
+		t.Fatalf("setKlusterletLogLevel() error = %v", err)
+	}
+	containers, _, _ := unstructured.NestedSlice(deployment.Object, "spec", "template", "spec", "containers")
+	args, _, _ := unstructured.NestedStringSlice(containers[0].(map[string]interface{}), "args")
+	if want := []string{"klusterlet", "--v=6"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("klusterlet args = %v, want %v", args, want)
+	}
+}
+
+func Test_generateImportYAMLsTopologySpreadConstraints(t *testing.T) {
+	tests := []struct {
+		name            string
+		constraints     string
+		wantConstraints []corev1.TopologySpreadConstraint
+		wantErr         bool
+	}{
+		{
+			name: "no constraints",
+		},
+		{
+			name: "zone spread",
+			constraints: `[{"maxSkew":1,"topologyKey":"topology.kubernetes.io/zone","whenUnsatisfiable":"ScheduleAnyway",` +
+				`"labelSelector":{"matchLabels":{"app":"klusterlet"}}}]`,
+			wantConstraints: []corev1.TopologySpreadConstraint{
+				{
+					MaxSkew:           1,
+					TopologyKey:       "topology.kubernetes.io/zone",
+					WhenUnsatisfiable: corev1.ScheduleAnyway,
+					LabelSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"app": "klusterlet"},
+					},
+				},
+			},
+		},
+		{
+			name:        "invalid json",
+			constraints: `{"maxSkew":1`,
+			wantErr:     true,
+		},
+		{
+			name:        "unknown field",
+			constraints: `[{"maxSkew":1,"topologyKey":"topology.kubernetes.io/zone","whenUnsatisfiable":"ScheduleAnyway","skew":2}]`,
+			wantErr:     true,
+		},
+		{
+			name:        "missing topology key",
+			constraints: `[{"maxSkew":1,"whenUnsatisfiable":"ScheduleAnyway"}]`,
+			wantErr:     true,
+		},
+		{
+			name:        "invalid max skew",
+			constraints: `[{"maxSkew":0,"topologyKey":"topology.kubernetes.io/zone","whenUnsatisfiable":"ScheduleAnyway"}]`,
+			wantErr:     true,
+		},
+		{
+			name:        "invalid when unsatisfiable",
+			constraints: `[{"maxSkew":1,"topologyKey":"topology.kubernetes.io/zone","whenUnsatisfiable":"Never"}]`,
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			managedCluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "cluster-spread",
+				},
+			}
+			if tt.constraints != "" {
+				managedCluster.SetAnnotations(map[string]string{
+					klusterletTopologySpreadConstraintsAnnotation: tt.constraints,
+				})
+			}
+			if tt.wantErr {
+				_, _, err := generateImportYAMLs(newRenderFakeClient(t, managedCluster), nil, nil, managedCluster, []string{})
+				if err == nil {
+					t.Error("generateImportYAMLs() expected an error")
+				}
+				return
+			}
+			deployment := renderKlusterletDeployment(t, managedCluster)
+			if constraints := deployment.Spec.Template.Spec.TopologySpreadConstraints; !reflect.DeepEqual(constraints, tt.wantConstraints) {
+				t.Errorf("klusterlet topology spread constraints = %v, want %v", constraints, tt.wantConstraints)
+			}
+		})
+	}
+}
+
+func Test_generateImportYAMLsDNSConfig(t *testing.T) {
+	ndots := "2"
+	tests := []struct {
+		name          string
+		dnsConfig     string
+		wantDNSConfig *corev1.PodDNSConfig
+		wantErr       bool
+	}{
+		{
+			name: "no dns config",
+		},
+		{
+			name:      "search domains",
+			dnsConfig: `{"searches":["hub.example.com","example.com."],"options":[{"name":"ndots","value":"2"}]}`,
+			wantDNSConfig: &corev1.PodDNSConfig{
+				Searches: []string{"hub.example.com", "example.com."},
+				Options:  []corev1.PodDNSConfigOption{{Name: "ndots", Value: &ndots}},
+			},
+		},
+		{
+			name:          "nameservers",
+			dnsConfig:     `{"nameservers":["10.0.0.10","fd00::10"]}`,
+			wantDNSConfig: &corev1.PodDNSConfig{Nameservers: []string{"10.0.0.10", "fd00::10"}},
+		},
+		{
+			name:      "invalid json",
+			dnsConfig: `{"searches":`,
+			wantErr:   true,
+		},
+		{
+			name:      "unknown field",
+			dnsConfig: `{"search":["hub.example.com"]}`,
+			wantErr:   true,
+		},
+		{
+			name:      "invalid nameserver",
+			dnsConfig: `{"nameservers":["dns.example.com"]}`,
+			wantErr:   true,
+		},
+		{
+			name:      "too many nameservers",
+			dnsConfig: `{"nameservers":["10.0.0.1","10.0.0.2","10.0.0.3","10.0.0.4"]}`,
+			wantErr:   true,
+		},
+		{
+			name:      "invalid search domain",
+			dnsConfig: `{"searches":["Hub_Example.com"]}`,
+			wantErr:   true,
+		},
+		{
+			name:      "too many search domains",
+			dnsConfig: `{"searches":["a.com","b.com","c.com","d.com","e.com","f.com","g.com"]}`,
+			wantErr:   true,
+		},
+		{
+			name:      "option without name",
+			dnsConfig: `{"options":[{"value":"2"}]}`,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			managedCluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "cluster-dns",
+				},
+			}
+			if tt.dnsConfig != "" {
+				managedCluster.SetAnnotations(map[string]string{
+					klusterletDNSConfigAnnotation: tt.dnsConfig,
+				})
+			}
+			if tt.wantErr {
+				_, _, err := generateImportYAMLs(newRenderFakeClient(t, managedCluster), nil, nil, managedCluster, []string{})
+				if err == nil {
+					t.Error("generateImportYAMLs() expected an error")
+				}
+				return
+			}
+			deployment := renderKlusterletDeployment(t, managedCluster)
+			if dnsConfig := deployment.Spec.Template.Spec.DNSConfig; !reflect.DeepEqual(dnsConfig, tt.wantDNSConfig) {
+				t.Errorf("klusterlet dns config = %v, want %v", dnsConfig, tt.wantDNSConfig)
+			}
+		})
+	}
+}
+
+func Test_generateImportYAMLsDeploymentStrategy(t *testing.T) {
+	tests := []struct {
+		name         string
+		annotated    bool
+		strategy     string
+		wantStrategy appsv1.DeploymentStrategyType
+		wantErr      bool
+	}{
+		{
+			name:         "no strategy",
+			wantStrategy: appsv1.RollingUpdateDeploymentStrategyType,
+		},
+		{
+			name:         "recreate",
+			annotated:    true,
+			strategy:     "Recreate",
+			wantStrategy: appsv1.RecreateDeploymentStrategyType,
+		},
+		{
+			name:         "rolling update",
+			annotated:    true,
+			strategy:     "RollingUpdate",
+			wantStrategy: appsv1.RollingUpdateDeploymentStrategyType,
+		},
+		{
+			name:      "invalid strategy",
+			annotated: true,
+			strategy:  "recreate",
+			wantErr:   true,
+		},
+		{
+			name:      "empty strategy",
+			annotated: true,
+			strategy:  "",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			managedCluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "cluster-strategy",
+				},
+			}
+			if tt.annotated {
+				managedCluster.SetAnnotations(map[string]string{
+					klusterletDeploymentStrategyAnnotation: tt.strategy,
+				})
+			}
+			if tt.wantErr {
+				_, _, err := generateImportYAMLs(newRenderFakeClient(t, managedCluster), nil, nil, managedCluster, []string{})
+				if err == nil {
+					t.Error("generateImportYAMLs() expected an error")
+				}
+				return
+			}
+			deployment := renderKlusterletDeployment(t, managedCluster)
+			if strategy := deployment.Spec.Strategy; strategy.Type != tt.wantStrategy || strategy.RollingUpdate != nil {
+				t.Errorf("klusterlet deployment strategy = %+v, want type %s", strategy, tt.wantStrategy)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// klusterletInitContainersAnnotation is the ManagedCluster annotation holding the JSON list of the init containers
+// of the klusterlet pods, for example to place certificates or set sysctls before the klusterlet starts
+const klusterletInitContainersAnnotation = "import.open-cluster-management.io/klusterlet-init-containers"
+
+// parseInitContainers validates and parses the JSON list of init containers
+func parseInitContainers(v string) ([]corev1.Container, error) {
+	decoder := json.NewDecoder(strings.NewReader(v))
+	decoder.DisallowUnknownFields()
+	containers := []corev1.Container{}
+	if err := decoder.Decode(&containers); err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	for i, container := range containers {
+		if errs := validation.IsDNS1123Label(container.Name); len(errs) != 0 {
+			return nil, fmt.Errorf("init container %d: invalid name %q: %s", i, container.Name, strings.Join(errs, ", "))
+		}
+		if names[container.Name] {
+			return nil, fmt.Errorf("init container %d: duplicate name %q", i, container.Name)
+		}
+		names[container.Name] = true
+		if container.Image == "" {
+			return nil, fmt.Errorf("init container %s: image is required", container.Name)
+		}
+	}
+	return containers, nil
+}
+
+// setKlusterletInitContainers sets the init containers of the klusterlet pods to the init containers annotation
+// of the ManagedCluster
+func setKlusterletInitContainers(managedCluster *clusterv1.ManagedCluster, deployment *unstructured.Unstructured) error {
+	v, ok := managedCluster.GetAnnotations()[klusterletInitContainersAnnotation]
+	if !ok {
+		return nil
+	}
+	initContainers, err := parseInitContainers(v)
+	if err != nil {
+		return fmt.Errorf("invalid %s annotation: %v", klusterletInitContainersAnnotation, err)
+	}
+
+	containers, _, err := unstructured.NestedSlice(deployment.Object, "spec", "template", "spec", "containers")
+	if err != nil {
+		return err
+	}
+	names := map[string]bool{}
+	for _, container := range containers {
+		if c, ok := container.(map[string]interface{}); ok {
+			if name, ok := c["name"].(string); ok {
+				names[name] = true
+			}
+		}
+	}
+	uinitContainers := make([]interface{}, 0, len(initContainers))
+	for i := range initContainers {
+		if names[initContainers[i].Name] {
+			return fmt.Errorf("invalid %s annotation: the name %q is the name of a klusterlet container",
+				klusterletInitContainersAnnotation, initContainers[i].Name)
+		}
+		uinitContainer, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&initContainers[i])
+		if err != nil {
+			return err
+		}
+		uinitContainers = append(uinitContainers, uinitContainer)
+	}
+	return unstructured.SetNestedSlice(deployment.Object, uinitContainers, "spec", "template", "spec", "initContainers")
+}
@@ -0,0 +1,96 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_generateImportYAMLsInitContainers(t *testing.T) {
+	privileged := true
+
+	tests := []struct {
+		name               string
+		initContainers     string
+		wantInitContainers []corev1.Container
+		wantErr            bool
+	}{
+		{
+			name: "no init containers",
+		},
+		{
+			name: "sysctl",
+			initContainers: `[{"name":"sysctl","image":"registry.example.com/tools:latest",` +
+				`"command":["sysctl","-w","net.core.somaxconn=1024"],"securityContext":{"privileged":true}}]`,
+			wantInitContainers: []corev1.Container{
+				{
+					Name:            "sysctl",
+					Image:           "registry.example.com/tools:latest",
+					Command:         []string{"sysctl", "-w", "net.core.somaxconn=1024"},
+					SecurityContext: &corev1.SecurityContext{Privileged: &privileged},
+				},
+			},
+		},
+		{
+			name:           "invalid json",
+			initContainers: `[{"name":"sysctl"`,
+			wantErr:        true,
+		},
+		{
+			name:           "not a container",
+			initContainers: `[{"name":"sysctl","image":"registry.example.com/tools:latest","cmd":["sysctl"]}]`,
+			wantErr:        true,
+		},
+		{
+			name:           "invalid name",
+			initContainers: `[{"name":"Place_Certs","image":"registry.example.com/tools:latest"}]`,
+			wantErr:        true,
+		},
+		{
+			name: "duplicate name",
+			initContainers: `[{"name":"certs","image":"registry.example.com/tools:latest"},` +
+				`{"name":"certs","image":"registry.example.com/tools:latest"}]`,
+			wantErr: true,
+		},
+		{
+			name:           "missing image",
+			initContainers: `[{"name":"certs"}]`,
+			wantErr:        true,
+		},
+		{
+			name:           "name of the klusterlet container",
+			initContainers: `[{"name":"klusterlet","image":"registry.example.com/tools:latest"}]`,
+			wantErr:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			managedCluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "cluster-init-containers",
+				},
+			}
+			if tt.initContainers != "" {
+				managedCluster.SetAnnotations(map[string]string{
+					klusterletInitContainersAnnotation: tt.initContainers,
+				})
+			}
+			if tt.wantErr {
+				_, _, err := generateImportYAMLs(newRenderFakeClient(t, managedCluster), nil, nil, managedCluster, []string{})
+				if err == nil {
+					t.Error("generateImportYAMLs() expected an error")
+				}
+				return
+			}
+			deployment := renderKlusterletDeployment(t, managedCluster)
+			if initContainers := deployment.Spec.Template.Spec.InitContainers; !equality.Semantic.DeepEqual(initContainers, tt.wantInitContainers) {
+				t.Errorf("klusterlet init containers = %v, want %v", initContainers, tt.wantInitContainers)
+			}
+		})
+	}
+}
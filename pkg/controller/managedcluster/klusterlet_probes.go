@@ -0,0 +1,133 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// klusterletProbesAnnotation is the ManagedCluster annotation holding the JSON probe timings of the klusterlet
+// containers, for example to relax the probes of the spokes with a slow startup
+const klusterletProbesAnnotation = "import.open-cluster-management.io/klusterlet-probes"
+
+// probeTiming overrides the timings of a probe, the unset fields keep the timing of the probe
+type probeTiming struct {
+	InitialDelaySeconds *int32 `json:"initialDelaySeconds,omitempty"`
+	PeriodSeconds       *int32 `json:"periodSeconds,omitempty"`
+	TimeoutSeconds      *int32 `json:"timeoutSeconds,omitempty"`
+	FailureThreshold    *int32 `json:"failureThreshold,omitempty"`
+}
+
+// klusterletProbes are the probe timings of the klusterlet containers. The startup probe is added with the
+// handler of the liveness probe, the liveness and readiness probes are only tuned.
+type klusterletProbes struct {
+	Startup   *probeTiming `json:"startup,omitempty"`
+	Liveness  *probeTiming `json:"liveness,omitempty"`
+	Readiness *probeTiming `json:"readiness,omitempty"`
+}
+
+// validate returns an error if a timing is out of the range allowed by the apiserver
+func (p *probeTiming) validate() error {
+	if p.InitialDelaySeconds != nil && *p.InitialDelaySeconds < 0 {
+		return fmt.Errorf("initialDelaySeconds must not be negative")
+	}
+	for field, v := range map[string]*int32{
+		"periodSeconds":    p.PeriodSeconds,
+		"timeoutSeconds":   p.TimeoutSeconds,
+		"failureThreshold": p.FailureThreshold,
+	} {
+		if v != nil && *v < 1 {
+			return fmt.Errorf("%s must be positive", field)
+		}
+	}
+	return nil
+}
+
+// apply sets the timings on the unstructured probe
+func (p *probeTiming) apply(probe map[string]interface{}) {
+	for field, v := range map[string]*int32{
+		"initialDelaySeconds": p.InitialDelaySeconds,
+		"periodSeconds":       p.PeriodSeconds,
+		"timeoutSeconds":      p.TimeoutSeconds,
+		"failureThreshold":    p.FailureThreshold,
+	} {
+		if v != nil {
+			probe[field] = int64(*v)
+		}
+	}
+}
+
+// parseKlusterletProbes validates and parses the JSON probe timings
+func parseKlusterletProbes(v string) (*klusterletProbes, error) {
+	decoder := json.NewDecoder(strings.NewReader(v))
+	decoder.DisallowUnknownFields()
+	probes := &klusterletProbes{}
+	if err := decoder.Decode(probes); err != nil {
+		return nil, err
+	}
+	for name, timing := range map[string]*probeTiming{
+		"startup":   probes.Startup,
+		"liveness":  probes.Liveness,
+		"readiness": probes.Readiness,
+	} {
+		if timing == nil {
+			continue
+		}
+		if err := timing.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return probes, nil
+}
+
+// setKlusterletProbes sets the probe timings of the klusterlet containers to the probes annotation
+// of the ManagedCluster
+func setKlusterletProbes(managedCluster *clusterv1.ManagedCluster, deployment *unstructured.Unstructured) error {
+	v, ok := managedCluster.GetAnnotations()[klusterletProbesAnnotation]
+	if !ok {
+		return nil
+	}
+	probes, err := parseKlusterletProbes(v)
+	if err != nil {
+		return fmt.Errorf("invalid %s annotation: %v", klusterletProbesAnnotation, err)
+	}
+
+	containers, _, err := unstructured.NestedSlice(deployment.Object, "spec", "template", "spec", "containers")
+	if err != nil {
+		return err
+	}
+	for i := range containers {
+		container, ok := containers[i].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		livenessProbe, hasLiveness := container["livenessProbe"].(map[string]interface{})
+		if probes.Startup != nil && hasLiveness {
+			startupProbe, ok := container["startupProbe"].(map[string]interface{})
+			if !ok {
+				startupProbe = map[string]interface{}{}
+				for _, handler := range []string{"exec", "httpGet", "tcpSocket"} {
+					if h, ok := livenessProbe[handler]; ok {
+						startupProbe[handler] = runtime.DeepCopyJSONValue(h)
+					}
+				}
+			}
+			probes.Startup.apply(startupProbe)
+			container["startupProbe"] = startupProbe
+		}
+		if probes.Liveness != nil && hasLiveness {
+			probes.Liveness.apply(livenessProbe)
+		}
+		if readinessProbe, ok := container["readinessProbe"].(map[string]interface{}); ok && probes.Readiness != nil {
+			probes.Readiness.apply(readinessProbe)
+		}
+		containers[i] = container
+	}
+	return unstructured.SetNestedSlice(deployment.Object, containers, "spec", "template", "spec", "containers")
+}
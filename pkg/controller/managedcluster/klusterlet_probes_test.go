@@ -0,0 +1,118 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func Test_generateImportYAMLsProbes(t *testing.T) {
+	healthz := corev1.Handler{
+		HTTPGet: &corev1.HTTPGetAction{
+			Path:   "/healthz",
+			Scheme: corev1.URISchemeHTTPS,
+			Port:   intstr.FromInt(8443),
+		},
+	}
+	newProbe := func(initialDelay, period, timeout, failureThreshold int32) *corev1.Probe {
+		return &corev1.Probe{
+			Handler:             healthz,
+			InitialDelaySeconds: initialDelay,
+			PeriodSeconds:       period,
+			TimeoutSeconds:      timeout,
+			FailureThreshold:    failureThreshold,
+		}
+	}
+
+	tests := []struct {
+		name          string
+		probes        string
+		wantStartup   *corev1.Probe
+		wantLiveness  *corev1.Probe
+		wantReadiness *corev1.Probe
+		wantErr       bool
+	}{
+		{
+			name:          "no probes",
+			wantLiveness:  newProbe(2, 10, 0, 0),
+			wantReadiness: newProbe(2, 0, 0, 0),
+		},
+		{
+			name:          "relaxed liveness and readiness",
+			probes:        `{"liveness":{"initialDelaySeconds":60,"periodSeconds":30,"timeoutSeconds":10},"readiness":{"timeoutSeconds":5}}`,
+			wantLiveness:  newProbe(60, 30, 10, 0),
+			wantReadiness: newProbe(2, 0, 5, 0),
+		},
+		{
+			name:          "startup",
+			probes:        `{"startup":{"periodSeconds":10,"failureThreshold":30}}`,
+			wantStartup:   newProbe(0, 10, 0, 30),
+			wantLiveness:  newProbe(2, 10, 0, 0),
+			wantReadiness: newProbe(2, 0, 0, 0),
+		},
+		{
+			name:    "invalid json",
+			probes:  `{"liveness":`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown probe",
+			probes:  `{"health":{"periodSeconds":10}}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			probes:  `{"liveness":{"successThreshold":1}}`,
+			wantErr: true,
+		},
+		{
+			name:    "negative initial delay",
+			probes:  `{"liveness":{"initialDelaySeconds":-1}}`,
+			wantErr: true,
+		},
+		{
+			name:    "zero timeout",
+			probes:  `{"startup":{"timeoutSeconds":0}}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			managedCluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "cluster-probes",
+				},
+			}
+			if tt.probes != "" {
+				managedCluster.SetAnnotations(map[string]string{
+					klusterletProbesAnnotation: tt.probes,
+				})
+			}
+			if tt.wantErr {
+				_, _, err := generateImportYAMLs(newRenderFakeClient(t, managedCluster), nil, nil, managedCluster, []string{})
+				if err == nil {
+					t.Error("generateImportYAMLs() expected an error")
+				}
+				return
+			}
+			deployment := renderKlusterletDeployment(t, managedCluster)
+			for _, container := range deployment.Spec.Template.Spec.Containers {
+				if !equality.Semantic.DeepEqual(container.StartupProbe, tt.wantStartup) {
+					t.Errorf("container %s startup probe = %v, want %v", container.Name, container.StartupProbe, tt.wantStartup)
+				}
+				if !equality.Semantic.DeepEqual(container.LivenessProbe, tt.wantLiveness) {
+					t.Errorf("container %s liveness probe = %v, want %v", container.Name, container.LivenessProbe, tt.wantLiveness)
+				}
+				if !equality.Semantic.DeepEqual(container.ReadinessProbe, tt.wantReadiness) {
+					t.Errorf("container %s readiness probe = %v, want %v", container.Name, container.ReadinessProbe, tt.wantReadiness)
+				}
+			}
+		})
+	}
+}
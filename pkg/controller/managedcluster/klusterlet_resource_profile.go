@@ -0,0 +1,121 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"fmt"
+	"strconv"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// klusterletResourceProfileAnnotation is the ManagedCluster annotation selecting the resource profile of
+	// the klusterlet containers, the klusterlet runs without resource requests and limits if not set
+	klusterletResourceProfileAnnotation = "import.open-cluster-management.io/klusterlet-resource-profile"
+
+	// nodeCountClaim is the cluster claim holding the number of nodes of the managed cluster,
+	// used to pick the profile of the auto resource profile
+	nodeCountClaim = "nodecount.import.open-cluster-management.io"
+)
+
+// resource profiles of the klusterlet
+const (
+	resourceProfileSmall  = "small"
+	resourceProfileMedium = "medium"
+	resourceProfileLarge  = "large"
+	resourceProfileAuto   = "auto"
+)
+
+// maximum number of nodes of the managed clusters of the small and medium profiles picked by the auto profile,
+// the larger clusters get the large profile
+const (
+	smallProfileMaxNodes  = 10
+	mediumProfileMaxNodes = 100
+)
+
+// newResourceRequirements returns the resource requirements requesting cpu and memory and limited to
+// cpuLimit and memoryLimit
+func newResourceRequirements(cpu, memory, cpuLimit, memoryLimit string) corev1.ResourceRequirements {
+	return corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(cpu),
+			corev1.ResourceMemory: resource.MustParse(memory),
+		},
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(cpuLimit),
+			corev1.ResourceMemory: resource.MustParse(memoryLimit),
+		},
+	}
+}
+
+// resourceProfiles are the resource requirements of the klusterlet containers of each profile
+var resourceProfiles = map[string]corev1.ResourceRequirements{
+	resourceProfileSmall:  newResourceRequirements("50m", "64Mi", "500m", "256Mi"),
+	resourceProfileMedium: newResourceRequirements("100m", "128Mi", "1", "512Mi"),
+	resourceProfileLarge:  newResourceRequirements("200m", "256Mi", "2", "1Gi"),
+}
+
+// autoResourceProfile returns the profile matching the node count claim of the ManagedCluster,
+// the small profile if the ManagedCluster has no valid node count claim
+func autoResourceProfile(managedCluster *clusterv1.ManagedCluster) string {
+	for _, claim := range managedCluster.Status.ClusterClaims {
+		if claim.Name != nodeCountClaim {
+			continue
+		}
+		nodes, err := strconv.Atoi(claim.Value)
+		if err != nil || nodes < 0 {
+			log.Info("Ignoring invalid node count claim", "cluster", managedCluster.Name, "value", claim.Value)
+			break
+		}
+		switch {
+		case nodes <= smallProfileMaxNodes:
+			return resourceProfileSmall
+		case nodes <= mediumProfileMaxNodes:
+			return resourceProfileMedium
+		default:
+			return resourceProfileLarge
+		}
+	}
+	return resourceProfileSmall
+}
+
+// setKlusterletResourceProfile sets the resource requirements of the klusterlet containers to the resource
+// profile annotation of the ManagedCluster
+func setKlusterletResourceProfile(managedCluster *clusterv1.ManagedCluster, deployment *unstructured.Unstructured) error {
+	profile, ok := managedCluster.GetAnnotations()[klusterletResourceProfileAnnotation]
+	if !ok {
+		return nil
+	}
+	if profile == resourceProfileAuto {
+		profile = autoResourceProfile(managedCluster)
+	}
+	requirements, ok := resourceProfiles[profile]
+	if !ok {
+		return fmt.Errorf("invalid %s annotation %q, must be one of %s, %s, %s or %s",
+			klusterletResourceProfileAnnotation, profile,
+			resourceProfileSmall, resourceProfileMedium, resourceProfileLarge, resourceProfileAuto)
+	}
+	uresources, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&requirements)
+	if err != nil {
+		return err
+	}
+
+	containers, _, err := unstructured.NestedSlice(deployment.Object, "spec", "template", "spec", "containers")
+	if err != nil {
+		return err
+	}
+	for i := range containers {
+		container, ok := containers[i].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		container["resources"] = runtime.DeepCopyJSON(uresources)
+		containers[i] = container
+	}
+	return unstructured.SetNestedSlice(deployment.Object, containers, "spec", "template", "spec", "containers")
+}
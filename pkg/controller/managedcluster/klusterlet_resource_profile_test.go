@@ -0,0 +1,114 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_generateImportYAMLsResourceProfile(t *testing.T) {
+	withNodeCount := func(nodes string) []clusterv1.ManagedClusterClaim {
+		return []clusterv1.ManagedClusterClaim{
+			{
+				Name:  nodeCountClaim,
+				Value: nodes,
+			},
+		}
+	}
+
+	tests := []struct {
+		name          string
+		profile       string
+		claims        []clusterv1.ManagedClusterClaim
+		wantResources corev1.ResourceRequirements
+		wantErr       bool
+	}{
+		{
+			name: "no profile",
+		},
+		{
+			name:          "small",
+			profile:       resourceProfileSmall,
+			wantResources: newResourceRequirements("50m", "64Mi", "500m", "256Mi"),
+		},
+		{
+			name:          "medium",
+			profile:       resourceProfileMedium,
+			wantResources: newResourceRequirements("100m", "128Mi", "1", "512Mi"),
+		},
+		{
+			name:          "large",
+			profile:       resourceProfileLarge,
+			wantResources: newResourceRequirements("200m", "256Mi", "2", "1Gi"),
+		},
+		{
+			name:          "auto without node count claim",
+			profile:       resourceProfileAuto,
+			wantResources: resourceProfiles[resourceProfileSmall],
+		},
+		{
+			name:          "auto small cluster",
+			profile:       resourceProfileAuto,
+			claims:        withNodeCount("3"),
+			wantResources: resourceProfiles[resourceProfileSmall],
+		},
+		{
+			name:          "auto medium cluster",
+			profile:       resourceProfileAuto,
+			claims:        withNodeCount("50"),
+			wantResources: resourceProfiles[resourceProfileMedium],
+		},
+		{
+			name:          "auto large cluster",
+			profile:       resourceProfileAuto,
+			claims:        withNodeCount("500"),
+			wantResources: resourceProfiles[resourceProfileLarge],
+		},
+		{
+			name:          "auto invalid node count claim",
+			profile:       resourceProfileAuto,
+			claims:        withNodeCount("many"),
+			wantResources: resourceProfiles[resourceProfileSmall],
+		},
+		{
+			name:    "unknown profile",
+			profile: "huge",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			managedCluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "cluster-profile",
+				},
+				Status: clusterv1.ManagedClusterStatus{
+					ClusterClaims: tt.claims,
+				},
+			}
+			if tt.profile != "" {
+				managedCluster.SetAnnotations(map[string]string{
+					klusterletResourceProfileAnnotation: tt.profile,
+				})
+			}
+			if tt.wantErr {
+				_, _, err := generateImportYAMLs(newRenderFakeClient(t, managedCluster), nil, nil, managedCluster, []string{})
+				if err == nil {
+					t.Error("generateImportYAMLs() expected an error")
+				}
+				return
+			}
+			deployment := renderKlusterletDeployment(t, managedCluster)
+			for _, container := range deployment.Spec.Template.Spec.Containers {
+				if !equality.Semantic.DeepEqual(container.Resources, tt.wantResources) {
+					t.Errorf("container %s resources = %v, want %v", container.Name, container.Resources, tt.wantResources)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// klusterletReuseEnvVarName is how the import handles a klusterlet already installed on the managed cluster,
+// for example when a cluster is imported again to a new hub
+const klusterletReuseEnvVarName = "IMPORT_KLUSTERLET_REUSE"
+
+const (
+	// klusterletReuseUpdate updates the resources of the existing klusterlet in place
+	klusterletReuseUpdate = "update"
+	// klusterletReuseNone applies the import resources without looking for an existing klusterlet
+	klusterletReuseNone = "none"
+
+	bootstrapHubKubeconfigSecretName = "bootstrap-hub-kubeconfig"
+	// hubKubeconfigSecretName is the secret of the hub credentials the klusterlet got with its bootstrap kubeconfig
+	hubKubeconfigSecretName = "hub-kubeconfig-secret"
+)
+
+// getKlusterletReuse returns the IMPORT_KLUSTERLET_REUSE value, none if not set
+func getKlusterletReuse() (string, error) {
+	switch v := os.Getenv(klusterletReuseEnvVarName); v {
+	case "":
+		return klusterletReuseNone, nil
+	case klusterletReuseUpdate, klusterletReuseNone:
+		return v, nil
+	default:
+		return "", fmt.Errorf("invalid %s value %q, must be %s or %s",
+			klusterletReuseEnvVarName, v, klusterletReuseUpdate, klusterletReuseNone)
+	}
+}
+
+// getExistingKlusterlet returns the Klusterlet installed on the managed cluster, nil if there is none or if its
+// crd is not installed
+func getExistingKlusterlet(managedClusterClient client.Client) (*unstructured.Unstructured, error) {
+	klusterlet := &unstructured.Unstructured{}
+	klusterlet.SetGroupVersionKind(klusterletGVK)
+	err := managedClusterClient.Get(context.TODO(), types.NamespacedName{Name: "klusterlet"}, klusterlet)
+	if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return klusterlet, nil
+}
+
+// updateImportResources updates in place the import resources of the existing klusterlet of the managed cluster,
+// the resources of the kinds of the apply order first. The resources changed on the managed cluster while they
+// are updated are read and updated again instead of failing on the conflict.
+// The hub credentials of the klusterlet are deleted if its bootstrap kubeconfig points to a new hub, so the
+// klusterlet registers again to the new hub.
+func updateImportResources(
+	managedClusterClient client.Client,
+	applyOrder []string,
+	resources []*unstructured.Unstructured) error {
+	previousHub, err := bootstrapHubServer(managedClusterClient)
+	if err != nil {
+		return err
+	}
+
+	first, rest := splitByApplyOrder(resources, applyOrder)
+	for _, u := range append(first, rest...) {
+		if err := retry.OnError(crdEstablishBackoff, notEstablished, func() error {
+			return updateInPlace(managedClusterClient, u)
+		}); err != nil {
+			return err
+		}
+	}
+
+	newHub, err := bootstrapHubServer(managedClusterClient)
+	if err != nil {
+		return err
+	}
+	if previousHub == "" || previousHub == newHub {
+		return nil
+	}
+	klog.Infof("The klusterlet moves from the hub %s to %s, delete its hub credentials", previousHub, newHub)
+	hubKubeconfigSecret := &corev1.Secret{}
+	hubKubeconfigSecret.Name = hubKubeconfigSecretName
+	hubKubeconfigSecret.Namespace = klusterletNamespace
+	if err := managedClusterClient.Delete(context.TODO(), hubKubeconfigSecret); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// updateInPlace creates the resource on the managed cluster or replaces the existing one, keeping its finalizers,
+// the resource is read again on conflict
+func updateInPlace(managedClusterClient client.Client, desired *unstructured.Unstructured) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current := &unstructured.Unstructured{}
+		current.SetGroupVersionKind(desired.GroupVersionKind())
+		err := managedClusterClient.Get(context.TODO(),
+			types.NamespacedName{Name: desired.GetName(), Namespace: desired.GetNamespace()}, current)
+		if errors.IsNotFound(err) {
+			return managedClusterClient.Create(context.TODO(), desired.DeepCopy())
+		}
+		if err != nil {
+			return err
+		}
+		u := desired.DeepCopy()
+		u.SetResourceVersion(current.GetResourceVersion())
+		u.SetFinalizers(current.GetFinalizers())
+		return managedClusterClient.Update(context.TODO(), u)
+	})
+}
+
+// bootstrapHubServer returns the server of the bootstrap kubeconfig of the klusterlet, empty if it has none
+func bootstrapHubServer(managedClusterClient client.Client) (string, error) {
+	secret := &corev1.Secret{}
+	err := managedClusterClient.Get(context.TODO(),
+		types.NamespacedName{Name: bootstrapHubKubeconfigSecretName, Namespace: klusterletNamespace}, secret)
+	if errors.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return kubeconfigServer(secret.Data["kubeconfig"]), nil
+}
+
+// kubeconfigServer returns the server of the current context of the kubeconfig, empty if it can not be parsed
+func kubeconfigServer(kubeconfig []byte) string {
+	config, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return ""
+	}
+	currentContext, ok := config.Contexts[config.CurrentContext]
+	if !ok {
+		return ""
+	}
+	cluster, ok := config.Clusters[currentContext.Cluster]
+	if !ok {
+		return ""
+	}
+	return cluster.Server
+}
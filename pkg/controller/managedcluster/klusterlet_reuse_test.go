@@ -0,0 +1,241 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const klusterletCleanupFinalizer = "operator.open-cluster-management.io/klusterlet-cleanup"
+
+// conflictingClient is a managed cluster client failing the first updates of each klusterlet with a conflict,
+// as when the klusterlet operator updates the klusterlet concurrently
+type conflictingClient struct {
+	client.Client
+	conflicts int
+	updates   int
+}
+
+func (c *conflictingClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	if obj.GetObjectKind().GroupVersionKind().Kind == "Klusterlet" {
+		c.updates++
+		if c.conflicts > 0 {
+			c.conflicts--
+			return errors.NewConflict(schema.GroupResource{Group: klusterletGVK.Group, Resource: "klusterlets"},
+				"klusterlet", nil)
+		}
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func newKubeconfig(server string) []byte {
+	return []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: hub
+  cluster:
+    server: ` + server + `
+contexts:
+- name: hub
+  context:
+    cluster: hub
+current-context: hub
+`)
+}
+
+func newExistingKlusterlet(clusterName string) *unstructured.Unstructured {
+	klusterlet := newUnstructured("operator.open-cluster-management.io/v1", "Klusterlet", "", "klusterlet")
+	klusterlet.SetFinalizers([]string{klusterletCleanupFinalizer})
+	_ = unstructured.SetNestedField(klusterlet.Object, clusterName, "spec", "clusterName")
+	return klusterlet
+}
+
+func newKlusterletSecret(name string, kubeconfig []byte) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: klusterletNamespace,
+		},
+		Data: map[string][]byte{"kubeconfig": kubeconfig},
+	}
+}
+
+func Test_getKlusterletReuse(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "not set",
+			want: klusterletReuseNone,
+		},
+		{
+			name:  "update",
+			value: klusterletReuseUpdate,
+			want:  klusterletReuseUpdate,
+		},
+		{
+			name:  "none",
+			value: klusterletReuseNone,
+			want:  klusterletReuseNone,
+		},
+		{
+			name:    "invalid",
+			value:   "replace",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(klusterletReuseEnvVarName, tt.value)
+			defer os.Unsetenv(klusterletReuseEnvVarName)
+			got, err := getKlusterletReuse()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getKlusterletReuse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("getKlusterletReuse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_updateImportResources(t *testing.T) {
+	oldHub := newKubeconfig("https://old-hub.example.com:6443")
+	newHub := newKubeconfig("https://new-hub.example.com:6443")
+	tests := []struct {
+		name                 string
+		bootstrapKubeconfig  []byte
+		wantHubKubeconfigDel bool
+	}{
+		{
+			name:                 "new hub",
+			bootstrapKubeconfig:  oldHub,
+			wantHubKubeconfigDel: true,
+		},
+		{
+			name:                "same hub",
+			bootstrapKubeconfig: newHub,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &conflictingClient{
+				Client: fake.NewFakeClientWithScheme(scheme.Scheme,
+					newExistingKlusterlet("old-name"),
+					newKlusterletSecret(bootstrapHubKubeconfigSecretName, tt.bootstrapKubeconfig),
+					newKlusterletSecret(hubKubeconfigSecretName, []byte("credentials"))),
+				conflicts: 2,
+			}
+			bootstrapSecret := newUnstructured("v1", "Secret", klusterletNamespace, bootstrapHubKubeconfigSecretName)
+			bootstrapSecret.Object["data"] = map[string]interface{}{
+				"kubeconfig": base64.StdEncoding.EncodeToString(newHub),
+			}
+			resources := []*unstructured.Unstructured{
+				newExistingKlusterlet("cluster1"),
+				bootstrapSecret,
+				newUnstructured("v1", "Namespace", "", klusterletNamespace),
+			}
+			resources[0].SetFinalizers(nil)
+
+			if err := updateImportResources(c, defaultApplyOrder, resources); err != nil {
+				t.Fatalf("updateImportResources() error = %v", err)
+			}
+			if c.updates != 3 {
+				t.Errorf("klusterlet updates = %d, want 3 with the conflicts", c.updates)
+			}
+			klusterlet, err := getExistingKlusterlet(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name, _, _ := unstructured.NestedString(klusterlet.Object, "spec", "clusterName"); name != "cluster1" {
+				t.Errorf("klusterlet cluster name = %q, want cluster1", name)
+			}
+			if finalizers := klusterlet.GetFinalizers(); len(finalizers) != 1 || finalizers[0] != klusterletCleanupFinalizer {
+				t.Errorf("klusterlet finalizers = %v, want the existing ones", finalizers)
+			}
+			if server, err := bootstrapHubServer(c); err != nil || server != "https://new-hub.example.com:6443" {
+				t.Errorf("bootstrap hub = %q, %v, want the new hub", server, err)
+			}
+			err = c.Get(context.TODO(), types.NamespacedName{Name: hubKubeconfigSecretName, Namespace: klusterletNamespace},
+				&corev1.Secret{})
+			if deleted := errors.IsNotFound(err); deleted != tt.wantHubKubeconfigDel {
+				t.Errorf("hub kubeconfig secret deleted = %v, want %v", deleted, tt.wantHubKubeconfigDel)
+			}
+		})
+	}
+}
+
+func TestReconcileManagedCluster_importClusterWithClientKlusterletReuse(t *testing.T) {
+	tests := []struct {
+		name            string
+		klusterletReuse string
+		existing        bool
+		wantUpdates     int
+	}{
+		{
+			name:            "fresh install",
+			klusterletReuse: klusterletReuseUpdate,
+		},
+		{
+			name:            "existing klusterlet",
+			klusterletReuse: klusterletReuseUpdate,
+			existing:        true,
+			wantUpdates:     2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			managedCluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "cluster-klusterlet-reuse",
+				},
+			}
+			r := &ReconcileManagedCluster{
+				client:          newRenderFakeClient(t, managedCluster),
+				scheme:          scheme.Scheme,
+				applyOrder:      defaultApplyOrder,
+				klusterletReuse: tt.klusterletReuse,
+			}
+			managedObjects := []runtime.Object{}
+			if tt.existing {
+				managedObjects = append(managedObjects, newExistingKlusterlet("old-name"))
+			}
+			c := &conflictingClient{
+				Client:    fake.NewFakeClientWithScheme(scheme.Scheme, managedObjects...),
+				conflicts: 1,
+			}
+
+			if _, err := r.importClusterWithClient(managedCluster, nil, c, "v1.20.0"); err != nil {
+				t.Fatalf("importClusterWithClient() error = %v", err)
+			}
+			if c.updates != tt.wantUpdates {
+				t.Errorf("klusterlet updates = %d, want %d", c.updates, tt.wantUpdates)
+			}
+			klusterlet, err := getExistingKlusterlet(c)
+			if err != nil || klusterlet == nil {
+				t.Fatalf("klusterlet = %v, %v, want the imported klusterlet", klusterlet, err)
+			}
+			if name, _, _ := unstructured.NestedString(klusterlet.Object, "spec", "clusterName"); name != managedCluster.Name {
+				t.Errorf("klusterlet cluster name = %q, want %s", name, managedCluster.Name)
+			}
+		})
+	}
+}
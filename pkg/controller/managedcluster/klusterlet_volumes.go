@@ -0,0 +1,152 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// klusterletVolumesAnnotation is the ManagedCluster annotation holding the JSON extra volumes of the klusterlet pods
+// and their mounts in the klusterlet containers, for example to mount a CA directory of the managed cluster hosts
+const klusterletVolumesAnnotation = "import.open-cluster-management.io/klusterlet-volumes"
+
+// klusterletVolumes are the extra volumes of the klusterlet pods and their mounts
+type klusterletVolumes struct {
+	Volumes      []corev1.Volume      `json:"volumes,omitempty"`
+	VolumeMounts []corev1.VolumeMount `json:"volumeMounts,omitempty"`
+}
+
+// validMountPath returns an error if the path is not an absolute clean path
+func validMountPath(p string) error {
+	if !path.IsAbs(p) {
+		return fmt.Errorf("path %q must be absolute", p)
+	}
+	for _, element := range strings.Split(p, "/") {
+		if element == ".." {
+			return fmt.Errorf("path %q must not contain '..'", p)
+		}
+	}
+	return nil
+}
+
+// parseKlusterletVolumes validates and parses the JSON extra volumes
+func parseKlusterletVolumes(v string) (*klusterletVolumes, error) {
+	decoder := json.NewDecoder(strings.NewReader(v))
+	decoder.DisallowUnknownFields()
+	volumes := &klusterletVolumes{}
+	if err := decoder.Decode(volumes); err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for i, volume := range volumes.Volumes {
+		if errs := validation.IsDNS1123Label(volume.Name); len(errs) != 0 {
+			return nil, fmt.Errorf("volume %d: invalid name %q: %s", i, volume.Name, strings.Join(errs, ", "))
+		}
+		if names[volume.Name] {
+			return nil, fmt.Errorf("volume %d: duplicate name %q", i, volume.Name)
+		}
+		names[volume.Name] = true
+		if volume.HostPath != nil {
+			if err := validMountPath(volume.HostPath.Path); err != nil {
+				return nil, fmt.Errorf("volume %s: host %v", volume.Name, err)
+			}
+		}
+	}
+	mountPaths := map[string]bool{}
+	for i, mount := range volumes.VolumeMounts {
+		if !names[mount.Name] {
+			return nil, fmt.Errorf("volume mount %d: volume %q is not defined", i, mount.Name)
+		}
+		if err := validMountPath(mount.MountPath); err != nil {
+			return nil, fmt.Errorf("volume mount %d: mount %v", i, err)
+		}
+		if mountPaths[path.Clean(mount.MountPath)] {
+			return nil, fmt.Errorf("volume mount %d: duplicate mount path %q", i, mount.MountPath)
+		}
+		mountPaths[path.Clean(mount.MountPath)] = true
+	}
+	return volumes, nil
+}
+
+// appendNamedItems appends the items to the unstructured list of the field, it returns an error if the list
+// already has an item with the same key
+func appendNamedItems(object map[string]interface{}, items []interface{}, key string, fields ...string) error {
+	list, _, err := unstructured.NestedSlice(object, fields...)
+	if err != nil {
+		return err
+	}
+	existing := map[string]bool{}
+	for _, item := range list {
+		if m, ok := item.(map[string]interface{}); ok {
+			if v, ok := m[key].(string); ok {
+				existing[path.Clean(v)] = true
+			}
+		}
+	}
+	for _, item := range items {
+		v, _ := item.(map[string]interface{})[key].(string)
+		if existing[path.Clean(v)] {
+			return fmt.Errorf("%s %q already exists", key, v)
+		}
+	}
+	return unstructured.SetNestedSlice(object, append(list, items...), fields...)
+}
+
+// setKlusterletVolumes adds the extra volumes of the volumes annotation of the ManagedCluster to the klusterlet pods
+// and their mounts to the klusterlet containers
+func setKlusterletVolumes(managedCluster *clusterv1.ManagedCluster, deployment *unstructured.Unstructured) error {
+	v, ok := managedCluster.GetAnnotations()[klusterletVolumesAnnotation]
+	if !ok {
+		return nil
+	}
+	volumes, err := parseKlusterletVolumes(v)
+	if err != nil {
+		return fmt.Errorf("invalid %s annotation: %v", klusterletVolumesAnnotation, err)
+	}
+
+	uvolumes := make([]interface{}, 0, len(volumes.Volumes))
+	for i := range volumes.Volumes {
+		uvolume, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&volumes.Volumes[i])
+		if err != nil {
+			return err
+		}
+		uvolumes = append(uvolumes, uvolume)
+	}
+	if err := appendNamedItems(deployment.Object, uvolumes, "name", "spec", "template", "spec", "volumes"); err != nil {
+		return fmt.Errorf("invalid %s annotation: volume %v", klusterletVolumesAnnotation, err)
+	}
+
+	containers, _, err := unstructured.NestedSlice(deployment.Object, "spec", "template", "spec", "containers")
+	if err != nil {
+		return err
+	}
+	for i := range containers {
+		container, ok := containers[i].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		umounts := make([]interface{}, 0, len(volumes.VolumeMounts))
+		for j := range volumes.VolumeMounts {
+			umount, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&volumes.VolumeMounts[j])
+			if err != nil {
+				return err
+			}
+			umounts = append(umounts, umount)
+		}
+		if err := appendNamedItems(container, umounts, "mountPath", "volumeMounts"); err != nil {
+			return fmt.Errorf("invalid %s annotation: volume mount %v", klusterletVolumesAnnotation, err)
+		}
+		containers[i] = container
+	}
+	return unstructured.SetNestedSlice(deployment.Object, containers, "spec", "template", "spec", "containers")
+}
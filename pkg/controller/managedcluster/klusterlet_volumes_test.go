@@ -0,0 +1,126 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_generateImportYAMLsVolumes(t *testing.T) {
+	hostPathDirectory := corev1.HostPathDirectory
+
+	tests := []struct {
+		name             string
+		volumes          string
+		wantVolumes      []corev1.Volume
+		wantVolumeMounts []corev1.VolumeMount
+		wantErr          bool
+	}{
+		{
+			name: "no volumes",
+		},
+		{
+			name: "host ca directory",
+			volumes: `{"volumes":[{"name":"host-ca","hostPath":{"path":"/etc/pki/ca-trust","type":"Directory"}}],` +
+				`"volumeMounts":[{"name":"host-ca","mountPath":"/etc/pki/ca-trust","readOnly":true}]}`,
+			wantVolumes: []corev1.Volume{
+				{
+					Name: "host-ca",
+					VolumeSource: corev1.VolumeSource{
+						HostPath: &corev1.HostPathVolumeSource{Path: "/etc/pki/ca-trust", Type: &hostPathDirectory},
+					},
+				},
+			},
+			wantVolumeMounts: []corev1.VolumeMount{
+				{Name: "host-ca", MountPath: "/etc/pki/ca-trust", ReadOnly: true},
+			},
+		},
+		{
+			name:    "volume without mount",
+			volumes: `{"volumes":[{"name":"audit","emptyDir":{}}]}`,
+			wantVolumes: []corev1.Volume{
+				{
+					Name:         "audit",
+					VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+				},
+			},
+		},
+		{
+			name:    "invalid json",
+			volumes: `{"volumes":[`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			volumes: `{"mounts":[{"name":"host-ca","mountPath":"/etc/pki"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid volume name",
+			volumes: `{"volumes":[{"name":"Host_CA","emptyDir":{}}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "duplicate volume name",
+			volumes: `{"volumes":[{"name":"audit","emptyDir":{}},{"name":"audit","emptyDir":{}}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "relative host path",
+			volumes: `{"volumes":[{"name":"host-ca","hostPath":{"path":"etc/pki"}}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "mount of an undefined volume",
+			volumes: `{"volumeMounts":[{"name":"host-ca","mountPath":"/etc/pki"}]}`,
+			wantErr: true,
+		},
+		{
+			name: "mount path escaping",
+			volumes: `{"volumes":[{"name":"audit","emptyDir":{}}],` +
+				`"volumeMounts":[{"name":"audit","mountPath":"/var/log/../../etc"}]}`,
+			wantErr: true,
+		},
+		{
+			name: "duplicate mount path",
+			volumes: `{"volumes":[{"name":"audit","emptyDir":{}},{"name":"logs","emptyDir":{}}],` +
+				`"volumeMounts":[{"name":"audit","mountPath":"/var/log"},{"name":"logs","mountPath":"/var/log/"}]}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			managedCluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "cluster-volumes",
+				},
+			}
+			if tt.volumes != "" {
+				managedCluster.SetAnnotations(map[string]string{
+					klusterletVolumesAnnotation: tt.volumes,
+				})
+			}
+			if tt.wantErr {
+				_, _, err := generateImportYAMLs(newRenderFakeClient(t, managedCluster), nil, nil, managedCluster, []string{})
+				if err == nil {
+					t.Error("generateImportYAMLs() expected an error")
+				}
+				return
+			}
+			deployment := renderKlusterletDeployment(t, managedCluster)
+			if volumes := deployment.Spec.Template.Spec.Volumes; !equality.Semantic.DeepEqual(volumes, tt.wantVolumes) {
+				t.Errorf("klusterlet volumes = %v, want %v", volumes, tt.wantVolumes)
+			}
+			for _, container := range deployment.Spec.Template.Spec.Containers {
+				if !equality.Semantic.DeepEqual(container.VolumeMounts, tt.wantVolumeMounts) {
+					t.Errorf("container %s volume mounts = %v, want %v", container.Name, container.VolumeMounts, tt.wantVolumeMounts)
+				}
+			}
+		})
+	}
+}
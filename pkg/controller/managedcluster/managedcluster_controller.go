@@ -117,6 +117,8 @@ func newManagedClusterSpecPredicate() predicate.Predicate {
 			if okNew && okOld {
 				return !reflect.DeepEqual(newManagedCluster.Spec, oldManagedCluster.Spec) ||
 					checkOffLine(newManagedCluster) != checkOffLine(oldManagedCluster) ||
+					isJoined(newManagedCluster) != isJoined(oldManagedCluster) ||
+					newManagedCluster.GetAnnotations()[addOnsAnnotation] != oldManagedCluster.GetAnnotations()[addOnsAnnotation] ||
 					newManagedCluster.DeletionTimestamp != nil
 				// !reflect.DeepEqual(newManagedCluster.Status.Conditions, oldManagedCluster.Status.Conditions)
 			}
@@ -166,6 +168,33 @@ type ReconcileManagedCluster struct {
 	// that reads objects from the cache and writes to the apiserver
 	client client.Client
 	scheme *runtime.Scheme
+	// remoteApplies limits the concurrent imports applied on the managed clusters
+	remoteApplies *remoteApplyLimiter
+	// credentialsWaitTimeout is the duration during which the import waits for the admin kubeconfig secret of the
+	// ClusterDeployment, the import does not wait if not positive
+	credentialsWaitTimeout time.Duration
+	// remoteApplyCircuits skips the imports of the managed clusters failing repeatedly
+	remoteApplyCircuits *remoteApplyCircuits
+	// clockSkewThreshold is the hub-spoke clock skew beyond which the ClockSkewDetected condition is set,
+	// the clock skew is not checked if not positive
+	clockSkewThreshold time.Duration
+	// importSecretLocation is the location of the import secrets, they are stored in the cluster namespaces if nil
+	importSecretLocation *importSecretLocation
+	// readinessGates are the resources which must exist on the managed cluster before its import succeeds
+	readinessGates []readinessGate
+	// bootstrapTokens requests the bootstrap tokens of the clusters with a bootstrap token TTL
+	bootstrapTokens *bootstrapTokenRequester
+	// manifestsWebhook mutates the klusterlet manifests of the imports, they are not mutated if nil
+	manifestsWebhook *manifestsWebhook
+	// tombstones keeps the import clients of the recently imported clusters to clean them up if force deleted
+	tombstones *clusterTombstones
+	// propagatedLabels are the ClusterDeployment labels copied on the ManagedCluster
+	propagatedLabels []string
+	// applyOrder are the kinds of the import resources applied first on the managed cluster, in that order
+	applyOrder []string
+	// klusterletReuse is how the import handles an existing klusterlet, its resources are updated in place
+	// only with klusterletReuseUpdate
+	klusterletReuse string
 }
 
 // Reconcile reads that state of the cluster for a ManagedCluster object and makes changes based on the state read
@@ -174,6 +203,15 @@ type ReconcileManagedCluster struct {
 // The Controller will requeue the Request to be processed again if the returned error is non-nil or
 // Result.Requeue is true, otherwise upon completion it will remove the work from the queue.
 func (r *ReconcileManagedCluster) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	result, err := r.reconcile(request)
+	if err != nil {
+		return result, err
+	}
+	return r.requeueAtBootstrapTokenRefresh(request.Name, result), nil
+}
+
+// reconcile reconciles the ManagedCluster of the request
+func (r *ReconcileManagedCluster) reconcile(request reconcile.Request) (reconcile.Result, error) {
 	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
 	reqLogger.Info("Reconciling ManagedCluster")
 
@@ -189,6 +227,9 @@ func (r *ReconcileManagedCluster) Reconcile(request reconcile.Request) (reconcil
 			// Request object not found, could have been deleted after reconcile request.
 			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
 			// Return and don't requeue
+			if err := r.cleanupForceDeletedCluster(request.Name); err != nil {
+				reqLogger.Error(err, "Failed to clean up the force deleted cluster")
+			}
 			reqLogger.Info(fmt.Sprintf("deleteNamespace: %s", request.Name))
 			err = r.deleteNamespace(request.Name)
 			if err != nil {
@@ -227,6 +268,8 @@ func (r *ReconcileManagedCluster) Reconcile(request reconcile.Request) (reconcil
 	}
 	//set the created_via annotation
 	r.setCreatedViaAnnotation(instance, clusterDeployment)
+	//copy the selected labels of the clusterDeployment
+	propagateClusterDeploymentLabels(instance, clusterDeployment, r.propagatedLabels)
 
 	//Patch the managedcluster
 	if err := r.client.Patch(context.TODO(), instance, patch); err != nil {
@@ -249,7 +292,16 @@ func (r *ReconcileManagedCluster) Reconcile(request reconcile.Request) (reconcil
 	if labels == nil {
 		labels = make(map[string]string)
 	}
-	if _, ok := labels[clusterLabel]; !ok {
+	//Clean up the artifacts of the previous cluster name if the namespace was associated to another name
+	oldName, renamed := clusterRenamed(instance, ns)
+	if renamed {
+		reqLogger.Info("Cluster renamed, cleaning up stale import artifacts", "oldName", oldName)
+		if err := deleteStaleImportSecrets(r.client, r.importSecretLocation, instance, oldName); err != nil {
+			reqLogger.Error(err, "Error while deleting stale import secrets", "namespace", instance.Name)
+			return reconcile.Result{Requeue: true, RequeueAfter: 1 * time.Second}, nil
+		}
+	}
+	if _, ok := labels[clusterLabel]; !ok || renamed {
 		labels[clusterLabel] = instance.Name
 		ns.SetLabels(labels)
 		if err := r.client.Update(context.TODO(), ns); err != nil {
@@ -297,7 +349,7 @@ func (r *ReconcileManagedCluster) Reconcile(request reconcile.Request) (reconcil
 		)
 		if err != nil {
 			reqLogger.Error(err, "Error while applying service account", "sa", instance.Name+bootstrapServiceAccountNamePostfix)
-			return reconcile.Result{}, err
+			return reconcile.Result{}, r.setConditionControllerMisconfigured(instance, err)
 		}
 	}
 
@@ -311,18 +363,16 @@ func (r *ReconcileManagedCluster) Reconcile(request reconcile.Request) (reconcil
 
 	if err != nil {
 		reqLogger.Error(err, "Error while applying manifest", "cluster", instance.Name)
-		return reconcile.Result{}, err
+		return reconcile.Result{}, r.setConditionControllerMisconfigured(instance, err)
 	}
 
-	crds, yamls, err := generateImportYAMLs(r.client, instance, []string{})
+	crds, yamls, err := r.generateImportSecret(instance)
 	if err != nil {
-		return reconcile.Result{}, err
+		return reconcile.Result{}, r.setConditionControllerMisconfigured(instance, err)
 	}
 
-	reqLogger.Info(fmt.Sprintf("createOrUpdateImportSecret: %s", instance.Name))
-	_, err = createOrUpdateImportSecret(r.client, r.scheme, instance, crds, yamls)
-	if err != nil {
-		reqLogger.Error(err, "create ManagedCluster Import Secret")
+	//The controller prerequisites are satisfied, clear a previous misconfiguration if any
+	if err := r.setConditionControllerMisconfigured(instance, nil); err != nil {
 		return reconcile.Result{}, err
 	}
 
@@ -332,6 +382,33 @@ func (r *ReconcileManagedCluster) Reconcile(request reconcile.Request) (reconcil
 		return result, err
 	}
 
+	//Guard against a concurrent detach which stripped the finalizer since it was added
+	hasFinalizer, err := r.hasImportFinalizer(instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if !hasFinalizer {
+		reqLogger.Info("Finalizer removed during the import, requeue", "finalizer", managedClusterFinalizer)
+		return reconcile.Result{Requeue: true, RequeueAfter: 1 * time.Second}, nil
+	}
+
+	//Enable the add-ons listed on the joined cluster
+	if err := enableAddOns(r.client, instance); err != nil {
+		reqLogger.Error(err, "Error while enabling the add-ons")
+		return reconcile.Result{}, err
+	}
+
+	//In pull mode the hub never connects to the managed cluster, it waits for the managed cluster to pull its import secret
+	if isPullMode(instance) {
+		if err := r.setConditionPullModeImport(instance); err != nil {
+			return reconcile.Result{}, err
+		}
+		if checkOffLine(instance) {
+			klog.Infof("Waiting for the pull mode cluster %s to pull its import secret", instance.Name)
+			return reconcile.Result{}, nil
+		}
+	}
+
 	if !checkOffLine(instance) {
 		reqLogger.Info(fmt.Sprintf("createOrUpdateManifestWorks: %s", instance.Name))
 		isV1, err := isAPIExtensionV1(nil, instance, "")
@@ -351,7 +428,10 @@ func (r *ReconcileManagedCluster) Reconcile(request reconcile.Request) (reconcil
 			reqLogger.Error(err, "Error while creating mw")
 			return reconcile.Result{}, err
 		}
-	} else {
+	}
+
+	//The import of an available cluster is completed once its readiness gates pass
+	if checkOffLine(instance) || readinessGatesPending(instance) {
 		autoImportSecret, toImport, err := r.toBeImported(instance, clusterDeployment)
 		if err != nil {
 			return reconcile.Result{}, err
@@ -501,6 +581,16 @@ func (r *ReconcileManagedCluster) setConditionImport(managedCluster *clusterv1.M
 	return errIn
 }
 
+// hasImportFinalizer re-reads the managedCluster and checks it still has the controller finalizer,
+// the import must not go on if the finalizer was removed as the cleanup would not be done on detach.
+func (r *ReconcileManagedCluster) hasImportFinalizer(managedCluster *clusterv1.ManagedCluster) (bool, error) {
+	current := &clusterv1.ManagedCluster{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: managedCluster.Name}, current); err != nil {
+		return false, err
+	}
+	return libgometav1.HasFinalizer(current, managedClusterFinalizer), nil
+}
+
 func filterFinalizers(managedCluster *clusterv1.ManagedCluster, finalizers []string) []string {
 	results := make([]string, 0)
 	clusterFinalizers := managedCluster.GetFinalizers()
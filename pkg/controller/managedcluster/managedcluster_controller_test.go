@@ -809,3 +809,57 @@ func Test_newCustomClient(t *testing.T) {
 	})
 
 }
+
+func TestReconcileManagedCluster_hasImportFinalizer(t *testing.T) {
+	s := scheme.Scheme
+	s.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	// the reconciled instance still has the finalizer added at the beginning of the reconcile
+	importing := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "cluster-finalizer",
+			Finalizers: []string{managedClusterFinalizer},
+		},
+	}
+	// a concurrent detach stripped the finalizer on the hub
+	detached := importing.DeepCopy()
+	detached.Finalizers = []string{registrationFinalizer}
+
+	tests := []struct {
+		name    string
+		objs    []runtime.Object
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "finalizer present",
+			objs: []runtime.Object{importing.DeepCopy()},
+			want: true,
+		},
+		{
+			name: "finalizer missing during import",
+			objs: []runtime.Object{detached},
+			want: false,
+		},
+		{
+			name:    "cluster deleted during import",
+			want:    false,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ReconcileManagedCluster{
+				client: fake.NewFakeClientWithScheme(s, tt.objs...),
+				scheme: s,
+			}
+			got, err := r.hasImportFinalizer(importing)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("hasImportFinalizer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("hasImportFinalizer() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
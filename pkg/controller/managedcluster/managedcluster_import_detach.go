@@ -9,6 +9,7 @@ import (
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -24,9 +25,6 @@ import (
 
 	hivev1 "github.com/openshift/hive/apis/hive/v1"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
-
-	"github.com/open-cluster-management/applier/pkg/applier"
-	"github.com/open-cluster-management/applier/pkg/templateprocessor"
 )
 
 func (r *ReconcileManagedCluster) importCluster(
@@ -35,6 +33,28 @@ func (r *ReconcileManagedCluster) importCluster(
 	autoImportSecret *corev1.Secret) (res reconcile.Result, err error) {
 	res = reconcile.Result{}
 
+	//Wait for the credentials of the cluster if they are not created yet
+	if res, wait, err := r.waitForCredentials(managedCluster, clusterDeployment, time.Now()); wait || err != nil {
+		return res, err
+	}
+
+	//Skip the import while the circuit of the cluster is open
+	if remaining, ok := r.remoteApplyCircuits.allow(managedCluster.Name, time.Now()); !ok {
+		klog.Infof("Circuit open, skip import of cluster %s for %s", managedCluster.Name, remaining)
+		return reconcile.Result{Requeue: true, RequeueAfter: remaining}, nil
+	}
+
+	//Wait for a remote apply slot, the import is requeued if all slots are in use
+	if !r.remoteApplies.tryAcquire() {
+		r.remoteApplyCircuits.abandon(managedCluster.Name)
+		klog.Infof("Maximum concurrent remote applies reached, requeue import of cluster %s", managedCluster.Name)
+		return reconcile.Result{Requeue: true, RequeueAfter: remoteApplyBusyRequeuePeriod}, nil
+	}
+	defer r.remoteApplies.release()
+	defer func() {
+		r.recordRemoteApply(managedCluster, err)
+	}()
+
 	//Assuming that is a local import
 	managedClusterClient := r.client
 
@@ -79,7 +99,11 @@ func (r *ReconcileManagedCluster) importCluster(
 		if err != nil {
 			return reconcile.Result{}, err
 		}
+		r.checkClockSkew(managedCluster, rConfig)
 		res, err = r.importClusterWithClient(managedCluster, autoImportSecret, managedClusterClient, managedClusterKubeVersion)
+		if err == nil && (clusterDeployment != nil || autoImportSecret != nil) {
+			r.tombstones.record(managedCluster.Name, managedClusterClient, time.Now())
+		}
 	}
 	if err != nil && autoImportSecret != nil {
 		errUpdate := r.updateAutoImportRetry(managedCluster, autoImportSecret)
@@ -241,66 +265,50 @@ func (r *ReconcileManagedCluster) importClusterWithClient(
 		excluded = append(excluded, "klusterlet/service_account.yaml")
 	}
 	//Generate crds and yamls
-	crds, yamls, err := generateImportYAMLs(r.client, managedCluster, excluded)
+	crds, yamls, err := generateImportYAMLs(r.client, r.bootstrapTokens, r.manifestsWebhook, managedCluster,
+		excluded)
 	if err != nil {
 		return reconcile.Result{Requeue: true, RequeueAfter: 30 * time.Second}, err
 	}
 
-	var bb [][]byte
-	//Convert crds to Yaml
 	isV1, err := isAPIExtensionV1(managedClusterClient, managedCluster, managedClusterKubeVersion)
 	if err != nil {
 		return reconcile.Result{Requeue: true, RequeueAfter: 30 * time.Second}, err
 	}
+	resources := crds["v1beta1"]
 	if isV1 {
-		bb, err = templateprocessor.ToYAMLsUnstructured(crds["v1"])
-	} else {
-		bb, err = templateprocessor.ToYAMLsUnstructured(crds["v1beta1"])
-	}
-	if err != nil {
-		return reconcile.Result{}, err
-	}
-	//create applier for crds
-	a, err := applier.NewApplier(
-		templateprocessor.NewYamlStringReader(templateprocessor.ConvertArrayOfBytesToString(bb),
-			templateprocessor.KubernetesYamlsDelimiter),
-		nil,
-		managedClusterClient,
-		nil,
-		nil,
-		nil)
-	if err != nil {
-		return reconcile.Result{}, err
+		resources = crds["v1"]
 	}
+	resources = append(append([]*unstructured.Unstructured{}, resources...), yamls...)
 
-	//Create the crds resources
-	err = a.CreateOrUpdateInPath(".", nil, false, nil)
-	if err != nil {
+	//Update the resources of an existing klusterlet in place, apply the crds and the yamls resources in the apply
+	//order otherwise
+	var klusterlet *unstructured.Unstructured
+	if r.klusterletReuse == klusterletReuseUpdate {
+		if klusterlet, err = getExistingKlusterlet(managedClusterClient); err != nil {
+			return reconcile.Result{Requeue: true, RequeueAfter: 30 * time.Second}, err
+		}
+	}
+	if klusterlet != nil {
+		klog.Infof("Update the existing klusterlet of cluster %s", managedCluster.Name)
+		if err := updateImportResources(managedClusterClient, r.applyOrder, resources); err != nil {
+			return reconcile.Result{Requeue: true, RequeueAfter: 30 * time.Second}, err
+		}
+	} else if err := applyImportResources(managedClusterClient, r.applyOrder, resources); err != nil {
 		return reconcile.Result{Requeue: true, RequeueAfter: 30 * time.Second}, err
 	}
 
-	//Convert yamls to yaml
-	bb, err = templateprocessor.ToYAMLsUnstructured(yamls)
+	//Wait for the readiness gates, the import is applied again until they pass
+	failed, err := checkReadinessGates(managedClusterClient, r.readinessGates)
 	if err != nil {
-		return reconcile.Result{}, err
+		return reconcile.Result{Requeue: true, RequeueAfter: readinessGatesRequeuePeriod}, err
 	}
-	//Create applier for yamls
-	a, err = applier.NewApplier(
-		templateprocessor.NewYamlStringReader(templateprocessor.ConvertArrayOfBytesToString(bb),
-			templateprocessor.KubernetesYamlsDelimiter),
-		nil,
-		managedClusterClient,
-		nil,
-		nil,
-		nil)
-	if err != nil {
-		return reconcile.Result{}, err
-	}
-
-	//Create the yamls resources
-	err = a.CreateOrUpdateInPath(".", excluded, false, nil)
-	if err != nil {
-		return reconcile.Result{Requeue: true, RequeueAfter: 30 * time.Second}, err
+	if len(failed) != 0 {
+		klog.Infof("Readiness gates of cluster %s not passed: %v", managedCluster.Name, failed)
+		if err := r.setConditionReadinessGatesNotPassed(managedCluster, failed); err != nil {
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{Requeue: true, RequeueAfter: readinessGatesRequeuePeriod}, nil
 	}
 
 	//Succeeded do not retry, then remove the autoImportRetryLabel
@@ -358,6 +366,9 @@ func (r *ReconcileManagedCluster) managedClusterDeletion(instance *clusterv1.Man
 	if err := r.client.Update(context.TODO(), instance); err != nil {
 		return reconcile.Result{}, err
 	}
+	//The cluster is detached by the controller, its tombstone is not needed. Until then the finalizers can still be
+	//removed externally, the tombstone then cleans up the force deleted cluster.
+	r.tombstones.forget(instance.Name)
 
 	return reconcile.Result{Requeue: true, RequeueAfter: 5 * time.Second}, nil
 }
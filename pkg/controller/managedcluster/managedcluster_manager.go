@@ -7,14 +7,19 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 
 	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
 	workv1 "github.com/open-cluster-management/api/work/v1"
+	libgoclient "github.com/open-cluster-management/library-go/pkg/client"
+	"github.com/open-cluster-management/managedcluster-import-controller/pkg/controller/backpressure"
+	"github.com/open-cluster-management/managedcluster-import-controller/pkg/controller/hubkubeconfig"
 	hivev1 "github.com/openshift/hive/apis/hive/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/client-go/kubernetes"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -31,13 +36,102 @@ var v1APIExtensionMinVersion = version.MustParseGeneric(_v1APIExtensionKubeMinVe
 // Add creates a new ManagedCluster Controller and adds it to the Manager. The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
 func Add(mgr manager.Manager) error {
-	return add(mgr, newReconciler(mgr))
+	o := &reconcilerOptions{}
+	if err := o.complete(mgr); err != nil {
+		return err
+	}
+	return add(mgr, newReconciler(mgr, o))
+}
+
+// reconcilerOptions are the options of the managedcluster controller, read from the environment variables of the
+// controller
+type reconcilerOptions struct {
+	maxConcurrentRemoteApplies int
+	clockSkewThreshold         time.Duration
+	importSecretLocation       *importSecretLocation
+	readinessGates             []readinessGate
+	bootstrapTokens            *bootstrapTokenRequester
+	manifestsWebhook           *manifestsWebhook
+	tombstoneTTL               time.Duration
+	propagatedLabels           []string
+	circuitFailures            int
+	circuitCooldown            time.Duration
+	credentialsWaitTimeout     time.Duration
+	applyOrder                 []string
+	klusterletReuse            string
+}
+
+// complete reads the options from the environment variables of the controller, an error if one of them is invalid
+func (o *reconcilerOptions) complete(mgr manager.Manager) error {
+	var err error
+	if o.maxConcurrentRemoteApplies, err = getMaxConcurrentRemoteApplies(); err != nil {
+		return err
+	}
+	if o.clockSkewThreshold, err = getClockSkewThreshold(); err != nil {
+		return err
+	}
+	if o.importSecretLocation, err = getImportSecretLocation(); err != nil {
+		return err
+	}
+	if o.readinessGates, err = getReadinessGates(); err != nil {
+		return err
+	}
+	minTokenTTL, maxTokenTTL, err := getBootstrapTokenTTLBounds()
+	if err != nil {
+		return err
+	}
+	var kubeClient kubernetes.Interface
+	if hubkubeconfig.Enabled() {
+		// the manager watches the hub of the hub kubeconfig secret
+		kubeClient, err = kubernetes.NewForConfig(mgr.GetConfig())
+	} else {
+		kubeClient, err = libgoclient.NewDefaultKubeClient("")
+	}
+	if err != nil {
+		kubeClient = nil
+	}
+	o.bootstrapTokens = newBootstrapTokenRequester(kubeClient, minTokenTTL, maxTokenTTL)
+	if o.manifestsWebhook, err = getManifestsWebhook(); err != nil {
+		return err
+	}
+	if o.tombstoneTTL, err = getClusterTombstoneTTL(); err != nil {
+		return err
+	}
+	if o.propagatedLabels, err = getPropagatedLabels(); err != nil {
+		return err
+	}
+	if o.circuitFailures, o.circuitCooldown, err = getRemoteApplyCircuit(); err != nil {
+		return err
+	}
+	if o.credentialsWaitTimeout, err = getCredentialsWaitTimeout(); err != nil {
+		return err
+	}
+	if o.applyOrder, err = getApplyOrder(); err != nil {
+		return err
+	}
+	o.klusterletReuse, err = getKlusterletReuse()
+	return err
 }
 
 // newReconciler returns a new reconcile.Reconciler
-func newReconciler(mgr manager.Manager) reconcile.Reconciler {
+func newReconciler(mgr manager.Manager, o *reconcilerOptions) reconcile.Reconciler {
 	client := newCustomClient(mgr.GetClient(), mgr.GetAPIReader())
-	return &ReconcileManagedCluster{client: client, scheme: mgr.GetScheme()}
+	return &ReconcileManagedCluster{
+		client:                 client,
+		scheme:                 mgr.GetScheme(),
+		remoteApplies:          newRemoteApplyLimiter(o.maxConcurrentRemoteApplies),
+		clockSkewThreshold:     o.clockSkewThreshold,
+		importSecretLocation:   o.importSecretLocation,
+		readinessGates:         o.readinessGates,
+		bootstrapTokens:        o.bootstrapTokens,
+		manifestsWebhook:       o.manifestsWebhook,
+		tombstones:             newClusterTombstones(o.tombstoneTTL),
+		propagatedLabels:       o.propagatedLabels,
+		remoteApplyCircuits:    newRemoteApplyCircuits(o.circuitFailures, o.circuitCooldown),
+		credentialsWaitTimeout: o.credentialsWaitTimeout,
+		applyOrder:             o.applyOrder,
+		klusterletReuse:        o.klusterletReuse,
+	}
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
@@ -57,6 +151,7 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		mgr,
 		controller.Options{Reconciler: r,
 			MaxConcurrentReconciles: maxConcurrentReconciles,
+			RateLimiter:             backpressure.NewRateLimiter(),
 		})
 	if err != nil {
 		return err
@@ -141,5 +236,10 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		log.Error(err, "Fail to add Watch for ManifestWork to controller")
 		return err
 	}
+
+	if err := addJoinedClustersMetrics(mgr); err != nil {
+		log.Error(err, "Fail to add the joined clusters metrics")
+		return err
+	}
 	return nil
 }
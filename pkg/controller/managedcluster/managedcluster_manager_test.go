@@ -0,0 +1,73 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func Test_reconcilerOptions_complete(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr bool
+		verify  func(t *testing.T, o *reconcilerOptions)
+	}{
+		{
+			name: "defaults",
+			verify: func(t *testing.T, o *reconcilerOptions) {
+				if o.tombstoneTTL != 0 || o.circuitFailures != 0 {
+					t.Errorf("complete() tombstone TTL = %v and circuit failures = %d, want none",
+						o.tombstoneTTL, o.circuitFailures)
+				}
+				if o.klusterletReuse != klusterletReuseNone {
+					t.Errorf("complete() klusterlet reuse = %q, want %q", o.klusterletReuse, klusterletReuseNone)
+				}
+			},
+		},
+		{
+			name: "environment",
+			env: map[string]string{
+				clusterTombstoneTTLEnvVarName:        "1h",
+				remoteApplyCircuitFailuresEnvVarName: "3",
+				remoteApplyCircuitCooldownEnvVarName: "1m",
+				klusterletReuseEnvVarName:            klusterletReuseUpdate,
+			},
+			verify: func(t *testing.T, o *reconcilerOptions) {
+				if o.tombstoneTTL != time.Hour {
+					t.Errorf("complete() tombstone TTL = %v, want 1h", o.tombstoneTTL)
+				}
+				if o.circuitFailures != 3 || o.circuitCooldown != time.Minute {
+					t.Errorf("complete() circuit = %d failures and %v, want 3 failures and 1m",
+						o.circuitFailures, o.circuitCooldown)
+				}
+				if o.klusterletReuse != klusterletReuseUpdate {
+					t.Errorf("complete() klusterlet reuse = %q, want %q", o.klusterletReuse, klusterletReuseUpdate)
+				}
+			},
+		},
+		{
+			name:    "invalid environment variable",
+			env:     map[string]string{klusterletReuseEnvVarName: "replace"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				os.Setenv(name, value)
+				defer os.Unsetenv(name)
+			}
+			o := &reconcilerOptions{}
+			err := o.complete(nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("complete() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				tt.verify(t, o)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// manifestsWebhookURLEnvVarName is the https URL of a webhook mutating the klusterlet manifests before they are
+	// written in the import secret and applied, the manifests are not mutated if not set
+	manifestsWebhookURLEnvVarName = "IMPORT_MANIFESTS_WEBHOOK_URL"
+	// manifestsWebhookCAFileEnvVarName is the file of the PEM encoded CA bundle verifying the webhook certificate,
+	// the system roots are used if not set
+	manifestsWebhookCAFileEnvVarName = "IMPORT_MANIFESTS_WEBHOOK_CA_FILE"
+
+	manifestsWebhookTimeout = 10 * time.Second
+	// manifestsWebhookIdleConnTimeout closes the idle connections to the webhook between the imports
+	manifestsWebhookIdleConnTimeout = 90 * time.Second
+	// maxManifestsReviewSize bounds the size of the webhook response
+	maxManifestsReviewSize = 10 * 1024 * 1024
+)
+
+// manifestsReview is the request and the response of the manifests webhook, the webhook responds with the
+// mutated manifests of the cluster
+type manifestsReview struct {
+	ClusterName string                   `json:"clusterName"`
+	Manifests   []map[string]interface{} `json:"manifests"`
+}
+
+// manifestsWebhook is the webhook mutating the klusterlet manifests, its client is shared by the imports of all the
+// clusters. A nil manifestsWebhook does not mutate the manifests.
+type manifestsWebhook struct {
+	url    string
+	client *http.Client
+}
+
+// getManifestsWebhook returns the webhook of the IMPORT_MANIFESTS_WEBHOOK_URL, nil if not set
+func getManifestsWebhook() (*manifestsWebhook, error) {
+	webhookURL := os.Getenv(manifestsWebhookURLEnvVarName)
+	if webhookURL == "" {
+		return nil, nil
+	}
+	if u, err := url.Parse(webhookURL); err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid %s value %q, must be an https URL", manifestsWebhookURLEnvVarName, webhookURL)
+	}
+	httpClient, err := newManifestsWebhookClient()
+	if err != nil {
+		return nil, err
+	}
+	return &manifestsWebhook{
+		url:    webhookURL,
+		client: httpClient,
+	}, nil
+}
+
+// newManifestsWebhookClient returns the http client of the manifests webhook
+func newManifestsWebhookClient() (*http.Client, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile := os.Getenv(manifestsWebhookCAFileEnvVarName); caFile != "" {
+		ca, err := ioutil.ReadFile(caFile) // #nosec G304
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in the %s file %s", manifestsWebhookCAFileEnvVarName, caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: tlsConfig,
+			IdleConnTimeout: manifestsWebhookIdleConnTimeout,
+		},
+		Timeout: manifestsWebhookTimeout,
+	}, nil
+}
+
+// validateManifests checks each mutated manifest is a kubernetes object with a group version, a kind and a name
+func validateManifests(manifests []map[string]interface{}) ([]*unstructured.Unstructured, error) {
+	if len(manifests) == 0 {
+		return nil, fmt.Errorf("no manifest returned")
+	}
+	yamls := make([]*unstructured.Unstructured, 0, len(manifests))
+	for i, manifest := range manifests {
+		u := &unstructured.Unstructured{Object: manifest}
+		if _, err := schema.ParseGroupVersion(u.GetAPIVersion()); err != nil || u.GetAPIVersion() == "" {
+			return nil, fmt.Errorf("manifest %d: invalid apiVersion %q", i, u.GetAPIVersion())
+		}
+		if u.GetKind() == "" || u.GetName() == "" {
+			return nil, fmt.Errorf("manifest %d: kind and metadata.name are required", i)
+		}
+		yamls = append(yamls, u)
+	}
+	return yamls, nil
+}
+
+// mutate sends the klusterlet manifests of the ManagedCluster to the manifests webhook and returns the
+// mutated manifests. The webhook fails closed, the manifests are not generated if the webhook fails or responds
+// with invalid manifests.
+func (w *manifestsWebhook) mutate(
+	managedCluster *clusterv1.ManagedCluster,
+	yamls []*unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	if w == nil {
+		return yamls, nil
+	}
+
+	review := manifestsReview{
+		ClusterName: managedCluster.Name,
+		Manifests:   make([]map[string]interface{}, 0, len(yamls)),
+	}
+	for _, y := range yamls {
+		review.Manifests = append(review.Manifests, y.Object)
+	}
+	body, err := json.Marshal(review)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("manifests webhook failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("manifests webhook failed: %s", resp.Status)
+	}
+	response := manifestsReview{}
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, resp.Body, maxManifestsReviewSize))
+	if err := decoder.Decode(&response); err != nil {
+		return nil, fmt.Errorf("invalid manifests webhook response: %v", err)
+	}
+	mutated, err := validateManifests(response.Manifests)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifests webhook response: %v", err)
+	}
+	log.Info("Import manifests mutated by the webhook", "cluster", managedCluster.Name)
+	return mutated, nil
+}
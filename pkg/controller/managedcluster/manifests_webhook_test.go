@@ -0,0 +1,195 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// injectSidecar is a fake mutation webhook adding a sidecar container to the deployments
+func injectSidecar(w http.ResponseWriter, req *http.Request) {
+	review := manifestsReview{}
+	if err := json.NewDecoder(req.Body).Decode(&review); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, manifest := range review.Manifests {
+		u := &unstructured.Unstructured{Object: manifest}
+		if u.GetKind() != "Deployment" {
+			continue
+		}
+		containers, _, _ := unstructured.NestedSlice(u.Object, "spec", "template", "spec", "containers")
+		containers = append(containers, map[string]interface{}{"name": "sidecar", "image": "sidecar:latest"})
+		_ = unstructured.SetNestedSlice(u.Object, containers, "spec", "template", "spec", "containers")
+	}
+	_ = json.NewEncoder(w).Encode(review)
+}
+
+func Test_manifestsWebhook_mutate(t *testing.T) {
+	managedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster1",
+		},
+	}
+	respond := func(status int, body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(body))
+		}
+	}
+
+	tests := []struct {
+		name           string
+		handler        http.HandlerFunc
+		url            string
+		noCA           bool
+		wantContainers int
+		wantErr        bool
+	}{
+		{
+			name:           "no webhook",
+			wantContainers: 0,
+		},
+		{
+			name:           "sidecar injected",
+			handler:        injectSidecar,
+			wantContainers: 1,
+		},
+		{
+			name:    "webhook error",
+			handler: respond(http.StatusInternalServerError, "internal error"),
+			wantErr: true,
+		},
+		{
+			name:    "invalid response",
+			handler: respond(http.StatusOK, `{"manifests":`),
+			wantErr: true,
+		},
+		{
+			name:    "no manifest returned",
+			handler: respond(http.StatusOK, `{"manifests":[]}`),
+			wantErr: true,
+		},
+		{
+			name:    "manifest without kind",
+			handler: respond(http.StatusOK, `{"manifests":[{"apiVersion":"v1","metadata":{"name":"a"}}]}`),
+			wantErr: true,
+		},
+		{
+			name:    "webhook certificate not trusted",
+			handler: injectSidecar,
+			noCA:    true,
+			wantErr: true,
+		},
+		{
+			name:    "not an https URL",
+			url:     "http://127.0.0.1:8443/mutate",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer os.Unsetenv(manifestsWebhookURLEnvVarName)
+			defer os.Unsetenv(manifestsWebhookCAFileEnvVarName)
+			os.Setenv(manifestsWebhookURLEnvVarName, tt.url)
+			if tt.handler != nil {
+				server := httptest.NewTLSServer(tt.handler)
+				defer server.Close()
+				os.Setenv(manifestsWebhookURLEnvVarName, server.URL)
+				if !tt.noCA {
+					dir, err := ioutil.TempDir("", "manifests-webhook")
+					if err != nil {
+						t.Fatal(err)
+					}
+					defer os.RemoveAll(dir)
+					caFile := filepath.Join(dir, "ca.crt")
+					ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+					if err := ioutil.WriteFile(caFile, ca, 0600); err != nil {
+						t.Fatal(err)
+					}
+					os.Setenv(manifestsWebhookCAFileEnvVarName, caFile)
+				}
+			}
+
+			webhook, err := getManifestsWebhook()
+			if err != nil {
+				if !tt.wantErr {
+					t.Fatalf("getManifestsWebhook() error = %v", err)
+				}
+				return
+			}
+			yamls, err := webhook.mutate(managedCluster, newKlusterletYAMLs(""))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("mutate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			deployment := getKlusterletDeployment(yamls)
+			if deployment == nil {
+				t.Fatal("klusterlet deployment not found")
+			}
+			containers, _, _ := unstructured.NestedSlice(deployment.Object, "spec", "template", "spec", "containers")
+			if len(containers) != tt.wantContainers {
+				t.Errorf("klusterlet containers = %d, want %d", len(containers), tt.wantContainers)
+			}
+		})
+	}
+}
+
+func Test_manifestsWebhookConnectionReuse(t *testing.T) {
+	managedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster1",
+		},
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(injectSidecar))
+	connections := 0
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections++
+		}
+	}
+	server.StartTLS()
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "manifests-webhook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.crt")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, ca, 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(manifestsWebhookURLEnvVarName)
+	defer os.Unsetenv(manifestsWebhookCAFileEnvVarName)
+	os.Setenv(manifestsWebhookURLEnvVarName, server.URL)
+	os.Setenv(manifestsWebhookCAFileEnvVarName, caFile)
+
+	webhook, err := getManifestsWebhook()
+	if err != nil {
+		t.Fatalf("getManifestsWebhook() error = %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := webhook.mutate(managedCluster, newKlusterletYAMLs("")); err != nil {
+			t.Fatalf("mutate() error = %v", err)
+		}
+	}
+	// the imports share the client of the webhook and its keep-alive connection
+	if connections != 1 {
+		t.Errorf("connections to the webhook = %d, want 1", connections)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"context"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// importModeAnnotation sets how the import manifests are delivered to the managed cluster.
+// In pull mode the hub does not connect to the managed cluster, the managed cluster pulls
+// the import secret from the hub, it is used for managed clusters not reachable from the hub.
+const importModeAnnotation = "import.open-cluster-management.io/import-mode"
+
+const importModePull = "pull"
+
+// reasons of the ManagedClusterImportSucceeded condition in pull mode
+const (
+	reasonAwaitingSpokePull = "AwaitingSpokePull"
+	reasonSpokeJoined       = "ManagedClusterJoined"
+)
+
+func isPullMode(managedCluster *clusterv1.ManagedCluster) bool {
+	return managedCluster.GetAnnotations()[importModeAnnotation] == importModePull
+}
+
+// pullModeImportCondition returns the import condition of a managed cluster in pull mode,
+// the import is awaiting the managed cluster until it reports in and becomes available.
+func pullModeImportCondition(managedCluster *clusterv1.ManagedCluster) metav1.Condition {
+	if checkOffLine(managedCluster) {
+		return metav1.Condition{
+			Type:    ManagedClusterImportSucceeded,
+			Status:  metav1.ConditionFalse,
+			Message: "Waiting for the managed cluster to pull its import secret",
+			Reason:  reasonAwaitingSpokePull,
+		}
+	}
+	return metav1.Condition{
+		Type:    ManagedClusterImportSucceeded,
+		Status:  metav1.ConditionTrue,
+		Message: "The managed cluster pulled its import secret and joined the hub",
+		Reason:  reasonSpokeJoined,
+	}
+}
+
+// setConditionPullModeImport updates the import condition of a managed cluster in pull mode
+func (r *ReconcileManagedCluster) setConditionPullModeImport(managedCluster *clusterv1.ManagedCluster) error {
+	newCondition := pullModeImportCondition(managedCluster)
+	if condition := meta.FindStatusCondition(managedCluster.Status.Conditions, newCondition.Type); condition != nil &&
+		condition.Status == newCondition.Status && condition.Reason == newCondition.Reason {
+		return nil
+	}
+
+	patch := client.MergeFrom(managedCluster.DeepCopy())
+	meta.SetStatusCondition(&managedCluster.Status.Conditions, newCondition)
+	return r.client.Status().Patch(context.TODO(), managedCluster, patch)
+}
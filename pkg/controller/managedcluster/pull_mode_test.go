@@ -0,0 +1,134 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"context"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_isPullMode(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        bool
+	}{
+		{
+			name: "no annotation",
+			want: false,
+		},
+		{
+			name:        "pull mode",
+			annotations: map[string]string{importModeAnnotation: importModePull},
+			want:        true,
+		},
+		{
+			name:        "unknown mode",
+			annotations: map[string]string{importModeAnnotation: "push"},
+			want:        false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			managedCluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "cluster-pull",
+					Annotations: tt.annotations,
+				},
+			}
+			if got := isPullMode(managedCluster); got != tt.want {
+				t.Errorf("isPullMode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileManagedCluster_setConditionPullModeImport(t *testing.T) {
+	s := scheme.Scheme
+	s.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	newManagedCluster := func(conditions ...metav1.Condition) *clusterv1.ManagedCluster {
+		return &clusterv1.ManagedCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "cluster-pull",
+				Annotations: map[string]string{importModeAnnotation: importModePull},
+			},
+			Status: clusterv1.ManagedClusterStatus{
+				Conditions: conditions,
+			},
+		}
+	}
+	available := func(status metav1.ConditionStatus) metav1.Condition {
+		return metav1.Condition{
+			Type:   clusterv1.ManagedClusterConditionAvailable,
+			Status: status,
+			Reason: "Test",
+		}
+	}
+	awaiting := metav1.Condition{
+		Type:   ManagedClusterImportSucceeded,
+		Status: metav1.ConditionFalse,
+		Reason: reasonAwaitingSpokePull,
+	}
+
+	tests := []struct {
+		name           string
+		managedCluster *clusterv1.ManagedCluster
+		wantStatus     metav1.ConditionStatus
+		wantReason     string
+	}{
+		{
+			name:           "new cluster awaits the spoke pull",
+			managedCluster: newManagedCluster(),
+			wantStatus:     metav1.ConditionFalse,
+			wantReason:     reasonAwaitingSpokePull,
+		},
+		{
+			name:           "offline cluster keeps awaiting the spoke pull",
+			managedCluster: newManagedCluster(available(metav1.ConditionUnknown), awaiting),
+			wantStatus:     metav1.ConditionFalse,
+			wantReason:     reasonAwaitingSpokePull,
+		},
+		{
+			name:           "spoke reported in",
+			managedCluster: newManagedCluster(available(metav1.ConditionTrue), awaiting),
+			wantStatus:     metav1.ConditionTrue,
+			wantReason:     reasonSpokeJoined,
+		},
+		{
+			name:           "spoke went offline after joining",
+			managedCluster: newManagedCluster(available(metav1.ConditionFalse), pullModeImportCondition(newManagedCluster(available(metav1.ConditionTrue)))),
+			wantStatus:     metav1.ConditionFalse,
+			wantReason:     reasonAwaitingSpokePull,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ReconcileManagedCluster{
+				client: fake.NewFakeClientWithScheme(s, tt.managedCluster),
+				scheme: s,
+			}
+			if err := r.setConditionPullModeImport(tt.managedCluster); err != nil {
+				t.Fatalf("setConditionPullModeImport() error = %v", err)
+			}
+			mc := &clusterv1.ManagedCluster{}
+			if err := r.client.Get(context.TODO(), types.NamespacedName{Name: tt.managedCluster.Name}, mc); err != nil {
+				t.Fatal(err)
+			}
+			condition := meta.FindStatusCondition(mc.Status.Conditions, ManagedClusterImportSucceeded)
+			if condition == nil {
+				t.Fatalf("condition %s not found", ManagedClusterImportSucceeded)
+			}
+			if condition.Status != tt.wantStatus || condition.Reason != tt.wantReason {
+				t.Errorf("condition = %s/%s, want %s/%s", condition.Status, condition.Reason, tt.wantStatus, tt.wantReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// readinessGatesEnvVarName is the JSON list of the resources which must exist on the managed cluster
+// before its import succeeds, the import succeeds once the import manifests are applied if not set
+const readinessGatesEnvVarName = "IMPORT_READINESS_GATES"
+
+// readinessGatesRequeuePeriod is the period the readiness gates of an import are checked at until they pass
+const readinessGatesRequeuePeriod = 30 * time.Second
+
+// reasonReadinessGatesNotPassed is the reason of the ManagedClusterImportSucceeded condition of the
+// imports waiting for their readiness gates
+const reasonReadinessGatesNotPassed = "ReadinessGatesNotPassed"
+
+// readinessGate is a resource which must exist on the managed cluster
+type readinessGate struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+func (g readinessGate) String() string {
+	if g.Namespace == "" {
+		return fmt.Sprintf("%s %s", g.Kind, g.Name)
+	}
+	return fmt.Sprintf("%s %s/%s", g.Kind, g.Namespace, g.Name)
+}
+
+// getReadinessGates returns the IMPORT_READINESS_GATES value, nil if not set
+func getReadinessGates() ([]readinessGate, error) {
+	v := os.Getenv(readinessGatesEnvVarName)
+	if v == "" {
+		return nil, nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(v))
+	decoder.DisallowUnknownFields()
+	gates := []readinessGate{}
+	if err := decoder.Decode(&gates); err != nil {
+		return nil, fmt.Errorf("invalid %s value: %v", readinessGatesEnvVarName, err)
+	}
+	for i, gate := range gates {
+		if gate.APIVersion == "" || gate.Kind == "" || gate.Name == "" {
+			return nil, fmt.Errorf("invalid %s value: gate %d requires apiVersion, kind and name", readinessGatesEnvVarName, i)
+		}
+	}
+	return gates, nil
+}
+
+// checkReadinessGates returns the readiness gates not passed on the managed cluster
+func checkReadinessGates(managedClusterClient client.Client, gates []readinessGate) ([]readinessGate, error) {
+	failed := []readinessGate{}
+	for _, gate := range gates {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion(gate.APIVersion)
+		u.SetKind(gate.Kind)
+		err := managedClusterClient.Get(context.TODO(), types.NamespacedName{Namespace: gate.Namespace, Name: gate.Name}, u)
+		switch {
+		case err == nil:
+		case errors.IsNotFound(err) || meta.IsNoMatchError(err):
+			failed = append(failed, gate)
+		default:
+			return nil, err
+		}
+	}
+	return failed, nil
+}
+
+// readinessGatesPending returns true if the import of the managed cluster is waiting for its readiness gates
+func readinessGatesPending(managedCluster *clusterv1.ManagedCluster) bool {
+	condition := meta.FindStatusCondition(managedCluster.Status.Conditions, ManagedClusterImportSucceeded)
+	return condition != nil && condition.Reason == reasonReadinessGatesNotPassed
+}
+
+// setConditionReadinessGatesNotPassed sets the import condition of a managed cluster waiting for its readiness gates
+func (r *ReconcileManagedCluster) setConditionReadinessGatesNotPassed(
+	managedCluster *clusterv1.ManagedCluster,
+	failed []readinessGate) error {
+	names := make([]string, 0, len(failed))
+	for _, gate := range failed {
+		names = append(names, gate.String())
+	}
+	patch := client.MergeFrom(managedCluster.DeepCopy())
+	meta.SetStatusCondition(&managedCluster.Status.Conditions, metav1.Condition{
+		Type:    ManagedClusterImportSucceeded,
+		Status:  metav1.ConditionFalse,
+		Message: "Waiting for the readiness gates: " + strings.Join(names, ", "),
+		Reason:  reasonReadinessGatesNotPassed,
+	})
+	return r.client.Status().Patch(context.TODO(), managedCluster, patch)
+}
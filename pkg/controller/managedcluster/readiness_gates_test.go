@@ -0,0 +1,147 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var addonReadinessGate = readinessGate{
+	APIVersion: "apps/v1",
+	Kind:       "Deployment",
+	Namespace:  "open-cluster-management-agent-addon",
+	Name:       "klusterlet-addon-workmgr",
+}
+
+func newAddonDeployment() *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      addonReadinessGate.Name,
+			Namespace: addonReadinessGate.Namespace,
+		},
+	}
+}
+
+func Test_getReadinessGates(t *testing.T) {
+	defer os.Unsetenv(readinessGatesEnvVarName)
+	tests := []struct {
+		name    string
+		value   string
+		want    []readinessGate
+		wantErr bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name: "gates",
+			value: `[{"apiVersion":"apps/v1","kind":"Deployment","namespace":"open-cluster-management-agent-addon",` +
+				`"name":"klusterlet-addon-workmgr"}]`,
+			want: []readinessGate{addonReadinessGate},
+		},
+		{
+			name:    "missing name",
+			value:   `[{"apiVersion":"apps/v1","kind":"Deployment"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			value:   `[{"apiVersion":"apps/v1","kind":"Deployment","name":"workmgr","ready":true}]`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(readinessGatesEnvVarName, tt.value)
+			got, err := getReadinessGates()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getReadinessGates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getReadinessGates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileManagedCluster_importClusterWithClientReadinessGates(t *testing.T) {
+	tests := []struct {
+		name                 string
+		managedObjects       []runtime.Object
+		want                 reconcile.Result
+		wantAutoImportSecret bool
+		wantReason           string
+	}{
+		{
+			name:                 "gates not passed",
+			want:                 reconcile.Result{Requeue: true, RequeueAfter: readinessGatesRequeuePeriod},
+			wantAutoImportSecret: true,
+			wantReason:           reasonReadinessGatesNotPassed,
+		},
+		{
+			name:                 "gates passed",
+			managedObjects:       []runtime.Object{newAddonDeployment()},
+			want:                 reconcile.Result{},
+			wantAutoImportSecret: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			managedCluster := &clusterv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "cluster-readiness-gates",
+				},
+			}
+			autoImportSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      autoImportSecretName,
+					Namespace: managedCluster.Name,
+				},
+				Data: map[string][]byte{
+					autoImportRetryName: []byte("5"),
+				},
+			}
+			r := &ReconcileManagedCluster{
+				client:         newRenderFakeClient(t, managedCluster, autoImportSecret),
+				scheme:         scheme.Scheme,
+				readinessGates: []readinessGate{addonReadinessGate},
+			}
+			managedClusterClient := fake.NewFakeClientWithScheme(scheme.Scheme, tt.managedObjects...)
+
+			got, err := r.importClusterWithClient(managedCluster, autoImportSecret, managedClusterClient, "v1.20.0")
+			if err != nil {
+				t.Fatalf("importClusterWithClient() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("importClusterWithClient() = %v, want %v", got, tt.want)
+			}
+
+			err = r.client.Get(context.TODO(), types.NamespacedName{Name: autoImportSecretName, Namespace: managedCluster.Name}, &corev1.Secret{})
+			if exist := err == nil; exist != tt.wantAutoImportSecret {
+				t.Errorf("auto-import secret exists = %v, want %v", exist, tt.wantAutoImportSecret)
+			}
+
+			mc := &clusterv1.ManagedCluster{}
+			if err := r.client.Get(context.TODO(), types.NamespacedName{Name: managedCluster.Name}, mc); err != nil {
+				t.Fatal(err)
+			}
+			if readinessGatesPending(mc) != (tt.wantReason == reasonReadinessGatesNotPassed) {
+				t.Errorf("import condition = %v, want reason %q", meta.FindStatusCondition(mc.Status.Conditions, ManagedClusterImportSucceeded), tt.wantReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// remoteApplyCircuitFailuresEnvVarName is the number of consecutive failed imports of a managed cluster
+	// opening its circuit, the circuits are never opened if not set
+	remoteApplyCircuitFailuresEnvVarName = "REMOTE_APPLY_CIRCUIT_FAILURES"
+	// remoteApplyCircuitCooldownEnvVarName is the duration during which the imports of a managed cluster are
+	// skipped once its circuit is open
+	remoteApplyCircuitCooldownEnvVarName = "REMOTE_APPLY_CIRCUIT_COOLDOWN"
+)
+
+const defaultRemoteApplyCircuitCooldown = 5 * time.Minute
+
+// CircuitOpen is a condition of the ManagedCluster set when its import failed too many times in a row,
+// the import is skipped until the end of the cooldown and then attempted once
+const CircuitOpen = "CircuitOpen"
+
+const (
+	reasonRemoteApplyFailing   = "RemoteApplyFailing"
+	reasonRemoteApplySucceeded = "RemoteApplySucceeded"
+)
+
+// remoteApplyCircuits are the circuit breakers of the imports applied on the managed clusters. The circuit of
+// a cluster opens after threshold consecutive failed imports, the imports are then skipped during the cooldown.
+// After the cooldown the circuit is half open, a single import is attempted: its success closes the circuit,
+// its failure opens it again for a cooldown. The other imports of the cluster are skipped while it is in flight.
+// A nil remoteApplyCircuits never skips an import.
+type remoteApplyCircuits struct {
+	threshold int
+	cooldown  time.Duration
+	lock      sync.Mutex
+	circuits  map[string]*remoteApplyCircuit
+}
+
+// remoteApplyCircuit is the count of consecutive failed imports of a cluster and the time its circuit opened,
+// zero while the circuit is closed. probing is true while the import attempted by the half open circuit is in flight.
+type remoteApplyCircuit struct {
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// newRemoteApplyCircuits returns circuits opening after threshold failures, nil if threshold is not positive
+func newRemoteApplyCircuits(threshold int, cooldown time.Duration) *remoteApplyCircuits {
+	if threshold <= 0 {
+		return nil
+	}
+	return &remoteApplyCircuits{
+		threshold: threshold,
+		cooldown:  cooldown,
+		circuits:  map[string]*remoteApplyCircuit{},
+	}
+}
+
+// getRemoteApplyCircuit returns the REMOTE_APPLY_CIRCUIT_FAILURES value, 0 if not set, and the
+// REMOTE_APPLY_CIRCUIT_COOLDOWN value, defaultRemoteApplyCircuitCooldown if not set
+func getRemoteApplyCircuit() (int, time.Duration, error) {
+	v := os.Getenv(remoteApplyCircuitFailuresEnvVarName)
+	if v == "" {
+		return 0, 0, nil
+	}
+	threshold, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid %s value %q: %v", remoteApplyCircuitFailuresEnvVarName, v, err)
+	}
+	cooldown := defaultRemoteApplyCircuitCooldown
+	if v := os.Getenv(remoteApplyCircuitCooldownEnvVarName); v != "" {
+		cooldown, err = time.ParseDuration(v)
+		if err != nil || cooldown <= 0 {
+			return 0, 0, fmt.Errorf("invalid %s value %q, must be a positive duration",
+				remoteApplyCircuitCooldownEnvVarName, v)
+		}
+	}
+	return threshold, cooldown, nil
+}
+
+// allow returns true if the import of the cluster can be attempted, otherwise the remaining cooldown of its circuit.
+// Once the cooldown ends only the first caller is allowed, until the outcome of its import is recorded or the
+// import is abandoned.
+func (c *remoteApplyCircuits) allow(clusterName string, now time.Time) (time.Duration, bool) {
+	if c == nil {
+		return 0, true
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	circuit, ok := c.circuits[clusterName]
+	if !ok || circuit.openedAt.IsZero() {
+		return 0, true
+	}
+	if remaining := circuit.openedAt.Add(c.cooldown).Sub(now); remaining > 0 {
+		return remaining, false
+	}
+	if circuit.probing {
+		return remoteApplyBusyRequeuePeriod, false
+	}
+	circuit.probing = true
+	return 0, true
+}
+
+// abandon releases the half open circuit of the cluster whose allowed import was not attempted, so another import
+// of the cluster can be attempted
+func (c *remoteApplyCircuits) abandon(clusterName string) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if circuit, ok := c.circuits[clusterName]; ok {
+		circuit.probing = false
+	}
+}
+
+// failed records a failed import of the cluster, it returns true if the circuit of the cluster is open
+func (c *remoteApplyCircuits) failed(clusterName string, now time.Time) bool {
+	if c == nil {
+		return false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	circuit, ok := c.circuits[clusterName]
+	if !ok {
+		circuit = &remoteApplyCircuit{}
+		c.circuits[clusterName] = circuit
+	}
+	circuit.failures++
+	// a failure while half open opens the circuit again
+	if !circuit.openedAt.IsZero() || circuit.failures >= c.threshold {
+		circuit.openedAt = now
+		circuit.probing = false
+		return true
+	}
+	return false
+}
+
+// succeeded records a successful import of the cluster, its circuit is closed
+func (c *remoteApplyCircuits) succeeded(clusterName string) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.circuits, clusterName)
+}
+
+// setConditionCircuitOpen updates the CircuitOpen condition of the managed cluster, the condition is only
+// added once the circuit opens
+func (r *ReconcileManagedCluster) setConditionCircuitOpen(managedCluster *clusterv1.ManagedCluster, open bool) error {
+	newCondition := metav1.Condition{
+		Type:    CircuitOpen,
+		Status:  metav1.ConditionFalse,
+		Message: "The import of the managed cluster succeeded",
+		Reason:  reasonRemoteApplySucceeded,
+	}
+	if open {
+		newCondition.Status = metav1.ConditionTrue
+		newCondition.Message = fmt.Sprintf("The import of the managed cluster failed %d times in a row, "+
+			"the import is skipped for %s", r.remoteApplyCircuits.threshold, r.remoteApplyCircuits.cooldown)
+		newCondition.Reason = reasonRemoteApplyFailing
+	}
+	condition := meta.FindStatusCondition(managedCluster.Status.Conditions, CircuitOpen)
+	if (condition == nil && !open) ||
+		(condition != nil && condition.Status == newCondition.Status && condition.Reason == newCondition.Reason) {
+		return nil
+	}
+
+	patch := client.MergeFrom(managedCluster.DeepCopy())
+	meta.SetStatusCondition(&managedCluster.Status.Conditions, newCondition)
+	return r.client.Status().Patch(context.TODO(), managedCluster, patch)
+}
+
+// recordRemoteApply records the outcome of an import of the managed cluster in its circuit and updates its
+// CircuitOpen condition, the condition update is best effort and never fails the import
+func (r *ReconcileManagedCluster) recordRemoteApply(managedCluster *clusterv1.ManagedCluster, importErr error) {
+	if r.remoteApplyCircuits == nil {
+		return
+	}
+	open := false
+	if importErr != nil {
+		open = r.remoteApplyCircuits.failed(managedCluster.Name, time.Now())
+	} else {
+		r.remoteApplyCircuits.succeeded(managedCluster.Name)
+	}
+	if open {
+		log.Info("Circuit of the remote apply open", "cluster", managedCluster.Name,
+			"cooldown", r.remoteApplyCircuits.cooldown.String())
+	}
+	if err := r.setConditionCircuitOpen(managedCluster, open); err != nil {
+		log.Error(err, "Unable to update the circuit open condition", "cluster", managedCluster.Name)
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	hivev1 "github.com/openshift/hive/apis/hive/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func Test_getRemoteApplyCircuit(t *testing.T) {
+	tests := []struct {
+		name          string
+		failures      string
+		cooldown      string
+		wantThreshold int
+		wantCooldown  time.Duration
+		wantErr       bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:          "default cooldown",
+			failures:      "3",
+			wantThreshold: 3,
+			wantCooldown:  defaultRemoteApplyCircuitCooldown,
+		},
+		{
+			name:          "cooldown",
+			failures:      "3",
+			cooldown:      "10m",
+			wantThreshold: 3,
+			wantCooldown:  10 * time.Minute,
+		},
+		{
+			name:     "invalid failures",
+			failures: "three",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid cooldown",
+			failures: "3",
+			cooldown: "-1m",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(remoteApplyCircuitFailuresEnvVarName, tt.failures)
+			os.Setenv(remoteApplyCircuitCooldownEnvVarName, tt.cooldown)
+			defer os.Unsetenv(remoteApplyCircuitFailuresEnvVarName)
+			defer os.Unsetenv(remoteApplyCircuitCooldownEnvVarName)
+			threshold, cooldown, err := getRemoteApplyCircuit()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getRemoteApplyCircuit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if threshold != tt.wantThreshold || cooldown != tt.wantCooldown {
+				t.Errorf("getRemoteApplyCircuit() = %d, %v, want %d, %v",
+					threshold, cooldown, tt.wantThreshold, tt.wantCooldown)
+			}
+		})
+	}
+}
+
+func Test_remoteApplyCircuits(t *testing.T) {
+	circuits := newRemoteApplyCircuits(2, time.Minute)
+	start := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		name        string
+		failed      bool
+		succeeded   bool
+		now         time.Time
+		wantOpen    bool
+		wantAllowed bool
+	}{
+		{
+			name:        "first failure",
+			failed:      true,
+			now:         start,
+			wantAllowed: true,
+		},
+		{
+			name:     "threshold reached",
+			failed:   true,
+			now:      start.Add(time.Second),
+			wantOpen: true,
+		},
+		{
+			name: "skipped during the cooldown",
+			now:  start.Add(30 * time.Second),
+		},
+		{
+			name:        "half open after the cooldown",
+			now:         start.Add(time.Minute + time.Second),
+			wantAllowed: true,
+		},
+		{
+			name:     "failure while half open",
+			failed:   true,
+			now:      start.Add(time.Minute + 2*time.Second),
+			wantOpen: true,
+		},
+		{
+			name:        "half open again",
+			now:         start.Add(2*time.Minute + 2*time.Second),
+			wantAllowed: true,
+		},
+		{
+			name:        "closed on success",
+			succeeded:   true,
+			now:         start.Add(2*time.Minute + 3*time.Second),
+			wantAllowed: true,
+		},
+		{
+			name:        "failure after the recovery",
+			failed:      true,
+			now:         start.Add(2*time.Minute + 4*time.Second),
+			wantAllowed: true,
+		},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if step.failed {
+				if open := circuits.failed("cluster1", step.now); open != step.wantOpen {
+					t.Errorf("failed() = %v, want %v", open, step.wantOpen)
+				}
+			}
+			if step.succeeded {
+				circuits.succeeded("cluster1")
+			}
+			if _, allowed := circuits.allow("cluster1", step.now); allowed != step.wantAllowed {
+				t.Errorf("allow() = %v, want %v", allowed, step.wantAllowed)
+			}
+			if _, allowed := circuits.allow("cluster2", step.now); !allowed {
+				t.Error("the circuit of another cluster must stay closed")
+			}
+		})
+	}
+
+	var disabled *remoteApplyCircuits
+	disabled.failed("cluster1", start)
+	disabled.failed("cluster1", start)
+	if _, allowed := disabled.allow("cluster1", start); !allowed {
+		t.Error("a nil circuits must allow the imports")
+	}
+}
+
+func Test_remoteApplyCircuitsHalfOpenProbe(t *testing.T) {
+	circuits := newRemoteApplyCircuits(1, time.Minute)
+	start := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	circuits.failed("cluster1", start)
+	halfOpen := start.Add(time.Minute + time.Second)
+
+	if _, allowed := circuits.allow("cluster1", halfOpen); !allowed {
+		t.Fatal("allow() must admit the first import of the half open circuit")
+	}
+	if requeue, allowed := circuits.allow("cluster1", halfOpen); allowed || requeue <= 0 {
+		t.Errorf("allow() = %v, %v while the import is in flight, want a requeue", requeue, allowed)
+	}
+
+	// the allowed import is not attempted, another import probes the circuit
+	circuits.abandon("cluster1")
+	if _, allowed := circuits.allow("cluster1", halfOpen); !allowed {
+		t.Fatal("allow() must admit an import once the probe is abandoned")
+	}
+
+	// the probe fails, the circuit opens again and is half open after another cooldown
+	circuits.failed("cluster1", halfOpen)
+	if _, allowed := circuits.allow("cluster1", halfOpen.Add(time.Second)); allowed {
+		t.Error("allow() must skip the imports once the probe failed")
+	}
+	halfOpen = halfOpen.Add(time.Minute + time.Second)
+	if _, allowed := circuits.allow("cluster1", halfOpen); !allowed {
+		t.Fatal("allow() must admit the first import of the half open circuit")
+	}
+	if _, allowed := circuits.allow("cluster1", halfOpen); allowed {
+		t.Error("allow() must skip the other imports while the probe is in flight")
+	}
+
+	// the probe succeeds, the circuit is closed
+	circuits.succeeded("cluster1")
+	for i := 0; i < 2; i++ {
+		if _, allowed := circuits.allow("cluster1", halfOpen); !allowed {
+			t.Error("allow() must admit the imports once the circuit is closed")
+		}
+	}
+}
+
+func TestReconcileManagedCluster_importClusterCircuitOpen(t *testing.T) {
+	managedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster-circuit",
+		},
+	}
+	//The admin kubeconfig secret does not exist, the imports fail
+	clusterDeployment := &hivev1.ClusterDeployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "cluster-circuit",
+			Namespace: "cluster-circuit",
+		},
+		Spec: hivev1.ClusterDeploymentSpec{
+			ClusterMetadata: &hivev1.ClusterMetadata{
+				AdminKubeconfigSecretRef: corev1.LocalObjectReference{
+					Name: "missing-secret",
+				},
+			},
+		},
+	}
+	r := &ReconcileManagedCluster{
+		client:              newRenderFakeClient(t, managedCluster),
+		scheme:              scheme.Scheme,
+		remoteApplyCircuits: newRemoteApplyCircuits(2, time.Hour),
+	}
+	circuitOpen := func() *metav1.Condition {
+		current := &clusterv1.ManagedCluster{}
+		if err := r.client.Get(context.TODO(), types.NamespacedName{Name: managedCluster.Name}, current); err != nil {
+			t.Fatal(err)
+		}
+		return meta.FindStatusCondition(current.Status.Conditions, CircuitOpen)
+	}
+
+	if _, err := r.importCluster(managedCluster, clusterDeployment, nil); err == nil {
+		t.Fatal("importCluster() expected an error")
+	}
+	if condition := circuitOpen(); condition != nil {
+		t.Errorf("%s condition = %v, want none before the threshold", CircuitOpen, condition)
+	}
+
+	if _, err := r.importCluster(managedCluster, clusterDeployment, nil); err == nil {
+		t.Fatal("importCluster() expected an error")
+	}
+	if condition := circuitOpen(); condition == nil || condition.Status != metav1.ConditionTrue {
+		t.Errorf("%s condition = %v, want true", CircuitOpen, condition)
+	}
+
+	res, err := r.importCluster(managedCluster, clusterDeployment, nil)
+	if err != nil {
+		t.Errorf("importCluster() error = %v, want the import skipped", err)
+	}
+	if !res.Requeue || res.RequeueAfter <= 0 || res.RequeueAfter > time.Hour {
+		t.Errorf("importCluster() = %v, want requeue at the end of the cooldown", res)
+	}
+
+	//A successful import closes the circuit
+	r.recordRemoteApply(managedCluster, nil)
+	if condition := circuitOpen(); condition == nil || condition.Status != metav1.ConditionFalse {
+		t.Errorf("%s condition = %v, want false", CircuitOpen, condition)
+	}
+	if _, allowed := r.remoteApplyCircuits.allow(managedCluster.Name, time.Now()); !allowed {
+		t.Error("the circuit must be closed after a successful import")
+	}
+}
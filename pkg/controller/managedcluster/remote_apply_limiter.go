@@ -0,0 +1,67 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+const maxConcurrentRemoteAppliesEnvVarName = "MAX_CONCURRENT_REMOTE_APPLIES"
+
+// remoteApplyBusyRequeuePeriod is the requeue period of an import waiting for a remote apply slot
+const remoteApplyBusyRequeuePeriod = 10 * time.Second
+
+// remoteApplyLimiter limits the number of concurrent remote applies on the managed clusters,
+// each of them opens connections to a different managed cluster.
+// A nil remoteApplyLimiter does not limit the remote applies.
+type remoteApplyLimiter struct {
+	slots chan struct{}
+}
+
+// newRemoteApplyLimiter returns a limiter allowing max concurrent remote applies, nil if max is not positive
+func newRemoteApplyLimiter(max int) *remoteApplyLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &remoteApplyLimiter{
+		slots: make(chan struct{}, max),
+	}
+}
+
+// getMaxConcurrentRemoteApplies returns the MAX_CONCURRENT_REMOTE_APPLIES value, 0 if not set
+func getMaxConcurrentRemoteApplies() (int, error) {
+	v := os.Getenv(maxConcurrentRemoteAppliesEnvVarName)
+	if v == "" {
+		return 0, nil
+	}
+	max, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value %q: %v", maxConcurrentRemoteAppliesEnvVarName, v, err)
+	}
+	log.Info(fmt.Sprintf("%s=%d", maxConcurrentRemoteAppliesEnvVarName, max))
+	return max, nil
+}
+
+// tryAcquire takes a remote apply slot without waiting, it returns false if all slots are in use
+func (l *remoteApplyLimiter) tryAcquire() bool {
+	if l == nil {
+		return true
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// release gives back a slot taken by tryAcquire
+func (l *remoteApplyLimiter) release() {
+	if l == nil {
+		return
+	}
+	<-l.slots
+}
@@ -0,0 +1,155 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func Test_getMaxConcurrentRemoteApplies(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{
+			name: "not set",
+			want: 0,
+		},
+		{
+			name:  "set",
+			value: "5",
+			want:  5,
+		},
+		{
+			name:    "invalid",
+			value:   "five",
+			wantErr: true,
+		},
+	}
+	defer os.Unsetenv(maxConcurrentRemoteAppliesEnvVarName)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(maxConcurrentRemoteAppliesEnvVarName, tt.value)
+			got, err := getMaxConcurrentRemoteApplies()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getMaxConcurrentRemoteApplies() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("getMaxConcurrentRemoteApplies() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_remoteApplyLimiter(t *testing.T) {
+	tests := []struct {
+		name      string
+		max       int
+		wantLimit int
+	}{
+		{
+			name:      "unlimited",
+			max:       0,
+			wantLimit: 0,
+		},
+		{
+			name:      "limited to one",
+			max:       1,
+			wantLimit: 1,
+		},
+		{
+			name:      "limited to three",
+			max:       3,
+			wantLimit: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newRemoteApplyLimiter(tt.max)
+			workers := 20
+			var inFlight, maxInFlight, applied int32
+			var wg sync.WaitGroup
+			start := make(chan struct{})
+			for i := 0; i < workers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-start
+					//Retry as a requeued reconcile would do
+					for !l.tryAcquire() {
+						time.Sleep(time.Millisecond)
+					}
+					defer l.release()
+					n := atomic.AddInt32(&inFlight, 1)
+					for {
+						m := atomic.LoadInt32(&maxInFlight)
+						if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+							break
+						}
+					}
+					time.Sleep(5 * time.Millisecond)
+					atomic.AddInt32(&inFlight, -1)
+					atomic.AddInt32(&applied, 1)
+				}()
+			}
+			close(start)
+			wg.Wait()
+
+			if applied != int32(workers) {
+				t.Errorf("applied = %d, want %d", applied, workers)
+			}
+			if tt.wantLimit > 0 && maxInFlight > int32(tt.wantLimit) {
+				t.Errorf("max in flight remote applies = %d, want at most %d", maxInFlight, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestReconcileManagedCluster_importClusterRemoteApplyLimit(t *testing.T) {
+	managedCluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster-remote-apply-limit",
+		},
+	}
+	r := &ReconcileManagedCluster{
+		client:        newRenderFakeClient(t, managedCluster),
+		scheme:        scheme.Scheme,
+		remoteApplies: newRemoteApplyLimiter(1),
+	}
+	//Take the only slot as an in-flight import would do
+	if !r.remoteApplies.tryAcquire() {
+		t.Fatal("failed to acquire the remote apply slot")
+	}
+	res, err := r.importCluster(managedCluster, nil, nil)
+	if err != nil {
+		t.Errorf("importCluster() error = %v", err)
+	}
+	if !res.Requeue || res.RequeueAfter != remoteApplyBusyRequeuePeriod {
+		t.Errorf("importCluster() = %v, want requeue after %s", res, remoteApplyBusyRequeuePeriod)
+	}
+	r.remoteApplies.release()
+	if !r.remoteApplies.tryAcquire() {
+		t.Error("the waiting import must not hold a remote apply slot")
+	}
+}
+
+func BenchmarkRemoteApplyLimiter(b *testing.B) {
+	l := newRemoteApplyLimiter(10)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if l.tryAcquire() {
+				l.release()
+			}
+		}
+	})
+}
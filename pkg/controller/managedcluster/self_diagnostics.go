@@ -0,0 +1,86 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ControllerMisconfigured is set on the ManagedCluster when the import fails because of
+// a prerequisite the controller is responsible of rather than because of the managed cluster.
+const ControllerMisconfigured string = "ControllerMisconfigured"
+
+const (
+	reasonMissingRBAC                    = "MissingRBAC"
+	reasonMissingBootstrapServiceAccount = "MissingBootstrapServiceAccount"
+	reasonPrerequisitesSatisfied         = "PrerequisitesSatisfied"
+)
+
+// missingPrerequisiteError is returned when a prerequisite of the import managed by the controller is missing
+type missingPrerequisiteError struct {
+	reason string
+	err    error
+}
+
+func (e *missingPrerequisiteError) Error() string {
+	return e.err.Error()
+}
+
+func (e *missingPrerequisiteError) Unwrap() error {
+	return e.err
+}
+
+// diagnoseError returns the reason of the missing controller prerequisite causing the error,
+// an empty reason is returned if the error is not caused by the controller setup.
+func diagnoseError(err error) string {
+	var prerequisiteErr *missingPrerequisiteError
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &prerequisiteErr):
+		return prerequisiteErr.reason
+	case apierrors.IsForbidden(err):
+		return reasonMissingRBAC
+	}
+	return ""
+}
+
+// setConditionControllerMisconfigured sets the ControllerMisconfigured condition when errIn is
+// caused by a missing controller prerequisite and clears it when errIn is nil. errIn is returned.
+func (r *ReconcileManagedCluster) setConditionControllerMisconfigured(
+	managedCluster *clusterv1.ManagedCluster,
+	errIn error) error {
+	newCondition := metav1.Condition{
+		Type:    ControllerMisconfigured,
+		Status:  metav1.ConditionFalse,
+		Message: "All import prerequisites of the controller are satisfied",
+		Reason:  reasonPrerequisitesSatisfied,
+	}
+	if errIn != nil {
+		reason := diagnoseError(errIn)
+		if reason == "" {
+			return errIn
+		}
+		newCondition.Status = metav1.ConditionTrue
+		newCondition.Reason = reason
+		newCondition.Message = fmt.Sprintf("The import controller is misconfigured: %s", errIn.Error())
+	} else if !meta.IsStatusConditionTrue(managedCluster.Status.Conditions, ControllerMisconfigured) {
+		// nothing to clear
+		return nil
+	}
+
+	patch := client.MergeFrom(managedCluster.DeepCopy())
+	meta.SetStatusCondition(&managedCluster.Status.Conditions, newCondition)
+	if err := r.client.Status().Patch(context.TODO(), managedCluster, patch); err != nil {
+		log.Error(err, "Failed to set condition", "condition", ControllerMisconfigured)
+	}
+	return errIn
+}
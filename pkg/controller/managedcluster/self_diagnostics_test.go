@@ -0,0 +1,154 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package managedcluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_diagnoseError(t *testing.T) {
+	forbidden := apierrors.NewForbidden(schema.GroupResource{Resource: "clusterroles"}, "test", fmt.Errorf("forbidden"))
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "no error",
+			err:  nil,
+			want: "",
+		},
+		{
+			name: "forbidden",
+			err:  forbidden,
+			want: reasonMissingRBAC,
+		},
+		{
+			name: "wrapped forbidden",
+			err:  fmt.Errorf("failed to apply: %w", forbidden),
+			want: reasonMissingRBAC,
+		},
+		{
+			name: "missing bootstrap service account",
+			err:  &missingPrerequisiteError{reason: reasonMissingBootstrapServiceAccount, err: errors.New("not found")},
+			want: reasonMissingBootstrapServiceAccount,
+		},
+		{
+			name: "spoke error",
+			err:  errors.New("connection refused"),
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diagnoseError(tt.err); got != tt.want {
+				t.Errorf("diagnoseError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileManagedCluster_setConditionControllerMisconfigured(t *testing.T) {
+	s := scheme.Scheme
+	s.AddKnownTypes(clusterv1.SchemeGroupVersion, &clusterv1.ManagedCluster{})
+
+	newManagedCluster := func(conditions ...metav1.Condition) *clusterv1.ManagedCluster {
+		return &clusterv1.ManagedCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "cluster-diagnostics",
+			},
+			Status: clusterv1.ManagedClusterStatus{
+				Conditions: conditions,
+			},
+		}
+	}
+
+	tests := []struct {
+		name           string
+		managedCluster *clusterv1.ManagedCluster
+		err            func(r *ReconcileManagedCluster, mc *clusterv1.ManagedCluster) error
+		wantStatus     metav1.ConditionStatus
+		wantReason     string
+	}{
+		{
+			name:           "missing bootstrap service account",
+			managedCluster: newManagedCluster(),
+			err: func(r *ReconcileManagedCluster, mc *clusterv1.ManagedCluster) error {
+				_, err := getBootstrapSecret(r.client, mc)
+				return err
+			},
+			wantStatus: metav1.ConditionTrue,
+			wantReason: reasonMissingBootstrapServiceAccount,
+		},
+		{
+			name:           "missing RBAC",
+			managedCluster: newManagedCluster(),
+			err: func(r *ReconcileManagedCluster, mc *clusterv1.ManagedCluster) error {
+				return apierrors.NewForbidden(schema.GroupResource{Resource: "clusterroles"}, mc.Name, fmt.Errorf("forbidden"))
+			},
+			wantStatus: metav1.ConditionTrue,
+			wantReason: reasonMissingRBAC,
+		},
+		{
+			name:           "non controller error",
+			managedCluster: newManagedCluster(),
+			err: func(r *ReconcileManagedCluster, mc *clusterv1.ManagedCluster) error {
+				return errors.New("spoke unreachable")
+			},
+		},
+		{
+			name: "misconfiguration resolved",
+			managedCluster: newManagedCluster(metav1.Condition{
+				Type:   ControllerMisconfigured,
+				Status: metav1.ConditionTrue,
+				Reason: reasonMissingRBAC,
+			}),
+			err: func(r *ReconcileManagedCluster, mc *clusterv1.ManagedCluster) error {
+				return nil
+			},
+			wantStatus: metav1.ConditionFalse,
+			wantReason: reasonPrerequisitesSatisfied,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ReconcileManagedCluster{
+				client: fake.NewFakeClientWithScheme(s, tt.managedCluster),
+				scheme: s,
+			}
+			errIn := tt.err(r, tt.managedCluster)
+			if err := r.setConditionControllerMisconfigured(tt.managedCluster, errIn); err != errIn {
+				t.Errorf("setConditionControllerMisconfigured() error = %v, want %v", err, errIn)
+			}
+			mc := &clusterv1.ManagedCluster{}
+			if err := r.client.Get(context.TODO(), types.NamespacedName{Name: tt.managedCluster.Name}, mc); err != nil {
+				t.Fatal(err)
+			}
+			condition := meta.FindStatusCondition(mc.Status.Conditions, ControllerMisconfigured)
+			if tt.wantStatus == "" {
+				if condition != nil {
+					t.Errorf("unexpected condition %v", condition)
+				}
+				return
+			}
+			if condition == nil {
+				t.Fatalf("condition %s not found", ControllerMisconfigured)
+			}
+			if condition.Status != tt.wantStatus || condition.Reason != tt.wantReason {
+				t.Errorf("condition = %s/%s, want %s/%s", condition.Status, condition.Reason, tt.wantStatus, tt.wantReason)
+			}
+		})
+	}
+}